@@ -1,22 +1,26 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
-	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/spf13/viper"
 	"github.com/vikin91/bid-tracker-go/internal/testutils"
 	"github.com/vikin91/bid-tracker-go/pkg/config"
 	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/outbox"
 	"github.com/vikin91/bid-tracker-go/pkg/server"
 	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/tenancy"
 )
 
 func main() {
@@ -30,17 +34,30 @@ func main() {
 	termSignal := make(chan os.Signal, 1)
 	signal.Notify(termSignal, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL)
 
-	db := storage.NewMapBiddingSystem()
+	registry := loadTenants(viper.GetString("TENANTS_FILE"))
+	namespaces := storage.NewNamespaces(nil)
+	var stopOutboxes []func()
+	for _, tenant := range registry.Tenants() {
+		db := storage.NewMapBiddingSystem().WithRules(tenant.Rules).WithRetention(viper.GetInt("JOURNAL_RETENTION"))
+		namespaces.Add(tenant.ID, db)
+		stopOutboxes = append(stopOutboxes, startOutbox(db, outboxTarget(viper.GetString("OUTBOX"), tenant.ID)))
 
-	if *demo {
-		numItems := 50
-		amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
-		testutils.CreateTestTwoUsersBidOnManyItems(db, numItems, amountsMatrix)
-		logging.LogInfo("System populated with demo data")
+		if *demo {
+			numItems := 50
+			amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+			testutils.CreateTestTwoUsersBidOnManyItems(db, numItems, amountsMatrix)
+			logging.LogInfo(fmt.Sprintf("Tenant %s populated with demo data", tenant.ID))
+		}
 	}
+	stopOutbox := func() {
+		for _, stop := range stopOutboxes {
+			stop()
+		}
+	}
+	defer stopOutbox()
 
 	server := server.NewServer()
-	server.SetupRoutes(db)
+	server.SetupTenantRoutes(registry, namespaces)
 
 	walkFunc := func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
 		route = strings.Replace(route, "/*/", "/", -1)
@@ -52,12 +69,18 @@ func main() {
 		fmt.Printf("Logging err: %s\n", err.Error())
 	}
 
-	go server.ListenAndServe(quitServerCh, errorsCh, port)
+	served := make(chan struct{})
+	go func() {
+		server.ListenAndServe(quitServerCh, errorsCh, port)
+		close(served)
+	}()
 
 	terminateFunc := func(quitServerCh chan struct{}) {
 		quitServerCh <- struct{}{}
 		close(quitServerCh)
-		time.Sleep(time.Second)
+		// the events committed until the services of the tenants have stopped are relayed as well
+		<-served
+		stopOutbox()
 	}
 
 	select {
@@ -70,3 +93,75 @@ func main() {
 		terminateFunc(quitServerCh)
 	}
 }
+
+//loadTenants reads the tenants from path - a single tenant "default" if path is empty
+func loadTenants(path string) *tenancy.Registry {
+	tenants := []tenancy.Tenant{{ID: tenancy.DefaultTenantID}}
+	if path != "" {
+		var err error
+		if tenants, err = tenancy.Load(path); err != nil {
+			logging.LogError("Cannot load tenants", err)
+			os.Exit(1)
+		}
+	}
+	registry, err := tenancy.NewRegistry(tenants...)
+	if err != nil {
+		logging.LogError("Invalid tenants", err)
+		os.Exit(1)
+	}
+	return registry
+}
+
+//outboxTarget returns the outbox of a tenant: the events of other tenants than the default one
+//are written to files of their own, named by the tenant ID inserted before the extension
+func outboxTarget(target, tenantID string) string {
+	if target == "" || target == config.OutboxStdout || tenantID == tenancy.DefaultTenantID {
+		return target
+	}
+	ext := filepath.Ext(target)
+	return strings.TrimSuffix(target, ext) + "." + tenantID + ext
+}
+
+//startOutbox relays all events of db to target ("stdout" or a file path) and returns a function
+//publishing the remaining events, stopping the relay and closing the file. Nothing is relayed if target is empty.
+func startOutbox(db *storage.MapBiddingSystem, target string) (stop func()) {
+	if target == "" {
+		return func() {}
+	}
+	var publisher outbox.Publisher
+	closePublisher := func() error { return nil }
+	if target == config.OutboxStdout {
+		publisher = outbox.NewWriterPublisher(os.Stdout)
+	} else {
+		filePublisher, err := outbox.NewFilePublisher(target)
+		if err != nil {
+			logging.LogError("Cannot open outbox file", err)
+			os.Exit(1)
+		}
+		publisher, closePublisher = filePublisher, filePublisher.Close
+	}
+
+	box := outbox.New()
+	db.Register(box)
+	relay := outbox.NewRelay(box, publisher, outbox.RelayConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			if err := relay.Drain(context.Background()); err != nil {
+				logging.LogError(fmt.Sprintf("%d events left in the outbox", box.Len()), err)
+			}
+			if err := closePublisher(); err != nil {
+				logging.LogError("Cannot close the outbox file", err)
+			}
+		})
+	}
+}
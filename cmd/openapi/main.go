@@ -0,0 +1,67 @@
+//Command openapi generates the OpenAPI document of a version of the API from the routes of the server, the
+//annotations of their handlers and the types the annotations name - the server serves it at /api/v1/openapi.json
+//and /api/v2/openapi.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/spf13/viper"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/openapi"
+	"github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func main() {
+	config.SetupEnv()
+	version := flag.String("version", "v1", "Version of the API to document: v1 or v2")
+	output := flag.String("o", "", "File to write the document to, - for stdout (default swagger/openapi.json for v1, swagger/openapi-v2.json for v2)")
+	dir := flag.String("handlers", "pkg/handlers", "Directory of the annotated handlers")
+	flag.Parse()
+
+	prefix, key, path := config.APIPrefixV1, "OPENAPI_DOCUMENT", config.DefaultOpenAPIDocument
+	switch *version {
+	case "v1":
+	case "v2":
+		prefix, key, path = config.APIPrefixV2, "OPENAPI_DOCUMENT_V2", config.DefaultOpenAPIDocumentV2
+	default:
+		log.Fatalf("Unknown version %q - expected v1 or v2", *version)
+	}
+	if *output == "" {
+		*output = path
+	}
+
+	// the routes are the same whatever is validated, and the document being generated may be missing
+	viper.Set("OPENAPI_VALIDATION", config.OpenAPIValidationOff)
+	viper.Set(key, *output)
+	srv := server.NewServer()
+	srv.SetupRoutes(storage.NewMapBiddingSystem())
+
+	annotations, err := openapi.ParseAnnotations(*dir)
+	if err != nil {
+		log.Fatalf("Cannot read annotations: %v", err)
+	}
+	spec, err := server.GenerateOpenAPI(srv.Mux(), annotations, prefix)
+	if err != nil {
+		log.Fatalf("Cannot generate the OpenAPI document: %v", err)
+	}
+	document, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		log.Fatalf("Cannot encode the OpenAPI document: %v", err)
+	}
+	document = append(document, '\n')
+	if *output == "-" {
+		os.Stdout.Write(document)
+		return
+	}
+	if err := ioutil.WriteFile(*output, document, 0644); err != nil {
+		log.Fatalf("Cannot write the OpenAPI document: %v", err)
+	}
+	fmt.Printf("OpenAPI document of %d paths written to %s\n", len(spec.Paths), *output)
+}
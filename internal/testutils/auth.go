@@ -0,0 +1,25 @@
+package testutils
+
+import (
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//APIKey returns the API key of user accepted by NewTestAuthenticator
+func APIKey(user *models.User) string {
+	return "test-key-" + user.ID.String()
+}
+
+//NewTestAuthenticator creates an authenticator accepting the APIKey of each of users - with all roles
+func NewTestAuthenticator(users ...*models.User) *auth.Authenticator {
+	return NewTestAuthenticatorWithRoles([]auth.Role{auth.RoleAdmin, auth.RoleSeller, auth.RoleBidder}, users...)
+}
+
+//NewTestAuthenticatorWithRoles creates an authenticator accepting the APIKey of each of users with roles
+func NewTestAuthenticatorWithRoles(roles []auth.Role, users ...*models.User) *auth.Authenticator {
+	keys := map[string]auth.Principal{}
+	for _, user := range users {
+		keys[APIKey(user)] = auth.Principal{UserID: user.ID, Roles: roles}
+	}
+	return auth.NewAuthenticator().WithAPIKeys(keys)
+}
@@ -0,0 +1,119 @@
+package testutils
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+//SMTPMessage is an email received by FakeSMTPServer
+type SMTPMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+//FakeSMTPServer is a minimal SMTP server on localhost that keeps the emails it receives - for tests of email senders
+type FakeSMTPServer struct {
+	Addr     string
+	listener net.Listener
+	received chan SMTPMessage
+	wg       sync.WaitGroup
+}
+
+//NewFakeSMTPServer starts a server listening on a random local port
+func NewFakeSMTPServer() (*FakeSMTPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeSMTPServer{Addr: listener.Addr().String(), listener: listener, received: make(chan SMTPMessage, 100)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+//Received returns the channel the received emails are delivered on
+func (s *FakeSMTPServer) Received() <-chan SMTPMessage {
+	return s.received
+}
+
+//Close stops the server
+func (s *FakeSMTPServer) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *FakeSMTPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *FakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	text.PrintfLine("220 localhost fake ESMTP")
+
+	msg := SMTPMessage{}
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO":
+			text.PrintfLine("250-localhost")
+			text.PrintfLine("250 8BITMIME")
+		case "HELO", "NOOP":
+			text.PrintfLine("250 OK")
+		case "RSET":
+			msg = SMTPMessage{}
+			text.PrintfLine("250 OK")
+		case "MAIL":
+			msg.From = address(line)
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(line))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := reader.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.received <- msg
+			msg = SMTPMessage{}
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+//address extracts the address from "MAIL FROM:<address>" or "RCPT TO:<address>"
+func address(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
@@ -0,0 +1,401 @@
+package accounts
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+// define defaults
+const (
+	DefaultSessionTTL    = 24 * time.Hour
+	DefaultResetTokenTTL = time.Hour
+	tokenSize            = 32
+	pruneInterval        = time.Minute
+)
+
+// define errors
+var (
+	ErrInvalidEmail = models.NewError(models.KindInvalid, "invalid_email", "Invalid email address",
+		models.FieldError{Field: "email", Message: "must be an email address"})
+	ErrAccountExists = models.NewError(models.KindConflict, "account_exists", "An account with this email address exists already",
+		models.FieldError{Field: "email", Message: "must not be used by another account"})
+	ErrAccountNotFound    = models.NewError(models.KindNotFound, "account_not_found", "Account not found")
+	ErrInvalidCredentials = errors.New("Invalid email address or password")
+	ErrSessionNotFound    = errors.New("Session not found or revoked")
+	ErrSessionExpired     = errors.New("Session has expired")
+	ErrInvalidResetToken  = errors.New("Invalid or expired password reset token")
+	ErrNoResetSender      = errors.New("Password reset is not available")
+)
+
+//Account holds the credentials of a user
+type Account struct {
+	UserID    uuid.UUID   `json:"userID"`
+	Email     string      `json:"email"`
+	Roles     []auth.Role `json:"roles"`
+	CreatedAt time.Time   `json:"createdAt"`
+
+	passwordHash string
+}
+
+//Session is a login of a user - it is identified by a random token, which is only kept hashed
+type Session struct {
+	UserID    uuid.UUID `json:"userID"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type resetToken struct {
+	userID    uuid.UUID
+	expiresAt time.Time
+}
+
+type digest [sha256.Size]byte
+
+//Manager keeps password accounts, their sessions and password reset tokens in memory.
+//Tokens are looked up by their SHA-256 digest, so that neither a memory dump nor the timing of a lookup reveals them.
+type Manager struct {
+	db            storage.Storage
+	sender        ResetSender
+	hasher        Hasher
+	sessionTTL    time.Duration
+	resetTokenTTL time.Duration
+	now           func() time.Time
+
+	// dummyHash is verified for unknown email addresses, so that logins take as long as for known ones
+	dummyOnce sync.Once
+	dummyHash string
+	// sending counts the reset tokens being sent in the background
+	sending sync.WaitGroup
+
+	mutex     sync.Mutex
+	accounts  map[uuid.UUID]*Account
+	byEmail   map[string]uuid.UUID
+	sessions  map[digest]*Session
+	resets    map[digest]*resetToken
+	nextPrune time.Time
+}
+
+//NewManager creates a manager of accounts of the users in db - reset tokens are delivered by sender (nil to disable resets)
+func NewManager(db storage.Storage, sender ResetSender) *Manager {
+	return &Manager{
+		db:            db,
+		sender:        sender,
+		sessionTTL:    DefaultSessionTTL,
+		resetTokenTTL: DefaultResetTokenTTL,
+		now:           time.Now,
+		accounts:      map[uuid.UUID]*Account{},
+		byEmail:       map[string]uuid.UUID{},
+		sessions:      map[digest]*Session{},
+		resets:        map[digest]*resetToken{},
+	}
+}
+
+//WithHasher replaces the password hasher - passwords hashed before can still be verified
+func (m *Manager) WithHasher(hasher Hasher) *Manager {
+	m.hasher = hasher
+	return m
+}
+
+//WithSessionTTL sets how long a session is valid after login
+func (m *Manager) WithSessionTTL(ttl time.Duration) *Manager {
+	m.sessionTTL = ttl
+	return m
+}
+
+//WithResetTokenTTL sets how long a password reset token may be used
+func (m *Manager) WithResetTokenTTL(ttl time.Duration) *Manager {
+	m.resetTokenTTL = ttl
+	return m
+}
+
+//WithClock replaces the clock - for tests
+func (m *Manager) WithClock(now func() time.Time) *Manager {
+	m.now = now
+	return m
+}
+
+//SignUp creates a user with a password account and DefaultRoles
+func (m *Manager) SignUp(name, email, password string) (Account, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return Account{}, err
+	}
+	hash, err := m.hasher.Hash(password)
+	if err != nil {
+		return Account{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.byEmail[email]; ok {
+		return Account{}, ErrAccountExists
+	}
+	user := models.NewUser(name)
+	if err := m.db.CreateUser(user); err != nil {
+		return Account{}, err
+	}
+	account := &Account{
+		UserID:       user.ID,
+		Email:        email,
+		Roles:        append([]auth.Role(nil), auth.DefaultRoles...),
+		CreatedAt:    m.now(),
+		passwordHash: hash,
+	}
+	m.accounts[user.ID] = account
+	m.byEmail[email] = user.ID
+	return account.copy(), nil
+}
+
+//Get returns the account of a user
+func (m *Manager) Get(userID uuid.UUID) (Account, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	account, ok := m.accounts[userID]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	return account.copy(), nil
+}
+
+//SetRoles replaces the roles of a user - they apply to the sessions of the user immediately
+func (m *Manager) SetRoles(userID uuid.UUID, roles []auth.Role) (Account, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	account, ok := m.accounts[userID]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	account.Roles = append([]auth.Role(nil), roles...)
+	return account.copy(), nil
+}
+
+//Login checks the password of the account with email and starts a session. It returns the token of the session.
+func (m *Manager) Login(email, password string) (string, Session, error) {
+	email, _ = normalizeEmail(email)
+	m.mutex.Lock()
+	var hash string
+	userID, ok := m.byEmail[email]
+	if ok {
+		hash = m.accounts[userID].passwordHash
+	}
+	m.mutex.Unlock()
+
+	// hashing is slow on purpose - the lock is not held meanwhile
+	if !ok {
+		m.dummyOnce.Do(func() { m.dummyHash, _ = m.hasher.Hash("not a password") })
+		m.hasher.Verify(m.dummyHash, password)
+		return "", Session{}, ErrInvalidCredentials
+	}
+	valid, err := m.hasher.Verify(hash, password)
+	if err != nil || !valid {
+		return "", Session{}, ErrInvalidCredentials
+	}
+
+	token, key, err := newToken()
+	if err != nil {
+		return "", Session{}, err
+	}
+	now := m.now()
+	session := &Session{UserID: userID, CreatedAt: now, ExpiresAt: now.Add(m.sessionTTL)}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.prune(now)
+	m.sessions[key] = session
+	return token, *session, nil
+}
+
+//Authenticate implements auth.SessionStore: it returns the principal of a valid session token
+func (m *Manager) Authenticate(token string) (*auth.Principal, error) {
+	key := sha256.Sum256([]byte(token))
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	session, ok := m.sessions[key]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !m.now().Before(session.ExpiresAt) {
+		delete(m.sessions, key)
+		return nil, ErrSessionExpired
+	}
+	account, ok := m.accounts[session.UserID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &auth.Principal{UserID: account.UserID, Roles: append([]auth.Role(nil), account.Roles...), Method: auth.MethodSession}, nil
+}
+
+//Logout revokes the session of token
+func (m *Manager) Logout(token string) error {
+	key := sha256.Sum256([]byte(token))
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.sessions[key]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(m.sessions, key)
+	return nil
+}
+
+//RevokeSessions ends all sessions of a user and returns their number
+func (m *Manager) RevokeSessions(userID uuid.UUID) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.revokeSessions(userID)
+}
+
+//revokeSessions - mutex must be held
+func (m *Manager) revokeSessions(userID uuid.UUID) int {
+	revoked := 0
+	for key, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, key)
+			revoked++
+		}
+	}
+	return revoked
+}
+
+//RequestReset sends a single-use password reset token to the account with email. Earlier tokens of the account
+//become invalid. Nothing is sent for unknown addresses, but no error is returned either - callers must not tell them apart.
+//The token is sent in the background, so that the time taken by the sender does not tell them apart either;
+//errors of the sender are logged.
+func (m *Manager) RequestReset(email string) error {
+	if m.sender == nil {
+		return ErrNoResetSender
+	}
+	email, _ = normalizeEmail(email)
+	token, key, err := newToken()
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	expiresAt := now.Add(m.resetTokenTTL)
+
+	m.mutex.Lock()
+	m.prune(now)
+	userID, ok := m.byEmail[email]
+	if ok {
+		for k, reset := range m.resets {
+			if reset.userID == userID {
+				delete(m.resets, k)
+			}
+		}
+		m.resets[key] = &resetToken{userID: userID, expiresAt: expiresAt}
+	}
+	m.mutex.Unlock()
+
+	if !ok {
+		logging.LogInfo("Password reset requested for unknown email address")
+		return nil
+	}
+	m.sending.Add(1)
+	go func() {
+		defer m.sending.Done()
+		if err := m.sender.SendReset(email, token, expiresAt); err != nil {
+			logging.LogError("Cannot send password reset", err)
+		}
+	}()
+	return nil
+}
+
+//WaitForResets waits until the reset tokens requested so far have been sent (or sending has failed)
+func (m *Manager) WaitForResets() {
+	m.sending.Wait()
+}
+
+//ResetPassword sets a new password with a token sent by RequestReset. The token is used up
+//and all sessions of the user are revoked.
+func (m *Manager) ResetPassword(token, password string) error {
+	key := sha256.Sum256([]byte(token))
+	// the token is checked before the password is hashed, so that guessing tokens does not cost a hash each
+	m.mutex.Lock()
+	_, err := m.reset(key)
+	m.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	hash, err := m.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	// the token is checked again, as it may have been used while the password was hashed
+	account, err := m.reset(key)
+	if err != nil {
+		return err
+	}
+	delete(m.resets, key)
+	account.passwordHash = hash
+	m.revokeSessions(account.UserID)
+	return nil
+}
+
+//reset returns the account of a valid reset token - mutex must be held
+func (m *Manager) reset(key digest) (*Account, error) {
+	reset, ok := m.resets[key]
+	if !ok {
+		return nil, ErrInvalidResetToken
+	}
+	account, ok := m.accounts[reset.userID]
+	if !ok || !m.now().Before(reset.expiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+	return account, nil
+}
+
+//prune removes expired sessions and reset tokens at most once per pruneInterval - mutex must be held
+func (m *Manager) prune(now time.Time) {
+	if now.Before(m.nextPrune) {
+		return
+	}
+	m.nextPrune = now.Add(pruneInterval)
+	for key, session := range m.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(m.sessions, key)
+		}
+	}
+	for key, reset := range m.resets {
+		if !now.Before(reset.expiresAt) {
+			delete(m.resets, key)
+		}
+	}
+}
+
+func (a *Account) copy() Account {
+	c := *a
+	c.Roles = append([]auth.Role(nil), a.Roles...)
+	return c
+}
+
+//newToken returns a random token and its digest
+func newToken() (string, digest, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", digest{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, sha256.Sum256([]byte(token)), nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.Index(email, "@")
+	if at < 1 || at == len(email)-1 || strings.ContainsAny(email, " \t\r\n") {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
@@ -0,0 +1,164 @@
+package accounts_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/accounts"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+// few iterations keep the tests fast
+var hasher = accounts.Hasher{Iterations: 10}
+
+type sentReset struct {
+	to, token string
+	expiresAt time.Time
+}
+
+func setup() (*accounts.Manager, *time.Time, *[]sentReset) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	sent := &[]sentReset{}
+	sender := accounts.ResetSenderFunc(func(to, token string, expiresAt time.Time) error {
+		*sent = append(*sent, sentReset{to, token, expiresAt})
+		return nil
+	})
+	manager := accounts.NewManager(storage.NewMapBiddingSystem(), sender).
+		WithHasher(hasher).
+		WithSessionTTL(time.Hour).
+		WithResetTokenTTL(10 * time.Minute).
+		WithClock(func() time.Time { return now })
+	return manager, &now, sent
+}
+
+func Test_Hasher(t *testing.T) {
+	hash, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.Regexp(t, `^pbkdf2-sha256\$10\$`, hash)
+	other, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "Hashes are salted")
+
+	ok, err := hasher.Verify(hash, "correct horse")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = hasher.Verify(hash, "battery staple")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = accounts.Hasher{Iterations: 20}.Verify(hash, "correct horse")
+	require.NoError(t, err)
+	assert.True(t, ok, "Hashes keep their iterations")
+
+	_, err = hasher.Hash("short")
+	assert.Equal(t, accounts.ErrWeakPassword, err)
+	_, err = hasher.Verify("md5$abc", "correct horse")
+	assert.Equal(t, accounts.ErrMalformedPassword, err)
+
+	// PBKDF2-HMAC-SHA256 test vector of RFC 7914
+	key, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+	vector := "pbkdf2-sha256$1$" + base64.RawStdEncoding.EncodeToString([]byte("salt")) + "$" + base64.RawStdEncoding.EncodeToString(key)
+	ok, err = hasher.Verify(vector, "passwd")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func Test_LoginAndLogout(t *testing.T) {
+	manager, now, _ := setup()
+	account, err := manager.SignUp("Alice", " Alice@Example.com ", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", account.Email)
+	assert.Equal(t, auth.DefaultRoles, account.Roles)
+	_, err = manager.SignUp("Alice again", "alice@example.com", "correct horse")
+	assert.Equal(t, accounts.ErrAccountExists, err)
+	_, err = manager.SignUp("Bob", "bob", "correct horse")
+	assert.Equal(t, accounts.ErrInvalidEmail, err)
+	_, err = manager.SignUp("Bob", "bob@example.com", "short")
+	assert.Equal(t, accounts.ErrWeakPassword, err)
+
+	_, _, err = manager.Login("alice@example.com", "wrong password")
+	assert.Equal(t, accounts.ErrInvalidCredentials, err)
+	_, _, err = manager.Login("nobody@example.com", "correct horse")
+	assert.Equal(t, accounts.ErrInvalidCredentials, err, "Unknown addresses are not told apart")
+
+	token, session, err := manager.Login("ALICE@example.com", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, account.UserID, session.UserID)
+	assert.Equal(t, now.Add(time.Hour), session.ExpiresAt)
+	principal, err := manager.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, account.UserID, principal.UserID)
+	assert.Equal(t, auth.MethodSession, principal.Method)
+
+	_, err = manager.SetRoles(account.UserID, []auth.Role{auth.RoleSeller})
+	require.NoError(t, err)
+	principal, err = manager.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, []auth.Role{auth.RoleSeller}, principal.Roles, "Role changes apply to sessions immediately")
+
+	require.NoError(t, manager.Logout(token))
+	_, err = manager.Authenticate(token)
+	assert.Equal(t, accounts.ErrSessionNotFound, err)
+	assert.Equal(t, accounts.ErrSessionNotFound, manager.Logout(token))
+
+	token, _, err = manager.Login("alice@example.com", "correct horse")
+	require.NoError(t, err)
+	*now = now.Add(time.Hour)
+	_, err = manager.Authenticate(token)
+	assert.Equal(t, accounts.ErrSessionExpired, err)
+}
+
+func Test_PasswordReset(t *testing.T) {
+	manager, now, sent := setup()
+	account, err := manager.SignUp("Alice", "alice@example.com", "correct horse")
+	require.NoError(t, err)
+	session, _, err := manager.Login("alice@example.com", "correct horse")
+	require.NoError(t, err)
+
+	require.NoError(t, manager.RequestReset("nobody@example.com"))
+	manager.WaitForResets()
+	assert.Empty(t, *sent, "Nothing is sent to unknown addresses")
+
+	require.NoError(t, manager.RequestReset("alice@example.com"))
+	manager.WaitForResets()
+	require.NoError(t, manager.RequestReset("alice@example.com"))
+	manager.WaitForResets()
+	require.Len(t, *sent, 2)
+	first, second := (*sent)[0], (*sent)[1]
+	assert.Equal(t, "alice@example.com", second.to)
+	assert.Equal(t, now.Add(10*time.Minute), second.expiresAt)
+	assert.Equal(t, accounts.ErrInvalidResetToken, manager.ResetPassword(first.token, "battery staple"), "A new request replaces earlier tokens")
+
+	assert.Equal(t, accounts.ErrWeakPassword, manager.ResetPassword(second.token, "short"))
+	assert.Equal(t, accounts.ErrInvalidResetToken, manager.ResetPassword("not a token", "short"), "Tokens are checked before passwords")
+	require.NoError(t, manager.ResetPassword(second.token, "battery staple"))
+	assert.Equal(t, accounts.ErrInvalidResetToken, manager.ResetPassword(second.token, "battery staple"), "Tokens are single-use")
+	_, err = manager.Authenticate(session)
+	assert.Equal(t, accounts.ErrSessionNotFound, err, "Resets revoke all sessions")
+	_, _, err = manager.Login("alice@example.com", "correct horse")
+	assert.Equal(t, accounts.ErrInvalidCredentials, err)
+	_, session2, err := manager.Login("alice@example.com", "battery staple")
+	require.NoError(t, err)
+	assert.Equal(t, account.UserID, session2.UserID)
+
+	require.NoError(t, manager.RequestReset("alice@example.com"))
+	manager.WaitForResets()
+	*now = now.Add(10 * time.Minute)
+	assert.Equal(t, accounts.ErrInvalidResetToken, manager.ResetPassword((*sent)[2].token, "another password"), "Tokens expire")
+
+	failing := accounts.NewManager(storage.NewMapBiddingSystem(), accounts.ResetSenderFunc(func(string, string, time.Time) error {
+		return errors.New("mail server down")
+	})).WithHasher(hasher)
+	_, err = failing.SignUp("Bob", "bob@example.com", "correct horse")
+	require.NoError(t, err)
+	assert.NoError(t, failing.RequestReset("bob@example.com"), "Errors of the sender are only logged")
+	failing.WaitForResets()
+	assert.Equal(t, accounts.ErrNoResetSender, accounts.NewManager(storage.NewMapBiddingSystem(), nil).RequestReset("bob@example.com"))
+}
@@ -0,0 +1,103 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+// define password errors
+var (
+	ErrWeakPassword = models.NewError(models.KindInvalid, "weak_password", "Password must have at least 8 characters",
+		models.FieldError{Field: "password", Message: "must have at least 8 characters"})
+	ErrMalformedPassword = errors.New("Malformed password hash")
+)
+
+const (
+	//DefaultIterations of PBKDF2 - as recommended by OWASP for PBKDF2-HMAC-SHA256
+	DefaultIterations = 600000
+	//MinPasswordLength is the number of characters a password must have at least
+	MinPasswordLength = 8
+
+	hashScheme = "pbkdf2-sha256"
+	saltSize   = 16
+	keySize    = sha256.Size
+)
+
+//Hasher hashes passwords with PBKDF2-HMAC-SHA256 and a random salt. Hashes are encoded as
+//"pbkdf2-sha256$<iterations>$<salt>$<key>", so that they can be verified after Iterations has been changed.
+type Hasher struct {
+	Iterations int
+}
+
+//Hash hashes a password that is long enough
+func (h Hasher) Hash(password string) (string, error) {
+	if len([]rune(password)) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+	iterations := h.Iterations
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, iterations, keySize)
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+//Verify checks password against a hash created by Hash
+func (h Hasher) Verify(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false, ErrMalformedPassword
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, ErrMalformedPassword
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrMalformedPassword
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrMalformedPassword
+	}
+	return subtle.ConstantTimeCompare(key, pbkdf2([]byte(password), salt, iterations, len(key))) == 1, nil
+}
+
+//pbkdf2 derives a key of keyLen bytes as defined by RFC 8018 with HMAC-SHA256 as the pseudorandom function
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen+sha256.Size)
+	u := make([]byte, sha256.Size)
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,38 @@
+package accounts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vikin91/bid-tracker-go/pkg/notifications"
+)
+
+//ResetSender delivers password reset tokens to the owners of accounts
+type ResetSender interface {
+	SendReset(to, token string, expiresAt time.Time) error
+}
+
+//ResetSenderFunc adapts a function to ResetSender
+type ResetSenderFunc func(to, token string, expiresAt time.Time) error
+
+//SendReset implements ResetSender
+func (f ResetSenderFunc) SendReset(to, token string, expiresAt time.Time) error {
+	return f(to, token, expiresAt)
+}
+
+//EmailResetSender emails reset tokens - the token is appended to URL, if set, to link a page completing the reset
+type EmailResetSender struct {
+	Email notifications.EmailSender
+	URL   string
+}
+
+//SendReset implements ResetSender
+func (s *EmailResetSender) SendReset(to, token string, expiresAt time.Time) error {
+	body := fmt.Sprintf("A password reset has been requested for your account.\n\nReset token: %s\n", token)
+	if s.URL != "" {
+		body = fmt.Sprintf("A password reset has been requested for your account.\n\nReset your password at %s%s\n", s.URL, token)
+	}
+	body += fmt.Sprintf("\nThe token can be used once until %s. Ignore this email if you have not requested the reset.\n",
+		expiresAt.UTC().Format(time.RFC1123))
+	return s.Email.SendEmail(to, "Password reset", body)
+}
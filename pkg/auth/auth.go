@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// define headers carrying credentials
+const (
+	HeaderAPIKey        = "X-API-Key"
+	HeaderAuthorization = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// define errors
+var (
+	ErrInvalidAPIKey        = errors.New("Invalid API key")
+	ErrMalformedCredentials = errors.New("Malformed Authorization header - expected a bearer token")
+	ErrNoCredentials        = errors.New("Authentication required")
+	ErrWrongTenant          = errors.New("Credentials are not valid for this tenant")
+)
+
+//Method tells how a principal has been authenticated
+type Method string
+
+// define authentication methods
+const (
+	MethodAPIKey  Method = "api-key"
+	MethodJWT     Method = "jwt"
+	MethodSession Method = "session"
+)
+
+//Principal is the authenticated caller of a request - the user the request acts for, the roles granted by the credentials
+//and the tenant they are valid for (empty if the credentials name no tenant)
+type Principal struct {
+	UserID uuid.UUID `json:"userID"`
+	Roles  []Role    `json:"roles"`
+	Method Method    `json:"method"`
+	Tenant string    `json:"tenant,omitempty"`
+}
+
+//HasRole checks whether the principal has role
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+//NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+//FromContext returns the principal carried by ctx, nil for anonymous requests
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(contextKey{}).(*Principal)
+	return principal
+}
+
+//ParseAPIKeys parses static API keys given as "key=userID[:role+role...][@tenant],..." - the principals have DefaultRoles
+//if no role is given and are valid for tenant only if one is given. Whitespace around entries is ignored.
+func ParseAPIKeys(s string) (map[string]Principal, error) {
+	keys := map[string]Principal{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Malformed API key entry %q - expected key=userID", entry)
+		}
+		principal := Principal{Roles: DefaultRoles, Method: MethodAPIKey}
+		scope := parts[1]
+		if i := strings.LastIndex(scope, "@"); i >= 0 {
+			principal.Tenant = scope[i+1:]
+			if principal.Tenant == "" {
+				return nil, fmt.Errorf("Malformed tenant of API key entry %q", entry)
+			}
+			scope = scope[:i]
+		}
+		user := strings.SplitN(scope, ":", 2)
+		userID, err := uuid.FromString(user[0])
+		if err != nil {
+			return nil, fmt.Errorf("Malformed user ID of API key entry %q: %v", entry, err)
+		}
+		principal.UserID = userID
+		if len(user) == 2 {
+			principal.Roles = nil
+			for _, s := range strings.Split(user[1], "+") {
+				role, err := ParseRole(s)
+				if err != nil {
+					return nil, fmt.Errorf("Malformed roles of API key entry %q: %v", entry, err)
+				}
+				principal.Roles = append(principal.Roles, role)
+			}
+		}
+		keys[parts[0]] = principal
+	}
+	return keys, nil
+}
+
+//SessionStore resolves the principal of opaque session tokens issued at login
+type SessionStore interface {
+	Authenticate(token string) (*Principal, error)
+}
+
+//Authenticator resolves the principal of requests from a static API key (X-API-Key header),
+//a signed JWT or a session token (both as Authorization: Bearer header)
+type Authenticator struct {
+	// keys are looked up by their SHA-256 digest, so that the lookup does not leak the keys by timing
+	keys     map[[sha256.Size]byte]Principal
+	jwt      *JWTVerifier
+	sessions SessionStore
+	now      func() time.Time
+	// tenant restricts the accepted credentials, unless scoped is false
+	tenant   string
+	scoped   bool
+	unscoped bool
+}
+
+//NewAuthenticator creates an authenticator accepting no credentials - add them with WithAPIKeys and WithJWT
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{keys: map[[sha256.Size]byte]Principal{}, now: time.Now}
+}
+
+//WithAPIKeys accepts the static API keys, each authenticating the principal it is mapped to
+func (a *Authenticator) WithAPIKeys(keys map[string]Principal) *Authenticator {
+	for key, principal := range keys {
+		principal.Method = MethodAPIKey
+		a.keys[sha256.Sum256([]byte(key))] = principal
+	}
+	return a
+}
+
+//WithJWT accepts bearer tokens verified by verifier
+func (a *Authenticator) WithJWT(verifier *JWTVerifier) *Authenticator {
+	a.jwt = verifier
+	return a
+}
+
+//WithSessions accepts bearer tokens of sessions - tokens without dots, which JWTs always contain
+func (a *Authenticator) WithSessions(sessions SessionStore) *Authenticator {
+	a.sessions = sessions
+	return a
+}
+
+//WithTenant accepts only credentials valid for tenant - API keys and JWTs naming the tenant, and the sessions
+//of the SessionStore, which belongs to the tenant. Credentials naming no tenant are accepted only if unscoped is true.
+func (a *Authenticator) WithTenant(tenant string, unscoped bool) *Authenticator {
+	a.tenant = tenant
+	a.scoped = true
+	a.unscoped = unscoped
+	return a
+}
+
+//WithClock replaces the clock used to check the expiry of tokens - for tests
+func (a *Authenticator) WithClock(now func() time.Time) *Authenticator {
+	a.now = now
+	return a
+}
+
+//BearerToken returns the token of the Authorization header of r - an empty one if there is no header
+func BearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get(HeaderAuthorization)
+	if header == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return "", ErrMalformedCredentials
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix)), nil
+}
+
+//Authenticate returns the principal of the request. It returns nil and no error if the request carries no credentials.
+//Credentials valid for another tenant (see WithTenant) are rejected with ErrWrongTenant.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	principal, err := a.authenticate(r)
+	if err != nil || principal == nil || !a.scoped {
+		return principal, err
+	}
+	if principal.Method == MethodSession {
+		principal.Tenant = a.tenant
+	}
+	if principal.Tenant != a.tenant && (principal.Tenant != "" || !a.unscoped) {
+		return nil, ErrWrongTenant
+	}
+	return principal, nil
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		principal, ok := a.keys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return nil, ErrInvalidAPIKey
+		}
+		return &principal, nil
+	}
+	token, err := BearerToken(r)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	if a.sessions != nil && !strings.Contains(token, ".") {
+		return a.sessions.Authenticate(token)
+	}
+	if a.jwt == nil {
+		return nil, ErrNoVerificationKey
+	}
+	claims, err := a.jwt.Verify(token, a.now())
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.FromString(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidSubject
+	}
+	roles := DefaultRoles
+	if len(claims.Roles) > 0 {
+		roles = nil
+		for _, s := range claims.Roles {
+			role, err := ParseRole(s)
+			if err != nil {
+				return nil, ErrInvalidRoles
+			}
+			roles = append(roles, role)
+		}
+	}
+	return &Principal{UserID: userID, Roles: roles, Method: MethodJWT, Tenant: claims.Tenant}, nil
+}
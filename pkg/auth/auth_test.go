@@ -0,0 +1,291 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+)
+
+func Test_ParseAPIKeys(t *testing.T) {
+	userID := uuid.NewV4()
+	keys, err := auth.ParseAPIKeys(" secret=" + userID.String() + ", admin=" + userID.String() + ":admin+seller" +
+		",acme=" + userID.String() + "@acme,globex=" + userID.String() + ":admin@globex")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]auth.Principal{
+		"secret": {UserID: userID, Roles: auth.DefaultRoles, Method: auth.MethodAPIKey},
+		"admin":  {UserID: userID, Roles: []auth.Role{auth.RoleAdmin, auth.RoleSeller}, Method: auth.MethodAPIKey},
+		"acme":   {UserID: userID, Roles: auth.DefaultRoles, Method: auth.MethodAPIKey, Tenant: "acme"},
+		"globex": {UserID: userID, Roles: []auth.Role{auth.RoleAdmin}, Method: auth.MethodAPIKey, Tenant: "globex"},
+	}, keys)
+
+	keys, err = auth.ParseAPIKeys("")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, s := range []string{"secret", "=" + userID.String(), "secret=bond", "secret=" + userID.String() + ":king", "secret=" + userID.String() + "@"} {
+		_, err := auth.ParseAPIKeys(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_JWTVerifier(t *testing.T) {
+	now := time.Now()
+	hmacKey := []byte("top secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	verifier := auth.NewJWTVerifier(hmacKey, &rsaKey.PublicKey).WithIssuer("issuer").WithAudience("bids")
+	valid := auth.Claims{Subject: "bond", Issuer: "issuer", Audience: auth.Audience{"bids"}, ExpiresAt: now.Add(time.Minute).Unix()}
+
+	for _, alg := range []string{auth.HS256, auth.HS384, auth.HS512} {
+		token, err := auth.Sign(valid, alg, hmacKey)
+		require.NoError(t, err)
+		claims, err := verifier.Verify(token, now)
+		require.NoError(t, err, alg)
+		assert.Equal(t, "bond", claims.Subject)
+	}
+	for _, alg := range []string{auth.RS256, auth.RS384, auth.RS512} {
+		token, err := auth.Sign(valid, alg, rsaKey)
+		require.NoError(t, err)
+		_, err = verifier.Verify(token, now)
+		require.NoError(t, err, alg)
+	}
+	_, err = auth.Sign(valid, auth.RS256, hmacKey)
+	assert.Error(t, err, "RSA algorithm with HMAC key")
+
+	sign := func(claims auth.Claims, alg string, key interface{}) string {
+		token, err := auth.Sign(claims, alg, key)
+		require.NoError(t, err)
+		return token
+	}
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Second).Unix()
+	noExpiry := valid
+	noExpiry.ExpiresAt = 0
+	notYetValid := valid
+	notYetValid.NotBefore = now.Add(time.Minute).Unix()
+	otherIssuer := valid
+	otherIssuer.Issuer = "other"
+	otherAudience := valid
+	otherAudience.Audience = auth.Audience{"other"}
+	token := sign(valid, auth.HS256, hmacKey)
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + strings.Split(sign(noExpiry, auth.HS256, hmacKey), ".")[1] + "." + parts[2]
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"expired", sign(expired, auth.HS256, hmacKey), auth.ErrTokenExpired},
+		{"no expiry", sign(noExpiry, auth.HS256, hmacKey), auth.ErrTokenExpired},
+		{"not yet valid", sign(notYetValid, auth.HS256, hmacKey), auth.ErrTokenNotYetValid},
+		{"other issuer", sign(otherIssuer, auth.HS256, hmacKey), auth.ErrInvalidIssuer},
+		{"other audience", sign(otherAudience, auth.HS256, hmacKey), auth.ErrInvalidAudience},
+		{"other HMAC key", sign(valid, auth.HS256, []byte("guess")), auth.ErrInvalidSignature},
+		{"tampered claims", tampered, auth.ErrInvalidSignature},
+		{"algorithm none", none, auth.ErrUnsupportedAlgorithm},
+		{"malformed", "not.a-token", auth.ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		_, err := verifier.Verify(tt.token, now)
+		assert.Equal(t, tt.want, err, tt.name)
+	}
+
+	_, err = auth.NewJWTVerifier(hmacKey, nil).Verify(sign(valid, auth.RS256, rsaKey), now)
+	assert.Equal(t, auth.ErrNoVerificationKey, err, "RSA token without RSA key")
+	_, err = verifier.WithLeeway(time.Minute).Verify(sign(expired, auth.HS256, hmacKey), now)
+	assert.NoError(t, err, "Expiry within leeway")
+}
+
+func Test_LoadKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	pkix := filepath.Join(dir, "pkix.pem")
+	require.NoError(t, ioutil.WriteFile(pkix, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	pkcs1 := filepath.Join(dir, "pkcs1.pem")
+	require.NoError(t, ioutil.WriteFile(pkcs1, pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}), 0600))
+	for _, path := range []string{pkix, pkcs1} {
+		key, err := auth.LoadRSAPublicKey(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, rsaKey.PublicKey, *key)
+	}
+	private := filepath.Join(dir, "private.pem")
+	require.NoError(t, ioutil.WriteFile(private, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), 0600))
+	_, err = auth.LoadRSAPublicKey(private)
+	assert.Error(t, err)
+
+	secret := filepath.Join(dir, "secret")
+	require.NoError(t, ioutil.WriteFile(secret, []byte("top secret\n"), 0600))
+	key, err := auth.LoadHMACKey(secret)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("top secret"), key)
+	require.NoError(t, ioutil.WriteFile(secret, []byte("\n"), 0600))
+	_, err = auth.LoadHMACKey(secret)
+	assert.Error(t, err)
+}
+
+func Test_Authenticator(t *testing.T) {
+	now := time.Now()
+	userID := uuid.NewV4()
+	hmacKey := []byte("top secret")
+	authenticator := auth.NewAuthenticator().
+		WithAPIKeys(map[string]auth.Principal{"secret": {UserID: userID, Roles: []auth.Role{auth.RoleSeller}}}).
+		WithJWT(auth.NewJWTVerifier(hmacKey, nil)).
+		WithClock(func() time.Time { return now })
+	token, err := auth.Sign(auth.Claims{Subject: userID.String(), ExpiresAt: now.Add(time.Minute).Unix()}, auth.HS256, hmacKey)
+	require.NoError(t, err)
+	admin, err := auth.Sign(auth.Claims{Subject: userID.String(), ExpiresAt: now.Add(time.Minute).Unix(), Roles: []string{"admin"}}, auth.HS256, hmacKey)
+	require.NoError(t, err)
+	badRoles, err := auth.Sign(auth.Claims{Subject: userID.String(), ExpiresAt: now.Add(time.Minute).Unix(), Roles: []string{"king"}}, auth.HS256, hmacKey)
+	require.NoError(t, err)
+	badSubject, err := auth.Sign(auth.Claims{Subject: "bond", ExpiresAt: now.Add(time.Minute).Unix()}, auth.HS256, hmacKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		want    *auth.Principal
+		wantErr error
+	}{
+		{"anonymous", "", "", nil, nil},
+		{"API key", auth.HeaderAPIKey, "secret", &auth.Principal{UserID: userID, Roles: []auth.Role{auth.RoleSeller}, Method: auth.MethodAPIKey}, nil},
+		{"unknown API key", auth.HeaderAPIKey, "guess", nil, auth.ErrInvalidAPIKey},
+		{"bearer token", auth.HeaderAuthorization, "Bearer " + token, &auth.Principal{UserID: userID, Roles: auth.DefaultRoles, Method: auth.MethodJWT}, nil},
+		{"bearer token with roles", auth.HeaderAuthorization, "Bearer " + admin, &auth.Principal{UserID: userID, Roles: []auth.Role{auth.RoleAdmin}, Method: auth.MethodJWT}, nil},
+		{"unknown role", auth.HeaderAuthorization, "Bearer " + badRoles, nil, auth.ErrInvalidRoles},
+		{"subject not a user", auth.HeaderAuthorization, "Bearer " + badSubject, nil, auth.ErrInvalidSubject},
+		{"basic auth", auth.HeaderAuthorization, "Basic Ym9uZDpqYW1lcw==", nil, auth.ErrMalformedCredentials},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		principal, err := authenticator.Authenticate(r)
+		assert.Equal(t, tt.wantErr, err, tt.name)
+		assert.Equal(t, tt.want, principal, tt.name)
+	}
+
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set(auth.HeaderAuthorization, "Bearer "+token)
+	_, err = auth.NewAuthenticator().Authenticate(r)
+	assert.Equal(t, auth.ErrNoVerificationKey, err, "JWTs are rejected without keys")
+}
+
+func Test_Authenticator_WithTenant(t *testing.T) {
+	now := time.Now()
+	userID := uuid.NewV4()
+	hmacKey := []byte("top secret")
+	keys := map[string]auth.Principal{
+		"acme":     {UserID: userID, Tenant: "acme"},
+		"globex":   {UserID: userID, Tenant: "globex"},
+		"unscoped": {UserID: userID},
+	}
+	sign := func(tenant string) string {
+		token, err := auth.Sign(auth.Claims{Subject: userID.String(), ExpiresAt: now.Add(time.Minute).Unix(), Tenant: tenant}, auth.HS256, hmacKey)
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+	authenticate := func(authenticator *auth.Authenticator, header, value string) (*auth.Principal, error) {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.Header.Set(header, value)
+		return authenticator.WithAPIKeys(keys).WithJWT(auth.NewJWTVerifier(hmacKey, nil)).
+			WithClock(func() time.Time { return now }).Authenticate(r)
+	}
+
+	tests := []struct {
+		name     string
+		unscoped bool
+		header   string
+		value    string
+		wantErr  error
+	}{
+		{"API key of the tenant", false, auth.HeaderAPIKey, "acme", nil},
+		{"API key of another tenant", false, auth.HeaderAPIKey, "globex", auth.ErrWrongTenant},
+		{"API key of no tenant", false, auth.HeaderAPIKey, "unscoped", auth.ErrWrongTenant},
+		{"API key of no tenant accepted", true, auth.HeaderAPIKey, "unscoped", nil},
+		{"API key of another tenant with unscoped", true, auth.HeaderAPIKey, "globex", auth.ErrWrongTenant},
+		{"token of the tenant", false, auth.HeaderAuthorization, sign("acme"), nil},
+		{"token of another tenant", false, auth.HeaderAuthorization, sign("globex"), auth.ErrWrongTenant},
+		{"token of no tenant", false, auth.HeaderAuthorization, sign(""), auth.ErrWrongTenant},
+		{"token of no tenant accepted", true, auth.HeaderAuthorization, sign(""), nil},
+	}
+	for _, tt := range tests {
+		principal, err := authenticate(auth.NewAuthenticator().WithTenant("acme", tt.unscoped), tt.header, tt.value)
+		assert.Equal(t, tt.wantErr, err, tt.name)
+		if tt.wantErr == nil {
+			assert.Equal(t, userID, principal.UserID, tt.name)
+		}
+	}
+
+	// without a tenant, credentials of all tenants are accepted
+	principal, err := authenticate(auth.NewAuthenticator(), auth.HeaderAPIKey, "globex")
+	require.NoError(t, err)
+	assert.Equal(t, "globex", principal.Tenant)
+}
+
+func Test_Policy(t *testing.T) {
+	policy := auth.DefaultPolicy()
+	bidder := &auth.Principal{UserID: uuid.NewV4(), Roles: []auth.Role{auth.RoleBidder}}
+	seller := &auth.Principal{UserID: uuid.NewV4(), Roles: []auth.Role{auth.RoleSeller}}
+	admin := &auth.Principal{UserID: uuid.NewV4(), Roles: []auth.Role{auth.RoleAdmin}}
+	nobody := &auth.Principal{UserID: uuid.NewV4()}
+
+	tests := []struct {
+		principal  *auth.Principal
+		permission auth.Permission
+		want       bool
+	}{
+		{nil, auth.PermissionListItems, true},
+		{nil, auth.PermissionPlaceBids, false},
+		{nil, auth.PermissionReadOwnUser, false},
+		{nobody, auth.PermissionListItems, false},
+		{bidder, auth.PermissionPlaceBids, true},
+		{bidder, auth.PermissionReadOwnUser, true},
+		{bidder, auth.PermissionReadAnyUser, false},
+		{bidder, auth.PermissionCreateItems, false},
+		{seller, auth.PermissionCreateItems, true},
+		{seller, auth.PermissionManageOwnItems, true},
+		{seller, auth.PermissionManageAnyItems, false},
+		{seller, auth.PermissionPlaceBids, false},
+		{admin, auth.PermissionListUsers, true},
+		{admin, auth.PermissionManageCredit, true},
+		{admin, auth.PermissionManageAnyItems, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.Allows(tt.principal, tt.permission), "%v %s", tt.principal, tt.permission)
+	}
+
+	both := &auth.Principal{Roles: []auth.Role{auth.RoleBidder, auth.RoleSeller}}
+	assert.True(t, policy.Allows(both, auth.PermissionPlaceBids))
+	assert.True(t, policy.Allows(both, auth.PermissionCreateItems))
+	assert.Equal(t, []auth.Permission{auth.PermissionListItems, auth.PermissionReadItems}, policy.Permissions(nil))
+	assert.Contains(t, policy.Permissions(both), auth.PermissionPlaceBids)
+	assert.Empty(t, policy.Permissions(nobody))
+
+	_, err := auth.ParseRole("king")
+	assert.Error(t, err)
+}
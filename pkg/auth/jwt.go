@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha512" // registers SHA-384 and SHA-512
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// define JWT signing algorithms
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+)
+
+// define JWT errors
+var (
+	ErrMalformedToken       = errors.New("Malformed token")
+	ErrUnsupportedAlgorithm = errors.New("Unsupported token signing algorithm")
+	ErrNoVerificationKey    = errors.New("No key configured to verify tokens signed with this algorithm")
+	ErrInvalidSignature     = errors.New("Invalid token signature")
+	ErrTokenExpired         = errors.New("Token has expired")
+	ErrTokenNotYetValid     = errors.New("Token is not valid yet")
+	ErrInvalidIssuer        = errors.New("Token has been issued by an unknown issuer")
+	ErrInvalidAudience      = errors.New("Token is meant for another audience")
+	ErrInvalidSubject       = errors.New("Token subject is not a user ID")
+	ErrInvalidRoles         = errors.New("Token grants an unknown role")
+)
+
+//algorithms maps JWT algorithms to their hash and tells whether they are HMAC (otherwise RSA PKCS #1 v1.5)
+var algorithms = map[string]struct {
+	hash crypto.Hash
+	hmac bool
+}{
+	HS256: {crypto.SHA256, true},
+	HS384: {crypto.SHA384, true},
+	HS512: {crypto.SHA512, true},
+	RS256: {crypto.SHA256, false},
+	RS384: {crypto.SHA384, false},
+	RS512: {crypto.SHA512, false},
+}
+
+//Audience is the aud claim - a single string or an array of strings
+type Audience []string
+
+//UnmarshalJSON accepts a string as well as an array
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+//Contains checks whether the audience includes aud
+func (a Audience) Contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+//Claims are the registered JWT claims checked by the JWTVerifier. Subject is the ID of the user.
+//Roles is a private claim with the roles of the user (see ParseRole), Tenant one with the ID of the tenant the token is valid for.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Tenant    string   `json:"tenant,omitempty"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+//JWTVerifier checks the signature and the time claims of compact-serialized JWTs.
+//Tokens signed with HMAC are verified with the HMAC key, tokens signed with RSA with the RSA key.
+//Tokens without an expiry are rejected.
+type JWTVerifier struct {
+	hmacKey  []byte
+	rsaKey   *rsa.PublicKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+//NewJWTVerifier creates a verifier - hmacKey or rsaKey may be nil to reject tokens signed with the algorithm
+func NewJWTVerifier(hmacKey []byte, rsaKey *rsa.PublicKey) *JWTVerifier {
+	return &JWTVerifier{hmacKey: hmacKey, rsaKey: rsaKey}
+}
+
+//WithIssuer requires the iss claim to equal issuer (if not empty)
+func (v *JWTVerifier) WithIssuer(issuer string) *JWTVerifier {
+	v.issuer = issuer
+	return v
+}
+
+//WithAudience requires the aud claim to include audience (if not empty)
+func (v *JWTVerifier) WithAudience(audience string) *JWTVerifier {
+	v.audience = audience
+	return v
+}
+
+//WithLeeway tolerates clock skew between the issuer and this server when checking exp and nbf
+func (v *JWTVerifier) WithLeeway(leeway time.Duration) *JWTVerifier {
+	v.leeway = leeway
+	return v
+}
+
+//Verify checks the token as of now and returns its claims
+func (v *JWTVerifier) Verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformedToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+	alg, ok := algorithms[h.Algorithm]
+	if !ok {
+		return Claims{}, ErrUnsupportedAlgorithm
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	if alg.hmac {
+		if v.hmacKey == nil {
+			return Claims{}, ErrNoVerificationKey
+		}
+		if !hmac.Equal(signature, signHMAC(alg.hash, v.hmacKey, signed)) {
+			return Claims{}, ErrInvalidSignature
+		}
+	} else {
+		if v.rsaKey == nil {
+			return Claims{}, ErrNoVerificationKey
+		}
+		if rsa.VerifyPKCS1v15(v.rsaKey, alg.hash, digest(alg.hash, signed), signature) != nil {
+			return Claims{}, ErrInvalidSignature
+		}
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+	if claims.ExpiresAt == 0 || !now.Before(time.Unix(claims.ExpiresAt, 0).Add(v.leeway)) {
+		return Claims{}, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(v.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return Claims{}, ErrTokenNotYetValid
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return Claims{}, ErrInvalidIssuer
+	}
+	if v.audience != "" && !claims.Audience.Contains(v.audience) {
+		return Claims{}, ErrInvalidAudience
+	}
+	return claims, nil
+}
+
+//Sign creates a compact-serialized JWT of claims - key is a []byte for HMAC and an *rsa.PrivateKey for RSA algorithms
+func Sign(claims Claims, algorithm string, key interface{}) (string, error) {
+	alg, ok := algorithms[algorithm]
+	if !ok {
+		return "", ErrUnsupportedAlgorithm
+	}
+	h, err := encodeSegment(header{Algorithm: algorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := h + "." + c
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		if !alg.hmac {
+			return "", fmt.Errorf("%s requires an RSA private key", algorithm)
+		}
+		signature = signHMAC(alg.hash, k, []byte(signed))
+	case *rsa.PrivateKey:
+		if alg.hmac {
+			return "", fmt.Errorf("%s requires an HMAC key", algorithm)
+		}
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, alg.hash, digest(alg.hash, []byte(signed)))
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("Unsupported key type %T", key)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+//LoadHMACKey reads an HMAC key from a file - surrounding whitespace is ignored
+func LoadHMACKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("HMAC key file %s is empty", path)
+	}
+	return key, nil
+}
+
+//LoadRSAPublicKey reads a PEM encoded RSA public key (PKIX or PKCS #1) or certificate from a file
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM data found in %s", path)
+	}
+	var key interface{}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Key in %s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
+
+func signHMAC(hash crypto.Hash, key, data []byte) []byte {
+	mac := hmac.New(hash.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func encodeSegment(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Role is a set of permissions granted to principals by a Policy
+type Role string
+
+// define roles
+const (
+	RoleAdmin  Role = "admin"
+	RoleSeller Role = "seller"
+	RoleBidder Role = "bidder"
+)
+
+//DefaultRoles are the roles of principals whose credentials name none
+var DefaultRoles = []Role{RoleBidder}
+
+//ParseRole parses one of the defined roles
+func ParseRole(s string) (Role, error) {
+	switch role := Role(strings.TrimSpace(s)); role {
+	case RoleAdmin, RoleSeller, RoleBidder:
+		return role, nil
+	default:
+		return "", fmt.Errorf("Unknown role %q", s)
+	}
+}
+
+//Permission allows an operation. Permissions ending in ":own" allow it on resources of the principal,
+//the matching ":any" permissions on resources of all users.
+type Permission string
+
+// define permissions
+const (
+	PermissionListUsers       Permission = "users:list"
+	PermissionReadOwnUser     Permission = "users:read:own"
+	PermissionReadAnyUser     Permission = "users:read:any"
+	PermissionUpdateOwnUser   Permission = "users:update:own"
+	PermissionUpdateAnyUser   Permission = "users:update:any"
+	PermissionManageCredit    Permission = "users:credit"
+	PermissionManageRoles     Permission = "users:roles"
+	PermissionListItems       Permission = "items:list"
+	PermissionReadItems       Permission = "items:read"
+	PermissionCreateItems     Permission = "items:create"
+	PermissionManageOwnItems  Permission = "items:manage:own"
+	PermissionManageAnyItems  Permission = "items:manage:any"
+	PermissionPlaceBids       Permission = "bids:place"
+	PermissionReadPermissions Permission = "permissions:read"
+	PermissionReadOwnInvoices Permission = "invoices:read:own"
+	PermissionReadAnyInvoices Permission = "invoices:read:any"
+	PermissionPayOwnInvoices  Permission = "invoices:pay:own"
+	PermissionPayAnyInvoices  Permission = "invoices:pay:any"
+	PermissionCancelInvoices  Permission = "invoices:cancel"
+	PermissionReadOwnOffers   Permission = "offers:read:own"
+	PermissionReadAnyOffers   Permission = "offers:read:any"
+	PermissionAnswerOwnOffers Permission = "offers:answer:own"
+	PermissionAnswerAnyOffers Permission = "offers:answer:any"
+	PermissionManageWebhooks  Permission = "webhooks:manage"
+	PermissionReadMetrics     Permission = "metrics:read"
+)
+
+//Policy grants permissions to roles and to anonymous requests
+type Policy struct {
+	grants    map[Role]map[Permission]bool
+	anonymous map[Permission]bool
+}
+
+//NewPolicy creates a policy granting nothing
+func NewPolicy() *Policy {
+	return &Policy{grants: map[Role]map[Permission]bool{}, anonymous: map[Permission]bool{}}
+}
+
+//DefaultPolicy lets everybody browse items, bidders bid, pay their invoices and answer their offers,
+//sellers sell their own items and admins do everything
+func DefaultPolicy() *Policy {
+	user := []Permission{PermissionReadOwnUser, PermissionUpdateOwnUser, PermissionListItems, PermissionReadItems, PermissionReadPermissions,
+		PermissionReadOwnInvoices, PermissionPayOwnInvoices, PermissionReadOwnOffers, PermissionAnswerOwnOffers}
+	return NewPolicy().
+		GrantAnonymous(PermissionListItems, PermissionReadItems).
+		Grant(RoleBidder, append(user, PermissionPlaceBids)...).
+		Grant(RoleSeller, append(user, PermissionCreateItems, PermissionManageOwnItems)...).
+		Grant(RoleAdmin,
+			PermissionListUsers, PermissionReadOwnUser, PermissionReadAnyUser, PermissionUpdateOwnUser, PermissionUpdateAnyUser,
+			PermissionManageCredit, PermissionManageRoles, PermissionListItems, PermissionReadItems, PermissionCreateItems,
+			PermissionManageOwnItems, PermissionManageAnyItems, PermissionPlaceBids, PermissionReadPermissions,
+			PermissionReadOwnInvoices, PermissionReadAnyInvoices, PermissionPayOwnInvoices, PermissionPayAnyInvoices, PermissionCancelInvoices,
+			PermissionReadOwnOffers, PermissionReadAnyOffers, PermissionAnswerOwnOffers, PermissionAnswerAnyOffers,
+			PermissionManageWebhooks, PermissionReadMetrics)
+}
+
+//Grant adds permissions to role
+func (p *Policy) Grant(role Role, permissions ...Permission) *Policy {
+	if p.grants[role] == nil {
+		p.grants[role] = map[Permission]bool{}
+	}
+	for _, permission := range permissions {
+		p.grants[role][permission] = true
+	}
+	return p
+}
+
+//GrantAnonymous adds permissions to requests without a principal
+func (p *Policy) GrantAnonymous(permissions ...Permission) *Policy {
+	for _, permission := range permissions {
+		p.anonymous[permission] = true
+	}
+	return p
+}
+
+//Allows checks whether principal (nil for anonymous requests) has permission by any of its roles
+func (p *Policy) Allows(principal *Principal, permission Permission) bool {
+	if principal == nil {
+		return p.anonymous[permission]
+	}
+	for _, role := range principal.Roles {
+		if p.grants[role][permission] {
+			return true
+		}
+	}
+	return false
+}
+
+//Permissions returns the sorted permissions of principal
+func (p *Policy) Permissions(principal *Principal) []Permission {
+	set := p.anonymous
+	if principal != nil {
+		set = map[Permission]bool{}
+		for _, role := range principal.Roles {
+			for permission := range p.grants[role] {
+				set[permission] = true
+			}
+		}
+	}
+	permissions := make([]Permission, 0, len(set))
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	return permissions
+}
@@ -28,8 +28,71 @@ const (
 	EnvPrefix = "BID"
 	//APIPrefixV1 URL prefix in API version 1
 	APIPrefixV1 = "/api/v1"
+	//APIPrefixV2 URL prefix in API version 2 - resources in envelopes with links, served side by side with version 1
+	APIPrefixV2 = "/api/v2"
 	//DefaultPort default port the service is served on
 	DefaultPort = "9000"
+	//DefaultStreamBuffer number of messages buffered for each live stream client before it is dropped as too slow
+	DefaultStreamBuffer = 64
+	//DefaultStreamHistory number of recent stream messages kept for clients resuming a server-sent events stream
+	DefaultStreamHistory = 1024
+	//DefaultWebhookWorkers number of webhooks delivered concurrently
+	DefaultWebhookWorkers = 4
+	//DefaultWebhookMaxAttempts number of attempts to deliver a webhook before it is put on the dead-letter list
+	DefaultWebhookMaxAttempts = 8
+	//DefaultWebhookBackoff wait before the first retry of a webhook, doubled for each further retry
+	DefaultWebhookBackoff = time.Second
+	//DefaultMetricsBuffer number of events queued for the metrics counters - events beyond are dropped for them
+	DefaultMetricsBuffer = 1024
+	//DefaultNotificationsBuffer number of events queued for sending notifications - events beyond are dropped for them
+	DefaultNotificationsBuffer = 1024
+	//DefaultReminderWindow how long before an auction closes the watchers of the item are reminded
+	DefaultReminderWindow = 15 * time.Minute
+	//DefaultReminderInterval how often items are checked for auctions about to close
+	DefaultReminderInterval = time.Minute
+	//DefaultBuyersPremium fee schedule of the buyer's premium ("rate@upTo,...,rate", see settlement.ParseSchedule)
+	DefaultBuyersPremium = "0.20"
+	//DefaultSellerCommission fee schedule of the seller commission
+	DefaultSellerCommission = "0.10"
+	//DefaultTaxRate tax rate charged on the hammer price and the buyer's premium
+	DefaultTaxRate = 0.0
+	//DefaultPaymentTerms time after settlement when an unpaid invoice becomes overdue
+	DefaultPaymentTerms = 14 * 24 * time.Hour
+	//DefaultOfferValidity time a second-chance offer may be accepted
+	DefaultOfferValidity = 48 * time.Hour
+	//DefaultJWTLeeway clock skew tolerated when checking the expiry of tokens
+	DefaultJWTLeeway = 30 * time.Second
+	//DefaultSessionTTL how long sessions started by logging in with a password are valid
+	DefaultSessionTTL = 24 * time.Hour
+	//DefaultResetTokenTTL how long password reset tokens can be used
+	DefaultResetTokenTTL = time.Hour
+	//DefaultPasswordIterations of PBKDF2 when hashing passwords
+	DefaultPasswordIterations = 600000
+	//DefaultRateLimits requests per client allowed on routes ("METHOD /pattern[|/pattern]=requests/unit[:burst],...", see ratelimit.ParseRules).
+	//Bids share one limit in API v1 and v2. Sign-ups and password resets are limited strictly, as each hashes a password.
+	DefaultRateLimits = "POST /item/{itemID}/bids|/items/{itemID}/bids=5/s:10,POST /accounts/login=10/m:10,POST /accounts=10/h," +
+		"POST /accounts/password-reset=5/h,POST /accounts/password-reset/confirm=10/h"
+	//DefaultJournalRetention number of events the journal of each tenant keeps in memory - older ones are dropped.
+	//0 keeps all of them: the journal is the only copy of the history.
+	DefaultJournalRetention = 0
+	//DefaultIdempotencyTTL how long the responses to bids sent with an Idempotency-Key are returned again to retries
+	DefaultIdempotencyTTL = 24 * time.Hour
+	//DefaultOpenAPIDocument path of the OpenAPI document generated from the routes (see cmd/openapi), served and validated against
+	DefaultOpenAPIDocument = "swagger/openapi.json"
+	//DefaultOpenAPIDocumentV2 path of the generated OpenAPI document of API v2
+	DefaultOpenAPIDocumentV2 = "swagger/openapi-v2.json"
+	//DefaultOpenAPIValidation what is validated against the OpenAPI document (see OpenAPIValidation...)
+	DefaultOpenAPIValidation = OpenAPIValidationRequests
+	//OpenAPIValidationOff disables the validation against the OpenAPI document
+	OpenAPIValidationOff = "off"
+	//OpenAPIValidationRequests validates the parameters and bodies of requests
+	OpenAPIValidationRequests = "requests"
+	//OpenAPIValidationAll validates responses as well, answering those that break the document with 500 - for tests
+	OpenAPIValidationAll = "all"
+	//DefaultSMTPFrom sender address of notification emails
+	DefaultSMTPFrom = "bid-tracker@localhost"
+	//OutboxStdout is the value of BID_OUTBOX relaying events to the standard output (any other value is a file path)
+	OutboxStdout = "stdout"
 )
 
 // ErrorMessage defines the type for the errors channel
@@ -48,4 +111,54 @@ func SetupEnv() {
 	viper.SetEnvPrefix(EnvPrefix)
 	// General
 	bindEnvVariable("PORT", DefaultPort)
+	// Live streams
+	bindEnvVariable("STREAM_BUFFER", DefaultStreamBuffer)
+	bindEnvVariable("STREAM_HISTORY", DefaultStreamHistory)
+	// Webhooks
+	bindEnvVariable("WEBHOOK_WORKERS", DefaultWebhookWorkers)
+	bindEnvVariable("WEBHOOK_MAX_ATTEMPTS", DefaultWebhookMaxAttempts)
+	bindEnvVariable("WEBHOOK_BACKOFF", DefaultWebhookBackoff)
+	// Metrics
+	bindEnvVariable("METRICS_BUFFER", DefaultMetricsBuffer)
+	// Notifications - no emails are sent if SMTP_ADDR (host:port) is empty
+	bindEnvVariable("NOTIFICATIONS_BUFFER", DefaultNotificationsBuffer)
+	bindEnvVariable("SMTP_ADDR", "")
+	bindEnvVariable("SMTP_FROM", DefaultSMTPFrom)
+	bindEnvVariable("SMTP_USERNAME", "")
+	bindEnvVariable("SMTP_PASSWORD", "")
+	bindEnvVariable("REMINDER_WINDOW", DefaultReminderWindow)
+	bindEnvVariable("REMINDER_INTERVAL", DefaultReminderInterval)
+	// Settlement
+	bindEnvVariable("BUYERS_PREMIUM", DefaultBuyersPremium)
+	bindEnvVariable("SELLER_COMMISSION", DefaultSellerCommission)
+	bindEnvVariable("TAX_RATE", DefaultTaxRate)
+	bindEnvVariable("PAYMENT_TERMS", DefaultPaymentTerms)
+	bindEnvVariable("OFFER_VALIDITY", DefaultOfferValidity)
+	bindEnvVariable("CURRENCY", "")
+	// Authentication - API_KEYS are "key=userID[:role+role][@tenant],...", JWTs are accepted if a key file is set
+	bindEnvVariable("API_KEYS", "")
+	bindEnvVariable("JWT_HMAC_KEY_FILE", "")
+	bindEnvVariable("JWT_RSA_KEY_FILE", "")
+	bindEnvVariable("JWT_ISSUER", "")
+	bindEnvVariable("JWT_AUDIENCE", "")
+	bindEnvVariable("JWT_LEEWAY", DefaultJWTLeeway)
+	// Accounts - reset tokens are emailed (to PASSWORD_RESET_URL + token, if set) if SMTP_ADDR is set
+	bindEnvVariable("SESSION_TTL", DefaultSessionTTL)
+	bindEnvVariable("RESET_TOKEN_TTL", DefaultResetTokenTTL)
+	bindEnvVariable("PASSWORD_ITERATIONS", DefaultPasswordIterations)
+	bindEnvVariable("PASSWORD_RESET_URL", "")
+	// Rate limits - none if empty
+	bindEnvVariable("RATE_LIMITS", DefaultRateLimits)
+	// Idempotency keys of bids
+	bindEnvVariable("IDEMPOTENCY_TTL", DefaultIdempotencyTTL)
+	// OpenAPI validation - OPENAPI_VALIDATION is off, requests or all
+	bindEnvVariable("OPENAPI_VALIDATION", DefaultOpenAPIValidation)
+	bindEnvVariable("OPENAPI_DOCUMENT", DefaultOpenAPIDocument)
+	bindEnvVariable("OPENAPI_DOCUMENT_V2", DefaultOpenAPIDocumentV2)
+	// Tenants - a JSON array of tenancy.Tenant; a single tenant "default" serves all requests if empty
+	bindEnvVariable("TENANTS_FILE", "")
+	// Journal - the latest JOURNAL_RETENTION events are kept in memory, all of them if 0
+	bindEnvVariable("JOURNAL_RETENTION", DefaultJournalRetention)
+	// Outbox - disabled if empty
+	bindEnvVariable("OUTBOX", "")
 }
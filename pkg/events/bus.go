@@ -0,0 +1,234 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+)
+
+//ErrBufferFull is logged when an event is dropped for an asynchronous subscriber
+var ErrBufferFull = errors.New("Buffer of the subscriber is full")
+
+//Bus publishes committed events to subscribers, in the order of the journal.
+//Synchronous subscribers are called by the publisher, one after another; they should be fast, as the next event
+//can only be published after they return. Events they commit are published after the event they handle (see Journal.Commit).
+//Asynchronous subscribers are called from their own goroutine and may be slow. Publishing never waits for them:
+//events that do not fit into their buffer are dropped and counted (see Dropped).
+//A subscriber that panics is logged and does not affect the other subscribers.
+//Subscribers may subscribe and unsubscribe while they handle an event, as events are delivered without holding the lock of the bus.
+type Bus struct {
+	mutex  sync.RWMutex
+	subs   []*subscription
+	nextID uint64
+	async  sync.WaitGroup
+	// dropped counts events not delivered to asynchronous subscribers - accessed atomically
+	dropped uint64
+}
+
+type subscription struct {
+	id    uint64
+	p     Projection
+	types map[Type]struct{}
+	queue chan Record // nil for synchronous subscribers
+
+	// mutex guards closed and sending to queue, so that events are not sent once queue has been closed
+	mutex  sync.RWMutex
+	closed bool
+}
+
+//SubscribeOption configures a subscription to a Bus
+type SubscribeOption func(*subscription)
+
+//Async delivers events to the subscriber from its own goroutine, buffering up to bufferSize events.
+//When the buffer is full, publishing does not wait for the subscriber - the event is dropped for it and counted.
+func Async(bufferSize int) SubscribeOption {
+	return func(s *subscription) {
+		if bufferSize < 0 {
+			bufferSize = 0
+		}
+		s.queue = make(chan Record, bufferSize)
+	}
+}
+
+//Only delivers events of the given types to the subscriber
+func Only(types ...Type) SubscribeOption {
+	return func(s *subscription) {
+		s.types = make(map[Type]struct{}, len(types))
+		for _, t := range types {
+			s.types[t] = struct{}{}
+		}
+	}
+}
+
+//NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+//Subscribe passes events published from now on to p (synchronously, unless the Async option is given)
+func (b *Bus) Subscribe(p Projection, opts ...SubscribeOption) (unsubscribe func()) {
+	s := &subscription{p: p}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	b.mutex.Lock()
+	b.nextID++
+	s.id = b.nextID
+	b.subs = append(b.subs, s)
+	b.mutex.Unlock()
+
+	if s.queue != nil {
+		b.async.Add(1)
+		go func() {
+			defer b.async.Done()
+			for r := range s.queue {
+				s.deliver(r)
+			}
+		}()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.unsubscribe(s) })
+	}
+}
+
+func (b *Bus) unsubscribe(s *subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for idx, sub := range b.subs {
+		if sub == s {
+			// the slice is copied, as publishers may still be reading the old one
+			b.subs = append(b.subs[:idx:idx], b.subs[idx+1:]...)
+			s.close()
+			return
+		}
+	}
+}
+
+//Publish passes r to all subscribers interested in its type. Subscribers are taken when Publish is called:
+//those subscribing meanwhile miss r, those unsubscribing meanwhile may still get it.
+func (b *Bus) Publish(r Record) {
+	b.mutex.RLock()
+	// subs is never modified in place (see unsubscribe), so it can be read without the lock
+	subs := b.subs
+	b.mutex.RUnlock()
+
+	for _, s := range subs {
+		if !s.wants(r.Event.Type()) {
+			continue
+		}
+		if s.queue != nil {
+			if !s.enqueue(r) {
+				atomic.AddUint64(&b.dropped, 1)
+				logging.LogWarning(fmt.Sprintf("Dropped event %d (%s) for a slow subscriber", r.Seq, r.Event.Type()), ErrBufferFull)
+			}
+			continue
+		}
+		s.deliver(r)
+	}
+}
+
+//Dropped returns the number of events dropped because the buffer of an asynchronous subscriber was full
+func (b *Bus) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+//Close unsubscribes everybody and waits until asynchronous subscribers have handled the events queued for them
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.mutex.Unlock()
+	for _, s := range subs {
+		s.close()
+	}
+
+	b.async.Wait()
+}
+
+func (s *subscription) wants(t Type) bool {
+	if s.types == nil {
+		return true
+	}
+	_, ok := s.types[t]
+	return ok
+}
+
+//enqueue queues r for an asynchronous subscriber and returns false if its buffer is full.
+//Events for a closed subscription are discarded.
+func (s *subscription) enqueue(r Record) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return true
+	}
+	select {
+	case s.queue <- r:
+		return true
+	default:
+		return false
+	}
+}
+
+//close stops the delivery of events - events already queued are still delivered
+func (s *subscription) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.queue != nil {
+		close(s.queue)
+	}
+}
+
+func (s *subscription) deliver(r Record) {
+	defer func() {
+		if err := recover(); err != nil {
+			logging.LogError(fmt.Sprintf("Subscriber failed on event %d (%s)", r.Seq, r.Event.Type()), fmt.Errorf("%v", err))
+		}
+	}()
+	s.p.Apply(r)
+}
+
+//OnItemListed adapts a function handling ItemListed events to a subscriber - subscribe it with Only(TypeItemListed)
+func OnItemListed(f func(Record, ItemListed)) Projection {
+	return ProjectionFunc(func(r Record) {
+		if e, ok := r.Event.(ItemListed); ok {
+			f(r, e)
+		}
+	})
+}
+
+//OnUserRegistered adapts a function handling UserRegistered events to a subscriber - subscribe it with Only(TypeUserRegistered)
+func OnUserRegistered(f func(Record, UserRegistered)) Projection {
+	return ProjectionFunc(func(r Record) {
+		if e, ok := r.Event.(UserRegistered); ok {
+			f(r, e)
+		}
+	})
+}
+
+//OnBidPlaced adapts a function handling BidPlaced events to a subscriber - subscribe it with Only(TypeBidPlaced)
+func OnBidPlaced(f func(Record, BidPlaced)) Projection {
+	return ProjectionFunc(func(r Record) {
+		if e, ok := r.Event.(BidPlaced); ok {
+			f(r, e)
+		}
+	})
+}
+
+//OnAuctionClosed adapts a function handling AuctionClosed events to a subscriber - subscribe it with Only(TypeAuctionClosed)
+func OnAuctionClosed(f func(Record, AuctionClosed)) Projection {
+	return ProjectionFunc(func(r Record) {
+		if e, ok := r.Event.(AuctionClosed); ok {
+			f(r, e)
+		}
+	})
+}
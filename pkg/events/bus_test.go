@@ -0,0 +1,227 @@
+package events_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+func commitItem(j *events.Journal) events.Record {
+	r, _ := j.Commit(func() (events.Event, error) {
+		return events.ItemListed{ItemID: uuid.NewV4()}, nil
+	})
+	return r
+}
+
+func Test_Bus_Sync(t *testing.T) {
+	j := events.NewJournal()
+	commitItem(j)
+
+	var seen []uint64
+	unsubscribe := j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+		seen = append(seen, r.Seq)
+	}))
+	commitItem(j)
+	assert.Equal(t, []uint64{2}, seen, "Synchronous subscribers should have seen the event when Commit returns")
+
+	unsubscribe()
+	unsubscribe()
+	commitItem(j)
+	assert.Equal(t, []uint64{2}, seen, "Only events published while subscribed should be seen")
+}
+
+func Test_Bus_Only(t *testing.T) {
+	j := events.NewJournal()
+	var bids []*models.Bid
+	j.Bus().Subscribe(events.OnBidPlaced(func(r events.Record, e events.BidPlaced) {
+		bids = append(bids, e.Bid)
+	}), events.Only(events.TypeBidPlaced))
+
+	commitItem(j)
+	bid := models.NewBid(uuid.NewV4(), uuid.NewV4(), 1)
+	j.Commit(func() (events.Event, error) { return events.BidPlaced{Bid: bid}, nil })
+
+	assert.Equal(t, []*models.Bid{bid}, bids)
+}
+
+func Test_Bus_Async(t *testing.T) {
+	j := events.NewJournal()
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	var seen []uint64
+	j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+		<-release
+		mutex.Lock()
+		seen = append(seen, r.Seq)
+		mutex.Unlock()
+	}), events.Async(10))
+
+	for i := 0; i < 5; i++ {
+		commitItem(j)
+	}
+	// commits do not wait for the blocked subscriber
+	close(release)
+	j.Bus().Close()
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, seen, "Queued events should be handled in order before Close returns")
+}
+
+func Test_Bus_AsyncSubscriberMayCommit(t *testing.T) {
+	j := events.NewJournal()
+	done := make(chan struct{})
+	j.Bus().Subscribe(events.OnItemListed(func(r events.Record, e events.ItemListed) {
+		if r.Seq == 1 {
+			commitItem(j)
+			close(done)
+		}
+	}), events.Async(1))
+
+	commitItem(j)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Asynchronous subscriber could not commit")
+	}
+	assert.Equal(t, 2, j.Len())
+}
+
+func Test_Bus_SyncSubscriberMayCommit(t *testing.T) {
+	j := events.NewJournal()
+	var seen []uint64
+	j.Bus().Subscribe(events.OnItemListed(func(r events.Record, e events.ItemListed) {
+		seen = append(seen, r.Seq)
+		if r.Seq == 1 {
+			nested := commitItem(j)
+			assert.Equal(t, uint64(2), nested.Seq)
+			assert.Equal(t, []uint64{1}, seen, "Events of subscribers should be published after the one being handled")
+		}
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		commitItem(j)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Synchronous subscriber could not commit")
+	}
+	assert.Equal(t, []uint64{1, 2}, seen, "Events committed by subscribers should be published before Commit returns")
+	commitItem(j)
+	assert.Equal(t, []uint64{1, 2, 3}, seen)
+}
+
+func Test_Bus_AsyncBufferFull(t *testing.T) {
+	j := events.NewJournal()
+	committed, release := make(chan struct{}), make(chan struct{})
+	var mutex sync.Mutex
+	var seen []uint64
+	j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+		if r.Seq == 1 {
+			// commits while the publisher fills the buffer
+			commitItem(j)
+			close(committed)
+			<-release
+		}
+		mutex.Lock()
+		seen = append(seen, r.Seq)
+		mutex.Unlock()
+	}), events.Async(1))
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			commitItem(j)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Publishing should not wait for a full buffer")
+	}
+	<-committed
+	close(release)
+	j.Bus().Close()
+
+	assert.Equal(t, 6, j.Len())
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, uint64(6), uint64(len(seen))+j.Bus().Dropped(), "Every event should be either delivered or counted as dropped")
+	assert.NotZero(t, j.Bus().Dropped())
+	assert.Equal(t, uint64(1), seen[0])
+}
+
+func Test_Bus_SubscribeFromSubscriber(t *testing.T) {
+	j := events.NewJournal()
+	var seen []uint64
+	var unsubscribe func()
+	unsubscribe = j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+		// would deadlock if the bus were locked while delivering
+		j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+			seen = append(seen, r.Seq)
+		}))
+		unsubscribe()
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		commitItem(j)
+		commitItem(j)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribing and unsubscribing from a subscriber should not block publishing")
+	}
+	assert.Equal(t, []uint64{2}, seen, "Subscribers see the events published after they have subscribed")
+}
+
+func Test_Bus_PanicIsolated(t *testing.T) {
+	j := events.NewJournal()
+	j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+		panic("broken subscriber")
+	}))
+	count := 0
+	j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+		count++
+	}))
+
+	commitItem(j)
+	commitItem(j)
+	assert.Equal(t, 2, count)
+}
+
+func Test_Bus_OrderUnderConcurrentCommits(t *testing.T) {
+	j := events.NewJournal()
+	var seen []uint64
+	j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) {
+		seen = append(seen, r.Seq)
+	}))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				commitItem(j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, seen, 8*200)
+	for idx, seq := range seen {
+		assert.Equal(t, uint64(idx+1), seq, "Events should be published in the order of the journal")
+	}
+}
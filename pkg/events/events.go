@@ -0,0 +1,173 @@
+// Package events defines the domain events of the bid tracker and an append-only journal recording them.
+// Every state change of the storage is recorded as an event first and then applied to projections,
+// which are the read models (e.g., winning bid of an item, items a user has bid on).
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//Type identifies the kind of an event
+type Type string
+
+// define event types
+const (
+	TypeItemListed       Type = "ItemListed"
+	TypeUserRegistered   Type = "UserRegistered"
+	TypeBidPlaced        Type = "BidPlaced"
+	TypeAuctionClosed    Type = "AuctionClosed"
+	TypeItemWatched      Type = "ItemWatched"
+	TypeItemUnwatched    Type = "ItemUnwatched"
+	TypeSearchSaved      Type = "SearchSaved"
+	TypeSearchDeleted    Type = "SearchDeleted"
+	TypeCreditChanged    Type = "CreditChanged"
+	TypeExposureReleased Type = "ExposureReleased"
+	TypeExposureAdded    Type = "ExposureAdded"
+)
+
+//Event is a state change in the bidding domain
+type Event interface {
+	Type() Type
+}
+
+//ItemListed is recorded when a new item is put on auction
+type ItemListed struct {
+	ItemID   uuid.UUID `json:"itemID"`
+	Name     string    `json:"name"`
+	ListedAt time.Time `json:"listedAt"`
+	SellerID uuid.UUID `json:"sellerID"`
+	// ReservePrice is the lowest price the item is sold for - 0 if there is none
+	ReservePrice float64 `json:"reservePrice,omitempty"`
+	// RelistedFrom is the item this one is a copy of - zero if the item is listed for the first time
+	RelistedFrom uuid.UUID `json:"relistedFrom"`
+	// ClosesAt is the announced end of the auction - nil if there is none
+	ClosesAt *time.Time `json:"closesAt,omitempty"`
+}
+
+//Type implements Event
+func (ItemListed) Type() Type { return TypeItemListed }
+
+//UserRegistered is recorded when a new user joins
+type UserRegistered struct {
+	UserID       uuid.UUID `json:"userID"`
+	Name         string    `json:"name"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+//Type implements Event
+func (UserRegistered) Type() Type { return TypeUserRegistered }
+
+//BidPlaced is recorded when a user places a bid on an item.
+//Outbid is the bid that was winning before and has been beaten by Bid - nil if Bid has not become the winning one,
+//or if there was no winning bid before.
+type BidPlaced struct {
+	Bid    *models.Bid `json:"bid"`
+	Outbid *models.Bid `json:"outbid,omitempty"`
+}
+
+//Type implements Event
+func (BidPlaced) Type() Type { return TypeBidPlaced }
+
+//AuctionClosed is recorded when an auction for an item ends. WinningBid is nil if there were no bids
+//or if the highest bid has not met the reserve price - then ReserveNotMet is true and HighestBid holds the bid.
+type AuctionClosed struct {
+	ItemID        uuid.UUID   `json:"itemID"`
+	WinningBid    *models.Bid `json:"winningBid,omitempty"`
+	ReserveNotMet bool        `json:"reserveNotMet,omitempty"`
+	HighestBid    *models.Bid `json:"highestBid,omitempty"`
+}
+
+//Type implements Event
+func (AuctionClosed) Type() Type { return TypeAuctionClosed }
+
+//ItemWatched is recorded when a user adds an item to the watchlist
+type ItemWatched struct {
+	UserID uuid.UUID `json:"userID"`
+	ItemID uuid.UUID `json:"itemID"`
+}
+
+//Type implements Event
+func (ItemWatched) Type() Type { return TypeItemWatched }
+
+//ItemUnwatched is recorded when a user removes an item from the watchlist
+type ItemUnwatched struct {
+	UserID uuid.UUID `json:"userID"`
+	ItemID uuid.UUID `json:"itemID"`
+}
+
+//Type implements Event
+func (ItemUnwatched) Type() Type { return TypeItemUnwatched }
+
+//SearchSaved is recorded when a user saves a search query
+type SearchSaved struct {
+	SearchID uuid.UUID `json:"searchID"`
+	UserID   uuid.UUID `json:"userID"`
+	Name     string    `json:"name"`
+	Query    string    `json:"query"`
+	SavedAt  time.Time `json:"savedAt"`
+}
+
+//Type implements Event
+func (SearchSaved) Type() Type { return TypeSearchSaved }
+
+//SearchDeleted is recorded when a user deletes a saved search
+type SearchDeleted struct {
+	UserID   uuid.UUID `json:"userID"`
+	SearchID uuid.UUID `json:"searchID"`
+}
+
+//Type implements Event
+func (SearchDeleted) Type() Type { return TypeSearchDeleted }
+
+//CreditChanged is recorded when the credit limit or the deposit of a user is set - Limited false removes the limit
+type CreditChanged struct {
+	UserID      uuid.UUID `json:"userID"`
+	Limited     bool      `json:"limited"`
+	CreditLimit float64   `json:"creditLimit"`
+	Deposit     float64   `json:"deposit"`
+}
+
+//Type implements Event
+func (CreditChanged) Type() Type { return TypeCreditChanged }
+
+//ExposureReleased is recorded when the winning bid of a user on a closed auction no longer counts against the credit
+//of the user, e.g., because it has been paid
+type ExposureReleased struct {
+	UserID uuid.UUID `json:"userID"`
+	ItemID uuid.UUID `json:"itemID"`
+}
+
+//Type implements Event
+func (ExposureReleased) Type() Type { return TypeExposureReleased }
+
+//ExposureAdded is recorded when a user buys a closed item after the auction, e.g., by accepting a second-chance offer,
+//so that the amount counts against the credit of the user
+type ExposureAdded struct {
+	UserID uuid.UUID `json:"userID"`
+	ItemID uuid.UUID `json:"itemID"`
+	Amount float64   `json:"amount"`
+}
+
+//Type implements Event
+func (ExposureAdded) Type() Type { return TypeExposureAdded }
+
+//Record is an event with its position in the journal and the time it has been recorded
+type Record struct {
+	Seq   uint64
+	At    time.Time
+	Event Event
+}
+
+//MarshalJSON renders a record together with the type of its event
+func (r Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Seq   uint64    `json:"seq"`
+		At    time.Time `json:"at"`
+		Type  Type      `json:"type"`
+		Event Event     `json:"event"`
+	}{r.Seq, r.At, r.Event.Type(), r.Event})
+}
@@ -0,0 +1,319 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// define errors of imports
+var (
+	ErrNoEvent    = errors.New("Record has no event")
+	ErrOutOfOrder = errors.New("Records must be imported in the order of their Seq, after the recorded ones")
+)
+
+//Projection is a read model that is built by applying recorded events in order
+type Projection interface {
+	Apply(Record)
+}
+
+//ProjectionFunc is an adapter to allow the use of ordinary functions as projections
+type ProjectionFunc func(Record)
+
+//Apply calls f(r)
+func (f ProjectionFunc) Apply(r Record) {
+	f(r)
+}
+
+//Replay applies records to the projections in order
+func Replay(records []Record, projections ...Projection) {
+	for _, r := range records {
+		for _, p := range projections {
+			p.Apply(r)
+		}
+	}
+}
+
+//Journal is an append-only in-memory log of events.
+//Writers lock only the aggregates they change, e.g., the item and the bidder of a bid: an event is decided, recorded
+//and applied to the state (see WithState) while the locks passed to Commit are held, so that the next decision on
+//the same aggregates sees it. Writers changing other aggregates do not wait - the only global step is giving out the
+//next Seq and appending the record, which is short. A decision must thus depend only on the aggregates it locks.
+//
+//Registered projections are applied to events in the order of the journal, one event at a time, and then the event
+//is published on the Bus of the journal - still in that order, but without blocking the next writer. Subscribers may
+//commit events themselves: those are published after the event being published, without waiting for it.
+//
+//With a retention, only the latest events are kept in memory - see WithRetention.
+type Journal struct {
+	//state is applied to every event under the locks of its writer
+	state Projection
+
+	//mutexProjections guards the projections and the Seq of the last record applied to them
+	mutexProjections sync.Mutex
+	projections      []Projection
+	applied          uint64
+
+	//mutexPublish guards the events recorded but not yet projected and published (by Seq), the Seq of the next one
+	//to be handed on, and who hands them on
+	mutexPublish sync.Mutex
+	pending      map[uint64]Record
+	next         uint64
+	publishing   bool
+	bus          *Bus
+
+	//mutexRecords guards records for readers, and gives out Seq
+	mutexRecords sync.RWMutex
+	//records are kept in chunks of fixed capacity, so that appending never copies recorded events
+	records [][]Record
+	//retained is the number of records kept, retention the maximum (0 keeps all)
+	retained  int
+	retention int
+	seq       uint64
+	last      time.Time
+}
+
+//chunkSize is the number of records kept in a single chunk
+const chunkSize = 4096
+
+//NewJournal creates an empty journal
+func NewJournal() *Journal {
+	return &Journal{bus: NewBus(), pending: map[uint64]Record{}, next: 1}
+}
+
+//WithState sets the projection holding the state that decisions are taken on. Unlike registered projections, it is
+//applied to an event right after it has been recorded, while the locks of its writer are held - events changing
+//different aggregates are thus applied concurrently and not necessarily in the order of the journal.
+func (j *Journal) WithState(state Projection) *Journal {
+	j.state = state
+	return j
+}
+
+//WithRetention keeps only the latest records events in memory - older ones are dropped as new ones are recorded,
+//so that the memory taken by the journal is bounded. 0 keeps all events. Projections are not affected, but Records
+//and Register see only the events kept: register projections that need all events before any is dropped.
+func (j *Journal) WithRetention(records int) *Journal {
+	j.mutexRecords.Lock()
+	defer j.mutexRecords.Unlock()
+	j.retention = records
+	j.trim()
+	return j
+}
+
+//Bus returns the bus committed events are published on
+func (j *Journal) Bus() *Bus {
+	return j.bus
+}
+
+//append adds a record and drops the oldest ones beyond the retention - mutexRecords must be held
+func (j *Journal) append(r Record) {
+	n := len(j.records)
+	if n == 0 || len(j.records[n-1]) == cap(j.records[n-1]) {
+		j.records = append(j.records, make([]Record, 0, chunkSize))
+		n++
+	}
+	j.records[n-1] = append(j.records[n-1], r)
+	j.retained++
+	j.seq = r.Seq
+	j.last = r.At
+	j.trim()
+}
+
+//trim drops the oldest records beyond the retention - mutexRecords must be held.
+//A chunk is released once all its records have been dropped; dropped records are cleared before, so that
+//their events can be collected right away.
+func (j *Journal) trim() {
+	for j.retention > 0 && j.retained > j.retention {
+		first := j.records[0]
+		first[0] = Record{}
+		if len(first) == 1 {
+			j.records[0] = nil
+			j.records = j.records[1:]
+		} else {
+			j.records[0] = first[1:]
+		}
+		j.retained--
+	}
+}
+
+//Commit locks the locks, in the order given, calls decide and records the event it returns. The event is applied to
+//the state before the locks are released, then to all projections, and then it is published on the Bus.
+//Commit returns after the event has been published, unless another Commit is publishing already or an event before
+//it is still being recorded - the event is then published after the ones before it, by the Commit publishing those.
+//This includes Commits of subscribers, which thus never wait for the event they are handling (which would deadlock).
+//If decide returns an error, nothing is recorded. If it returns a nil event, there is nothing to change:
+//nothing is recorded and a zero Record is returned. decide must not call the Journal.
+func (j *Journal) Commit(decide func() (Event, error), locks ...sync.Locker) (Record, error) {
+	record, err := j.record(decide, locks)
+	if err != nil || record.Event == nil {
+		return Record{}, err
+	}
+	j.mutexPublish.Lock()
+	j.pending[record.Seq] = record
+	j.mutexPublish.Unlock()
+	j.publish()
+	return record, nil
+}
+
+//publish applies the pending events to the projections and publishes them in order, unless another call is doing
+//so already. Events recorded meanwhile - by other writers or by subscribers - are handed on by the same call, before
+//it returns. It stops at the first Seq missing: the writer recording it publishes the events from there.
+func (j *Journal) publish() {
+	j.mutexPublish.Lock()
+	defer j.mutexPublish.Unlock()
+	if j.publishing {
+		return
+	}
+	j.publishing = true
+	for {
+		var pending []Record
+		for r, ok := j.pending[j.next]; ok; r, ok = j.pending[j.next] {
+			delete(j.pending, j.next)
+			pending = append(pending, r)
+			j.next++
+		}
+		if len(pending) == 0 {
+			break
+		}
+		j.mutexPublish.Unlock()
+		for _, r := range pending {
+			j.project(r)
+			j.bus.Publish(r)
+		}
+		j.mutexPublish.Lock()
+	}
+	j.publishing = false
+}
+
+//project applies a record to all projections
+func (j *Journal) project(r Record) {
+	j.mutexProjections.Lock()
+	defer j.mutexProjections.Unlock()
+	for _, p := range j.projections {
+		p.Apply(r)
+	}
+	j.applied = r.Seq
+}
+
+//record decides, records and applies an event to the state under the locks
+func (j *Journal) record(decide func() (Event, error), locks []sync.Locker) (Record, error) {
+	for _, l := range locks {
+		l.Lock()
+	}
+	defer func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}()
+
+	event, err := decide()
+	if err != nil || event == nil {
+		return Record{}, err
+	}
+
+	j.mutexRecords.Lock()
+	record := Record{Seq: j.seq + 1, At: time.Now(), Event: event}
+	if record.At.Before(j.last) {
+		//wall clock went backwards - keep the journal ordered by time
+		record.At = j.last
+	}
+	j.append(record)
+	j.mutexRecords.Unlock()
+
+	if j.state != nil {
+		j.state.Apply(record)
+	}
+	return record, nil
+}
+
+//Import appends records taken from another journal, keeping their Seq and At, and applies them to the state and
+//all projections. Every record is checked before it is appended: it must have an event, follow the previous one and
+//pass check (if not nil), which sees the state as it is after the previous records. Import stops at the first
+//record failing and returns the error - the records before it have been imported. Imported records are not
+//published. Import must not be called concurrently with Commit.
+func (j *Journal) Import(records []Record, check func(Record) error) error {
+	for _, r := range records {
+		err := ErrNoEvent
+		if r.Event != nil {
+			err = ErrOutOfOrder
+			if r.Seq > j.seq {
+				err = nil
+			}
+		}
+		if err == nil && check != nil {
+			err = check(r)
+		}
+		if err != nil {
+			if r.Event == nil {
+				return fmt.Errorf("Cannot import record %d: %w", r.Seq, err)
+			}
+			return fmt.Errorf("Cannot import record %d (%s): %w", r.Seq, r.Event.Type(), err)
+		}
+
+		j.mutexRecords.Lock()
+		j.append(r)
+		j.mutexRecords.Unlock()
+		if j.state != nil {
+			j.state.Apply(r)
+		}
+		j.project(r)
+		j.mutexPublish.Lock()
+		j.next = r.Seq + 1
+		j.mutexPublish.Unlock()
+	}
+	return nil
+}
+
+//Register replays the recorded events kept in memory into p and keeps it updated with events committed later
+func (j *Journal) Register(p Projection) {
+	j.mutexProjections.Lock()
+	defer j.mutexProjections.Unlock()
+
+	for _, r := range j.Records() {
+		if r.Seq > j.applied {
+			//applied to p once it is handed on
+			break
+		}
+		p.Apply(r)
+	}
+	j.projections = append(j.projections, p)
+}
+
+//Records returns a copy of the recorded events kept in memory
+func (j *Journal) Records() []Record {
+	j.mutexRecords.RLock()
+	defer j.mutexRecords.RUnlock()
+
+	records := make([]Record, 0, j.retained)
+	for _, chunk := range j.records {
+		records = append(records, chunk...)
+	}
+	return records
+}
+
+//Len returns the number of recorded events kept in memory
+func (j *Journal) Len() int {
+	j.mutexRecords.RLock()
+	defer j.mutexRecords.RUnlock()
+	return j.retained
+}
+
+//Reset removes all recorded events. Registered projections are kept, but are not reset.
+//Reset must not be called concurrently with Commit.
+func (j *Journal) Reset() {
+	j.mutexRecords.Lock()
+	j.records = nil
+	j.retained = 0
+	j.seq = 0
+	j.mutexRecords.Unlock()
+
+	j.mutexPublish.Lock()
+	j.pending = map[uint64]Record{}
+	j.next = 1
+	j.mutexPublish.Unlock()
+
+	j.mutexProjections.Lock()
+	j.applied = 0
+	j.mutexProjections.Unlock()
+}
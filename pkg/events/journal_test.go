@@ -0,0 +1,216 @@
+package events_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+)
+
+func Test_Journal_Commit(t *testing.T) {
+	j := events.NewJournal()
+	var applied []uint64
+	j.Register(events.ProjectionFunc(func(r events.Record) {
+		applied = append(applied, r.Seq)
+	}))
+
+	record, err := j.Commit(func() (events.Event, error) {
+		return events.ItemListed{ItemID: uuid.NewV4(), Name: "A thing"}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), record.Seq)
+	assert.False(t, record.At.IsZero())
+
+	_, err = j.Commit(func() (events.Event, error) {
+		return nil, errors.New("rejected")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, j.Len(), "Rejected events must not be recorded")
+
+	record, err = j.Commit(func() (events.Event, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, events.Record{}, record)
+	assert.Equal(t, 1, j.Len(), "Nothing is recorded without an event")
+
+	record, err = j.Commit(func() (events.Event, error) {
+		return events.UserRegistered{UserID: uuid.NewV4(), Name: "James Bond"}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), record.Seq)
+	assert.Equal(t, []uint64{1, 2}, applied)
+
+	records := j.Records()
+	assert.False(t, records[1].At.Before(records[0].At), "Records must be ordered by time")
+}
+
+func Test_Journal_Register(t *testing.T) {
+	j := events.NewJournal()
+	for i := 0; i < 3; i++ {
+		j.Commit(func() (events.Event, error) {
+			return events.ItemListed{ItemID: uuid.NewV4()}, nil
+		})
+	}
+
+	count := 0
+	j.Register(events.ProjectionFunc(func(r events.Record) {
+		count++
+	}))
+	assert.Equal(t, 3, count, "Past events should be replayed into new projection")
+
+	j.Commit(func() (events.Event, error) {
+		return events.AuctionClosed{ItemID: uuid.NewV4()}, nil
+	})
+	assert.Equal(t, 4, count)
+}
+
+func Test_Journal_Commit_Locks(t *testing.T) {
+	var first, second sync.Mutex
+	blocked, release := make(chan struct{}), make(chan struct{})
+	var state []uint64
+	var mutexState sync.Mutex
+	j := events.NewJournal().WithState(events.ProjectionFunc(func(r events.Record) {
+		if r.Event.(events.ItemListed).Name == "blocking" {
+			close(blocked)
+			<-release
+		}
+		mutexState.Lock()
+		state = append(state, r.Seq)
+		mutexState.Unlock()
+	}))
+	var projected []uint64
+	j.Register(events.ProjectionFunc(func(r events.Record) { projected = append(projected, r.Seq) }))
+	published := make(chan uint64, 2)
+	j.Bus().Subscribe(events.ProjectionFunc(func(r events.Record) { published <- r.Seq }))
+
+	done := make(chan struct{})
+	go func() {
+		j.Commit(func() (events.Event, error) {
+			return events.ItemListed{ItemID: uuid.NewV4(), Name: "blocking"}, nil
+		}, &first)
+		close(done)
+	}()
+	<-blocked
+
+	record, err := j.Commit(func() (events.Event, error) {
+		return events.ItemListed{ItemID: uuid.NewV4()}, nil
+	}, &second)
+	assert.NoError(t, err, "Writers locking other aggregates do not wait")
+	assert.Equal(t, uint64(2), record.Seq)
+	mutexState.Lock()
+	assert.Equal(t, []uint64{2}, state, "The state is applied under the locks of the writer")
+	mutexState.Unlock()
+
+	close(release)
+	<-done
+	assert.Equal(t, []uint64{1, 2}, projected, "Projections see events in the order of the journal")
+	assert.Equal(t, uint64(1), <-published)
+	assert.Equal(t, uint64(2), <-published)
+}
+
+func Test_Journal_Records_Copy(t *testing.T) {
+	j := events.NewJournal()
+	j.Commit(func() (events.Event, error) {
+		return events.ItemListed{ItemID: uuid.NewV4()}, nil
+	})
+	records := j.Records()
+	j.Commit(func() (events.Event, error) {
+		return events.ItemListed{ItemID: uuid.NewV4()}, nil
+	})
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, 2, len(j.Records()))
+}
+
+func Test_Record_MarshalJSON(t *testing.T) {
+	itemID := uuid.NewV4()
+	j := events.NewJournal()
+	record, _ := j.Commit(func() (events.Event, error) {
+		return events.AuctionClosed{ItemID: itemID}, nil
+	})
+
+	payload, err := json.Marshal(record)
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(payload, &decoded))
+	assert.Equal(t, float64(1), decoded["seq"])
+	assert.Equal(t, string(events.TypeAuctionClosed), decoded["type"])
+	assert.Equal(t, itemID.String(), decoded["event"].(map[string]interface{})["itemID"])
+}
+
+func Test_Journal_Retention(t *testing.T) {
+	j := events.NewJournal().WithRetention(5000)
+	applied := 0
+	j.Register(events.ProjectionFunc(func(r events.Record) { applied++ }))
+	commit := func() events.Record {
+		record, err := j.Commit(func() (events.Event, error) {
+			return events.ItemListed{ItemID: uuid.NewV4()}, nil
+		})
+		assert.NoError(t, err)
+		return record
+	}
+
+	for i := 0; i < 10000; i++ {
+		commit()
+	}
+	assert.Equal(t, 10000, applied, "Projections see all events")
+	assert.Equal(t, 5000, j.Len())
+	records := j.Records()
+	assert.Len(t, records, 5000)
+	assert.Equal(t, uint64(5001), records[0].Seq, "The oldest events are dropped")
+	assert.Equal(t, uint64(10000), records[len(records)-1].Seq)
+	assert.Equal(t, uint64(10001), commit().Seq, "Seq keeps counting")
+
+	var replayed []uint64
+	j.Register(events.ProjectionFunc(func(r events.Record) { replayed = append(replayed, r.Seq) }))
+	assert.Len(t, replayed, 5000, "Only the events kept are replayed")
+	assert.Equal(t, uint64(5002), replayed[0])
+
+	j.WithRetention(10)
+	assert.Equal(t, 10, j.Len())
+	assert.Equal(t, uint64(9992), j.Records()[0].Seq)
+	j.WithRetention(0)
+	for i := 0; i < 10; i++ {
+		commit()
+	}
+	assert.Equal(t, 20, j.Len(), "0 keeps all events")
+
+	imported := events.NewJournal().WithRetention(10)
+	assert.NoError(t, imported.Import(j.Records(), nil))
+	assert.Equal(t, 10, imported.Len())
+	record, err := imported.Commit(func() (events.Event, error) { return events.ItemListed{ItemID: uuid.NewV4()}, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10012), record.Seq, "Seq continues after imported events")
+
+	err = imported.Import([]events.Record{{Seq: 10012, Event: record.Event}}, nil)
+	assert.True(t, errors.Is(err, events.ErrOutOfOrder), "Recorded events cannot be imported again")
+	err = imported.Import([]events.Record{{Seq: 10013}}, nil)
+	assert.True(t, errors.Is(err, events.ErrNoEvent))
+	rejected := errors.New("rejected")
+	err = imported.Import([]events.Record{{Seq: 10013, Event: record.Event}, {Seq: 10014, Event: record.Event}},
+		func(r events.Record) error {
+			if r.Seq == 10014 {
+				return rejected
+			}
+			return nil
+		})
+	assert.True(t, errors.Is(err, rejected))
+	assert.Equal(t, uint64(10013), imported.Records()[imported.Len()-1].Seq, "Records before the rejected one are imported")
+}
+
+func Benchmark_Journal_Commit(b *testing.B) {
+	j := events.NewJournal().WithRetention(1000000)
+	j.Register(events.ProjectionFunc(func(events.Record) {}))
+	event := events.ItemListed{ItemID: uuid.NewV4(), Name: "A thing"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		j.Commit(func() (events.Event, error) { return event, nil })
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/accounts"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+)
+
+// define error messages
+const (
+	SignUpDecodeFailure = "Failed to decode account"
+	LoginDecodeFailure  = "Failed to decode login"
+	ResetDecodeFailure  = "Failed to decode password reset"
+	ResetUnavailable    = "Password reset is not available"
+)
+
+//TokenTypeBearer is the type of session tokens - they are sent as "Authorization: Bearer <token>"
+const TokenTypeBearer = "Bearer"
+
+//NewAccountHandler initializes a new handler
+func NewAccountHandler(manager *accounts.Manager) *AccountHandler {
+	return &AccountHandler{manager: manager}
+}
+
+//AccountHandler is the handler responsible for password accounts, logins and password resets
+type AccountHandler struct {
+	manager *accounts.Manager
+}
+
+type signUpPayload struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"tokenType"`
+	UserID    uuid.UUID `json:"userID"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type resetPayload struct {
+	Email string `json:"email"`
+}
+
+type resetConfirmPayload struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+//Routes returns the routes for the AccountHandler - they need no credentials
+func (e *AccountHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(render.SetContentType(render.ContentTypeJSON))
+	router.Post("/", e.SignUp)
+	router.Post("/login", e.Login)
+	router.Post("/logout", e.Logout)
+	router.Post("/password-reset", e.RequestPasswordReset)
+	router.Post("/password-reset/confirm", e.ResetPassword)
+	return router
+}
+
+// SignUp creates a user with a password account and returns the account
+//
+// @summary Sign up - create a user with a password account
+// @tags Accounts
+// @body {signUpPayload} The name, email address and password of the user
+// @response 201 {accounts.Account} CREATED
+// @response 400 The email address is invalid or the password is too short
+// @response 409 An account with the email address exists already
+func (e *AccountHandler) SignUp(w http.ResponseWriter, r *http.Request) {
+	payload := signUpPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logging.LogError(SignUpDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(SignUpDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	account, err := e.manager.SignUp(payload.Name, payload.Email, payload.Password)
+	switch err {
+	case nil:
+	case accounts.ErrInvalidEmail, accounts.ErrWeakPassword:
+		WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+		return
+	case accounts.ErrAccountExists:
+		WriteHTTPErrorCode(w, r, err, http.StatusConflict)
+		return
+	default:
+		logging.LogError("Cannot create account", err)
+		WriteHTTPErrorCode(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, account)
+}
+
+// Login checks email and password and returns the token of a new session
+//
+// @summary Log in with email and password and get a session token
+// @tags Accounts
+// @body {loginPayload} The email address and password
+// @response 200 {loginResponse} OK
+// @response 401 The email address or the password is wrong
+// @response 429 Too many logins - retry after Retry-After seconds
+func (e *AccountHandler) Login(w http.ResponseWriter, r *http.Request) {
+	payload := loginPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logging.LogError(LoginDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(LoginDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	token, session, err := e.manager.Login(payload.Email, payload.Password)
+	if err == accounts.ErrInvalidCredentials {
+		WriteHTTPErrorCode(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		logging.LogError("Cannot log in", err)
+		WriteHTTPErrorCode(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	render.JSON(w, r, loginResponse{Token: token, TokenType: TokenTypeBearer, UserID: session.UserID, ExpiresAt: session.ExpiresAt})
+}
+
+// Logout revokes the session whose token is sent as bearer token
+//
+// @summary Revoke the session whose token is sent as bearer token
+// @tags Accounts
+// @response 204 NO CONTENT
+// @response 401 The session token is missing, expired or revoked already
+func (e *AccountHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.BearerToken(r)
+	if err == nil && token == "" {
+		err = auth.ErrNoCredentials
+	}
+	if err == nil {
+		err = e.manager.Logout(token)
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="bid-tracker"`)
+		WriteHTTPErrorCode(w, r, err, http.StatusUnauthorized)
+		return
+	}
+	WriteHTTPCode(w, http.StatusNoContent)
+}
+
+// RequestPasswordReset sends a password reset token to the owner of an account. It is accepted
+// for unknown email addresses as well, so that it does not tell which addresses have accounts.
+//
+// @summary Send a single-use password reset token to the owner of an account
+// @tags Accounts
+// @body {resetPayload} The email address of the account
+// @response 202 ACCEPTED, also for unknown email addresses
+// @response 429 Too many resets - retry after Retry-After seconds
+// @response 501 No sender of reset tokens is configured
+func (e *AccountHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	payload := resetPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logging.LogError(ResetDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(ResetDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	err := e.manager.RequestReset(payload.Email)
+	if err == accounts.ErrNoResetSender {
+		WriteHTTPErrorCode(w, r, errors.New(ResetUnavailable), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		logging.LogError("Cannot send password reset", err)
+	}
+	WriteHTTPCode(w, http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with a reset token and ends all sessions of the user
+//
+// @summary Set a new password with a reset token and revoke all sessions of the user
+// @tags Accounts
+// @body {resetConfirmPayload} The reset token and the new password
+// @response 204 NO CONTENT
+// @response 400 The reset token is invalid, used or expired, or the password is too short
+func (e *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	payload := resetConfirmPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logging.LogError(ResetDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(ResetDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	switch err := e.manager.ResetPassword(payload.Token, payload.Password); err {
+	case nil:
+		WriteHTTPCode(w, http.StatusNoContent)
+	case accounts.ErrWeakPassword:
+		WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+	case accounts.ErrInvalidResetToken:
+		WriteHTTPErrorCode(w, r, errors.New(InvalidResetToken), http.StatusBadRequest)
+	default:
+		logging.LogError("Cannot reset password", err)
+		WriteHTTPErrorCode(w, r, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/accounts"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/ratelimit"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestAccountHandler(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	admin := testutils.CreateTestUsers(db, 1)[0]
+	tokens := make(chan string, 1)
+	manager := accounts.NewManager(db, accounts.ResetSenderFunc(func(to, token string, expiresAt time.Time) error {
+		tokens <- token
+		return nil
+	})).WithHasher(accounts.Hasher{Iterations: 10})
+
+	policy := auth.DefaultPolicy()
+	router := chi.NewRouter()
+	router.Mount("/accounts", handlers.NewAccountHandler(manager).Routes())
+	router.Group(func(r chi.Router) {
+		r.Use(srv.Authenticate(testutils.NewTestAuthenticatorWithRoles([]auth.Role{auth.RoleAdmin}, admin).WithSessions(manager)))
+		r.Mount("/user", handlers.NewUserHandler(db).WithPolicy(policy).WithAccounts(manager).Routes())
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	credentials := map[string]interface{}{"name": "Alice", "email": "alice@example.com", "password": "correct horse"}
+	userID := e.POST("/accounts").WithJSON(credentials).Expect().Status(http.StatusCreated).JSON().Object().
+		ValueEqual("email", "alice@example.com").ValueEqual("roles", []string{"bidder"}).
+		NotContainsKey("passwordHash").Value("userID").String().Raw()
+	e.POST("/accounts").WithJSON(credentials).Expect().Status(http.StatusConflict)
+	e.POST("/accounts").WithJSON(map[string]interface{}{"name": "Bob", "email": "bob@example.com", "password": "short"}).
+		Expect().Status(http.StatusBadRequest).Body().Contains(accounts.ErrWeakPassword.Error())
+	e.GET("/user/{userID}", userID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(admin)).Expect().Status(http.StatusOK)
+
+	e.POST("/accounts/login").WithJSON(map[string]interface{}{"email": "alice@example.com", "password": "wrong password"}).
+		Expect().Status(http.StatusUnauthorized)
+	login := e.POST("/accounts/login").WithJSON(credentials).Expect().Status(http.StatusOK).JSON().Object()
+	login.ValueEqual("userID", userID).ValueEqual("tokenType", handlers.TokenTypeBearer).ContainsKey("expiresAt")
+	bearer := "Bearer " + login.Value("token").String().Raw()
+
+	// sessions authenticate like other credentials
+	e.GET("/user/{userID}/permissions", userID).WithHeader(auth.HeaderAuthorization, bearer).Expect().
+		Status(http.StatusOK).JSON().Object().ValueEqual("roles", []string{"bidder"})
+	e.PUT("/user/{userID}/roles", userID).WithHeader(auth.HeaderAuthorization, bearer).
+		WithJSON(map[string]interface{}{"roles": []string{"admin"}}).Expect().
+		Status(http.StatusForbidden).Body().Contains(handlers.UserRolesForbidden)
+	e.PUT("/user/{userID}/roles", userID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(admin)).
+		WithJSON(map[string]interface{}{"roles": []string{"bidder", "seller"}}).Expect().
+		Status(http.StatusOK).JSON().Object().ValueEqual("roles", []string{"bidder", "seller"})
+	e.PUT("/user/{userID}/roles", admin.ID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(admin)).
+		WithJSON(map[string]interface{}{"roles": []string{"seller"}}).Expect().
+		Status(http.StatusNotFound).Body().Contains(handlers.AccountNotFound)
+	e.GET("/user/{userID}/permissions", userID).WithHeader(auth.HeaderAuthorization, bearer).Expect().
+		Status(http.StatusOK).JSON().Object().ValueEqual("roles", []string{"bidder", "seller"})
+
+	e.POST("/accounts/logout").WithHeader(auth.HeaderAuthorization, bearer).Expect().Status(http.StatusNoContent)
+	e.POST("/accounts/logout").WithHeader(auth.HeaderAuthorization, bearer).Expect().Status(http.StatusUnauthorized)
+	e.POST("/accounts/logout").Expect().Status(http.StatusUnauthorized)
+	e.GET("/user/{userID}/permissions", userID).WithHeader(auth.HeaderAuthorization, bearer).Expect().
+		Status(http.StatusUnauthorized)
+
+	// password reset
+	login = e.POST("/accounts/login").WithJSON(credentials).Expect().Status(http.StatusOK).JSON().Object()
+	bearer = "Bearer " + login.Value("token").String().Raw()
+	e.POST("/accounts/password-reset").WithJSON(map[string]interface{}{"email": "nobody@example.com"}).
+		Expect().Status(http.StatusAccepted)
+	e.POST("/accounts/password-reset").WithJSON(map[string]interface{}{"email": "alice@example.com"}).
+		Expect().Status(http.StatusAccepted)
+	manager.WaitForResets()
+	var token string
+	select {
+	case token = <-tokens:
+	default:
+		require.Fail(t, "No reset token has been sent")
+	}
+	e.POST("/accounts/password-reset/confirm").WithJSON(map[string]interface{}{"token": "guessed", "password": "battery staple"}).
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.InvalidResetToken)
+	e.POST("/accounts/password-reset/confirm").WithJSON(map[string]interface{}{"token": token, "password": "battery staple"}).
+		Expect().Status(http.StatusNoContent)
+	e.POST("/accounts/password-reset/confirm").WithJSON(map[string]interface{}{"token": token, "password": "battery staple"}).
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.InvalidResetToken)
+	e.GET("/user/{userID}/permissions", userID).WithHeader(auth.HeaderAuthorization, bearer).Expect().
+		Status(http.StatusUnauthorized)
+	e.POST("/accounts/login").WithJSON(credentials).Expect().Status(http.StatusUnauthorized)
+	e.POST("/accounts/login").WithJSON(map[string]interface{}{"email": "alice@example.com", "password": "battery staple"}).
+		Expect().Status(http.StatusOK)
+}
+
+func TestAccountHandler_ResetUnavailable(t *testing.T) {
+	manager := accounts.NewManager(storage.NewMapBiddingSystem(), nil)
+	server := httptest.NewServer(handlers.NewAccountHandler(manager).Routes())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+	e.POST("/password-reset").WithJSON(map[string]interface{}{"email": "alice@example.com"}).
+		Expect().Status(http.StatusNotImplemented).Body().Contains(handlers.ResetUnavailable)
+}
+
+func TestAccountHandler_DefaultRateLimits(t *testing.T) {
+	rules, err := ratelimit.ParseRules(config.DefaultRateLimits)
+	require.NoError(t, err)
+	limits := ratelimit.NewLimits(rules)
+	for _, path := range []string{"/accounts", "/accounts/login", "/accounts/password-reset", "/accounts/password-reset/confirm"} {
+		limiter, _ := limits.Match(http.MethodPost, path)
+		assert.NotNil(t, limiter, "%s hashes or checks passwords and is limited by default", path)
+	}
+}
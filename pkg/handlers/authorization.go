@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+)
+
+//ownerFunc returns the user owning the resource of a request - ok is false if the resource cannot be found
+type ownerFunc func(r *http.Request) (owner uuid.UUID, ok bool)
+
+//authorize is a middleware rejecting requests without permission by policy: anonymous ones with 401,
+//others with 403 and message. Without a policy, all requests are allowed.
+func authorize(policy *auth.Policy, permission auth.Permission, message string) func(http.Handler) http.Handler {
+	return authorizeOwner(policy, nil, permission, permission, message)
+}
+
+//authorizeOwner is authorize for resources owned by users: the principal needs the own permission on its resources
+//and the anyOwner permission on those of other users. Requests for resources that cannot be found are passed on,
+//so that the handler answers them.
+func authorizeOwner(policy *auth.Policy, owner ownerFunc, own, anyOwner auth.Permission, message string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if policy == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.FromContext(r.Context())
+			permission := anyOwner
+			if owner != nil {
+				id, ok := owner(r)
+				if !ok {
+					next.ServeHTTP(w, r)
+					return
+				}
+				if principal != nil && id == principal.UserID {
+					permission = own
+				}
+			}
+			if policy.Allows(principal, permission) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if principal == nil {
+				WriteHTTPErrorCode(w, r, auth.ErrNoCredentials, http.StatusUnauthorized)
+				return
+			}
+			WriteHTTPErrorCode(w, r, errors.New(message), http.StatusForbidden)
+		})
+	}
+}
+
+//userOwner takes the owner of a resource from the userID URL parameter
+func userOwner(r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.FromString(chi.URLParam(r, "userID"))
+	return userID, err == nil
+}
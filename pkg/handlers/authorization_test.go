@@ -0,0 +1,159 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/metrics"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+func TestAuthorization(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	users := testutils.CreateTestUsers(db, 4)
+	bidder, seller, otherSeller, admin := users[0], users[1], users[2], users[3]
+	item := models.NewItem("A painting")
+	item.SellerID = otherSeller.ID
+	require.NoError(t, db.CreateItem(item))
+
+	keys := map[string]auth.Principal{}
+	for user, role := range map[*models.User]auth.Role{bidder: auth.RoleBidder, seller: auth.RoleSeller, otherSeller: auth.RoleSeller, admin: auth.RoleAdmin} {
+		keys[testutils.APIKey(user)] = auth.Principal{UserID: user.ID, Roles: []auth.Role{role}}
+	}
+	policy := auth.DefaultPolicy()
+	router := chi.NewRouter()
+	router.Use(srv.Authenticate(auth.NewAuthenticator().WithAPIKeys(keys)))
+	router.Mount("/user", handlers.NewUserHandler(db).WithPolicy(policy).Routes())
+	router.Mount("/item", handlers.NewItemHandler(db).WithPolicy(policy).Routes())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+	as := func(user *models.User, req *httpexpect.Request) *httpexpect.Response {
+		return req.WithHeader(auth.HeaderAPIKey, testutils.APIKey(user)).Expect()
+	}
+
+	// users see their own resources, admins those of everybody
+	as(bidder, e.GET("/user")).Status(http.StatusForbidden).Body().Contains(handlers.UserListForbidden)
+	as(admin, e.GET("/user")).Status(http.StatusOK).JSON().Array().Length().Equal(4)
+	as(bidder, e.GET("/user/{userID}/bids", bidder.ID)).Status(http.StatusOK)
+	as(bidder, e.GET("/user/{userID}/bids", seller.ID)).Status(http.StatusForbidden).Body().Contains(handlers.UserGetForbidden)
+	as(admin, e.GET("/user/{userID}/bids", seller.ID)).Status(http.StatusOK)
+	e.GET("/user/{userID}/bids", bidder.ID).Expect().Status(http.StatusUnauthorized)
+	as(bidder, e.PUT("/user/{userID}/watchlist/{itemID}", seller.ID, item.ID)).Status(http.StatusForbidden).Body().Contains(handlers.UserUpdateForbidden)
+	as(bidder, e.PUT("/user/{userID}/watchlist/{itemID}", bidder.ID, item.ID)).Status(http.StatusNoContent)
+	as(bidder, e.PUT("/user/{userID}/credit", bidder.ID).WithJSON(map[string]interface{}{"limited": false})).
+		Status(http.StatusForbidden).Body().Contains(handlers.UserUpdateForbidden)
+	as(admin, e.PUT("/user/{userID}/credit", bidder.ID).WithJSON(map[string]interface{}{"limited": false})).Status(http.StatusOK)
+	as(bidder, e.GET("/user/{userID}/permissions", bidder.ID)).Status(http.StatusOK).JSON().Object().
+		ValueEqual("roles", []string{"bidder"}).Value("permissions").Array().Contains("bids:place").NotContains("users:list")
+	as(admin, e.GET("/user/{userID}/permissions", bidder.ID)).Status(http.StatusForbidden).Body().Contains(handlers.UserPermissionsForbidden)
+
+	// everybody browses items, bidders bid and sellers manage their own items
+	e.GET("/item").Expect().Status(http.StatusOK)
+	e.GET("/item/{itemID}/bids", item.ID).Expect().Status(http.StatusOK)
+	as(seller, e.POST("/item/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": 10})).
+		Status(http.StatusForbidden).Body().Contains(handlers.BidForbidden)
+	as(bidder, e.POST("/item/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": 10})).Status(http.StatusCreated)
+	as(bidder, e.POST("/item").WithJSON(map[string]interface{}{"name": "A vase"})).
+		Status(http.StatusForbidden).Body().Contains(handlers.ItemCreationForbidden)
+	as(seller, e.POST("/item").WithJSON(map[string]interface{}{"name": "A vase", "sellerID": otherSeller.ID})).
+		Status(http.StatusForbidden).Body().Contains(handlers.ItemCreationForbidden)
+	as(seller, e.POST("/item").WithJSON(map[string]interface{}{"name": "A vase"})).Status(http.StatusCreated)
+	as(admin, e.GET("/user/{userID}/items", seller.ID)).Status(http.StatusOK)
+	items, err := db.AllItems()
+	require.NoError(t, err)
+	for _, i := range items {
+		if i.Name == "A vase" {
+			require.Equal(t, seller.ID, i.SellerID, "Items are sold by their creator")
+		}
+	}
+
+	as(seller, e.POST("/item/{itemID}/close", item.ID)).Status(http.StatusForbidden).Body().Contains(handlers.ItemUpdateForbidden)
+	as(otherSeller, e.POST("/item/{itemID}/close", item.ID)).Status(http.StatusOK)
+	as(admin, e.POST("/item/{itemID}/relist", item.ID)).Status(http.StatusCreated)
+	as(seller, e.POST("/item/{itemID}/close", "not-a-uuid")).Status(http.StatusBadRequest)
+}
+
+func TestAuthorization_Settlement(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	ledger := settlement.NewLedger(db, settlement.Fees{}, time.Hour)
+	db.Subscribe(ledger, events.Only(events.TypeAuctionClosed))
+	users := testutils.CreateTestUsers(db, 3)
+	buyer, other, admin := users[0], users[1], users[2]
+	sold, unsold := models.NewItem("A painting"), models.NewItem("A vase")
+	unsold.ReservePrice = 100
+	require.NoError(t, db.CreateItem(sold))
+	require.NoError(t, db.CreateItem(unsold))
+	require.NoError(t, db.PlaceBid(models.NewBid(sold.ID, buyer.ID, 50)))
+	require.NoError(t, db.PlaceBid(models.NewBid(unsold.ID, buyer.ID, 80)))
+	_, err := db.CloseAuction(sold.ID)
+	require.NoError(t, err)
+	_, err = db.CloseAuction(unsold.ID)
+	require.NoError(t, err)
+	invoices := ledger.All("")
+	require.Len(t, invoices, 1)
+	invoice := invoices[0]
+	offer, err := ledger.OfferSecondChance(unsold.ID)
+	require.NoError(t, err)
+
+	keys := map[string]auth.Principal{}
+	for user, role := range map[*models.User]auth.Role{buyer: auth.RoleBidder, other: auth.RoleBidder, admin: auth.RoleAdmin} {
+		keys[testutils.APIKey(user)] = auth.Principal{UserID: user.ID, Roles: []auth.Role{role}}
+	}
+	policy := auth.DefaultPolicy()
+	router := chi.NewRouter()
+	router.Use(srv.Authenticate(auth.NewAuthenticator().WithAPIKeys(keys)))
+	router.Mount("/invoices", handlers.NewInvoiceHandler(ledger).WithPolicy(policy).Routes())
+	router.Mount("/offers", handlers.NewOfferHandler(ledger).WithPolicy(policy).Routes())
+	router.Mount("/webhooks", handlers.NewWebhookHandler(webhooks.NewRegistry(), nil).WithPolicy(policy).Routes())
+	router.Mount("/metrics", handlers.NewMetricsHandler(metrics.NewCounters()).WithPolicy(policy).Routes())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+	as := func(user *models.User, req *httpexpect.Request) *httpexpect.Response {
+		return req.WithHeader(auth.HeaderAPIKey, testutils.APIKey(user)).Expect()
+	}
+
+	// buyers read and pay their own invoices, admins list and cancel all of them
+	e.GET("/invoices").Expect().Status(http.StatusUnauthorized)
+	as(buyer, e.GET("/invoices")).Status(http.StatusForbidden).Body().Contains(handlers.InvoiceListForbidden)
+	as(admin, e.GET("/invoices")).Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	as(other, e.GET("/invoices/{invoiceID}", invoice.ID)).Status(http.StatusForbidden).Body().Contains(handlers.InvoiceGetForbidden)
+	as(buyer, e.GET("/invoices/{invoiceID}", invoice.ID)).Status(http.StatusOK)
+	as(other, e.POST("/invoices/{invoiceID}/pay", invoice.ID)).Status(http.StatusForbidden).Body().Contains(handlers.InvoicePayForbidden)
+	as(buyer, e.POST("/invoices/{invoiceID}/cancel", invoice.ID)).Status(http.StatusForbidden).Body().Contains(handlers.InvoiceCancelForbidden)
+	as(buyer, e.POST("/invoices/{invoiceID}/pay", invoice.ID)).Status(http.StatusOK)
+
+	// offers are answered by the bidder they are made to
+	e.GET("/offers/{offerID}", offer.ID).Expect().Status(http.StatusUnauthorized)
+	as(other, e.GET("/offers/{offerID}", offer.ID)).Status(http.StatusForbidden).Body().Contains(handlers.OfferGetForbidden)
+	as(other, e.POST("/offers/{offerID}/accept", offer.ID)).Status(http.StatusForbidden).Body().Contains(handlers.OfferAnswerForbidden)
+	as(other, e.POST("/offers/{offerID}/decline", offer.ID)).Status(http.StatusForbidden).Body().Contains(handlers.OfferAnswerForbidden)
+	as(admin, e.GET("/offers/{offerID}", offer.ID)).Status(http.StatusOK)
+	as(buyer, e.POST("/offers/{offerID}/decline", offer.ID)).Status(http.StatusOK)
+
+	// webhooks receive the events of all users and metrics describe the whole auction house - admins only
+	e.GET("/webhooks").Expect().Status(http.StatusUnauthorized)
+	e.GET("/webhooks/dead-letters").Expect().Status(http.StatusUnauthorized)
+	as(buyer, e.GET("/webhooks/dead-letters")).Status(http.StatusForbidden).Body().Contains(handlers.WebhookForbidden)
+	as(buyer, e.POST("/webhooks").WithJSON(map[string]interface{}{"url": "http://example.com"})).
+		Status(http.StatusForbidden).Body().Contains(handlers.WebhookForbidden)
+	as(admin, e.GET("/webhooks")).Status(http.StatusOK)
+	e.GET("/metrics").Expect().Status(http.StatusUnauthorized)
+	as(buyer, e.GET("/metrics")).Status(http.StatusForbidden).Body().Contains(handlers.MetricsForbidden)
+	as(admin, e.GET("/metrics")).Status(http.StatusOK)
+}
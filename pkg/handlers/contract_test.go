@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/openapi"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+// wildcards are the segments chi adds to the patterns of mounted routers
+var wildcards = regexp.MustCompile(`/\*`)
+
+// routes lists the operations that the router serves as "METHOD /path", relative to basePath
+func routes(t *testing.T, router chi.Routes, basePath string) []string {
+	seen := map[string]bool{}
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path := strings.TrimSuffix(wildcards.ReplaceAllString(route, ""), "/")
+		if !strings.HasPrefix(path, basePath) {
+			return nil
+		}
+		path = strings.TrimPrefix(path, basePath)
+		if path == "" {
+			path = "/"
+		}
+		seen[method+" "+path] = true
+		return nil
+	})
+	require.NoError(t, err)
+	var list []string
+	for route := range seen {
+		list = append(list, route)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// TestContract fails when the routes of SetupRoutes and the paths of swagger/openapi.json disagree - regenerate it with make openapi
+func TestContract(t *testing.T) {
+	spec, err := openapi.Load("../../swagger/openapi.json")
+	require.NoError(t, err)
+
+	server := srv.NewServer()
+	server.SetupRoutes(storage.NewMapBiddingSystem())
+	defer server.Shutdown()
+	served := routes(t, server.Mux(), spec.BasePath())
+
+	var documented []string
+	for _, route := range spec.Routes() {
+		documented = append(documented, route.Method+" "+route.Path)
+	}
+	sort.Strings(documented)
+
+	assert.Empty(t, difference(served, documented), "routes served but not documented in swagger/openapi.json")
+	assert.Empty(t, difference(documented, served), "routes documented in swagger/openapi.json but not served")
+}
+
+// difference returns the elements of a that are not in b
+func difference(a, b []string) []string {
+	in := map[string]bool{}
+	for _, s := range b {
+		in[s] = true
+	}
+	var diff []string
+	for _, s := range a {
+		if !in[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+// TestContract_Responses sends requests to the routes of SetupRoutes with responses validated against the spec
+// (see TestMain), so that responses breaking swagger/openapi.json are answered with 500
+func TestContract_Responses(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	bids, items, users := testutils.CreateTestBids(db, 2, []float64{10, 20})
+	admin := users[0]
+	viper.Set("API_KEYS", testutils.APIKey(admin)+"="+admin.ID.String()+":admin")
+	defer viper.Set("API_KEYS", "")
+	s := srv.NewServer()
+	s.SetupRoutes(db)
+	defer s.Shutdown()
+	server := httptest.NewServer(s.Mux())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL).Builder(func(req *httpexpect.Request) {
+		req.WithHeader(auth.HeaderAPIKey, testutils.APIKey(admin))
+	})
+
+	for _, path := range []string{"/item", "/user", "/metrics", "/tenant", "/invoices", "/invoices/fees", "/webhooks", "/webhooks/dead-letters"} {
+		e.GET("/api/v1" + path).Expect().Status(http.StatusOK)
+	}
+	for _, path := range []string{"/item/{id}/bids", "/item/{id}/winner", "/item/{id}/offers"} {
+		e.GET("/api/v1"+path, items[0].ID).Expect().Status(http.StatusOK)
+	}
+	for _, path := range []string{"/user/{id}", "/user/{id}/bids", "/user/{id}/items", "/user/{id}/watchlist", "/user/{id}/searches",
+		"/user/{id}/credit", "/user/{id}/invoices", "/user/{id}/sales", "/user/{id}/offers", "/user/{id}/permissions",
+		"/user/{id}/notifications", "/user/{id}/notifications/preferences"} {
+		e.GET("/api/v1"+path, admin.ID).Expect().Status(http.StatusOK)
+	}
+
+	e.POST("/api/v1/item").WithJSON(map[string]interface{}{"name": "Lamp"}).Expect().Status(http.StatusCreated)
+	e.POST("/api/v1/item/{id}/bids", items[1].ID).WithJSON(map[string]interface{}{"amount": bids[1].Amount + 1}).
+		Expect().Status(http.StatusCreated)
+	e.PUT("/api/v1/user/{id}/watchlist/{itemID}", admin.ID, items[0].ID).Expect().Status(http.StatusNoContent)
+	e.POST("/api/v1/user/{id}/searches", admin.ID).WithJSON(map[string]interface{}{"query": "lamp"}).
+		Expect().Status(http.StatusCreated)
+	e.PUT("/api/v1/user/{id}/credit", admin.ID).WithJSON(map[string]interface{}{"limited": true, "creditLimit": 100}).
+		Expect().Status(http.StatusOK)
+	e.POST("/api/v1/item/{id}/close", items[0].ID).Expect().Status(http.StatusOK)
+	e.POST("/api/v1/webhooks").WithJSON(map[string]interface{}{"url": "http://localhost:1/hook"}).Expect().Status(http.StatusCreated)
+
+	// invalid requests are rejected before they reach the handlers
+	invalid := e.POST("/api/v1/item/{id}/bids", items[1].ID).WithJSON(map[string]interface{}{"amount": "a lot"}).Expect()
+	invalid.Status(http.StatusBadRequest).JSON(problem).Object().ValueEqual("code", "invalid_request").
+		Value("errors").Array().Contains(map[string]string{"field": "body.amount", "message": "must be a number"})
+	e.GET("/api/v1/item/{id}/bids", items[0].ID).WithQuery("asOf", "yesterday").Expect().
+		Status(http.StatusBadRequest).JSON(problem).Object().Value("errors").Array().
+		Contains(map[string]string{"field": "query.asOf", "message": "must be an RFC 3339 date-time"})
+}
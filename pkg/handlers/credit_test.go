@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestUserHandler_Credit(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 2)
+
+	router := chi.NewRouter()
+	router.Use(srv.Authenticate(testutils.NewTestAuthenticator(users...)))
+	router.Mount("/user", handlers.NewUserHandler(db).Routes())
+	router.Mount("/item", handlers.NewItemHandler(db).Routes())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	e.GET("/user/{userID}/credit", users[0].ID).Expect().Status(http.StatusOK).JSON().Object().ValueEqual("limited", false)
+	e.PUT("/user/{userID}/credit", users[0].ID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		WithJSON(map[string]interface{}{"limited": true, "creditLimit": 50, "deposit": 25}).
+		Expect().Status(http.StatusOK).JSON().Object().ValueEqual("available", 75)
+	e.PUT("/user/{userID}/credit", users[0].ID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		WithJSON(map[string]interface{}{"limited": true, "deposit": -1}).
+		Expect().Status(http.StatusBadRequest)
+	e.PUT("/user/{userID}/credit", users[0].ID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		WithText("{").
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.CreditDecodeFailure)
+
+	bid := func(itemIdx, userIdx int, amount float64) *httpexpect.Response {
+		return e.POST("/item/{itemID}/bids", items[itemIdx].ID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[userIdx])).
+			WithJSON(map[string]interface{}{"amount": amount}).Expect()
+	}
+	bid(0, 0, 70).Status(http.StatusCreated)
+	bid(1, 0, 10).Status(http.StatusPaymentRequired).Body().Contains(handlers.CreditLimitExceeded)
+	e.GET("/user/{userID}/credit", users[0].ID).Expect().JSON().Object().ValueEqual("exposure", 70).ValueEqual("available", 5)
+
+	bid(0, 1, 80).Status(http.StatusCreated)
+	bid(1, 0, 10).Status(http.StatusCreated)
+	e.GET("/user/{userID}/credit", users[0].ID).Expect().JSON().Object().ValueEqual("exposure", 10)
+}
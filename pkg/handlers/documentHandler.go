@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/vikin91/bid-tracker-go/pkg/accounts"
+	"github.com/vikin91/bid-tracker-go/pkg/metrics"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/notifications"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+// define error messages
+const (
+	DocumentNotGenerated = "The OpenAPI document has not been generated"
+)
+
+//NewDocumentHandler initializes a new handler serving document - the handler answers 404 if document is empty
+func NewDocumentHandler(document []byte) *DocumentHandler {
+	return &DocumentHandler{document: document}
+}
+
+//DocumentHandler is the handler serving the OpenAPI document generated from the routes (see cmd/openapi)
+type DocumentHandler struct {
+	document []byte
+}
+
+//Routes returns the routes for the DocumentHandler
+func (e *DocumentHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/", e.GetDocument)
+	return router
+}
+
+// GetDocument returns the OpenAPI document of the API
+//
+// @summary Get the OpenAPI 3 document of the API, generated from its routes and handlers
+// @tags Documentation
+// @response 200 {object} OK
+// @response 404 The document has not been generated
+func (e *DocumentHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
+	if len(e.document) == 0 {
+		WriteHTTPErrorCode(w, r, errors.New(DocumentNotGenerated), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(e.document)
+}
+
+//Payloads are the types that the handlers read and write, as named by their annotations (e.g., "models.Bid")
+func Payloads() []interface{} {
+	return []interface{}{
+		Problem{},
+		signUpPayload{}, loginPayload{}, loginResponse{}, resetPayload{}, resetConfirmPayload{},
+		permissionsResponse{}, savedSearchPayload{}, rolesPayload{}, relistPayload{}, tenantResponse{},
+		itemEnvelope{}, itemsEnvelope{}, bidEnvelope{}, bidsEnvelope{}, userEnvelope{}, usersEnvelope{},
+		models.Item{}, models.User{}, models.Bid{}, models.Credit{}, models.SavedSearch{},
+		settlement.Invoice{}, settlement.Offer{}, settlement.Fees{},
+		notifications.Notification{}, notifications.Preferences{},
+		webhooks.Subscription{}, webhooks.Delivery{},
+		accounts.Account{},
+		metrics.Snapshot{},
+	}
+}
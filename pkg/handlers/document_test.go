@@ -0,0 +1,69 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gavv/httpexpect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/openapi"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+// TestDocument fails when the documents in swagger are not those generated from the routes and the annotations
+func TestDocument(t *testing.T) {
+	server := srv.NewServer()
+	server.SetupRoutes(storage.NewMapBiddingSystem())
+	defer server.Shutdown()
+	annotations, err := openapi.ParseAnnotations(".")
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.Mux())
+	defer ts.Close()
+	e := httpexpect.New(t, ts.URL)
+
+	for prefix, path := range map[string]string{
+		config.APIPrefixV1: "../../swagger/openapi.json",
+		config.APIPrefixV2: "../../swagger/openapi-v2.json",
+	} {
+		spec, err := srv.GenerateOpenAPI(server.Mux(), annotations, prefix)
+		require.NoError(t, err, "every handler is annotated")
+		generated, err := json.MarshalIndent(spec, "", "  ")
+		require.NoError(t, err)
+		document, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(document), string(generated), "%s is outdated - run make openapi", path)
+
+		// the generated document is valid and covers every route
+		parsed, err := openapi.Parse(document)
+		require.NoError(t, err)
+		assert.Equal(t, prefix, parsed.BasePath())
+		assert.ElementsMatch(t, routes(t, server.Mux(), parsed.BasePath()), routeList(parsed))
+
+		e.GET(prefix+"/openapi.json").Expect().Status(http.StatusOK).ContentType("application/json").
+			JSON().Object().ValueEqual("openapi", "3.0.2").Path("$.paths").Object().ContainsKey("/openapi.json")
+	}
+
+	v1, err := openapi.Load("../../swagger/openapi.json")
+	require.NoError(t, err)
+	route, _ := v1.Find("POST", "/item/4a417d40-d1eb-4184-abbe-58700f0a062a/bids")
+	require.NotNil(t, route)
+	assert.Equal(t, &openapi.Schema{Ref: "#/components/schemas/Bid"}, route.Operation.RequestBody.Content["application/json"].Schema)
+	assert.NoError(t, v1.ValidateResponse(route, http.StatusConflict, "application/problem+json",
+		[]byte(`{"type": "about:blank", "title": "Conflict", "status": 409}`)))
+}
+
+// routeList lists the operations of spec like routes
+func routeList(spec *openapi.Spec) []string {
+	var list []string
+	for _, route := range spec.Routes() {
+		list = append(list, route.Method+" "+route.Path)
+	}
+	return list
+}
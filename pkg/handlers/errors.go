@@ -1,12 +1,107 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
 )
 
+//ContentTypeProblem is the media type of error responses
+const ContentTypeProblem = "application/problem+json"
+
+//InternalError is the detail of server errors, whose own message may reveal internals - it is logged instead
+const InternalError = "The request could not be completed - see the logs of the request"
+
+//Problem is an error response as described by RFC 7807. Code is stable, so that clients can tell errors apart
+//without parsing Detail; RequestID refers to the logs of the request.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      string              `json:"code"`
+	RequestID string              `json:"requestID,omitempty"`
+	Errors    []models.FieldError `json:"errors,omitempty"`
+}
+
+//NewProblem describes err as a problem of the request with HTTP code. The code of the problem is the one of a
+//models.Error, or else derived from the HTTP code, e.g., "not_found". Server errors that are not a models.Error
+//are described by InternalError only.
+func NewProblem(r *http.Request, err error, code int) *Problem {
+	problem := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: err.Error(),
+		Code:   strings.ReplaceAll(strings.ToLower(http.StatusText(code)), " ", "_"),
+	}
+	var e *models.Error
+	if errors.As(err, &e) {
+		problem.Code = e.Code
+		problem.Errors = e.Fields
+	} else if code == http.StatusInternalServerError {
+		problem.Detail = InternalError
+	}
+	if r != nil {
+		problem.Instance = r.URL.Path
+		problem.RequestID = middleware.GetReqID(r.Context())
+	}
+	return problem
+}
+
+//WriteProblem writes problem to the HTTP response
+func WriteProblem(w http.ResponseWriter, problem *Problem) {
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
 //WriteHTTPErrorCode writes given HTTP Code to the HTTP response and provides explanation - for errors
-func WriteHTTPErrorCode(w http.ResponseWriter, err error, code int) {
-	http.Error(w, err.Error(), code)
+func WriteHTTPErrorCode(w http.ResponseWriter, r *http.Request, err error, code int) {
+	problem := NewProblem(r, err, code)
+	if problem.Detail != err.Error() {
+		logging.LogError(problem.Detail+" (request "+problem.RequestID+")", err)
+	}
+	WriteProblem(w, problem)
+}
+
+//WriteError writes err with the HTTP Code of its kind (see StatusOf)
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	WriteHTTPErrorCode(w, r, err, StatusOf(err))
+}
+
+//StatusOf maps the kind of err to an HTTP Code - 500 for errors that are not a models.Error
+func StatusOf(err error) int {
+	switch models.KindOf(err) {
+	case models.KindInvalid:
+		return http.StatusBadRequest
+	case models.KindNotFound:
+		return http.StatusNotFound
+	case models.KindConflict:
+		return http.StatusConflict
+	case models.KindRejected:
+		return http.StatusUnprocessableEntity
+	case models.KindCreditExceeded:
+		return http.StatusPaymentRequired
+	}
+	return http.StatusInternalServerError
+}
+
+//NotFound answers requests for unknown routes
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteHTTPErrorCode(w, r, errors.New(ResourceNotFound), http.StatusNotFound)
+}
+
+//MethodNotAllowed answers requests for known routes with methods they do not support
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteHTTPErrorCode(w, r, errors.New(http.StatusText(http.StatusMethodNotAllowed)), http.StatusMethodNotAllowed)
 }
 
 //WriteHTTPCode writes given HTTP Code to the HTTP response
@@ -0,0 +1,67 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gavv/httpexpect"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestProblems(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	user := testutils.CreateTestUsers(db, 1)[0]
+	viper.Set("API_KEYS", testutils.APIKey(user)+"="+user.ID.String()+":admin")
+	defer viper.Set("API_KEYS", "")
+	s := srv.NewServer()
+	s.SetupRoutes(db)
+	defer s.Shutdown()
+	server := httptest.NewServer(s.Mux())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	// errors carry a stable code, the request ID and the invalid fields
+	credit := e.PUT("/api/v1/user/{userID}/credit", user.ID).WithHeader(auth.HeaderAPIKey, testutils.APIKey(user)).
+		WithJSON(map[string]interface{}{"limited": true, "creditLimit": -1}).Expect()
+	credit.Status(http.StatusBadRequest).ContentType(handlers.ContentTypeProblem)
+	body := credit.JSON(problem).Object()
+	body.ValueEqual("type", "about:blank").ValueEqual("title", "Bad Request").ValueEqual("status", 400).
+		ValueEqual("code", "negative_credit").ValueEqual("instance", "/api/v1/user/"+user.ID.String()+"/credit")
+	body.Value("requestID").String().NotEmpty()
+	body.Value("errors").Array().Contains(map[string]string{"field": "creditLimit", "message": "must not be negative"})
+
+	// parameters breaking the OpenAPI document are rejected before they reach the handlers
+	e.GET("/api/v1/user/{userID}", "not-a-uuid").WithHeader(auth.HeaderAPIKey, testutils.APIKey(user)).Expect().
+		Status(http.StatusBadRequest).JSON(problem).Object().ValueEqual("code", "invalid_request").ContainsKey("errors")
+	e.GET("/api/v1/user/{userID}", "00000000-0000-0000-0000-000000000000").WithHeader(auth.HeaderAPIKey, testutils.APIKey(user)).Expect().
+		Status(http.StatusNotFound).JSON(problem).Object().ValueEqual("code", storage.ErrUserNotFound.Code)
+	// errors without a code of their own are coded by their status
+	e.POST("/api/v1/item/x/bids").Expect().
+		Status(http.StatusUnauthorized).JSON(problem).Object().ValueEqual("code", "unauthorized")
+
+	// so are unknown routes
+	e.GET("/api/v1/nothing").Expect().
+		Status(http.StatusNotFound).JSON(problem).Object().ValueEqual("code", "not_found")
+	e.GET("/nothing").Expect().
+		Status(http.StatusNotFound).JSON(problem).Object().ValueEqual("detail", handlers.ResourceNotFound)
+	e.DELETE("/api/v1/item").WithHeader(auth.HeaderAPIKey, testutils.APIKey(user)).Expect().
+		Status(http.StatusMethodNotAllowed).JSON(problem).Object().ValueEqual("code", "method_not_allowed")
+}
+
+func TestProblems_ServerErrors(t *testing.T) {
+	problem := handlers.NewProblem(nil, errors.New("dial tcp 10.0.0.1:5432: connection refused"), http.StatusInternalServerError)
+	assert.Equal(t, handlers.InternalError, problem.Detail, "Server errors do not reveal internals")
+	assert.Equal(t, "internal_server_error", problem.Code)
+
+	problem = handlers.NewProblem(nil, storage.ErrUserNotFound, http.StatusInternalServerError)
+	assert.Equal(t, storage.ErrUserNotFound.Error(), problem.Detail, "Errors of the models describe themselves")
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+)
+
+// define error messages
+const (
+	MalformedLastEventID = "Malformed Last-Event-ID"
+	StreamingUnsupported = "Streaming not supported"
+)
+
+//HeaderLastEventID is the header set by EventSource clients when they reconnect
+const HeaderLastEventID = "Last-Event-ID"
+
+//QueryParamLastEventID lets clients that cannot set headers resume a stream
+const QueryParamLastEventID = "lastEventId"
+
+//EventResync is sent instead of the missed messages when they are no longer kept - clients should reload the state
+const EventResync = "resync"
+
+const eventsKeepAlive = 30 * time.Second
+
+// ParseLastEventID parses the ID of the last message seen by the client (from the Last-Event-ID header or the lastEventId query parameter)
+// and sends the HTTPError Response on failure. The bool is false if the client does not resume a stream.
+func ParseLastEventID(w http.ResponseWriter, r *http.Request) (uint64, bool, error) {
+	value := r.Header.Get(HeaderLastEventID)
+	if value == "" {
+		value = r.URL.Query().Get(QueryParamLastEventID)
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+	lastID, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		logging.LogError("Error parsing Last-Event-ID", err)
+		WriteHTTPErrorCode(w, r, errors.New(MalformedLastEventID), http.StatusBadRequest)
+		return 0, false, err
+	}
+	return lastID, true, nil
+}
+
+// serveEvents writes the missed messages and then the messages delivered on sub as server-sent events,
+// until the client goes away or the subscription is dropped for being too slow (the client then resumes with Last-Event-ID)
+func serveEvents(w http.ResponseWriter, r *http.Request, sub *stream.Subscription, missed []stream.Message, complete bool) {
+	defer sub.Close()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteHTTPErrorCode(w, r, errors.New(StreamingUnsupported), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	WriteHTTPCode(w, http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", EventResync)
+	}
+	for _, msg := range missed {
+		if err := writeEvent(w, msg); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case msg, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, msg); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, msg stream.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logging.LogError("Cannot encode stream message", err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, data)
+	return err
+}
@@ -0,0 +1,206 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+)
+
+type serverSentEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// eventSource reads server-sent events from url until the test ends
+type eventSource struct {
+	resp    *http.Response
+	scanner *bufio.Scanner
+	cancel  context.CancelFunc
+}
+
+func openEventSource(t *testing.T, url string, lastEventID string) *eventSource {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set(handlers.HeaderLastEventID, lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return &eventSource{resp: resp, scanner: bufio.NewScanner(resp.Body), cancel: cancel}
+}
+
+func (s *eventSource) Close() {
+	s.cancel()
+	s.resp.Body.Close()
+}
+
+func (s *eventSource) next(t *testing.T) serverSentEvent {
+	event := serverSentEvent{}
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case line == "":
+			if event.Event != "" {
+				return event
+			}
+		case strings.HasPrefix(line, "id: "):
+			event.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	require.FailNow(t, "Event stream ended", "%v", s.scanner.Err())
+	return event
+}
+
+func (s *eventSource) nextMessage(t *testing.T) stream.Message {
+	msg := stream.Message{}
+	require.NoError(t, json.Unmarshal([]byte(s.next(t).Data), &msg))
+	return msg
+}
+
+func newEventsServer(historySize int) (storage.Storage, *stream.Hub, *httptest.Server) {
+	db := storage.NewMapBiddingSystem()
+	hub := stream.NewHub(0, historySize)
+	db.Subscribe(hub)
+	router := http.NewServeMux()
+	router.Handle("/item/", http.StripPrefix("/item", handlers.NewItemHandler(db).WithStream(hub).Routes()))
+	router.Handle("/user/", http.StripPrefix("/user", handlers.NewUserHandler(db).WithStream(hub).Routes()))
+	return db, hub, httptest.NewServer(router)
+}
+
+// waitForSubscribers waits until the handlers have subscribed to the hub
+func waitForSubscribers(t *testing.T, hub *stream.Hub, n int) {
+	for i := 0; hub.Len() < n; i++ {
+		require.True(t, i < 500, "Handlers did not subscribe")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestItemHandler_GetEvents(t *testing.T) {
+	db, hub, server := newEventsServer(0)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 2)
+
+	events := openEventSource(t, server.URL+"/item/"+items[0].ID.String()+"/events", "")
+	defer events.Close()
+	waitForSubscribers(t, hub, 1)
+
+	first := models.NewBid(items[0].ID, users[0].ID, 10)
+	db.PlaceBid(first)
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+	db.CloseAuction(items[0].ID)
+
+	event := events.next(t)
+	assert.Equal(t, "1", event.ID)
+	assert.Equal(t, string(stream.MessageNewBid), event.Event)
+	msg := stream.Message{}
+	require.NoError(t, json.Unmarshal([]byte(event.Data), &msg))
+	assert.Equal(t, first.ID, msg.Bid.ID)
+
+	assert.Equal(t, stream.MessageNewBid, events.nextMessage(t).Type)
+	assert.Equal(t, stream.MessageOutbid, events.nextMessage(t).Type)
+	assert.Equal(t, stream.MessageAuctionClosed, events.nextMessage(t).Type)
+}
+
+func TestItemHandler_GetEvents_Resume(t *testing.T) {
+	db, _, server := newEventsServer(0)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+	for i := 1; i <= 3; i++ {
+		db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, float64(i)))
+	}
+
+	events := openEventSource(t, server.URL+"/item/"+items[0].ID.String()+"/events", "1")
+	defer events.Close()
+	assert.Equal(t, "2", events.next(t).ID)
+	assert.Equal(t, "3", events.next(t).ID)
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 4))
+	assert.Equal(t, "4", events.next(t).ID)
+}
+
+func TestItemHandler_GetEvents_ResumeTooOld(t *testing.T) {
+	db, _, server := newEventsServer(1)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+	for i := 1; i <= 3; i++ {
+		db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, float64(i)))
+	}
+
+	events := openEventSource(t, server.URL+"/item/"+items[0].ID.String()+"/events?lastEventId=1", "")
+	defer events.Close()
+	assert.Equal(t, handlers.EventResync, events.next(t).Event)
+	assert.Equal(t, "3", events.next(t).ID)
+}
+
+func TestUserHandler_GetEvents(t *testing.T) {
+	db, hub, server := newEventsServer(0)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 2)
+
+	events := openEventSource(t, server.URL+"/user/"+users[0].ID.String()+"/events", "")
+	defer events.Close()
+	waitForSubscribers(t, hub, 1)
+
+	first := models.NewBid(items[0].ID, users[0].ID, 10)
+	db.PlaceBid(first)
+	db.PlaceBid(models.NewBid(items[1].ID, users[1].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+
+	assert.Equal(t, stream.MessageNewBid, events.nextMessage(t).Type)
+	msg := events.nextMessage(t)
+	assert.Equal(t, stream.MessageOutbid, msg.Type)
+	assert.Equal(t, first.ID, msg.Outbid.ID)
+}
+
+func TestHandlers_GetEvents_BadRequest(t *testing.T) {
+	db, _, server := newEventsServer(0)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+
+	e := httpexpect.New(t, server.URL)
+	e.GET("/item/{itemID}/events", uuid.NewV4()).Expect().Status(http.StatusNotFound)
+	e.GET("/user/{userID}/events", uuid.NewV4()).Expect().Status(http.StatusNotFound)
+	e.GET("/item/{itemID}/events", items[0].ID).WithHeader(handlers.HeaderLastEventID, "x").
+		Expect().Status(http.StatusBadRequest)
+	e.GET("/user/{userID}/events", users[0].ID).WithQuery(handlers.QueryParamLastEventID, "-1").
+		Expect().Status(http.StatusBadRequest)
+}
+
+func TestItemHandler_GetEvents_WithoutStream(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(db, 1)
+	server := httptest.NewServer(handlers.NewItemHandler(db).Routes())
+	defer server.Close()
+
+	httpexpect.New(t, server.URL).GET("/{itemID}/events", items[0].ID).Expect().Status(http.StatusNotFound)
+}
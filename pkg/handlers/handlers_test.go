@@ -4,11 +4,22 @@ import (
 	"os"
 	"testing"
 
+	"github.com/gavv/httpexpect"
+	"github.com/spf13/viper"
+
 	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
 )
 
+// problem reads error responses as JSON
+var problem = httpexpect.ContentOpts{MediaType: handlers.ContentTypeProblem}
+
 // Executed before test runs in this package (fails otherwise)
 func TestMain(m *testing.M) {
 	config.SetupEnv()
+	// servers of the tests check their responses against the spec as well
+	viper.Set("OPENAPI_VALIDATION", config.OpenAPIValidationAll)
+	viper.Set("OPENAPI_DOCUMENT", "../../swagger/openapi.json")
+	viper.Set("OPENAPI_DOCUMENT_V2", "../../swagger/openapi-v2.json")
 	os.Exit(m.Run())
 }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/idempotency"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+)
+
+// define idempotency headers
+const (
+	//HeaderIdempotencyKey is sent by clients to make retries of a request safe
+	HeaderIdempotencyKey = "Idempotency-Key"
+	//HeaderIdempotentReplayed is set on responses returned again for a retry
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// define error messages
+const (
+	IdempotencyKeyReused = "Idempotency-Key has been used already for a different request"
+	RequestReadFailure   = "Failed to read the request"
+)
+
+//idempotent is a middleware returning the stored response to retries of a request sent with the same Idempotency-Key.
+//Keys are scoped to the authenticated user. Reusing a key for a different request is rejected with 409.
+//Server errors are not stored, so that the request can be retried. Without a store, or a key, requests are passed on.
+func idempotent(store *idempotency.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if store == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if principal := auth.FromContext(r.Context()); principal != nil {
+				key = principal.UserID.String() + ":" + key
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				logging.LogError(RequestReadFailure, err)
+				WriteHTTPErrorCode(w, r, errors.New(RequestReadFailure), http.StatusBadRequest)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			stored, err := store.Begin(r.Context(), key, idempotency.NewFingerprint(r.Method, r.URL.Path, body))
+			if err == idempotency.ErrKeyReused {
+				WriteHTTPErrorCode(w, r, errors.New(IdempotencyKeyReused), http.StatusConflict)
+				return
+			}
+			if err == idempotency.ErrKeyTooLong {
+				WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				// the client has gone while waiting for a duplicate
+				return
+			}
+			if stored != nil {
+				for name, values := range stored.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(HeaderIdempotentReplayed, "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					store.Release(key)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+			if !recorder.wroteHeader {
+				recorder.header = w.Header().Clone()
+			}
+			store.Complete(key, &idempotency.Response{Status: recorder.status, Header: recorder.header, Body: recorder.body.Bytes()})
+			completed = true
+		})
+	}
+}
+
+//responseRecorder passes a response on and keeps a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	rec.header = rec.ResponseWriter.Header().Clone()
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+)
+
+// define error messages
+const (
+	MalformedStatusParam   = "Malformed status Parameter"
+	MalformedFormatParam   = "Malformed format Parameter"
+	InvoiceListForbidden   = "Not allowed to list Invoices"
+	InvoiceGetForbidden    = "Not allowed to get Invoice"
+	InvoicePayForbidden    = "Not allowed to pay Invoice"
+	InvoiceCancelForbidden = "Not allowed to cancel Invoice"
+)
+
+// define query parameters of invoice listings
+const (
+	//QueryParamStatus selects only invoices in the given status
+	QueryParamStatus = "status"
+	//QueryParamFormat selects the rendering of invoices: json (default) or text
+	QueryParamFormat = "format"
+)
+
+// define formats of invoices
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+//NewInvoiceHandler initializes a new handler
+func NewInvoiceHandler(ledger *settlement.Ledger) *InvoiceHandler {
+	return &InvoiceHandler{ledger: ledger}
+}
+
+//InvoiceHandler is the handler responsible for invoices of settled auctions
+type InvoiceHandler struct {
+	ledger *settlement.Ledger
+	policy *auth.Policy
+}
+
+//WithPolicy authorizes requests by policy: buyers read and pay their own invoices, admins all of them
+func (e *InvoiceHandler) WithPolicy(policy *auth.Policy) *InvoiceHandler {
+	e.policy = policy
+	return e
+}
+
+//Routes returns the routes for the InvoiceHandler
+func (e *InvoiceHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.With(authorize(e.policy, auth.PermissionReadAnyInvoices, InvoiceListForbidden)).Get("/", e.GetInvoices)
+	router.Get("/fees", e.GetFees)
+	router.With(authorizeOwner(e.policy, e.invoiceBuyer, auth.PermissionReadOwnInvoices, auth.PermissionReadAnyInvoices, InvoiceGetForbidden)).
+		Get("/{invoiceID}", e.GetInvoice)
+	router.With(authorizeOwner(e.policy, e.invoiceBuyer, auth.PermissionPayOwnInvoices, auth.PermissionPayAnyInvoices, InvoicePayForbidden)).
+		Post("/{invoiceID}/pay", e.PayInvoice)
+	router.With(authorize(e.policy, auth.PermissionCancelInvoices, InvoiceCancelForbidden)).Post("/{invoiceID}/cancel", e.CancelInvoice)
+	return router
+}
+
+//invoiceBuyer is the ownerFunc of invoices
+func (e *InvoiceHandler) invoiceBuyer(r *http.Request) (uuid.UUID, bool) {
+	invoiceID, err := uuid.FromString(chi.URLParam(r, "invoiceID"))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	invoice, err := e.ledger.Get(invoiceID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return invoice.BuyerID, true
+}
+
+// GetInvoices returns all invoices in the order they have been issued
+//
+// @summary Get all invoices in the order they have been issued
+// @tags Invoices
+// @param query status {string} Return only invoices in this status
+// @param query format {string} Rendering of the invoices: json (default) or text, also with Accept text/plain
+// @response 200 {[]settlement.Invoice} OK
+// @response 400 The status or format is invalid
+// @response 401 No credentials
+// @response 403 Not allowed to list invoices
+func (e *InvoiceHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	status, err := ParseInvoiceStatus(w, r)
+	if err != nil {
+		return
+	}
+	WriteInvoices(w, r, e.ledger.All(status))
+}
+
+// GetFees returns the fee schedules applied on settlement
+//
+// @summary Get the fee schedules applied on settlement
+// @tags Invoices
+// @response 200 {settlement.Fees} OK
+func (e *InvoiceHandler) GetFees(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, e.ledger.Fees())
+}
+
+// GetInvoice returns an invoice as JSON or plain text
+//
+// @summary Get an invoice
+// @tags Invoices
+// @param query format {string} Rendering of the invoice: json (default) or text, also with Accept text/plain
+// @response 200 {settlement.Invoice} OK
+// @response 400 The invoiceID or format is invalid
+// @response 401 No credentials
+// @response 403 Not the invoice of the user
+// @response 404 Invoice not found
+func (e *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := parseUUIDParam(w, r, "invoiceID")
+	if err != nil {
+		return
+	}
+	invoice, err := e.ledger.Get(invoiceID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	WriteInvoice(w, r, invoice)
+}
+
+// PayInvoice marks an issued or overdue invoice as paid
+//
+// @summary Mark an issued or overdue invoice as paid
+// @tags Invoices
+// @response 200 {settlement.Invoice} OK
+// @response 401 No credentials
+// @response 403 Not the invoice of the user
+// @response 404 Invoice not found
+// @response 409 The invoice has been paid or cancelled already, or the item has been offered to another bidder
+func (e *InvoiceHandler) PayInvoice(w http.ResponseWriter, r *http.Request) {
+	e.settle(w, r, e.ledger.Pay)
+}
+
+// CancelInvoice cancels an issued or overdue invoice
+//
+// @summary Cancel an issued or overdue invoice
+// @tags Invoices
+// @response 200 {settlement.Invoice} OK
+// @response 401 No credentials
+// @response 403 Not allowed to cancel invoices
+// @response 404 Invoice not found
+// @response 409 The invoice has been paid or cancelled already
+func (e *InvoiceHandler) CancelInvoice(w http.ResponseWriter, r *http.Request) {
+	e.settle(w, r, e.ledger.Cancel)
+}
+
+func (e *InvoiceHandler) settle(w http.ResponseWriter, r *http.Request, change func(uuid.UUID) (settlement.Invoice, error)) {
+	invoiceID, err := parseUUIDParam(w, r, "invoiceID")
+	if err != nil {
+		return
+	}
+	invoice, err := change(invoiceID)
+	if err != nil {
+		var known *models.Error
+		if !errors.As(err, &known) {
+			logging.LogError("Cannot settle invoice", err)
+		}
+		WriteError(w, r, err)
+		return
+	}
+	render.JSON(w, r, invoice)
+}
+
+// ParseInvoiceStatus parses the optional status query parameter and sends the HTTPError Response on failure.
+// It returns an empty status if the parameter is not present.
+func ParseInvoiceStatus(w http.ResponseWriter, r *http.Request) (settlement.Status, error) {
+	value := r.URL.Query().Get(QueryParamStatus)
+	if value == "" {
+		return "", nil
+	}
+	status, err := settlement.ParseStatus(value)
+	if err != nil {
+		WriteHTTPErrorCode(w, r, errors.New(MalformedStatusParam), http.StatusBadRequest)
+		return "", err
+	}
+	return status, nil
+}
+
+// WriteInvoice renders the invoice as JSON, or as plain text if requested (see ParseInvoiceFormat)
+func WriteInvoice(w http.ResponseWriter, r *http.Request, invoice settlement.Invoice) {
+	format, err := ParseInvoiceFormat(w, r)
+	if err != nil {
+		return
+	}
+	if format == FormatText {
+		writeText(w, invoice.Text())
+		return
+	}
+	render.JSON(w, r, invoice)
+}
+
+// WriteInvoices renders the invoices as a JSON array, or as plain text separated by empty lines if requested (see ParseInvoiceFormat)
+func WriteInvoices(w http.ResponseWriter, r *http.Request, invoices []settlement.Invoice) {
+	format, err := ParseInvoiceFormat(w, r)
+	if err != nil {
+		return
+	}
+	if format == FormatText {
+		texts := make([]string, 0, len(invoices))
+		for _, invoice := range invoices {
+			texts = append(texts, invoice.Text())
+		}
+		writeText(w, strings.Join(texts, "\n"))
+		return
+	}
+	render.JSON(w, r, invoices)
+}
+
+// ParseInvoiceFormat returns FormatText if requested with the format query parameter or an Accept header preferring text/plain,
+// FormatJSON otherwise. It sends the HTTPError Response if the format parameter is unknown.
+func ParseInvoiceFormat(w http.ResponseWriter, r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get(QueryParamFormat); format {
+	case FormatJSON, FormatText:
+		return format, nil
+	case "":
+		if strings.HasPrefix(r.Header.Get("Accept"), "text/plain") {
+			return FormatText, nil
+		}
+		return FormatJSON, nil
+	}
+	err := errors.New(MalformedFormatParam)
+	WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+	return "", err
+}
+
+func writeText(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(text))
+}
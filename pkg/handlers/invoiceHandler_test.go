@@ -0,0 +1,75 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestInvoiceHandler(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	fees := settlement.Fees{BuyersPremium: settlement.FlatRate(0.25), SellerCommission: settlement.FlatRate(0.1)}
+	ledger := settlement.NewLedger(db, fees, time.Hour)
+	db.Subscribe(ledger, events.Only(events.TypeAuctionClosed))
+	users := testutils.CreateTestUsers(db, 2)
+	seller, buyer := users[0], users[1]
+	item := models.NewItem("A painting")
+	item.SellerID = seller.ID
+	require.NoError(t, db.CreateItem(item))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, buyer.ID, 200)))
+	_, err := db.CloseAuction(item.ID)
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Mount("/user", handlers.NewUserHandler(db).WithInvoices(ledger).Routes())
+	router.Mount("/invoices", handlers.NewInvoiceHandler(ledger).Routes())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	list := e.GET("/user/{userID}/invoices", buyer.ID).Expect().Status(http.StatusOK).JSON().Array()
+	list.Length().Equal(1)
+	invoice := list.Element(0).Object()
+	invoice.ValueEqual("hammerPrice", 200).ValueEqual("buyersPremium", 50).ValueEqual("total", 250).
+		ValueEqual("sellerPayout", 180).ValueEqual("status", "issued")
+	id := invoice.Value("id").String().Raw()
+
+	e.GET("/user/{userID}/sales", seller.ID).Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/user/{userID}/sales", buyer.ID).Expect().Status(http.StatusOK).JSON().Array().Empty()
+	e.GET("/user/{userID}/invoices", uuid.NewV4()).Expect().Status(http.StatusNotFound)
+	e.GET("/user/{userID}/invoices", buyer.ID).WithQuery(handlers.QueryParamStatus, "lost").
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.MalformedStatusParam)
+
+	e.GET("/invoices/{invoiceID}", id).Expect().Status(http.StatusOK).JSON().Object().ValueEqual("number", "INV-000001")
+	e.GET("/invoices/{invoiceID}", id).WithQuery(handlers.QueryParamFormat, handlers.FormatText).
+		Expect().Status(http.StatusOK).ContentType("text/plain").Body().Contains("INVOICE INV-000001").Contains("250.00")
+	e.GET("/invoices/{invoiceID}", id).WithHeader("Accept", "text/plain").
+		Expect().Status(http.StatusOK).Body().Contains("A painting")
+	e.GET("/invoices/{invoiceID}", id).WithQuery(handlers.QueryParamFormat, "pdf").
+		Expect().Status(http.StatusBadRequest)
+	e.GET("/invoices/{invoiceID}", uuid.NewV4()).Expect().Status(http.StatusNotFound).
+		JSON(problem).Object().ValueEqual("code", "invoice_not_found")
+	e.GET("/invoices/fees").Expect().Status(http.StatusOK).JSON().Object().Value("buyersPremium").Array().Length().Equal(1)
+
+	e.POST("/invoices/{invoiceID}/pay", id).Expect().Status(http.StatusOK).JSON().Object().ValueEqual("status", "paid")
+	e.POST("/invoices/{invoiceID}/cancel", id).Expect().Status(http.StatusConflict).
+		JSON(problem).Object().ValueEqual("code", "invoice_settled")
+	e.POST("/invoices/{invoiceID}/pay", uuid.NewV4()).Expect().Status(http.StatusNotFound).
+		JSON(problem).Object().ValueEqual("code", "invoice_not_found")
+	e.GET("/invoices").WithQuery(handlers.QueryParamStatus, "paid").Expect().JSON().Array().Length().Equal(1)
+	e.GET("/user/{userID}/invoices", buyer.ID).WithQuery(handlers.QueryParamStatus, "issued").
+		Expect().JSON().Array().Empty()
+}
@@ -4,13 +4,19 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/idempotency"
 	"github.com/vikin91/bid-tracker-go/pkg/logging"
 	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
 	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
 )
 
 // define error messages
@@ -24,8 +30,35 @@ const (
 	ItemListForbidden     = "Not allowed to get all Items"
 	ResourceNotFound      = "Resource not found"
 	ItemNotFound          = "Item not found"
+	AuctionCloseFailure   = "Failed to close the auction"
+	ClosesAtInPast        = "Closing time of the auction must be in the future"
+	UnknownSeller         = "Cannot find the seller of this item"
+	CreditLimitExceeded   = "Bid exceeds the available credit of the user"
+	RelistDecodeFailure   = "Failed to decode relisting"
+	RelistFailure         = "Failed to relist the item"
+	BidderUnauthenticated = "Bids can only be placed by an authenticated user"
+	BidForAnotherUser     = "Not allowed to place a bid for another user"
+	BidForbidden          = "Not allowed to place a Bid"
+	ItemUpdateForbidden   = "Not allowed to update Item"
 )
 
+// define errors of requests
+var (
+	errClosesAtInPast = models.NewError(models.KindInvalid, "closes_at_in_past", ClosesAtInPast,
+		models.FieldError{Field: "closesAt", Message: "must be in the future"})
+	errUnknownSeller = models.NewError(models.KindInvalid, "unknown_seller", UnknownSeller,
+		models.FieldError{Field: "sellerID", Message: "must be a registered user"})
+	errUnknownBidder = models.NewError(models.KindRejected, "unknown_bidder", UnknownUserBids,
+		models.FieldError{Field: "userID", Message: "must be a registered user"})
+)
+
+//QueryParamSearch filters items by name - every whitespace-separated term must occur in the name, ignoring case
+const QueryParamSearch = "q"
+
+type relistPayload struct {
+	ClosesAt *time.Time `json:"closesAt"`
+}
+
 //NewItemHandler initializes a new handler
 func NewItemHandler(db storage.Storage) *ItemHandler {
 	return &ItemHandler{db: db}
@@ -33,114 +66,427 @@ func NewItemHandler(db storage.Storage) *ItemHandler {
 
 //ItemHandler is the handler responsible for Item operations
 type ItemHandler struct {
-	db storage.Storage
+	db     storage.Storage
+	hub    *stream.Hub
+	ledger *settlement.Ledger
+	policy *auth.Policy
+	//idempotency keeps the responses to bids, so that they can be retried safely
+	idempotency *idempotency.Store
+}
+
+//WithStream serves the activity on items from hub as server-sent events
+func (e *ItemHandler) WithStream(hub *stream.Hub) *ItemHandler {
+	e.hub = hub
+	return e
+}
+
+//WithSettlement serves the second-chance offers for items kept by ledger
+func (e *ItemHandler) WithSettlement(ledger *settlement.Ledger) *ItemHandler {
+	e.ledger = ledger
+	return e
+}
+
+//WithPolicy authorizes requests by policy: items are managed by their seller (or an admin)
+func (e *ItemHandler) WithPolicy(policy *auth.Policy) *ItemHandler {
+	e.policy = policy
+	return e
+}
+
+//WithIdempotency stores the responses to bids sent with an Idempotency-Key in store, returning them again to retries
+func (e *ItemHandler) WithIdempotency(store *idempotency.Store) *ItemHandler {
+	e.idempotency = store
+	return e
 }
 
 //Routes returns the routes for the ItemHandler
 func (e *ItemHandler) Routes() *chi.Mux {
 	router := chi.NewRouter()
 	router.Use(render.SetContentType(render.ContentTypeJSON))
-	router.Get("/", e.GetItems)
-	router.Post("/", e.CreateItem)
+	read := authorize(e.policy, auth.PermissionReadItems, ItemAccountForbidden)
+	manage := authorizeOwner(e.policy, e.itemSeller, auth.PermissionManageOwnItems, auth.PermissionManageAnyItems, ItemUpdateForbidden)
+	router.With(authorize(e.policy, auth.PermissionListItems, ItemListForbidden)).Get("/", e.GetItems)
+	router.With(authorize(e.policy, auth.PermissionCreateItems, ItemCreationForbidden)).Post("/", e.CreateItem)
 
-	router.Get("/{itemID}/bids", e.GetBids)
-	router.Post("/{itemID}/bids", e.PlaceBid)
-	router.Get("/{itemID}/winner", e.GetWinner)
+	router.With(read).Get("/{itemID}/bids", e.GetBids)
+	router.With(authorize(e.policy, auth.PermissionPlaceBids, BidForbidden), idempotent(e.idempotency)).Post("/{itemID}/bids", e.PlaceBid)
+	router.With(read).Get("/{itemID}/winner", e.GetWinner)
+	router.With(manage).Post("/{itemID}/close", e.CloseAuction)
+	router.With(manage).Post("/{itemID}/relist", e.RelistItem)
+	if e.hub != nil {
+		router.With(read).Get("/{itemID}/events", e.GetEvents)
+	}
+	if e.ledger != nil {
+		router.With(manage).Get("/{itemID}/offers", e.GetOffers)
+		router.With(manage).Post("/{itemID}/offers", e.OfferSecondChance)
+	}
 	return router
 }
 
-// GetItems returns list of items
+//itemSeller returns the seller of the item of the request
+func (e *ItemHandler) itemSeller(r *http.Request) (uuid.UUID, bool) {
+	itemID, err := uuid.FromString(chi.URLParam(r, "itemID"))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	item, err := e.db.GetItem(itemID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return item.SellerID, true
+}
+
+// GetItems returns list of items (only the ones matching the q query parameter, if given)
+//
+// @summary Get a list of items
+// @tags Items
+// @param query q {string} Return only items whose name contains every whitespace-separated term, ignoring case
+// @response 200 {[]models.Item} OK
 func (e *ItemHandler) GetItems(w http.ResponseWriter, r *http.Request) {
 
 	items, err := e.db.AllItems()
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusInternalServerError)
+		WriteError(w, r, err)
 		return
 	}
 
+	if query := r.URL.Query().Get(QueryParamSearch); query != "" {
+		matching := make([]*models.Item, 0, len(items))
+		for _, item := range items {
+			if models.MatchesQuery(query, item.Name) {
+				matching = append(matching, item)
+			}
+		}
+		items = matching
+	}
 	render.JSON(w, r, items)
 }
 
 // CreateItem creates new item (only admin or item owner)
+//
+// @summary Put an item on auction
+// @tags Items
+// @body {models.Item} A new item - sellerID defaults to the authenticated seller
+// @response 201 CREATED
+// @response 400 The item is invalid, closesAt is in the past or the seller is not a user of the tenant
+// @response 401 The request is not authenticated
+// @response 403 The user may not create items or sells on behalf of another user
 func (e *ItemHandler) CreateItem(w http.ResponseWriter, r *http.Request) {
+	if _, err := e.createItem(w, r); err != nil {
+		return
+	}
+	WriteHTTPCode(w, http.StatusCreated)
+}
 
+//createItem lists the item of the request and sends the HTTPError Response on failure
+func (e *ItemHandler) createItem(w http.ResponseWriter, r *http.Request) (*models.Item, error) {
 	// Item has to be valid
 	item := &models.Item{}
 	err := json.NewDecoder(r.Body).Decode(item)
 	if err != nil {
 		logging.LogError("Error decoding item creation request payload", err)
-		WriteHTTPErrorCode(w, err, http.StatusBadRequest)
-		return
+		WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+		return nil, err
+	}
+	// the ID is given out by the system - a client must not list an item under the ID of another one
+	item.ID, item.CreatedAt = config.ZeroUUID, time.Time{}
+	if item.ClosesAt != nil && !item.ClosesAt.After(time.Now()) {
+		WriteError(w, r, errClosesAtInPast)
+		return nil, errClosesAtInPast
+	}
+	if principal := auth.FromContext(r.Context()); e.policy != nil && !e.policy.Allows(principal, auth.PermissionManageAnyItems) {
+		// sellers sell their own items
+		if item.SellerID == config.ZeroUUID && principal != nil {
+			item.SellerID = principal.UserID
+		}
+		if principal == nil || item.SellerID != principal.UserID {
+			err := errors.New(ItemCreationForbidden)
+			WriteHTTPErrorCode(w, r, err, http.StatusForbidden)
+			return nil, err
+		}
+	}
+	if item.SellerID != config.ZeroUUID {
+		if _, err := e.db.GetUser(item.SellerID); err != nil {
+			WriteError(w, r, errUnknownSeller)
+			return nil, errUnknownSeller
+		}
 	}
 	err = e.db.CreateItem(item)
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusInternalServerError)
-		return
+		WriteError(w, r, err)
+		return nil, err
 	}
-
-	WriteHTTPCode(w, http.StatusCreated)
+	return item, nil
 }
 
-// GetBids returns list of bids on item
+// GetBids returns list of bids on item (as of the time given in the asOf query parameter)
+//
+// @summary Get all bids for an item
+// @tags Items, Bids
+// @param query asOf {date-time} Return the state as it was at this time (RFC 3339)
+// @response 200 {[]models.Bid} OK
+// @response 400 The itemID or asOf is invalid
+// @response 404 Item not found
 func (e *ItemHandler) GetBids(w http.ResponseWriter, r *http.Request) {
 	item, err := e.findItem(w, r)
 	if err != nil {
 		return
 	}
-	bids, err := e.db.GetBidsOnItem(item.ID)
+	asOf, err := ParseAsOf(w, r)
+	if err != nil {
+		return
+	}
+	var bids []*models.Bid
+	if asOf.IsZero() {
+		bids, err = e.db.GetBidsOnItem(item.ID)
+	} else {
+		bids, err = e.db.GetBidsOnItemAsOf(item.ID, asOf)
+	}
 	if err != nil {
 		logging.LogError("Cannot get bids on item", err)
-		WriteHTTPErrorCode(w, err, http.StatusInternalServerError)
+		WriteError(w, r, err)
 		return
 	}
 	render.JSON(w, r, bids)
 }
 
-// PlaceBid returns list of bids on item
+// PlaceBid places a bid of the authenticated user on item - the userID of the payload may be omitted
+//
+// @summary Place a bid of the authenticated user on the item
+// @tags Items, Bids
+// @param header Idempotency-Key {string} Unique key of the bid - retries with the same key and bid get the original response
+// @body {models.Bid} A new bid - userID may be omitted, the bidder is the authenticated user
+// @response 201 CREATED
+// @response 400 The bid is malformed
+// @response 401 The request is not authenticated
+// @response 402 The bid would take the winning bids of the user above the credit limit and deposit
+// @response 403 The userID is not the authenticated user or the user is not a bidder
+// @response 404 Item not found
+// @response 409 The auction is closed or the Idempotency-Key has been used for a different bid
+// @response 422 The bid breaks the bidding rules of the tenant or the bidder is not a user of the tenant
+// @response 429 Too many bids - retry after Retry-After seconds
 func (e *ItemHandler) PlaceBid(w http.ResponseWriter, r *http.Request) {
+	if _, _, err := e.placeBid(w, r); err != nil {
+		return
+	}
+	WriteHTTPCode(w, http.StatusCreated)
+}
+
+//placeBid places the bid of the request on its item and sends the HTTPError Response on failure
+func (e *ItemHandler) placeBid(w http.ResponseWriter, r *http.Request) (*models.Item, *models.Bid, error) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil {
+		err := errors.New(BidderUnauthenticated)
+		WriteHTTPErrorCode(w, r, err, http.StatusUnauthorized)
+		return nil, nil, err
+	}
 	item, err := e.findItem(w, r)
 	if err != nil {
-		return
+		return nil, nil, err
 	}
 
 	bid := &models.Bid{}
 	err = json.NewDecoder(r.Body).Decode(bid)
 	if err != nil || (*bid == models.Bid{}) {
 		logging.LogError("Error decoding bid", err)
-		WriteHTTPErrorCode(w, errors.New(BidDecodeFailure), http.StatusBadRequest)
-		return
+		err := errors.New(BidDecodeFailure)
+		WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+		return nil, nil, err
+	}
+	if bid.UserID != config.ZeroUUID && bid.UserID != principal.UserID {
+		err := errors.New(BidForAnotherUser)
+		WriteHTTPErrorCode(w, r, err, http.StatusForbidden)
+		return nil, nil, err
 	}
+	bid.UserID = principal.UserID
 	bid.ItemID = item.ID
+	// the principal is authenticated, yet it may have no user in the storage (of this tenant)
 	_, err = e.db.GetUser(bid.UserID)
 	if err != nil {
 		logging.LogError(UnknownUserBids, err)
-		WriteHTTPErrorCode(w, errors.New(UnknownUserBids), http.StatusInternalServerError)
-		return
+		WriteError(w, r, errUnknownBidder)
+		return nil, nil, errUnknownBidder
 	}
 	err = e.db.PlaceBid(bid)
+	var rejected *models.Error
+	if errors.As(err, &rejected) {
+		WriteError(w, r, err)
+		return nil, nil, err
+	}
 	if err != nil {
 		logging.LogError(BidPlacementFailure, err)
-		WriteHTTPErrorCode(w, errors.New(BidPlacementFailure), http.StatusInternalServerError)
-		return
+		WriteHTTPErrorCode(w, r, errors.New(BidPlacementFailure), http.StatusInternalServerError)
+		return nil, nil, err
 	}
-	WriteHTTPCode(w, http.StatusCreated)
+	return item, bid, nil
 }
 
-// GetWinner returns single winning bid
+// GetWinner returns single winning bid (as of the time given in the asOf query parameter)
+//
+// @summary Get the winning bid for an item
+// @tags Items, Bids
+// @param query asOf {date-time} Return the state as it was at this time (RFC 3339)
+// @response 200 {models.Bid} OK
+// @response 400 The itemID or asOf is invalid
+// @response 404 Item not found or it has no bids (code no_bids)
 func (e *ItemHandler) GetWinner(w http.ResponseWriter, r *http.Request) {
 	item, err := e.findItem(w, r)
 	if err != nil {
 		return
 	}
-	bid, err := e.db.GetWinningBid(item.ID)
+	asOf, err := ParseAsOf(w, r)
+	if err != nil {
+		return
+	}
+	var bid *models.Bid
+	if asOf.IsZero() {
+		bid, err = e.db.GetWinningBid(item.ID)
+	} else {
+		bid, err = e.db.GetWinningBidAsOf(item.ID, asOf)
+	}
 	if err != nil {
 		logging.LogError("Cannot get winning bid on item", err)
-		WriteHTTPErrorCode(w, err, http.StatusInternalServerError)
+		WriteError(w, r, err)
+		return
+	}
+	render.JSON(w, r, bid)
+}
+
+// CloseAuction ends bidding on item and returns the winning bid (no content if nobody has bid)
+//
+// @summary Close the auction of the item and return the winning bid
+// @tags Items
+// @response 200 {models.Bid} The winning bid
+// @response 204 The auction closed without bids
+// @response 400 The itemID is invalid
+// @response 403 The user does not sell the item and may not manage any items
+// @response 404 Item not found
+// @response 409 The auction is closed already
+func (e *ItemHandler) CloseAuction(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	bid, err := e.db.CloseAuction(item.ID)
+	if err != nil {
+		logging.LogError(AuctionCloseFailure, err)
+		WriteError(w, r, err)
+		return
+	}
+	if bid == nil {
+		WriteHTTPCode(w, http.StatusNoContent)
 		return
 	}
 	render.JSON(w, r, bid)
 }
 
+// RelistItem puts a copy of a closed item on auction again and returns the new item
+//
+// @summary List a copy of a closed item in a new auction
+// @tags Items
+// @body {relistPayload} The end of the new auction, optional
+// @response 201 {models.Item} The new item
+// @response 400 The closesAt is in the past
+// @response 403 The user does not sell the item and may not manage any items
+// @response 404 Item not found
+// @response 409 The auction of the item is open
+func (e *ItemHandler) RelistItem(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	payload := relistPayload{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			logging.LogError(RelistDecodeFailure, err)
+			WriteHTTPErrorCode(w, r, errors.New(RelistDecodeFailure), http.StatusBadRequest)
+			return
+		}
+	}
+	if payload.ClosesAt != nil && !payload.ClosesAt.After(time.Now()) {
+		WriteError(w, r, errClosesAtInPast)
+		return
+	}
+	relisted, err := e.db.RelistItem(item.ID, payload.ClosesAt)
+	var rejected *models.Error
+	if errors.As(err, &rejected) {
+		WriteError(w, r, err)
+		return
+	}
+	if err != nil {
+		logging.LogError(RelistFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(RelistFailure), http.StatusInternalServerError)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, relisted)
+}
+
+// GetOffers returns the second-chance offers made for the item
+//
+// @summary Get the second-chance offers made for an item
+// @tags Items, Offers
+// @response 200 {[]settlement.Offer} OK
+// @response 404 Item not found
+func (e *ItemHandler) GetOffers(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	render.JSON(w, r, e.ledger.OffersForItem(item.ID))
+}
+
+// OfferSecondChance offers a closed item, whose reserve price has not been met or whose buyer has failed to pay,
+// to the next-highest bidder
+//
+// @summary Offer an unsold item to the next-highest bidder
+// @tags Items, Offers
+// @response 201 {settlement.Offer} CREATED
+// @response 404 Item not found
+// @response 409 The auction is open, the item is sold or awaits payment, an offer is pending or no bidder is left
+func (e *ItemHandler) OfferSecondChance(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	offer, err := e.ledger.OfferSecondChance(item.ID)
+	if err != nil {
+		var known *models.Error
+		if !errors.As(err, &known) {
+			logging.LogError("Cannot make a second-chance offer", err)
+		}
+		WriteError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, offer)
+}
+
+// GetEvents streams bids on the item and its closing as server-sent events, resuming after Last-Event-ID
+//
+// @summary Stream bids on an item and its closing as server-sent events
+// @tags Items
+// @param header Last-Event-ID {integer} Resume after the event with this ID
+// @param query lastEventId {integer} Resume after the event with this ID (for clients that cannot set headers)
+// @response 200 {stream} OK
+// @response 400 The itemID or Last-Event-ID is invalid
+// @response 404 Item not found
+func (e *ItemHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	lastID, resume, err := ParseLastEventID(w, r)
+	if err != nil {
+		return
+	}
+	if !resume {
+		serveEvents(w, r, e.hub.Subscribe(item.ID), nil, true)
+		return
+	}
+	sub, missed, complete := e.hub.ResumeItem(item.ID, lastID)
+	serveEvents(w, r, sub, missed, complete)
+}
+
 func (e *ItemHandler) findItem(w http.ResponseWriter, r *http.Request) (*models.Item, error) {
 	itemID, err := ParseItemID(w, r)
 	if err != nil {
@@ -149,7 +495,7 @@ func (e *ItemHandler) findItem(w http.ResponseWriter, r *http.Request) (*models.
 	item, err := e.db.GetItem(itemID)
 	if err != nil {
 		logging.LogError("Cannot find item", err)
-		WriteHTTPErrorCode(w, err, http.StatusNotFound)
+		WriteError(w, r, err)
 		return nil, err
 	}
 	return item, nil
@@ -160,7 +506,7 @@ func ParseItemID(w http.ResponseWriter, r *http.Request) (uuid.UUID, error) {
 	itemID, err := uuid.FromString(chi.URLParam(r, "itemID"))
 	if err != nil {
 		logging.LogError("Error parsing URL parameter to UUID", err)
-		WriteHTTPErrorCode(w, errors.New("Malformed URL Parameter"), http.StatusBadRequest)
+		WriteHTTPErrorCode(w, r, errors.New("Malformed URL Parameter"), http.StatusBadRequest)
 		return uuid.FromStringOrNil(""), err
 	}
 	return itemID, nil
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+// define error messages
+const (
+	BidNotFound = "Bid not found"
+)
+
+//errBidNotFound is returned for bids that are not on the item of the request
+var errBidNotFound = models.NewError(models.KindNotFound, "bid_not_found", BidNotFound)
+
+//RoutesV2 returns the routes of API v2 for the ItemHandler - items and their bids as resources
+func (e *ItemHandler) RoutesV2() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(render.SetContentType(render.ContentTypeJSON))
+	read := authorize(e.policy, auth.PermissionReadItems, ItemAccountForbidden)
+	router.With(authorize(e.policy, auth.PermissionListItems, ItemListForbidden)).Get("/", e.GetItemsV2)
+	router.With(authorize(e.policy, auth.PermissionCreateItems, ItemCreationForbidden)).Post("/", e.CreateItemV2)
+	router.With(read).Get("/{itemID}", e.GetItemV2)
+	router.With(read).Get("/{itemID}/bids", e.GetBidsV2)
+	router.With(authorize(e.policy, auth.PermissionPlaceBids, BidForbidden), idempotent(e.idempotency)).Post("/{itemID}/bids", e.PlaceBidV2)
+	router.With(read).Get("/{itemID}/bids/{bidID}", e.GetBidV2)
+	return router
+}
+
+// GetItemsV2 returns a page of items, oldest first (only the ones matching the q query parameter, if given)
+//
+// @summary Get a page of items
+// @tags Items
+// @param query q {string} Return only items whose name contains every whitespace-separated term, ignoring case
+// @param query offset {integer} Number of items skipped, 0 by default
+// @param query limit {integer} Maximum number of items returned, 20 by default and at most 100
+// @response 200 {itemsEnvelope} OK
+// @response 400 The offset or limit is invalid
+func (e *ItemHandler) GetItemsV2(w http.ResponseWriter, r *http.Request) {
+	page, err := ParsePage(w, r)
+	if err != nil {
+		return
+	}
+	items, err := e.db.AllItems()
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	query := r.URL.Query().Get(QueryParamSearch)
+	matching := make([]*models.Item, 0, len(items))
+	for _, item := range items {
+		if query == "" || models.MatchesQuery(query, item.Name) {
+			matching = append(matching, item)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return createdBefore(matching[i].BaseModel, matching[j].BaseModel) })
+
+	start, end := page.bounds(len(matching))
+	data := make([]itemResource, 0, end-start)
+	for _, item := range matching[start:end] {
+		data = append(data, newItemResource(item))
+	}
+	render.JSON(w, r, itemsEnvelope{Data: data, Meta: page, Links: pageLinks(r, page)})
+}
+
+// CreateItemV2 creates new item (only admin or item owner) and returns it
+//
+// @summary Put an item on auction
+// @tags Items
+// @body {models.Item} A new item - sellerID defaults to the authenticated seller
+// @response 201 {itemEnvelope} CREATED, the Location header holds the URL of the item
+// @response 400 The item is invalid, closesAt is in the past or the seller is not a user of the tenant
+// @response 401 The request is not authenticated
+// @response 403 The user may not create items or sells on behalf of another user
+func (e *ItemHandler) CreateItemV2(w http.ResponseWriter, r *http.Request) {
+	item, err := e.createItem(w, r)
+	if err != nil {
+		return
+	}
+	resource := newItemResource(item)
+	writeCreated(w, r, resource.Links["self"], itemEnvelope{Data: resource, Links: Links{"self": resource.Links["self"]}})
+}
+
+// GetItemV2 returns an item
+//
+// @summary Get an item
+// @tags Items
+// @response 200 {itemEnvelope} OK
+// @response 400 The itemID is invalid
+// @response 404 Item not found
+func (e *ItemHandler) GetItemV2(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	resource := newItemResource(item)
+	render.JSON(w, r, itemEnvelope{Data: resource, Links: Links{"self": resource.Links["self"]}})
+}
+
+// GetBidsV2 returns a page of the bids on item with their status, in the order they have been placed
+//
+// @summary Get a page of the bids for an item
+// @tags Items, Bids
+// @param query offset {integer} Number of bids skipped, 0 by default
+// @param query limit {integer} Maximum number of bids returned, 20 by default and at most 100
+// @response 200 {bidsEnvelope} OK
+// @response 400 The itemID, offset or limit is invalid
+// @response 404 Item not found
+func (e *ItemHandler) GetBidsV2(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	page, err := ParsePage(w, r)
+	if err != nil {
+		return
+	}
+	bids := item.GetBids()
+	start, end := page.bounds(len(bids))
+	data := make([]bidResource, 0, end-start)
+	for _, bid := range bids[start:end] {
+		data = append(data, newBidResource(bid, item))
+	}
+	render.JSON(w, r, bidsEnvelope{Data: data, Meta: page, Links: pageLinks(r, page)})
+}
+
+// PlaceBidV2 places a bid of the authenticated user on item and returns it with its status
+//
+// @summary Place a bid of the authenticated user on the item
+// @tags Items, Bids
+// @param header Idempotency-Key {string} Unique key of the bid - retries with the same key and bid get the original response
+// @body {models.Bid} A new bid - userID may be omitted, the bidder is the authenticated user
+// @response 201 {bidEnvelope} CREATED, the Location header holds the URL of the bid
+// @response 400 The bid is malformed
+// @response 401 The request is not authenticated
+// @response 402 The bid would take the winning bids of the user above the credit limit and deposit
+// @response 403 The userID is not the authenticated user or the user is not a bidder
+// @response 404 Item not found
+// @response 409 The auction is closed or the Idempotency-Key has been used for a different bid
+// @response 422 The bid breaks the bidding rules of the tenant or the bidder is not a user of the tenant
+// @response 429 Too many bids - retry after Retry-After seconds
+func (e *ItemHandler) PlaceBidV2(w http.ResponseWriter, r *http.Request) {
+	item, bid, err := e.placeBid(w, r)
+	if err != nil {
+		return
+	}
+	resource := newBidResource(bid, item)
+	writeCreated(w, r, resource.Links["self"], bidEnvelope{Data: resource, Links: Links{"self": resource.Links["self"]}})
+}
+
+// GetBidV2 returns a bid on item with its status
+//
+// @summary Get a bid for an item
+// @tags Items, Bids
+// @response 200 {bidEnvelope} OK
+// @response 400 The itemID or bidID is invalid
+// @response 404 Item or bid not found
+func (e *ItemHandler) GetBidV2(w http.ResponseWriter, r *http.Request) {
+	item, err := e.findItem(w, r)
+	if err != nil {
+		return
+	}
+	bidID, err := uuid.FromString(chi.URLParam(r, "bidID"))
+	if err != nil {
+		logging.LogError("Error parsing URL parameter to UUID", err)
+		WriteHTTPErrorCode(w, r, errors.New("Malformed URL Parameter"), http.StatusBadRequest)
+		return
+	}
+	for _, bid := range item.GetBids() {
+		if bid.ID == bidID {
+			resource := newBidResource(bid, item)
+			render.JSON(w, r, bidEnvelope{Data: resource, Links: Links{"self": resource.Links["self"]}})
+			return
+		}
+	}
+	WriteError(w, r, errBidNotFound)
+}
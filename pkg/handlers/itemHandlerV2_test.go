@@ -0,0 +1,191 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gavv/httpexpect"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/ratelimit"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+// newV2Server serves the routes of SetupRoutes on db to an admin until stop is called
+func newV2Server(t *testing.T, db storage.Storage, admin *models.User) (stop func(), e *httpexpect.Expect) {
+	viper.Set("API_KEYS", testutils.APIKey(admin)+"="+admin.ID.String()+":admin")
+	s := srv.NewServer()
+	s.SetupRoutes(db)
+	viper.Set("API_KEYS", "")
+	server := httptest.NewServer(s.Mux())
+	e = httpexpect.New(t, server.URL).Builder(func(req *httpexpect.Request) {
+		req.WithHeader(auth.HeaderAPIKey, testutils.APIKey(admin))
+	})
+	return func() {
+		server.Close()
+		s.Shutdown()
+	}, e
+}
+
+func TestItemHandlerV2_CreateItem(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	admin := testutils.CreateTestUsers(db, 1)[0]
+	stop, e := newV2Server(t, db, admin)
+	defer stop()
+
+	created := e.POST("/api/v2/items").WithJSON(map[string]interface{}{"name": "Lamp", "sellerID": admin.ID}).
+		Expect().Status(http.StatusCreated)
+	data := created.JSON().Object().Value("data").Object()
+	data.ValueEqual("name", "Lamp").ValueEqual("status", "open")
+	id := data.Value("id").String().Raw()
+	location := "/api/v2/items/" + id
+	created.Header("Location").Equal(location)
+	data.Value("links").Object().
+		ValueEqual("self", location).
+		ValueEqual("bids", location+"/bids").
+		ValueEqual("seller", "/api/v2/users/"+admin.ID.String())
+
+	e.GET(location).Expect().Status(http.StatusOK).JSON().Object().
+		ValueEqual("links", map[string]string{"self": location}).
+		Path("$.data.name").Equal("Lamp")
+	e.GET("/api/v2/items/{id}", admin.ID).Expect().Status(http.StatusNotFound)
+	e.POST("/api/v2/items").WithJSON(map[string]interface{}{"name": "Lamp", "closesAt": "2000-01-01T00:00:00Z"}).
+		Expect().Status(http.StatusBadRequest).JSON(problem).Object().ValueEqual("code", "closes_at_in_past")
+
+	// v1 answers as before
+	e.POST("/api/v1/item").WithJSON(map[string]interface{}{"name": "Pen"}).
+		Expect().Status(http.StatusCreated).NoContent()
+}
+
+func TestItemHandlerV2_GetItems(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	admin := testutils.CreateTestUsers(db, 1)[0]
+	items := testutils.CreateTestItems(db, 5)
+	stop, e := newV2Server(t, db, admin)
+	defer stop()
+
+	page := e.GET("/api/v2/items").WithQuery("offset", 1).WithQuery("limit", 2).Expect().Status(http.StatusOK).JSON().Object()
+	page.ValueEqual("meta", handlers.Page{Offset: 1, Limit: 2, Total: 5})
+	page.Value("data").Array().Length().Equal(2)
+	page.Value("links").Object().
+		ValueEqual("self", "/api/v2/items?limit=2&offset=1").
+		ValueEqual("first", "/api/v2/items?limit=2&offset=0").
+		ValueEqual("prev", "/api/v2/items?limit=2&offset=0").
+		ValueEqual("next", "/api/v2/items?limit=2&offset=3").
+		ValueEqual("last", "/api/v2/items?limit=2&offset=4")
+
+	// pages are in the order the items have been created
+	var ids []string
+	for offset := 0; offset < len(items); offset += 2 {
+		for _, item := range e.GET("/api/v2/items").WithQuery("offset", offset).WithQuery("limit", 2).
+			Expect().Status(http.StatusOK).JSON().Path("$.data").Array().Iter() {
+			ids = append(ids, item.Object().Value("id").String().Raw())
+		}
+	}
+	assert.Len(t, ids, len(items))
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	assert.Len(t, seen, len(items), "every item is on exactly one page")
+
+	last := e.GET("/api/v2/items").WithQuery("offset", 4).Expect().Status(http.StatusOK).JSON().Object()
+	last.Value("links").Object().NotContainsKey("next").ContainsKey("prev")
+	last.ValueEqual("meta", handlers.Page{Offset: 4, Limit: handlers.DefaultPageLimit, Total: 5})
+	e.GET("/api/v2/items").WithQuery("offset", 10).Expect().Status(http.StatusOK).JSON().Path("$.data").Array().Empty()
+
+	// other query parameters are kept in the links
+	e.GET("/api/v2/items").WithQuery("q", "no such item").Expect().Status(http.StatusOK).JSON().Object().
+		ValueEqual("meta", handlers.Page{Limit: handlers.DefaultPageLimit}).
+		Path("$.links.self").String().Contains("q=no+such+item")
+
+	invalid := e.GET("/api/v2/items").WithQuery("offset", -1).WithQuery("limit", handlers.MaxPageLimit+1).
+		Expect().Status(http.StatusBadRequest).JSON(problem).Object()
+	invalid.ValueEqual("code", "invalid_page")
+	invalid.Value("errors").Array().Length().Equal(2)
+}
+
+func TestItemHandlerV2_Bids(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	bids, items, users := testutils.CreateTestBids(db, 1, []float64{10})
+	admin := users[0]
+	stop, e := newV2Server(t, db, admin)
+	defer stop()
+	item := items[0]
+	bidsURL := "/api/v2/items/" + item.ID.String() + "/bids"
+
+	placed := e.POST(bidsURL).WithJSON(map[string]interface{}{"amount": 20}).Expect().Status(http.StatusCreated)
+	data := placed.JSON().Object().Value("data").Object()
+	data.ValueEqual("status", models.BidStatusWinning).ValueEqual("amount", 20).ValueEqual("userID", admin.ID)
+	location := bidsURL + "/" + data.Value("id").String().Raw()
+	placed.Header("Location").Equal(location)
+	data.Value("links").Object().
+		ValueEqual("self", location).
+		ValueEqual("item", "/api/v2/items/"+item.ID.String()).
+		ValueEqual("user", "/api/v2/users/"+admin.ID.String())
+
+	list := e.GET(bidsURL).Expect().Status(http.StatusOK).JSON().Object()
+	list.ValueEqual("meta", handlers.Page{Limit: handlers.DefaultPageLimit, Total: 2})
+	list.Path("$.data[0].id").Equal(bids[0].ID)
+	list.Path("$.data[0].status").Equal(models.BidStatusOutbid)
+	list.Path("$.data[1].status").Equal(models.BidStatusWinning)
+
+	e.GET(location).Expect().Status(http.StatusOK).JSON().Path("$.data.status").Equal(models.BidStatusWinning)
+	e.GET(bidsURL+"/"+item.ID.String()).Expect().Status(http.StatusNotFound).JSON(problem).Object().ValueEqual("code", "bid_not_found")
+	e.GET(bidsURL + "/x").Expect().Status(http.StatusBadRequest)
+
+	// retries get the original response, Location included
+	retry := func() *httpexpect.Response {
+		return e.POST(bidsURL).WithHeader(handlers.HeaderIdempotencyKey, "bid-1").WithJSON(map[string]interface{}{"amount": 30}).Expect()
+	}
+	first := retry().Status(http.StatusCreated)
+	retry().Status(http.StatusCreated).Header("Location").Equal(first.Header("Location").Raw())
+
+	e.POST("/api/v1/item/{id}/close", item.ID).Expect().Status(http.StatusOK)
+	e.GET(location).Expect().Status(http.StatusOK).JSON().Path("$.data.status").Equal(models.BidStatusLost)
+	e.GET(first.Header("Location").Raw()).Expect().Status(http.StatusOK).JSON().Path("$.data.status").Equal(models.BidStatusWon)
+	e.GET("/api/v2/items/{id}", item.ID).Expect().Status(http.StatusOK).JSON().Path("$.data.status").Equal("closed")
+
+	// v1 bids have no status
+	e.GET("/api/v1/item/{id}/bids", item.ID).Expect().Status(http.StatusOK).JSON().Array().First().Object().NotContainsKey("status")
+}
+
+func TestItemHandlerV2_LimitsAndValidation(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	admin := testutils.CreateTestUsers(db, 1)[0]
+	item := testutils.CreateTestItems(db, 1)[0]
+	viper.Set("RATE_LIMITS", "POST /item/{itemID}/bids|/items/{itemID}/bids=1/m:2")
+	defer viper.Set("RATE_LIMITS", config.DefaultRateLimits)
+	stop, e := newV2Server(t, db, admin)
+	defer stop()
+
+	// requests to v2 are validated against the v2 document
+	e.POST("/api/v2/items/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": "ten"}).
+		Expect().Status(http.StatusBadRequest).JSON(problem).Object().
+		ValueEqual("code", "invalid_request").Value("errors").Array().First().Object().ValueEqual("field", "body.amount")
+	e.GET("/api/v2/items").WithQuery("limit", "many").Expect().Status(http.StatusBadRequest).
+		JSON(problem).Object().ValueEqual("code", "invalid_request")
+
+	// bids share one limit in v1 and v2 - the invalid bid above has taken a token already
+	e.POST("/api/v1/item/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": 2}).
+		Expect().Status(http.StatusCreated).Header(srv.HeaderRateLimitRemaining).Equal("0")
+	e.POST("/api/v2/items/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": 3}).
+		Expect().Status(http.StatusTooManyRequests)
+	e.POST("/api/v1/item/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": 4}).
+		Expect().Status(http.StatusTooManyRequests)
+
+	rules, err := ratelimit.ParseRules(config.DefaultRateLimits)
+	assert.NoError(t, err)
+	v1, _ := ratelimit.NewLimits(rules).Match(http.MethodPost, "/item/"+item.ID.String()+"/bids")
+	assert.NotNil(t, v1, "Bids are limited by default")
+	v2, _ := ratelimit.NewLimits(rules).Match(http.MethodPost, "/items/"+item.ID.String()+"/bids")
+	assert.NotNil(t, v2, "Bids are limited in v2 by default")
+}
@@ -1,19 +1,26 @@
 package handlers_test
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
 	"github.com/vikin91/bid-tracker-go/pkg/config"
 	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/idempotency"
 	"github.com/vikin91/bid-tracker-go/pkg/models"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
 	"github.com/vikin91/bid-tracker-go/pkg/storage"
 )
 
@@ -75,6 +82,30 @@ func TestItemHandler_CreateItem(t *testing.T) {
 		Status(http.StatusOK).JSON().Array().NotEmpty()
 }
 
+func TestItemHandler_CreateItem_IgnoresID(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(db, 1)
+	users := testutils.CreateTestUsers(db, 1)
+	assert.NoError(t, db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10)))
+	handler := handlers.NewItemHandler(db)
+
+	server := httptest.NewServer(handler.Routes())
+	defer server.Close()
+
+	e := httpexpect.New(t, server.URL)
+
+	e.POST("/").WithJSON(map[string]interface{}{"id": items[0].ID, "name": "Not a pen"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	e.GET("/").
+		Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(2)
+	e.GET(fmt.Sprintf("/%s/bids", items[0].ID)).
+		Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(1)
+}
+
 func TestItemHandler_GetBids(t *testing.T) {
 	db := storage.NewMapBiddingSystem()
 	numItems := 3
@@ -97,8 +128,10 @@ func TestItemHandler_PlaceBid(t *testing.T) {
 	items := testutils.CreateTestItems(db, 1)
 	users := testutils.CreateTestUsers(db, 1)
 	handler := handlers.NewItemHandler(db)
+	ghost := models.NewUser("Ghost")
+	authenticator := testutils.NewTestAuthenticator(append(users, ghost)...)
 
-	server := httptest.NewServer(handler.Routes())
+	server := httptest.NewServer(srv.Authenticate(authenticator)(handler.Routes()))
 	defer server.Close()
 
 	e := httpexpect.New(t, server.URL)
@@ -113,6 +146,7 @@ func TestItemHandler_PlaceBid(t *testing.T) {
 		Status(http.StatusOK).JSON().Array().Empty()
 
 	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
 		WithJSON(bid).
 		Expect().
 		Status(http.StatusCreated).NoContent()
@@ -122,6 +156,7 @@ func TestItemHandler_PlaceBid(t *testing.T) {
 		Status(http.StatusOK).JSON().Array().NotEmpty().Contains(bidAfterSaving)
 
 	e.POST(fmt.Sprintf("/%s/bids", "xxx-trash")).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
 		WithJSON(bid).
 		Expect().
 		Status(http.StatusBadRequest).Body().Contains("Malformed URL Parameter")
@@ -130,21 +165,48 @@ func TestItemHandler_PlaceBid(t *testing.T) {
 		"money": 1000.000,
 	}
 	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
 		WithJSON(fakeBid).
 		Expect().
 		Body().Contains(handlers.BidDecodeFailure)
 
 	//place bid on non existing item
 	e.POST(fmt.Sprintf("/%s/bids", config.ZeroUUID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
 		WithJSON(bid).
 		Expect().
 		Status(http.StatusNotFound)
 
-	bidFakeUser := models.NewBid(config.ZeroUUID, config.ZeroUUID, 99.55)
+	bidFakeUser := models.NewBid(config.ZeroUUID, ghost.ID, 99.55)
+	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(ghost)).
+		WithJSON(bidFakeUser).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON(problem).Object().
+		ValueEqual("code", "unknown_bidder").ValueEqual("detail", handlers.UnknownUserBids).
+		ValueEqual("errors", []map[string]string{{"field": "userID", "message": "must be a registered user"}})
+
+	//the bidder is the authenticated user
+	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		WithJSON(map[string]interface{}{"amount": 100}).
+		Expect().
+		Status(http.StatusCreated)
 	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
 		WithJSON(bidFakeUser).
 		Expect().
-		Status(http.StatusInternalServerError).Body().Contains(handlers.UnknownUserBids)
+		Status(http.StatusForbidden).Body().Contains(handlers.BidForAnotherUser)
+	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, "").
+		WithJSON(bid).
+		Expect().
+		Status(http.StatusUnauthorized)
+	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, "wrong").
+		WithJSON(bid).
+		Expect().
+		Status(http.StatusUnauthorized)
 }
 
 func TestItemHandler_GetWinner(t *testing.T) {
@@ -175,3 +237,183 @@ func TestItemHandler_GetWinner(t *testing.T) {
 		Expect().
 		Status(http.StatusOK).JSON().Object().Equal(bid2)
 }
+
+func TestItemHandler_CloseAuction(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(db, 2)
+	users := testutils.CreateTestUsers(db, 1)
+	handler := handlers.NewItemHandler(db)
+
+	server := httptest.NewServer(srv.Authenticate(testutils.NewTestAuthenticator(users...))(handler.Routes()))
+	defer server.Close()
+
+	e := httpexpect.New(t, server.URL)
+
+	bid := models.NewBid(items[0].ID, users[0].ID, 10.1)
+	db.PlaceBid(bid)
+
+	e.POST(fmt.Sprintf("/%s/close", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		Expect().
+		Status(http.StatusOK).JSON().Object().Equal(bid)
+
+	e.POST(fmt.Sprintf("/%s/close", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		Expect().
+		Status(http.StatusConflict)
+
+	e.POST(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		WithJSON(models.NewBid(config.ZeroUUID, users[0].ID, 20.0)).
+		Expect().
+		Status(http.StatusConflict).JSON(problem).Object().ValueEqual("code", "auction_closed")
+
+	e.POST(fmt.Sprintf("/%s/close", items[1].ID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		Expect().
+		Status(http.StatusNoContent)
+
+	e.POST(fmt.Sprintf("/%s/close", config.ZeroUUID.String())).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0])).
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestItemHandler_AsOf(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(db, 1)
+	users := testutils.CreateTestUsers(db, 2)
+	handler := handlers.NewItemHandler(db)
+
+	server := httptest.NewServer(handler.Routes())
+	defer server.Close()
+
+	e := httpexpect.New(t, server.URL)
+
+	beforeBids := time.Now()
+	time.Sleep(time.Millisecond)
+	first := models.NewBid(items[0].ID, users[0].ID, 10.0)
+	db.PlaceBid(first)
+	time.Sleep(time.Millisecond)
+	afterFirst := time.Now()
+	time.Sleep(time.Millisecond)
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20.0))
+
+	e.GET(fmt.Sprintf("/%s/winner", items[0].ID.String())).
+		WithQuery(handlers.QueryParamAsOf, afterFirst.Format(config.DateLayout)).
+		Expect().
+		Status(http.StatusOK).JSON().Object().Equal(first)
+
+	e.GET(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithQuery(handlers.QueryParamAsOf, afterFirst.Format(config.DateLayout)).
+		Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(1)
+
+	e.GET(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(2)
+
+	e.GET(fmt.Sprintf("/%s/winner", items[0].ID.String())).
+		WithQuery(handlers.QueryParamAsOf, beforeBids.Format(config.DateLayout)).
+		Expect().
+		Status(http.StatusNotFound).JSON(problem).Object().ValueEqual("code", "no_bids")
+
+	e.GET(fmt.Sprintf("/%s/bids", items[0].ID.String())).
+		WithQuery(handlers.QueryParamAsOf, "yesterday").
+		Expect().
+		Status(http.StatusBadRequest).Body().Contains("Malformed asOf Parameter")
+}
+
+func TestItemHandler_PlaceBidIdempotent(t *testing.T) {
+	db := &failingStorage{MapBiddingSystem: storage.NewMapBiddingSystem().WithRules(models.Rules{MinIncrement: 1})}
+	items := testutils.CreateTestItems(db, 1)
+	users := testutils.CreateTestUsers(db, 2)
+	ghost := models.NewUser("Ghost")
+	handler := handlers.NewItemHandler(db).WithIdempotency(idempotency.NewStore(time.Hour))
+	server := httptest.NewServer(srv.Authenticate(testutils.NewTestAuthenticator(append(users, ghost)...))(handler.Routes()))
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+	bid := func(user *models.User, key string, amount float64) *httpexpect.Response {
+		return e.POST("/{itemID}/bids", items[0].ID).
+			WithHeader(auth.HeaderAPIKey, testutils.APIKey(user)).
+			WithHeader(handlers.HeaderIdempotencyKey, key).
+			WithJSON(map[string]interface{}{"amount": amount}).
+			Expect()
+	}
+	numBids := func() int {
+		bids, err := db.AllBids()
+		require.NoError(t, err)
+		return len(bids)
+	}
+
+	bid(users[0], "first", 10).Status(http.StatusCreated).Header(handlers.HeaderIdempotentReplayed).Empty()
+	bid(users[0], "first", 10).Status(http.StatusCreated).Header(handlers.HeaderIdempotentReplayed).Equal("true")
+	assert.Equal(t, 1, numBids(), "Retries do not place the bid again")
+	bid(users[0], "first", 11).Status(http.StatusConflict).Body().Contains(handlers.IdempotencyKeyReused)
+	bid(users[1], "first", 11).Status(http.StatusCreated).Header(handlers.HeaderIdempotentReplayed).Empty()
+	bid(users[0], "second", 5).Status(http.StatusUnprocessableEntity)
+	bid(users[0], "second", 5).Status(http.StatusUnprocessableEntity).Header(handlers.HeaderIdempotentReplayed).Equal("true")
+	bid(ghost, "third", 20).Status(http.StatusUnprocessableEntity)
+	bid(ghost, "third", 20).Status(http.StatusUnprocessableEntity).Header(handlers.HeaderIdempotentReplayed).Equal("true")
+	db.failures = 1
+	bid(users[0], "fourth", 30).Status(http.StatusInternalServerError)
+	bid(users[0], "fourth", 30).Status(http.StatusCreated).Header(handlers.HeaderIdempotentReplayed).Empty()
+	assert.Equal(t, 3, numBids(), "Server errors are not stored")
+
+	// concurrent duplicates place a single bid
+	statuses := make(chan int, 10)
+	for i := 0; i < cap(statuses); i++ {
+		go func() {
+			req, _ := http.NewRequest("POST", fmt.Sprintf("%s/%s/bids", server.URL, items[0].ID), strings.NewReader(`{"amount": 50}`))
+			req.Header.Set(auth.HeaderAPIKey, testutils.APIKey(users[0]))
+			req.Header.Set(handlers.HeaderIdempotencyKey, "concurrent")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	for i := 0; i < cap(statuses); i++ {
+		assert.Equal(t, http.StatusCreated, <-statuses)
+	}
+	assert.Equal(t, 4, numBids())
+}
+
+//failingStorage fails to place the next bids
+type failingStorage struct {
+	*storage.MapBiddingSystem
+	failures int
+}
+
+func (f *failingStorage) PlaceBid(bid *models.Bid) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("Storage unavailable")
+	}
+	return f.MapBiddingSystem.PlaceBid(bid)
+}
+
+func Benchmark_ItemHandler_PlaceBid(b *testing.B) {
+	db := storage.NewMapBiddingSystem()
+	user := testutils.CreateTestUsers(db, 1)[0]
+	item := testutils.CreateTestItems(db, 1)[0]
+	router := chi.NewRouter()
+	router.Use(srv.Authenticate(testutils.NewTestAuthenticator(user)))
+	router.Mount("/item", handlers.NewItemHandler(db).Routes())
+	url := "/item/" + item.ID.String() + "/bids"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(fmt.Sprintf(`{"amount": %d}`, n+1)))
+		r.Header.Set(auth.HeaderAPIKey, testutils.APIKey(user))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			b.Fatalf("Unexpected status %d", w.Code)
+		}
+	}
+}
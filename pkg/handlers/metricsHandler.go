@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/metrics"
+)
+
+// define error messages
+const (
+	MetricsForbidden = "Not allowed to get Metrics"
+)
+
+//NewMetricsHandler initializes a new handler
+func NewMetricsHandler(counters *metrics.Counters) *MetricsHandler {
+	return &MetricsHandler{counters: counters}
+}
+
+//MetricsHandler is the handler exposing the activity counters
+type MetricsHandler struct {
+	counters *metrics.Counters
+	policy   *auth.Policy
+}
+
+//WithPolicy authorizes requests by policy: only admins read the metrics
+func (e *MetricsHandler) WithPolicy(policy *auth.Policy) *MetricsHandler {
+	e.policy = policy
+	return e
+}
+
+//Routes returns the routes for the MetricsHandler
+func (e *MetricsHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(render.SetContentType(render.ContentTypeJSON))
+	router.With(authorize(e.policy, auth.PermissionReadMetrics, MetricsForbidden)).Get("/", e.GetMetrics)
+	return router
+}
+
+// GetMetrics returns the current values of the counters
+//
+// @summary Get counters of the events of the auction house
+// @tags Metrics
+// @response 200 {metrics.Snapshot} OK
+// @response 401 No credentials
+// @response 403 Not allowed to get metrics
+func (e *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, e.counters.Snapshot())
+}
@@ -0,0 +1,33 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gavv/httpexpect"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/metrics"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestMetricsHandler_GetMetrics(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	counters := metrics.NewCounters()
+	db.Subscribe(counters)
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 12.5))
+
+	server := httptest.NewServer(handlers.NewMetricsHandler(counters).Routes())
+	defer server.Close()
+
+	obj := httpexpect.New(t, server.URL).GET("/").Expect().Status(http.StatusOK).JSON().Object()
+	obj.ValueEqual("itemsListed", 1)
+	obj.ValueEqual("usersRegistered", 1)
+	obj.ValueEqual("bidsPlaced", 1)
+	obj.ValueEqual("bidVolume", 12.5)
+}
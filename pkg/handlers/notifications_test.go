@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gavv/httpexpect"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/notifications"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestUserHandler_GetNotifications(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	notifier := notifications.NewNotifier(db, nil)
+	db.Subscribe(notifier)
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 2)
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[1].ID, users[1].ID, 20))
+
+	server := httptest.NewServer(handlers.NewUserHandler(db).WithNotifications(notifier).Routes())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	resp := e.GET("/{userID}/notifications", users[0].ID).Expect().Status(http.StatusOK)
+	resp.Header(handlers.HeaderUnreadCount).Equal("2")
+	list := resp.JSON().Array()
+	list.Length().Equal(2)
+	first := list.Element(0).Object()
+	first.ValueEqual("kind", "outbid").ValueEqual("itemID", items[1].ID).ValueEqual("read", false)
+	id := first.Value("id").String().Raw()
+
+	e.POST("/{userID}/notifications/{notificationID}/read", users[0].ID, id).Expect().Status(http.StatusNoContent)
+	e.GET("/{userID}/notifications", users[0].ID).WithQuery("unread", "true").
+		Expect().Status(http.StatusOK).Header(handlers.HeaderUnreadCount).Equal("1")
+	e.GET("/{userID}/notifications", users[0].ID).WithQuery("unread", "true").
+		Expect().JSON().Array().Length().Equal(1)
+
+	e.POST("/{userID}/notifications/{notificationID}/unread", users[0].ID, id).Expect().Status(http.StatusNoContent)
+	e.POST("/{userID}/notifications/read", users[0].ID).Expect().Status(http.StatusNoContent)
+	e.GET("/{userID}/notifications", users[0].ID).WithQuery("unread", "true").
+		Expect().JSON().Array().Empty()
+
+	e.POST("/{userID}/notifications/{notificationID}/read", users[1].ID, id).Expect().Status(http.StatusNotFound)
+	e.GET("/{userID}/notifications", uuid.NewV4()).Expect().Status(http.StatusNotFound)
+	e.GET("/{userID}/notifications", users[0].ID).WithQuery("unread", "maybe").Expect().Status(http.StatusBadRequest)
+}
+
+func TestUserHandler_NotificationPreferences(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	notifier := notifications.NewNotifier(db, nil)
+	users := testutils.CreateTestUsers(db, 1)
+
+	server := httptest.NewServer(handlers.NewUserHandler(db).WithNotifications(notifier).Routes())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	e.GET("/{userID}/notifications/preferences", users[0].ID).Expect().Status(http.StatusOK).
+		JSON().Object().Value("channels").Array().Elements("inbox")
+
+	e.PUT("/{userID}/notifications/preferences", users[0].ID).
+		WithJSON(map[string]interface{}{"channels": []string{"email", "webhook"}, "email": "bond@example.com", "webhookURL": "https://example.com/n"}).
+		Expect().Status(http.StatusOK).JSON().Object().Value("webhookSecret").String().NotEmpty()
+	e.GET("/{userID}/notifications/preferences", users[0].ID).Expect().Status(http.StatusOK).
+		JSON().Object().ValueEqual("email", "bond@example.com").Value("channels").Array().Elements("email", "webhook")
+
+	e.PUT("/{userID}/notifications/preferences", users[0].ID).WithJSON(map[string]interface{}{"channels": []string{"email"}}).
+		Expect().Status(http.StatusBadRequest)
+	e.PUT("/{userID}/notifications/preferences", users[0].ID).WithText("{").Expect().Status(http.StatusBadRequest)
+}
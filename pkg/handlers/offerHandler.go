@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+)
+
+// define error messages
+const (
+	OfferGetForbidden    = "Not allowed to get Offer"
+	OfferAnswerForbidden = "Not allowed to answer Offer"
+)
+
+//NewOfferHandler initializes a new handler
+func NewOfferHandler(ledger *settlement.Ledger) *OfferHandler {
+	return &OfferHandler{ledger: ledger}
+}
+
+//OfferHandler is the handler responsible for second-chance offers
+type OfferHandler struct {
+	ledger *settlement.Ledger
+	policy *auth.Policy
+}
+
+//WithPolicy authorizes requests by policy: bidders read and answer the offers made to them, admins all of them
+func (e *OfferHandler) WithPolicy(policy *auth.Policy) *OfferHandler {
+	e.policy = policy
+	return e
+}
+
+//Routes returns the routes for the OfferHandler
+func (e *OfferHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(render.SetContentType(render.ContentTypeJSON))
+	read := authorizeOwner(e.policy, e.offeree, auth.PermissionReadOwnOffers, auth.PermissionReadAnyOffers, OfferGetForbidden)
+	answer := authorizeOwner(e.policy, e.offeree, auth.PermissionAnswerOwnOffers, auth.PermissionAnswerAnyOffers, OfferAnswerForbidden)
+	router.With(read).Get("/{offerID}", e.GetOffer)
+	router.With(answer).Post("/{offerID}/accept", e.AcceptOffer)
+	router.With(answer).Post("/{offerID}/decline", e.DeclineOffer)
+	return router
+}
+
+//offeree is the ownerFunc of offers: the user the item is offered to
+func (e *OfferHandler) offeree(r *http.Request) (uuid.UUID, bool) {
+	offerID, err := uuid.FromString(chi.URLParam(r, "offerID"))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	offer, err := e.ledger.GetOffer(offerID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return offer.UserID, true
+}
+
+// GetOffer returns a second-chance offer
+//
+// @summary Get a second-chance offer
+// @tags Offers
+// @response 200 {settlement.Offer} OK
+// @response 401 No credentials
+// @response 403 Not an offer to the user
+// @response 404 Offer not found
+func (e *OfferHandler) GetOffer(w http.ResponseWriter, r *http.Request) {
+	offerID, err := parseUUIDParam(w, r, "offerID")
+	if err != nil {
+		return
+	}
+	offer, err := e.ledger.GetOffer(offerID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	render.JSON(w, r, offer)
+}
+
+// AcceptOffer accepts a pending offer and returns the invoice issued for it
+//
+// @summary Accept a pending offer and get the invoice issued for it
+// @tags Offers
+// @response 200 {settlement.Invoice} OK
+// @response 401 No credentials
+// @response 403 Not an offer to the user
+// @response 404 Offer not found
+// @response 409 The offer is no longer pending or the item has been paid for
+func (e *OfferHandler) AcceptOffer(w http.ResponseWriter, r *http.Request) {
+	offerID, err := parseUUIDParam(w, r, "offerID")
+	if err != nil {
+		return
+	}
+	_, invoice, err := e.ledger.AcceptOffer(offerID)
+	if err != nil {
+		writeOfferError(w, r, err)
+		return
+	}
+	render.JSON(w, r, invoice)
+}
+
+// DeclineOffer declines a pending offer
+//
+// @summary Decline a pending offer
+// @tags Offers
+// @response 200 {settlement.Offer} OK
+// @response 401 No credentials
+// @response 403 Not an offer to the user
+// @response 404 Offer not found
+// @response 409 The offer is no longer pending
+func (e *OfferHandler) DeclineOffer(w http.ResponseWriter, r *http.Request) {
+	offerID, err := parseUUIDParam(w, r, "offerID")
+	if err != nil {
+		return
+	}
+	offer, err := e.ledger.DeclineOffer(offerID)
+	if err != nil {
+		writeOfferError(w, r, err)
+		return
+	}
+	render.JSON(w, r, offer)
+}
+
+func writeOfferError(w http.ResponseWriter, r *http.Request, err error) {
+	var known *models.Error
+	if !errors.As(err, &known) {
+		logging.LogError("Cannot answer offer", err)
+	}
+	WriteError(w, r, err)
+}
@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestOfferHandler_SecondChance(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	ledger := settlement.NewLedger(db, settlement.Fees{}, time.Hour)
+	db.Subscribe(ledger, events.Only(events.TypeAuctionClosed))
+	users := testutils.CreateTestUsers(db, 2)
+	item := models.NewItem("A painting")
+	item.ReservePrice = 100
+	require.NoError(t, db.CreateItem(item))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[0].ID, 50)))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[1].ID, 80)))
+
+	router := chi.NewRouter()
+	router.Mount("/item", handlers.NewItemHandler(db).WithSettlement(ledger).Routes())
+	router.Mount("/user", handlers.NewUserHandler(db).WithInvoices(ledger).Routes())
+	router.Mount("/offers", handlers.NewOfferHandler(ledger).Routes())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	e.POST("/item/{itemID}/offers", item.ID).Expect().Status(http.StatusConflict).
+		JSON(problem).Object().ValueEqual("code", "auction_open")
+	e.POST("/item/{itemID}/close", item.ID).Expect().Status(http.StatusNoContent)
+
+	offer := e.POST("/item/{itemID}/offers", item.ID).Expect().Status(http.StatusCreated).JSON().Object()
+	offer.ValueEqual("userID", users[1].ID).ValueEqual("amount", 80).
+		ValueEqual("reason", "reserve-not-met").ValueEqual("status", "pending")
+	first := offer.Value("id").String().Raw()
+	e.POST("/item/{itemID}/offers", item.ID).Expect().Status(http.StatusConflict).
+		JSON(problem).Object().ValueEqual("code", "offer_pending")
+
+	e.POST("/offers/{offerID}/decline", first).Expect().Status(http.StatusOK).JSON().Object().ValueEqual("status", "declined")
+	e.POST("/offers/{offerID}/accept", first).Expect().Status(http.StatusConflict).
+		JSON(problem).Object().ValueEqual("code", "offer_closed")
+
+	second := e.POST("/item/{itemID}/offers", item.ID).Expect().Status(http.StatusCreated).
+		JSON().Object().ValueEqual("userID", users[0].ID).Value("id").String().Raw()
+	e.GET("/user/{userID}/offers", users[0].ID).Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.POST("/offers/{offerID}/accept", second).Expect().Status(http.StatusOK).
+		JSON().Object().ValueEqual("buyerID", users[0].ID).ValueEqual("hammerPrice", 50)
+	e.GET("/offers/{offerID}", second).Expect().Status(http.StatusOK).JSON().Object().ValueEqual("status", "accepted")
+
+	e.GET("/item/{itemID}/offers", item.ID).Expect().Status(http.StatusOK).JSON().Array().Length().Equal(2)
+	e.POST("/item/{itemID}/offers", item.ID).Expect().Status(http.StatusConflict).
+		JSON(problem).Object().ValueEqual("code", "no_second_chance")
+	e.GET("/offers/{offerID}", uuid.NewV4()).Expect().Status(http.StatusNotFound).
+		JSON(problem).Object().ValueEqual("code", "offer_not_found")
+	e.POST("/offers/{offerID}/accept", uuid.NewV4()).Expect().Status(http.StatusNotFound).
+		JSON(problem).Object().ValueEqual("code", "offer_not_found")
+	e.POST("/item/{itemID}/offers", uuid.NewV4()).Expect().Status(http.StatusNotFound)
+}
+
+func TestItemHandler_RelistItem(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(db, 1)
+
+	server := httptest.NewServer(handlers.NewItemHandler(db).Routes())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	e.POST("/{itemID}/relist", items[0].ID).Expect().Status(http.StatusConflict)
+	e.POST("/{itemID}/close", items[0].ID).Expect().Status(http.StatusNoContent)
+	e.POST("/{itemID}/relist", items[0].ID).WithJSON(map[string]interface{}{"closesAt": time.Now().Add(-time.Hour)}).
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.ClosesAtInPast)
+	e.POST("/{itemID}/relist", items[0].ID).WithText("{").Expect().Status(http.StatusBadRequest)
+
+	relisted := e.POST("/{itemID}/relist", items[0].ID).Expect().Status(http.StatusCreated).JSON().Object()
+	relisted.ValueEqual("name", items[0].Name).ValueEqual("relistedFrom", items[0].ID).ValueNotEqual("id", items[0].ID)
+	e.GET("/{itemID}/bids", relisted.Value("id").String().Raw()).Expect().Status(http.StatusOK).JSON().Array().Empty()
+	e.POST("/{itemID}/relist", uuid.NewV4()).Expect().Status(http.StatusNotFound)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+)
+
+//QueryParamAsOf is the name of the query parameter selecting a historical state
+const QueryParamAsOf = "asOf"
+
+// ParseAsOf parses the optional asOf query parameter (config.DateLayout) and sends the HTTPError Response on failure.
+// It returns a zero time if the parameter is not present.
+func ParseAsOf(w http.ResponseWriter, r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get(QueryParamAsOf)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	asOf, err := time.Parse(config.DateLayout, value)
+	if err != nil {
+		logging.LogError("Error parsing asOf query parameter", err)
+		WriteHTTPErrorCode(w, r, errors.New("Malformed asOf Parameter"), http.StatusBadRequest)
+		return time.Time{}, err
+	}
+	return asOf, nil
+}
@@ -0,0 +1,61 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/ratelimit"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestRateLimit(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	users := testutils.CreateTestUsers(db, 2)
+	item := testutils.CreateTestItems(db, 1)[0]
+	rules, err := ratelimit.ParseRules("POST /item/{itemID}/bids=1/m:2,GET /item=2/s")
+	require.NoError(t, err)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	limits := ratelimit.NewLimits(rules).WithClock(func() time.Time { return now })
+
+	router := chi.NewRouter()
+	router.Use(srv.Authenticate(testutils.NewTestAuthenticator(users...)), srv.RateLimit(limits))
+	router.Mount("/item", handlers.NewItemHandler(db).Routes())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+	bid := func(amount float64) *httpexpect.Request {
+		return e.POST("/item/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": amount}).
+			WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[0]))
+	}
+
+	// a burst of two bids, then one bid a minute
+	bid(1).Expect().Status(http.StatusCreated).
+		Header(srv.HeaderRateLimitLimit).Equal("2")
+	bid(2).Expect().Status(http.StatusCreated).
+		Header(srv.HeaderRateLimitRemaining).Equal("0")
+	limited := bid(3).Expect()
+	limited.Status(http.StatusTooManyRequests).Body().Contains(ratelimit.ErrLimited.Error())
+	limited.Header(srv.HeaderRetryAfter).Equal("60")
+	limited.Header(srv.HeaderRateLimitReset).Equal("120")
+	e.POST("/item/{itemID}/bids", item.ID).WithJSON(map[string]interface{}{"amount": 3}).
+		WithHeader(auth.HeaderAPIKey, testutils.APIKey(users[1])).
+		Expect().Status(http.StatusCreated)
+	now = now.Add(time.Minute)
+	bid(4).Expect().Status(http.StatusCreated)
+
+	// anonymous clients are limited by their IP address, routes without rules are not limited
+	e.GET("/item").Expect().Status(http.StatusOK).Header(srv.HeaderRateLimitRemaining).Equal("1")
+	e.GET("/item").Expect().Status(http.StatusOK)
+	e.GET("/item").Expect().Status(http.StatusTooManyRequests).Header(srv.HeaderRetryAfter).Equal("1")
+	e.GET("/item/{itemID}/bids", item.ID).Expect().Status(http.StatusOK).Header(srv.HeaderRateLimitLimit).Empty()
+}
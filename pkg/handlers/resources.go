@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/render"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+// Responses of API v2 are envelopes: the resource (or a page of resources) is the data, next to the links of the
+// response. Every resource carries the links to itself and the resources related to it.
+
+// define the query parameters paging collections
+const (
+	//QueryParamOffset is the number of resources of a collection skipped before the page
+	QueryParamOffset = "offset"
+	//QueryParamLimit is the maximum number of resources on a page
+	QueryParamLimit = "limit"
+	//DefaultPageLimit is the number of resources on a page if no limit is given
+	DefaultPageLimit = 20
+	//MaxPageLimit is the highest limit accepted
+	MaxPageLimit = 100
+)
+
+// define error messages
+const (
+	InvalidPage = "Invalid offset or limit"
+)
+
+//Links of a resource or a response by relation, e.g., "self" or "next"
+type Links map[string]string
+
+//Page describes the part of a collection that a response holds
+type Page struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	//Total is the number of resources in the collection
+	Total int `json:"total"`
+}
+
+type itemResource struct {
+	*models.Item
+	//Status is open or closed
+	Status string `json:"status"`
+	Links  Links  `json:"links"`
+}
+
+type bidResource struct {
+	*models.Bid
+	Status models.BidStatus `json:"status"`
+	Links  Links            `json:"links"`
+}
+
+type userResource struct {
+	*models.User
+	Links Links `json:"links"`
+}
+
+type itemEnvelope struct {
+	Data  itemResource `json:"data"`
+	Links Links        `json:"links"`
+}
+
+type itemsEnvelope struct {
+	Data  []itemResource `json:"data"`
+	Meta  Page           `json:"meta"`
+	Links Links          `json:"links"`
+}
+
+type bidEnvelope struct {
+	Data  bidResource `json:"data"`
+	Links Links       `json:"links"`
+}
+
+type bidsEnvelope struct {
+	Data  []bidResource `json:"data"`
+	Meta  Page          `json:"meta"`
+	Links Links         `json:"links"`
+}
+
+type userEnvelope struct {
+	Data  userResource `json:"data"`
+	Links Links        `json:"links"`
+}
+
+type usersEnvelope struct {
+	Data  []userResource `json:"data"`
+	Meta  Page           `json:"meta"`
+	Links Links          `json:"links"`
+}
+
+func itemURL(itemID uuid.UUID) string {
+	return config.APIPrefixV2 + "/items/" + itemID.String()
+}
+
+func bidURL(bid *models.Bid) string {
+	return itemURL(bid.ItemID) + "/bids/" + bid.ID.String()
+}
+
+func userURL(userID uuid.UUID) string {
+	return config.APIPrefixV2 + "/users/" + userID.String()
+}
+
+func newItemResource(item *models.Item) itemResource {
+	status := "open"
+	if item.IsClosed() {
+		status = "closed"
+	}
+	links := Links{"self": itemURL(item.ID), "bids": itemURL(item.ID) + "/bids"}
+	if item.SellerID != config.ZeroUUID {
+		links["seller"] = userURL(item.SellerID)
+	}
+	if item.RelistedFrom != config.ZeroUUID {
+		links["relistedFrom"] = itemURL(item.RelistedFrom)
+	}
+	return itemResource{Item: item, Status: status, Links: links}
+}
+
+//newBidResource describes bid with its standing in the auction of item
+func newBidResource(bid *models.Bid, item *models.Item) bidResource {
+	return bidResource{Bid: bid, Status: item.BidStatus(bid), Links: Links{
+		"self": bidURL(bid),
+		"item": itemURL(bid.ItemID),
+		"user": userURL(bid.UserID),
+	}}
+}
+
+func newUserResource(user *models.User) userResource {
+	return userResource{User: user, Links: Links{
+		"self":  userURL(user.ID),
+		"bids":  userURL(user.ID) + "/bids",
+		"items": userURL(user.ID) + "/items",
+	}}
+}
+
+//ParsePage parses the optional offset and limit query parameters and sends the HTTPError Response on failure
+func ParsePage(w http.ResponseWriter, r *http.Request) (Page, error) {
+	page := Page{Limit: DefaultPageLimit}
+	var fields []models.FieldError
+	query := r.URL.Query()
+	if value := query.Get(QueryParamOffset); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			fields = append(fields, models.FieldError{Field: QueryParamOffset, Message: "must be a non-negative integer"})
+		}
+		page.Offset = offset
+	}
+	if value := query.Get(QueryParamLimit); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			fields = append(fields, models.FieldError{Field: QueryParamLimit, Message: "must be an integer from 1 to " + strconv.Itoa(MaxPageLimit)})
+		}
+		page.Limit = limit
+	}
+	if len(fields) > 0 {
+		err := models.NewError(models.KindInvalid, "invalid_page", InvalidPage, fields...)
+		WriteError(w, r, err)
+		return Page{}, err
+	}
+	return page, nil
+}
+
+//bounds returns the indices of the page in a collection of total resources and sets its total
+func (p *Page) bounds(total int) (int, int) {
+	p.Total = total
+	start, end := p.Offset, p.Offset+p.Limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+//pageLinks links the page of the collection requested by r to itself, the first and the last page, and the pages
+//before and after it, if any - other query parameters are kept
+func pageLinks(r *http.Request, page Page) Links {
+	link := func(offset int) string {
+		query := r.URL.Query()
+		query.Set(QueryParamOffset, strconv.Itoa(offset))
+		query.Set(QueryParamLimit, strconv.Itoa(page.Limit))
+		return (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()
+	}
+	last := 0
+	if page.Total > 0 {
+		last = (page.Total - 1) / page.Limit * page.Limit
+	}
+	links := Links{"self": link(page.Offset), "first": link(0), "last": link(last)}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links["prev"] = link(prev)
+	}
+	if page.Offset+page.Limit < page.Total {
+		links["next"] = link(page.Offset + page.Limit)
+	}
+	return links
+}
+
+//createdBefore orders resources by the time they have been created, then by their IDs, so that pages are stable
+func createdBefore(a, b models.BaseModel) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+//writeCreated answers with 201, the Location of the created resource and its envelope
+func writeCreated(w http.ResponseWriter, r *http.Request, location string, envelope interface{}) {
+	w.Header().Set("Location", location)
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, envelope)
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/websocket"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+)
+
+// define error messages
+const (
+	StreamUpgradeFailure = "Failed to open the bid stream"
+	StreamCommandFailure = "Failed to decode a stream command"
+	StreamUnknownAction  = "Unknown stream action"
+	StreamSlowConsumer   = "Subscriber too slow - reconnect to continue"
+	MalformedItemParam   = "Malformed item Parameter"
+)
+
+//QueryParamStreamItem is the query parameter (repeatable) selecting the items to stream
+const QueryParamStreamItem = "item"
+
+// define stream command actions and replies
+const (
+	StreamActionSubscribe   = "subscribe"
+	StreamActionUnsubscribe = "unsubscribe"
+	StreamReplySubscribed   = "subscribed"
+	StreamReplyUnsubscribed = "unsubscribed"
+	StreamReplyError        = "error"
+)
+
+const (
+	streamWriteWait      = 10 * time.Second
+	streamPongWait       = 60 * time.Second
+	streamPingPeriod     = streamPongWait * 9 / 10
+	streamMaxCommandSize = 64 * 1024
+)
+
+//StreamCommand is sent by clients to change the items they are subscribed to
+type StreamCommand struct {
+	Action string      `json:"action"`
+	Items  []uuid.UUID `json:"items"`
+}
+
+//StreamReply confirms a StreamCommand or reports why it failed
+type StreamReply struct {
+	Type  string      `json:"type"`
+	Items []uuid.UUID `json:"items,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+//NewStreamHandler initializes a new handler pushing messages of hub to WebSocket clients
+func NewStreamHandler(db storage.Storage, hub *stream.Hub) *StreamHandler {
+	return &StreamHandler{
+		db:  db,
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+//StreamHandler is the handler responsible for live bid streams
+type StreamHandler struct {
+	db       storage.Storage
+	hub      *stream.Hub
+	upgrader websocket.Upgrader
+}
+
+//Routes returns the routes for the StreamHandler
+func (e *StreamHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/", e.Stream)
+	return router
+}
+
+// Stream upgrades the connection to WebSocket and pushes messages for the items given in the item query parameters.
+// Clients change their subscriptions by sending StreamCommand messages.
+//
+// @summary Open a WebSocket pushing the bids and closings of items
+// @tags Bids
+// @param query item {[]uuid} Items to subscribe to, may be repeated - clients change their subscriptions with messages
+// @response 101 SWITCHING PROTOCOLS, the WebSocket is open
+// @response 400 An item is invalid
+// @response 404 An item is not found
+func (e *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	var itemIDs []uuid.UUID
+	for _, param := range r.URL.Query()[QueryParamStreamItem] {
+		itemID, err := uuid.FromString(param)
+		if err != nil {
+			logging.LogError(MalformedItemParam, err)
+			WriteHTTPErrorCode(w, r, errors.New(MalformedItemParam), http.StatusBadRequest)
+			return
+		}
+		if _, err := e.db.GetItem(itemID); err != nil {
+			logging.LogError("Cannot find item", err)
+			WriteHTTPErrorCode(w, r, errors.New(ItemNotFound), http.StatusNotFound)
+			return
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+
+	conn, err := e.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already responded to the client
+		logging.LogError(StreamUpgradeFailure, err)
+		return
+	}
+	defer conn.Close()
+
+	sub := e.hub.Subscribe(itemIDs...)
+	defer sub.Close()
+
+	replies := make(chan StreamReply, 8)
+	replies <- StreamReply{Type: StreamReplySubscribed, Items: itemIDs}
+	writerDone := make(chan struct{})
+	defer close(writerDone)
+	readerDone := make(chan struct{})
+	go e.readCommands(conn, sub, replies, readerDone, writerDone)
+
+	ping := time.NewTicker(streamPingPeriod)
+	defer ping.Stop()
+	for {
+		var payload interface{}
+		select {
+		case msg, ok := <-sub.C():
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, StreamSlowConsumer),
+					time.Now().Add(streamWriteWait))
+				return
+			}
+			payload = msg
+		case reply := <-replies:
+			payload = reply
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+			continue
+		case <-readerDone:
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		if err := conn.WriteJSON(payload); err != nil {
+			logging.LogError("Cannot write to bid stream", err)
+			return
+		}
+	}
+}
+
+// readCommands applies commands sent by the client until the connection fails or the writer stops
+func (e *StreamHandler) readCommands(conn *websocket.Conn, sub *stream.Subscription, replies chan<- StreamReply, readerDone chan<- struct{}, writerDone <-chan struct{}) {
+	defer close(readerDone)
+
+	conn.SetReadLimit(streamMaxCommandSize)
+	conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		reply := StreamReply{Type: StreamReplyError, Error: StreamCommandFailure}
+		cmd := StreamCommand{}
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			logging.LogError(StreamCommandFailure, err)
+		} else {
+			reply = e.apply(sub, cmd)
+		}
+		select {
+		case replies <- reply:
+		case <-writerDone:
+			return
+		}
+	}
+}
+
+func (e *StreamHandler) apply(sub *stream.Subscription, cmd StreamCommand) StreamReply {
+	switch cmd.Action {
+	case StreamActionSubscribe:
+		for _, itemID := range cmd.Items {
+			if _, err := e.db.GetItem(itemID); err != nil {
+				return StreamReply{Type: StreamReplyError, Items: []uuid.UUID{itemID}, Error: ItemNotFound}
+			}
+		}
+		sub.Add(cmd.Items...)
+		return StreamReply{Type: StreamReplySubscribed, Items: cmd.Items}
+	case StreamActionUnsubscribe:
+		sub.Remove(cmd.Items...)
+		return StreamReply{Type: StreamReplyUnsubscribed, Items: cmd.Items}
+	}
+	return StreamReply{Type: StreamReplyError, Error: StreamUnknownAction}
+}
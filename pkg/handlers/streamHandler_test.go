@@ -0,0 +1,139 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/gorilla/websocket"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+)
+
+func newStreamServer(bufferSize int) (storage.Storage, *stream.Hub, *httptest.Server) {
+	db := storage.NewMapBiddingSystem()
+	hub := stream.NewHub(bufferSize, 0)
+	db.Subscribe(hub)
+	return db, hub, httptest.NewServer(handlers.NewStreamHandler(db, hub).Routes())
+}
+
+func dialStream(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readReply(t *testing.T, conn *websocket.Conn) handlers.StreamReply {
+	reply := handlers.StreamReply{}
+	require.NoError(t, conn.ReadJSON(&reply))
+	return reply
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) stream.Message {
+	msg := stream.Message{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestStreamHandler_Stream(t *testing.T) {
+	db, _, server := newStreamServer(0)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 1)
+
+	conn := dialStream(t, server, "?item="+items[0].ID.String())
+	defer conn.Close()
+	assert.Equal(t, handlers.StreamReply{Type: handlers.StreamReplySubscribed, Items: []uuid.UUID{items[0].ID}}, readReply(t, conn))
+
+	first := models.NewBid(items[0].ID, users[0].ID, 10)
+	second := models.NewBid(items[0].ID, users[1].ID, 20)
+	db.PlaceBid(first)
+	db.PlaceBid(second)
+	db.CloseAuction(items[0].ID)
+
+	msg := readMessage(t, conn)
+	assert.Equal(t, stream.MessageNewBid, msg.Type)
+	assert.Equal(t, first.ID, msg.Bid.ID)
+	assert.Equal(t, stream.MessageNewBid, readMessage(t, conn).Type)
+	msg = readMessage(t, conn)
+	assert.Equal(t, stream.MessageOutbid, msg.Type)
+	assert.Equal(t, first.ID, msg.Outbid.ID)
+	msg = readMessage(t, conn)
+	assert.Equal(t, stream.MessageAuctionClosed, msg.Type)
+	assert.Equal(t, second.ID, msg.Bid.ID)
+}
+
+func TestStreamHandler_Commands(t *testing.T) {
+	db, _, server := newStreamServer(0)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 2)
+
+	conn := dialStream(t, server, "")
+	defer conn.Close()
+	assert.Equal(t, handlers.StreamReplySubscribed, readReply(t, conn).Type)
+
+	require.NoError(t, conn.WriteJSON(handlers.StreamCommand{Action: handlers.StreamActionSubscribe, Items: []uuid.UUID{items[1].ID}}))
+	assert.Equal(t, handlers.StreamReply{Type: handlers.StreamReplySubscribed, Items: []uuid.UUID{items[1].ID}}, readReply(t, conn))
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 10))
+	assert.Equal(t, items[1].ID, readMessage(t, conn).ItemID, "Only subscribed items should be streamed")
+
+	require.NoError(t, conn.WriteJSON(handlers.StreamCommand{Action: handlers.StreamActionUnsubscribe, Items: []uuid.UUID{items[1].ID}}))
+	assert.Equal(t, handlers.StreamReplyUnsubscribed, readReply(t, conn).Type)
+
+	unknown := uuid.NewV4()
+	require.NoError(t, conn.WriteJSON(handlers.StreamCommand{Action: handlers.StreamActionSubscribe, Items: []uuid.UUID{unknown}}))
+	assert.Equal(t, handlers.StreamReply{Type: handlers.StreamReplyError, Items: []uuid.UUID{unknown}, Error: handlers.ItemNotFound}, readReply(t, conn))
+
+	require.NoError(t, conn.WriteJSON(handlers.StreamCommand{Action: "bid"}))
+	assert.Equal(t, handlers.StreamReply{Type: handlers.StreamReplyError, Error: handlers.StreamUnknownAction}, readReply(t, conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{")))
+	assert.Equal(t, handlers.StreamReply{Type: handlers.StreamReplyError, Error: handlers.StreamCommandFailure}, readReply(t, conn))
+}
+
+func TestStreamHandler_SlowConsumer(t *testing.T) {
+	db, hub, server := newStreamServer(1)
+	defer server.Close()
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+
+	conn := dialStream(t, server, "?item="+items[0].ID.String())
+	defer conn.Close()
+	readReply(t, conn)
+
+	// the client does not read, so messages pile up in the hub faster than the writer drains them
+	for i := 1; hub.Len() > 0 && i <= 1000000; i++ {
+		db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, float64(i)))
+	}
+	require.Equal(t, 0, hub.Len(), "Slow consumer should be dropped by the hub")
+
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), "Slow consumer should be disconnected, got: %v", err)
+}
+
+func TestStreamHandler_BadRequest(t *testing.T) {
+	_, _, server := newStreamServer(0)
+	defer server.Close()
+
+	e := httpexpect.New(t, server.URL)
+	e.GET("/").WithQuery("item", "not-a-uuid").Expect().Status(http.StatusBadRequest)
+	e.GET("/").WithQuery("item", uuid.NewV4().String()).Expect().Status(http.StatusNotFound)
+}
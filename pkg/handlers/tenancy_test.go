@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	srv "github.com/vikin91/bid-tracker-go/pkg/server"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/tenancy"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	taxRate := 0.19
+	registry, err := tenancy.NewRegistry(
+		tenancy.Tenant{ID: "acme", Hosts: []string{"acme.example.com"}},
+		tenancy.Tenant{ID: "globex", Hosts: []string{"globex.example.com"}, Currency: "EUR", TaxRate: &taxRate,
+			Rules: models.Rules{MinOpeningBid: 5, MinIncrement: 1}},
+	)
+	require.NoError(t, err)
+	acmeTenant, _ := registry.Get("acme")
+	globexTenant, _ := registry.Get("globex")
+	namespaces := storage.NewNamespaces(func(name string) storage.Storage { return storage.NewMapBiddingSystem() })
+	acme := storage.NewMapBiddingSystem().WithRules(acmeTenant.Rules)
+	globex := storage.NewMapBiddingSystem().WithRules(globexTenant.Rules)
+	namespaces.Add("acme", acme)
+	namespaces.Add("globex", globex)
+
+	acmeItem := testutils.CreateTestItems(acme, 1)[0]
+	acmeUser := testutils.CreateTestUsers(acme, 1)[0]
+	globexItem := testutils.CreateTestItems(globex, 1)[0]
+	globexUser := testutils.CreateTestUsers(globex, 1)[0]
+
+	config.SetupEnv()
+	viper.Set("API_KEYS", fmt.Sprintf("%s=%s@acme,%s=%s:admin@globex", testutils.APIKey(acmeUser), acmeUser.ID, testutils.APIKey(globexUser), globexUser.ID))
+	hmacKey := []byte("top secret")
+	keyFile, err := ioutil.TempFile("", "hmac")
+	require.NoError(t, err)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.Write(hmacKey)
+	require.NoError(t, err)
+	require.NoError(t, keyFile.Close())
+	viper.Set("JWT_HMAC_KEY_FILE", keyFile.Name())
+	viper.Set("PASSWORD_ITERATIONS", 10)
+	defer viper.Set("API_KEYS", "")
+	defer viper.Set("JWT_HMAC_KEY_FILE", "")
+	defer viper.Set("PASSWORD_ITERATIONS", config.DefaultPasswordIterations)
+	s := srv.NewServer()
+	s.SetupTenantRoutes(registry, namespaces)
+	defer s.Shutdown()
+	server := httptest.NewServer(s.Mux())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+	in := func(tenant string, req *httpexpect.Request) *httpexpect.Request {
+		return req.WithHeader(tenancy.HeaderTenant, tenant)
+	}
+
+	// the tenant is resolved from the header or the hostname
+	in("acme", e.GET("/api/v1/item")).Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	in("acme", e.GET("/api/v1/item")).Expect().JSON().Array().First().Object().ValueEqual("id", acmeItem.ID)
+	e.GET("/api/v1/item").WithHeader("Host", "globex.example.com").Expect().
+		Status(http.StatusOK).JSON().Array().First().Object().ValueEqual("id", globexItem.ID)
+	e.GET("/api/v1/item").Expect().Status(http.StatusBadRequest).Body().Contains(tenancy.ErrNoTenant.Error())
+	in("initech", e.GET("/api/v1/item")).Expect().Status(http.StatusNotFound).Body().Contains(tenancy.ErrUnknownTenant.Error())
+	in("globex", e.GET("/api/v1/item")).WithHeader("Host", "acme.example.com").Expect().
+		Status(http.StatusBadRequest).Body().Contains(tenancy.ErrTenantMismatch.Error())
+
+	// no request reaches the data of another tenant
+	in("globex", e.GET("/api/v1/item/{itemID}/bids", acmeItem.ID)).Expect().Status(http.StatusNotFound)
+	in("globex", e.POST("/api/v1/item/{itemID}/bids", acmeItem.ID)).WithHeader(auth.HeaderAPIKey, testutils.APIKey(globexUser)).
+		WithJSON(map[string]interface{}{"amount": 10}).Expect().Status(http.StatusNotFound)
+	in("globex", e.GET("/api/v1/user")).WithHeader(auth.HeaderAPIKey, testutils.APIKey(globexUser)).Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	in("globex", e.GET("/api/v1/user/{userID}", acmeUser.ID)).WithHeader(auth.HeaderAPIKey, testutils.APIKey(globexUser)).Expect().
+		Status(http.StatusNotFound)
+	in("acme", e.POST("/api/v1/item/{itemID}/bids", acmeItem.ID)).WithHeader(auth.HeaderAPIKey, testutils.APIKey(acmeUser)).
+		WithJSON(map[string]interface{}{"amount": 1}).Expect().Status(http.StatusCreated)
+	bids, err := globex.AllBids()
+	require.NoError(t, err)
+	require.Empty(t, bids)
+
+	// API keys and tokens are valid only for their tenant
+	sign := func(user *models.User, tenant string) string {
+		token, err := auth.Sign(auth.Claims{Subject: user.ID.String(), ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			Roles: []string{"admin"}, Tenant: tenant}, auth.HS256, hmacKey)
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+	in("globex", e.POST("/api/v1/item/{itemID}/bids", globexItem.ID)).WithHeader(auth.HeaderAPIKey, testutils.APIKey(acmeUser)).
+		WithJSON(map[string]interface{}{"amount": 10}).Expect().Status(http.StatusUnauthorized).Body().Contains(auth.ErrWrongTenant.Error())
+	in("acme", e.GET("/api/v1/user")).WithHeader(auth.HeaderAPIKey, testutils.APIKey(globexUser)).Expect().
+		Status(http.StatusUnauthorized).Body().Contains(auth.ErrWrongTenant.Error())
+	in("acme", e.GET("/api/v2/users")).WithHeader(auth.HeaderAPIKey, testutils.APIKey(globexUser)).Expect().
+		Status(http.StatusUnauthorized)
+	in("globex", e.GET("/api/v1/user")).WithHeader(auth.HeaderAuthorization, sign(globexUser, "globex")).Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	in("acme", e.GET("/api/v1/user")).WithHeader(auth.HeaderAuthorization, sign(globexUser, "globex")).Expect().
+		Status(http.StatusUnauthorized).Body().Contains(auth.ErrWrongTenant.Error())
+	in("acme", e.GET("/api/v1/user")).WithHeader(auth.HeaderAuthorization, sign(acmeUser, "")).Expect().
+		Status(http.StatusUnauthorized).Body().Contains(auth.ErrWrongTenant.Error())
+
+	// accounts and sessions are kept per tenant
+	credentials := map[string]interface{}{"name": "Alice", "email": "alice@example.com", "password": "correct horse"}
+	in("acme", e.POST("/api/v1/accounts")).WithJSON(credentials).Expect().Status(http.StatusCreated)
+	in("globex", e.POST("/api/v1/accounts/login")).WithJSON(credentials).Expect().Status(http.StatusUnauthorized)
+	token := in("acme", e.POST("/api/v1/accounts/login")).WithJSON(credentials).Expect().
+		Status(http.StatusOK).JSON().Object().Value("token").String().Raw()
+	in("globex", e.GET("/api/v1/item")).WithHeader(auth.HeaderAuthorization, "Bearer "+token).Expect().Status(http.StatusUnauthorized)
+
+	// each tenant has its own currency, fees and bidding rules
+	in("globex", e.GET("/api/v1/tenant")).Expect().Status(http.StatusOK).JSON().Object().
+		ValueEqual("currency", "EUR").ValueEqual("rules", map[string]interface{}{"minOpeningBid": 5, "minIncrement": 1}).
+		Value("fees").Object().ValueEqual("taxRate", 0.19)
+	in("acme", e.GET("/api/v1/tenant")).Expect().Status(http.StatusOK).JSON().Object().
+		NotContainsKey("currency").Value("fees").Object().ValueEqual("taxRate", 0)
+	in("globex", e.POST("/api/v1/item/{itemID}/bids", globexItem.ID)).WithHeader(auth.HeaderAPIKey, testutils.APIKey(globexUser)).
+		WithJSON(map[string]interface{}{"amount": 1}).Expect().
+		Status(http.StatusUnprocessableEntity).Body().Contains(models.ErrBelowOpeningBid.Error())
+}
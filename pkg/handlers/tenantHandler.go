@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+	"github.com/vikin91/bid-tracker-go/pkg/tenancy"
+)
+
+//NewTenantHandler initializes a new handler
+func NewTenantHandler(tenant *tenancy.Tenant, ledger *settlement.Ledger) *TenantHandler {
+	return &TenantHandler{tenant: tenant, ledger: ledger}
+}
+
+//TenantHandler is the handler describing the auction house (tenant) of requests
+type TenantHandler struct {
+	tenant *tenancy.Tenant
+	ledger *settlement.Ledger
+}
+
+type tenantResponse struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name,omitempty"`
+	Currency string          `json:"currency,omitempty"`
+	Fees     settlement.Fees `json:"fees"`
+	Rules    models.Rules    `json:"rules"`
+}
+
+//Routes returns the routes for the TenantHandler
+func (e *TenantHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(render.SetContentType(render.ContentTypeJSON))
+	router.Get("/", e.GetTenant)
+	return router
+}
+
+// GetTenant returns the currency, fees and bidding rules of the tenant
+//
+// @summary Get the currency, fees and bidding rules of the tenant of the request
+// @tags Tenants
+// @param header X-Tenant-ID {string} The tenant - resolved from the hostname if omitted
+// @response 200 {tenantResponse} OK
+// @response 400 No tenant is named or the header and the hostname name different tenants
+// @response 404 The tenant is unknown
+func (e *TenantHandler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, tenantResponse{
+		ID:       e.tenant.ID,
+		Name:     e.tenant.Name,
+		Currency: e.ledger.Currency(),
+		Fees:     e.ledger.Fees(),
+		Rules:    e.tenant.Rules,
+	})
+}
@@ -1,14 +1,22 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/accounts"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
 	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/notifications"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
 	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
 )
 
 // define error messages
@@ -20,8 +28,39 @@ const (
 	UserPermissionsForbidden = "Not allowed to get User permissions"
 	InvalidResetToken        = "Invalid Password Reset Token"
 	MismatchedUserIDs        = "Request User IDs do not match"
+	NotificationNotFound     = "Notification not found"
+	PreferencesDecodeFailure = "Failed to decode notification preferences"
+	MalformedUnreadParam     = "Malformed unread Parameter"
+	SearchDecodeFailure      = "Failed to decode a saved search"
+	SearchNotFound           = "Saved search not found"
+	WatchlistItemNotFound    = "Item is not on the watchlist"
+	CreditDecodeFailure      = "Failed to decode credit"
+	RolesDecodeFailure       = "Failed to decode roles"
+	UserRolesForbidden       = "Not allowed to change User roles"
+	AccountNotFound          = "User has no password account"
 )
 
+//QueryParamUnread selects only unread notifications
+const QueryParamUnread = "unread"
+
+//HeaderUnreadCount holds the number of unread notifications of the user
+const HeaderUnreadCount = "X-Unread-Count"
+
+type permissionsResponse struct {
+	UserID      uuid.UUID         `json:"userID"`
+	Roles       []auth.Role       `json:"roles"`
+	Permissions []auth.Permission `json:"permissions"`
+}
+
+type savedSearchPayload struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+type rolesPayload struct {
+	Roles []string `json:"roles"`
+}
+
 //NewUserHandler initializes a new handler
 func NewUserHandler(db storage.Storage) *UserHandler {
 	return &UserHandler{db: db}
@@ -29,30 +68,107 @@ func NewUserHandler(db storage.Storage) *UserHandler {
 
 //UserHandler is the handler responsible for User operations
 type UserHandler struct {
-	db storage.Storage
+	db       storage.Storage
+	hub      *stream.Hub
+	notifier *notifications.Notifier
+	ledger   *settlement.Ledger
+	policy   *auth.Policy
+	accounts *accounts.Manager
+}
+
+//WithStream serves the activity of users from hub as server-sent events
+func (e *UserHandler) WithStream(hub *stream.Hub) *UserHandler {
+	e.hub = hub
+	return e
+}
+
+//WithNotifications serves the in-app inbox and the notification preferences of users kept by notifier
+func (e *UserHandler) WithNotifications(notifier *notifications.Notifier) *UserHandler {
+	e.notifier = notifier
+	return e
+}
+
+//WithInvoices serves the invoices of users as buyers and as sellers and the second-chance offers to users kept by ledger
+func (e *UserHandler) WithInvoices(ledger *settlement.Ledger) *UserHandler {
+	e.ledger = ledger
+	return e
+}
+
+//WithPolicy authorizes requests by policy: users access their own resources, admins those of all users
+func (e *UserHandler) WithPolicy(policy *auth.Policy) *UserHandler {
+	e.policy = policy
+	return e
+}
+
+//WithAccounts lets admins change the roles of the password accounts kept by manager
+func (e *UserHandler) WithAccounts(manager *accounts.Manager) *UserHandler {
+	e.accounts = manager
+	return e
 }
 
 //Routes returns the routes for the UserHandler
 func (e *UserHandler) Routes() *chi.Mux {
 	router := chi.NewRouter()
-	router.Get("/", e.GetUsers)
-	router.Get("/{userID}", e.GetUserByID)
-	router.Get("/{userID}/bids", e.GetUserBids)
-	router.Get("/{userID}/items", e.GetItemsUserHasBid) //TODO: Check swagger!
+	read := authorizeOwner(e.policy, userOwner, auth.PermissionReadOwnUser, auth.PermissionReadAnyUser, UserGetForbidden)
+	update := authorizeOwner(e.policy, userOwner, auth.PermissionUpdateOwnUser, auth.PermissionUpdateAnyUser, UserUpdateForbidden)
+	router.With(authorize(e.policy, auth.PermissionListUsers, UserListForbidden)).Get("/", e.GetUsers)
+	router.With(read).Get("/{userID}", e.GetUserByID)
+	router.With(read).Get("/{userID}/bids", e.GetUserBids)
+	router.With(read).Get("/{userID}/items", e.GetItemsUserHasBid) //TODO: Check swagger!
+	router.With(read).Get("/{userID}/watchlist", e.GetWatchlist)
+	router.With(update).Put("/{userID}/watchlist/{itemID}", e.WatchItem)
+	router.With(update).Delete("/{userID}/watchlist/{itemID}", e.UnwatchItem)
+	router.With(read).Get("/{userID}/searches", e.GetSavedSearches)
+	router.With(update).Post("/{userID}/searches", e.SaveSearch)
+	router.With(update).Delete("/{userID}/searches/{searchID}", e.DeleteSearch)
+	router.With(read).Get("/{userID}/credit", e.GetCredit)
+	router.With(authorize(e.policy, auth.PermissionManageCredit, UserUpdateForbidden)).Put("/{userID}/credit", e.SetCredit)
+	if e.policy != nil {
+		router.With(authorize(e.policy, auth.PermissionReadPermissions, UserPermissionsForbidden)).Get("/{userID}/permissions", e.GetPermissions)
+	}
+	if e.accounts != nil {
+		router.With(authorize(e.policy, auth.PermissionManageRoles, UserRolesForbidden)).Put("/{userID}/roles", e.SetRoles)
+	}
+	if e.hub != nil {
+		router.With(read).Get("/{userID}/events", e.GetEvents)
+	}
+	if e.notifier != nil {
+		router.With(read).Get("/{userID}/notifications", e.GetNotifications)
+		router.With(update).Post("/{userID}/notifications/read", e.MarkAllNotificationsRead)
+		router.With(update).Post("/{userID}/notifications/{notificationID}/read", e.MarkNotificationRead)
+		router.With(update).Post("/{userID}/notifications/{notificationID}/unread", e.MarkNotificationUnread)
+		router.With(read).Get("/{userID}/notifications/preferences", e.GetNotificationPreferences)
+		router.With(update).Put("/{userID}/notifications/preferences", e.SetNotificationPreferences)
+	}
+	if e.ledger != nil {
+		router.With(read).Get("/{userID}/invoices", e.GetInvoices)
+		router.With(read).Get("/{userID}/sales", e.GetSales)
+		router.With(read).Get("/{userID}/offers", e.GetOffers)
+	}
 	return router
 }
 
 // GetUsers returns lists of Users
+//
+// @summary Get a list of users
+// @tags Users
+// @response 200 {[]models.User} OK
 func (e *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	users, err := e.db.AllUsers()
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusInternalServerError)
+		WriteError(w, r, err)
 		return
 	}
 	render.JSON(w, r, users)
 }
 
 // GetUserByID returns User for the given user id
+//
+// @summary Get a user
+// @tags Users
+// @response 200 {models.User} OK
+// @response 400 The userID is invalid
+// @response 404 User not found
 func (e *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	userID, err := ParseUserID(w, r)
 	if err != nil {
@@ -61,50 +177,516 @@ func (e *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 
 	user, err := e.db.GetUser(userID)
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusNotFound)
+		WriteError(w, r, err)
 		return
 	}
 	render.JSON(w, r, user)
 }
 
+// GetPermissions returns the roles and permissions of the authenticated user - only to the user
+//
+// @summary Get the roles and permissions of the authenticated user
+// @tags Users
+// @response 200 {permissionsResponse} OK
+// @response 401 The request is not authenticated
+// @response 403 The userID is not the authenticated user
+func (e *UserHandler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	userID, err := ParseUserID(w, r)
+	if err != nil {
+		return
+	}
+	principal := auth.FromContext(r.Context())
+	if principal.UserID != userID {
+		WriteHTTPErrorCode(w, r, errors.New(UserPermissionsForbidden), http.StatusForbidden)
+		return
+	}
+	render.JSON(w, r, permissionsResponse{principal.UserID, principal.Roles, e.policy.Permissions(principal)})
+}
+
 // GetUserBids returns User bids
+//
+// @summary Get all bids of the user
+// @tags Users, Bids
+// @response 200 {[]models.Bid} OK
+// @response 400 The userID is invalid
+// @response 404 User not found
 func (e *UserHandler) GetUserBids(w http.ResponseWriter, r *http.Request) {
 	userID, err := ParseUserID(w, r)
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	bids, err := e.db.GetUserBids(userID)
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusNotFound)
+		WriteError(w, r, err)
 		return
 	}
 	render.JSON(w, r, bids)
 }
 
-// GetItemsUserHasBid returns User bids
+// GetItemsUserHasBid returns items the user has bid on (as of the time given in the asOf query parameter)
+//
+// @summary Get all items on which the user has bid
+// @tags Users
+// @param query asOf {date-time} Return the state as it was at this time (RFC 3339)
+// @response 200 {[]models.Item} OK
+// @response 400 The userID or asOf is invalid
+// @response 404 User not found
 func (e *UserHandler) GetItemsUserHasBid(w http.ResponseWriter, r *http.Request) {
 	userID, err := ParseUserID(w, r)
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusInternalServerError)
 		return
 	}
+	asOf, err := ParseAsOf(w, r)
+	if err != nil {
+		return
+	}
+
+	var items []*models.Item
+	if asOf.IsZero() {
+		items, err = e.db.GetItemsUserHasBid(userID)
+	} else {
+		items, err = e.db.GetItemsUserHasBidAsOf(userID, asOf)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	render.JSON(w, r, items)
+}
 
-	items, err := e.db.GetItemsUserHasBid(userID)
+// GetWatchlist returns the items the user watches, in the order they have been added
+//
+// @summary Get the items the user watches, in the order they have been added
+// @tags Users
+// @response 200 {[]models.Item} OK
+// @response 404 User not found
+func (e *UserHandler) GetWatchlist(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	items, err := e.db.GetWatchlist(user.ID)
 	if err != nil {
-		WriteHTTPErrorCode(w, err, http.StatusNotFound)
+		WriteError(w, r, err)
 		return
 	}
 	render.JSON(w, r, items)
 }
 
+// WatchItem adds an item to the watchlist of the user
+//
+// @summary Add an item to the watchlist of the user
+// @tags Users
+// @response 204 NO CONTENT
+// @response 404 User or item not found
+func (e *UserHandler) WatchItem(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	itemID, err := ParseItemID(w, r)
+	if err != nil {
+		return
+	}
+	if err := e.db.WatchItem(user.ID, itemID); err != nil {
+		logging.LogError("Cannot watch item", err)
+		WriteError(w, r, err)
+		return
+	}
+	WriteHTTPCode(w, http.StatusNoContent)
+}
+
+// UnwatchItem removes an item from the watchlist of the user
+//
+// @summary Remove an item from the watchlist of the user
+// @tags Users
+// @response 204 NO CONTENT
+// @response 404 User not found or item not on the watchlist
+func (e *UserHandler) UnwatchItem(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	itemID, err := ParseItemID(w, r)
+	if err != nil {
+		return
+	}
+	if err := e.db.UnwatchItem(user.ID, itemID); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	WriteHTTPCode(w, http.StatusNoContent)
+}
+
+// GetSavedSearches returns the searches saved by the user
+//
+// @summary Get the searches saved by the user
+// @tags Users
+// @response 200 {[]models.SavedSearch} OK
+// @response 404 User not found
+func (e *UserHandler) GetSavedSearches(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	searches, err := e.db.GetSavedSearches(user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	render.JSON(w, r, searches)
+}
+
+// SaveSearch saves a search query of the user - the user is notified about new items matching it
+//
+// @summary Save a search - the user is notified about new items matching it
+// @tags Users
+// @body {savedSearchPayload} The search - the name defaults to the query
+// @response 201 {models.SavedSearch} CREATED
+// @response 400 The query is empty
+// @response 404 User not found
+func (e *UserHandler) SaveSearch(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	payload := savedSearchPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logging.LogError(SearchDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(SearchDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	search := models.NewSavedSearch(user.ID, payload.Name, payload.Query)
+	if err := e.db.SaveSearch(search); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, search)
+}
+
+// DeleteSearch deletes a saved search of the user
+//
+// @summary Delete a saved search of the user
+// @tags Users
+// @response 204 NO CONTENT
+// @response 404 User or saved search not found
+func (e *UserHandler) DeleteSearch(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	searchID, err := parseUUIDParam(w, r, "searchID")
+	if err != nil {
+		return
+	}
+	if err := e.db.DeleteSearch(user.ID, searchID); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	WriteHTTPCode(w, http.StatusNoContent)
+}
+
+// GetCredit returns the credit limit, deposit and exposure of the user
+//
+// @summary Get the credit limit, deposit and exposure of the user
+// @tags Users
+// @response 200 {models.Credit} OK
+// @response 404 User not found
+func (e *UserHandler) GetCredit(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	credit, err := e.db.GetCredit(user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	render.JSON(w, r, credit)
+}
+
+// SetCredit sets the credit limit and deposit of the user (limited=false removes the limit) and returns the credit
+//
+// @summary Set the credit limit and deposit of the user
+// @tags Users
+// @body {models.Credit} The credit - limited false removes the limit
+// @response 200 {models.Credit} OK
+// @response 400 The limit or the deposit is negative
+// @response 404 User not found
+func (e *UserHandler) SetCredit(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	credit := models.Credit{}
+	if err := json.NewDecoder(r.Body).Decode(&credit); err != nil {
+		logging.LogError(CreditDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(CreditDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	if err := e.db.SetCredit(user.ID, credit); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	e.GetCredit(w, r)
+}
+
+// SetRoles replaces the roles of the password account of the user and returns the account
+//
+// @summary Replace the roles of the password account of a user (admins only)
+// @tags Users, Accounts
+// @body {rolesPayload} The roles: admin, seller or bidder
+// @response 200 {accounts.Account} OK
+// @response 400 The userID or a role is invalid
+// @response 401 The request is not authenticated
+// @response 403 The user is not an admin
+// @response 404 The user has no password account
+func (e *UserHandler) SetRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := ParseUserID(w, r)
+	if err != nil {
+		return
+	}
+	payload := rolesPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logging.LogError(RolesDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(RolesDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	roles := make([]auth.Role, 0, len(payload.Roles))
+	for _, s := range payload.Roles {
+		role, err := auth.ParseRole(s)
+		if err != nil {
+			WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+			return
+		}
+		roles = append(roles, role)
+	}
+	account, err := e.accounts.SetRoles(userID, roles)
+	if err != nil {
+		WriteHTTPErrorCode(w, r, errors.New(AccountNotFound), http.StatusNotFound)
+		return
+	}
+	render.JSON(w, r, account)
+}
+
+// GetEvents streams the bids of the user, the bids outbidding the user and the auctions the user has won
+// as server-sent events, resuming after Last-Event-ID
+//
+// @summary Stream bids of a user, bids outbidding the user and auctions won as server-sent events
+// @tags Users
+// @param header Last-Event-ID {integer} Resume after the event with this ID
+// @param query lastEventId {integer} Resume after the event with this ID (for clients that cannot set headers)
+// @response 200 {stream} OK
+// @response 400 The userID or Last-Event-ID is invalid
+// @response 404 User not found
+func (e *UserHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	userID := user.ID
+	lastID, resume, err := ParseLastEventID(w, r)
+	if err != nil {
+		return
+	}
+	if !resume {
+		serveEvents(w, r, e.hub.SubscribeUsers(userID), nil, true)
+		return
+	}
+	sub, missed, complete := e.hub.ResumeUser(userID, lastID)
+	serveEvents(w, r, sub, missed, complete)
+}
+
+// GetNotifications returns the in-app notifications of the user, newest first (only unread ones with unread=true)
+//
+// @summary Get the in-app notifications of the user, newest first
+// @tags Users, Notifications
+// @param query unread {boolean} Return only unread notifications
+// @response 200 {[]notifications.Notification} OK, the X-Unread-Count header holds the number of unread notifications
+// @response 400 The userID or unread is invalid
+// @response 404 User not found
+func (e *UserHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	unreadOnly := false
+	if value := r.URL.Query().Get(QueryParamUnread); value != "" {
+		if unreadOnly, err = strconv.ParseBool(value); err != nil {
+			WriteHTTPErrorCode(w, r, errors.New(MalformedUnreadParam), http.StatusBadRequest)
+			return
+		}
+	}
+	inbox := e.notifier.Inbox()
+	w.Header().Set(HeaderUnreadCount, strconv.Itoa(inbox.Unread(user.ID)))
+	render.JSON(w, r, inbox.List(user.ID, unreadOnly))
+}
+
+// MarkAllNotificationsRead marks all in-app notifications of the user as read
+//
+// @summary Mark all notifications of the user as read
+// @tags Users, Notifications
+// @response 204 NO CONTENT
+// @response 404 User not found
+func (e *UserHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	e.notifier.Inbox().MarkAllRead(user.ID)
+	WriteHTTPCode(w, http.StatusNoContent)
+}
+
+// MarkNotificationRead marks an in-app notification as read
+//
+// @summary Mark a notification as read
+// @tags Users, Notifications
+// @response 204 NO CONTENT
+// @response 404 User or notification not found
+func (e *UserHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	e.setNotificationRead(w, r, true)
+}
+
+// MarkNotificationUnread marks an in-app notification as unread
+//
+// @summary Mark a notification as unread
+// @tags Users, Notifications
+// @response 204 NO CONTENT
+// @response 404 User or notification not found
+func (e *UserHandler) MarkNotificationUnread(w http.ResponseWriter, r *http.Request) {
+	e.setNotificationRead(w, r, false)
+}
+
+func (e *UserHandler) setNotificationRead(w http.ResponseWriter, r *http.Request, read bool) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	notificationID, err := parseUUIDParam(w, r, "notificationID")
+	if err != nil {
+		return
+	}
+	if err := e.notifier.Inbox().SetRead(user.ID, notificationID, read); err != nil {
+		WriteHTTPErrorCode(w, r, errors.New(NotificationNotFound), http.StatusNotFound)
+		return
+	}
+	WriteHTTPCode(w, http.StatusNoContent)
+}
+
+// GetNotificationPreferences returns the channels the user is notified on
+//
+// @summary Get the channels the user is notified on
+// @tags Users, Notifications
+// @response 200 {notifications.Preferences} OK
+// @response 404 User not found
+func (e *UserHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	render.JSON(w, r, e.notifier.Preferences().Get(user.ID))
+}
+
+// SetNotificationPreferences replaces the channels the user is notified on
+//
+// @summary Set the channels the user is notified on
+// @tags Users, Notifications
+// @body {notifications.Preferences} The channels and their addresses
+// @response 200 {notifications.Preferences} OK
+// @response 400 A channel is unknown or lacks its address
+// @response 404 User not found
+func (e *UserHandler) SetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	prefs := notifications.Preferences{}
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		logging.LogError(PreferencesDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(PreferencesDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	prefs, err = e.notifier.Preferences().Set(user.ID, prefs)
+	if err != nil {
+		WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+		return
+	}
+	render.JSON(w, r, prefs)
+}
+
+// GetInvoices returns the invoices of the user as buyer, as JSON or plain text
+//
+// @summary Get the invoices of the user as buyer
+// @tags Users, Invoices
+// @param query status {string} Return only invoices in this status
+// @param query format {string} Rendering of the invoices: json (default) or text, also with Accept text/plain
+// @response 200 {[]settlement.Invoice} OK
+// @response 400 The status or format is invalid
+// @response 404 User not found
+func (e *UserHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	e.writeInvoices(w, r, e.ledger.ForBuyer)
+}
+
+// GetSales returns the invoices for the items the user has sold, as JSON or plain text
+//
+// @summary Get the invoices for the items sold by the user
+// @tags Users, Invoices
+// @param query status {string} Return only invoices in this status
+// @param query format {string} Rendering of the invoices: json (default) or text, also with Accept text/plain
+// @response 200 {[]settlement.Invoice} OK
+// @response 400 The status or format is invalid
+// @response 404 User not found
+func (e *UserHandler) GetSales(w http.ResponseWriter, r *http.Request) {
+	e.writeInvoices(w, r, e.ledger.ForSeller)
+}
+
+// GetOffers returns the second-chance offers made to the user
+//
+// @summary Get the second-chance offers made to the user
+// @tags Users, Offers
+// @response 200 {[]settlement.Offer} OK
+// @response 404 User not found
+func (e *UserHandler) GetOffers(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	render.JSON(w, r, e.ledger.OffersForUser(user.ID))
+}
+
+func (e *UserHandler) writeInvoices(w http.ResponseWriter, r *http.Request, list func(uuid.UUID, settlement.Status) []settlement.Invoice) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	status, err := ParseInvoiceStatus(w, r)
+	if err != nil {
+		return
+	}
+	WriteInvoices(w, r, list(user.ID, status))
+}
+
+func (e *UserHandler) findUser(w http.ResponseWriter, r *http.Request) (*models.User, error) {
+	userID, err := ParseUserID(w, r)
+	if err != nil {
+		return nil, err
+	}
+	user, err := e.db.GetUser(userID)
+	if err != nil {
+		WriteError(w, r, err)
+		return nil, err
+	}
+	return user, nil
+}
+
 // ParseUserID parses the URLParam or return an error if there is none
 func ParseUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, error) {
 	userID, err := uuid.FromString(chi.URLParam(r, "userID"))
 	if err != nil {
 		logging.LogError("Error parsing URL parameter to UUID", err)
-		WriteHTTPErrorCode(w, errors.New("Malformed URL Parameter"), http.StatusBadRequest)
+		WriteHTTPErrorCode(w, r, errors.New("Malformed URL Parameter"), http.StatusBadRequest)
 		return uuid.FromStringOrNil(""), err
 	}
 	return userID, nil
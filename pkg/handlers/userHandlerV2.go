@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+)
+
+//RoutesV2 returns the routes of API v2 for the UserHandler - users, their bids and the items they have bid on
+func (e *UserHandler) RoutesV2() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(render.SetContentType(render.ContentTypeJSON))
+	read := authorizeOwner(e.policy, userOwner, auth.PermissionReadOwnUser, auth.PermissionReadAnyUser, UserGetForbidden)
+	router.With(authorize(e.policy, auth.PermissionListUsers, UserListForbidden)).Get("/", e.GetUsersV2)
+	router.With(read).Get("/{userID}", e.GetUserV2)
+	router.With(read).Get("/{userID}/bids", e.GetUserBidsV2)
+	router.With(read).Get("/{userID}/items", e.GetUserItemsV2)
+	return router
+}
+
+// GetUsersV2 returns a page of users, oldest first
+//
+// @summary Get a page of users
+// @tags Users
+// @param query offset {integer} Number of users skipped, 0 by default
+// @param query limit {integer} Maximum number of users returned, 20 by default and at most 100
+// @response 200 {usersEnvelope} OK
+// @response 400 The offset or limit is invalid
+func (e *UserHandler) GetUsersV2(w http.ResponseWriter, r *http.Request) {
+	page, err := ParsePage(w, r)
+	if err != nil {
+		return
+	}
+	users, err := e.db.AllUsers()
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	sort.Slice(users, func(i, j int) bool { return createdBefore(users[i].BaseModel, users[j].BaseModel) })
+
+	start, end := page.bounds(len(users))
+	data := make([]userResource, 0, end-start)
+	for _, user := range users[start:end] {
+		data = append(data, newUserResource(user))
+	}
+	render.JSON(w, r, usersEnvelope{Data: data, Meta: page, Links: pageLinks(r, page)})
+}
+
+// GetUserV2 returns User for the given user id
+//
+// @summary Get a user
+// @tags Users
+// @response 200 {userEnvelope} OK
+// @response 400 The userID is invalid
+// @response 404 User not found
+func (e *UserHandler) GetUserV2(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	resource := newUserResource(user)
+	render.JSON(w, r, userEnvelope{Data: resource, Links: Links{"self": resource.Links["self"]}})
+}
+
+// GetUserBidsV2 returns a page of the bids of the user with their status, in the order they have been placed
+//
+// @summary Get a page of the bids of the user
+// @tags Users, Bids
+// @param query offset {integer} Number of bids skipped, 0 by default
+// @param query limit {integer} Maximum number of bids returned, 20 by default and at most 100
+// @response 200 {bidsEnvelope} OK
+// @response 400 The userID, offset or limit is invalid
+// @response 404 User not found
+func (e *UserHandler) GetUserBidsV2(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	page, err := ParsePage(w, r)
+	if err != nil {
+		return
+	}
+	bids := user.GetBids()
+	sort.Slice(bids, func(i, j int) bool { return createdBefore(bids[i].BaseModel, bids[j].BaseModel) })
+
+	start, end := page.bounds(len(bids))
+	data := make([]bidResource, 0, end-start)
+	for _, bid := range bids[start:end] {
+		item, err := e.db.GetItem(bid.ItemID)
+		if err != nil {
+			logging.LogError("Cannot find item of bid", err)
+			WriteError(w, r, err)
+			return
+		}
+		data = append(data, newBidResource(bid, item))
+	}
+	render.JSON(w, r, bidsEnvelope{Data: data, Meta: page, Links: pageLinks(r, page)})
+}
+
+// GetUserItemsV2 returns a page of the items the user has bid on, in the order of the first bid of the user
+//
+// @summary Get a page of the items on which the user has bid
+// @tags Users, Items
+// @param query offset {integer} Number of items skipped, 0 by default
+// @param query limit {integer} Maximum number of items returned, 20 by default and at most 100
+// @response 200 {itemsEnvelope} OK
+// @response 400 The userID, offset or limit is invalid
+// @response 404 User not found
+func (e *UserHandler) GetUserItemsV2(w http.ResponseWriter, r *http.Request) {
+	user, err := e.findUser(w, r)
+	if err != nil {
+		return
+	}
+	page, err := ParsePage(w, r)
+	if err != nil {
+		return
+	}
+	items, err := e.db.GetItemsUserHasBid(user.ID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	start, end := page.bounds(len(items))
+	data := make([]itemResource, 0, end-start)
+	for _, item := range items[start:end] {
+		data = append(data, newItemResource(item))
+	}
+	render.JSON(w, r, itemsEnvelope{Data: data, Meta: page, Links: pageLinks(r, page)})
+}
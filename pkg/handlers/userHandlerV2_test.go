@@ -0,0 +1,40 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestUserHandlerV2(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	_, items, users := testutils.CreateTestBids(db, 2, []float64{10, 20})
+	admin := users[0]
+	stop, e := newV2Server(t, db, admin)
+	defer stop()
+	self := "/api/v2/users/" + admin.ID.String()
+
+	list := e.GET("/api/v2/users").WithQuery("limit", 1).Expect().Status(http.StatusOK).JSON().Object()
+	list.ValueEqual("meta", handlers.Page{Limit: 1, Total: len(users)})
+	list.Path("$.links.next").Equal("/api/v2/users?limit=1&offset=1")
+
+	user := e.GET(self).Expect().Status(http.StatusOK).JSON().Object()
+	user.ValueEqual("links", map[string]string{"self": self})
+	user.Path("$.data.name").Equal(admin.Name)
+	user.Path("$.data.links").Object().ValueEqual("bids", self+"/bids").ValueEqual("items", self+"/items")
+
+	bids := e.GET(self + "/bids").Expect().Status(http.StatusOK).JSON().Object()
+	bids.ValueEqual("meta", handlers.Page{Limit: handlers.DefaultPageLimit, Total: 1})
+	bids.Path("$.data[0].status").Equal(models.BidStatusWinning)
+	bids.Path("$.data[0].links.item").Equal("/api/v2/items/" + items[0].ID.String())
+
+	e.GET(self + "/items").Expect().Status(http.StatusOK).JSON().Object().
+		Path("$.data[0].id").Equal(items[0].ID)
+	e.GET("/api/v2/users/{id}", items[0].ID).Expect().Status(http.StatusNotFound)
+	e.GET("/api/v2/users/x/bids").Expect().Status(http.StatusBadRequest)
+	e.GET(self+"/items").WithQuery("limit", "all").Expect().Status(http.StatusBadRequest)
+}
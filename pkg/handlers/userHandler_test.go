@@ -5,11 +5,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gavv/httpexpect"
 	"github.com/stretchr/testify/assert"
 	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
 	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
 	"github.com/vikin91/bid-tracker-go/pkg/storage"
 )
 
@@ -113,3 +116,31 @@ func TestUserHandler_GetItemsUserHasBid(t *testing.T) {
 		Expect().
 		Status(http.StatusOK).JSON().Array().Contains(items[1], items[3]).NotContains(items[0], items[2])
 }
+
+func TestUserHandler_GetItemsUserHasBid_AsOf(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(db, 2)
+	users := testutils.CreateTestUsers(db, 1)
+
+	handler := handlers.NewUserHandler(db)
+
+	server := httptest.NewServer(handler.Routes())
+	defer server.Close()
+
+	e := httpexpect.New(t, server.URL)
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10.0))
+	time.Sleep(time.Millisecond)
+	afterFirst := time.Now()
+	time.Sleep(time.Millisecond)
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 10.0))
+
+	e.GET(fmt.Sprintf("/%s/items", users[0].ID.String())).
+		WithQuery(handlers.QueryParamAsOf, afterFirst.Format(config.DateLayout)).
+		Expect().
+		Status(http.StatusOK).JSON().Array().Contains(items[0]).NotContains(items[1])
+
+	e.GET(fmt.Sprintf("/%s/items", users[0].ID.String())).
+		Expect().
+		Status(http.StatusOK).JSON().Array().Contains(items[0], items[1])
+}
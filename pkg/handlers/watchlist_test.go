@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func TestUserHandler_Watchlist(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 2)
+
+	server := httptest.NewServer(handlers.NewUserHandler(db).Routes())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	e.GET("/{userID}/watchlist", users[0].ID).Expect().Status(http.StatusOK).JSON().Array().Empty()
+
+	e.PUT("/{userID}/watchlist/{itemID}", users[0].ID, items[1].ID).Expect().Status(http.StatusNoContent)
+	e.PUT("/{userID}/watchlist/{itemID}", users[0].ID, items[0].ID).Expect().Status(http.StatusNoContent)
+	e.PUT("/{userID}/watchlist/{itemID}", users[0].ID, items[0].ID).Expect().Status(http.StatusNoContent)
+	list := e.GET("/{userID}/watchlist", users[0].ID).Expect().Status(http.StatusOK).JSON().Array()
+	list.Length().Equal(2)
+	list.Element(0).Object().ValueEqual("id", items[1].ID)
+
+	e.DELETE("/{userID}/watchlist/{itemID}", users[0].ID, items[1].ID).Expect().Status(http.StatusNoContent)
+	e.DELETE("/{userID}/watchlist/{itemID}", users[0].ID, items[1].ID).
+		Expect().Status(http.StatusNotFound).Body().Contains(handlers.WatchlistItemNotFound)
+	e.GET("/{userID}/watchlist", users[0].ID).Expect().JSON().Array().Length().Equal(1)
+
+	e.PUT("/{userID}/watchlist/{itemID}", users[0].ID, uuid.NewV4()).
+		Expect().Status(http.StatusNotFound).Body().Contains(handlers.ItemNotFound)
+	e.PUT("/{userID}/watchlist/{itemID}", uuid.NewV4(), items[0].ID).Expect().Status(http.StatusNotFound)
+	e.PUT("/{userID}/watchlist/{itemID}", users[0].ID, "xxx-trash").Expect().Status(http.StatusBadRequest)
+}
+
+func TestUserHandler_SavedSearches(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	users := testutils.CreateTestUsers(db, 2)
+
+	server := httptest.NewServer(handlers.NewUserHandler(db).Routes())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	search := e.POST("/{userID}/searches", users[0].ID).
+		WithJSON(map[string]interface{}{"name": "Pens", "query": "fountain pen"}).
+		Expect().Status(http.StatusCreated).JSON().Object()
+	search.ValueEqual("name", "Pens").ValueEqual("query", "fountain pen").ValueEqual("userID", users[0].ID)
+	id := search.Value("id").String().Raw()
+
+	e.POST("/{userID}/searches", users[0].ID).WithJSON(map[string]interface{}{"query": " "}).
+		Expect().Status(http.StatusBadRequest)
+	e.POST("/{userID}/searches", users[0].ID).WithText("{").
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.SearchDecodeFailure)
+
+	e.GET("/{userID}/searches", users[0].ID).Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/{userID}/searches", users[1].ID).Expect().Status(http.StatusOK).JSON().Array().Empty()
+
+	e.DELETE("/{userID}/searches/{searchID}", users[1].ID, id).
+		Expect().Status(http.StatusNotFound).Body().Contains(handlers.SearchNotFound)
+	e.DELETE("/{userID}/searches/{searchID}", users[0].ID, id).Expect().Status(http.StatusNoContent)
+	e.GET("/{userID}/searches", users[0].ID).Expect().JSON().Array().Empty()
+}
+
+func TestItemHandler_CreateAndSearch(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	server := httptest.NewServer(handlers.NewItemHandler(db).Routes())
+	defer server.Close()
+	e := httpexpect.New(t, server.URL)
+
+	closesAt := time.Now().Add(time.Hour).UTC()
+	e.POST("/").WithJSON(map[string]interface{}{"name": "Red fountain pen", "closesAt": closesAt.Format(config.DateLayout)}).
+		Expect().Status(http.StatusCreated)
+	e.POST("/").WithJSON(map[string]interface{}{"name": "Blue pencil"}).
+		Expect().Status(http.StatusCreated)
+	e.POST("/").WithJSON(map[string]interface{}{"name": "Too late", "closesAt": time.Now().Add(-time.Hour).Format(config.DateLayout)}).
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.ClosesAtInPast)
+
+	e.POST("/").WithJSON(map[string]interface{}{"name": "Stolen", "sellerID": uuid.NewV4()}).
+		Expect().Status(http.StatusBadRequest).Body().Contains(handlers.UnknownSeller)
+
+	e.GET("/").Expect().JSON().Array().Length().Equal(2)
+	e.GET("/").WithQuery(handlers.QueryParamSearch, "PEN").Expect().JSON().Array().Length().Equal(2)
+	found := e.GET("/").WithQuery(handlers.QueryParamSearch, "pen red").Expect().JSON().Array()
+	found.Length().Equal(1)
+	found.Element(0).Object().ValueEqual("closesAt", closesAt.Format(config.DateLayout))
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+// define error messages
+const (
+	WebhookDecodeFailure = "Failed to decode a webhook"
+	WebhookNotFound      = "Webhook not found"
+	DeadLetterNotFound   = "Dead letter not found"
+	RedeliveryFailure    = "Failed to redeliver"
+	WebhookForbidden     = "Not allowed to manage Webhooks"
+)
+
+//NewWebhookHandler initializes a new handler
+func NewWebhookHandler(registry *webhooks.Registry, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{registry: registry, dispatcher: dispatcher}
+}
+
+//WebhookHandler is the handler responsible for webhook subscriptions and failed deliveries
+type WebhookHandler struct {
+	registry   *webhooks.Registry
+	dispatcher *webhooks.Dispatcher
+	policy     *auth.Policy
+}
+
+//WithPolicy authorizes requests by policy: webhooks receive the events of all users, so only admins manage them
+func (e *WebhookHandler) WithPolicy(policy *auth.Policy) *WebhookHandler {
+	e.policy = policy
+	return e
+}
+
+//Routes returns the routes for the WebhookHandler
+func (e *WebhookHandler) Routes() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(render.SetContentType(render.ContentTypeJSON))
+	router.Use(authorize(e.policy, auth.PermissionManageWebhooks, WebhookForbidden))
+	router.Get("/", e.GetWebhooks)
+	router.Post("/", e.CreateWebhook)
+	router.Get("/dead-letters", e.GetDeadLetters)
+	router.Post("/dead-letters/{deliveryID}/retry", e.RetryDeadLetter)
+
+	router.Get("/{webhookID}", e.GetWebhook)
+	router.Delete("/{webhookID}", e.DeleteWebhook)
+	return router
+}
+
+// GetWebhooks returns the list of webhook subscriptions (without secrets)
+//
+// @summary Get a list of webhook subscriptions
+// @tags Webhooks
+// @response 200 {[]webhooks.Subscription} OK
+// @response 401 No credentials
+// @response 403 Not allowed to manage webhooks
+func (e *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs := e.registry.All()
+	for idx := range subs {
+		subs[idx] = subs[idx].Redacted()
+	}
+	render.JSON(w, r, subs)
+}
+
+// CreateWebhook subscribes a URL to events and returns the subscription with its signing secret
+//
+// @summary Subscribe a URL to auction events
+// @tags Webhooks
+// @body {webhooks.Subscription} The URL, the event types (all if empty) and the secret (generated if empty)
+// @response 201 {webhooks.Subscription} CREATED, with the secret of the subscription
+// @response 400 The URL or the event types are invalid
+// @response 401 No credentials
+// @response 403 Not allowed to manage webhooks
+func (e *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	sub := webhooks.Subscription{}
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		logging.LogError(WebhookDecodeFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(WebhookDecodeFailure), http.StatusBadRequest)
+		return
+	}
+	sub, err := e.registry.Add(sub)
+	if err == webhooks.ErrInvalidURL || err == webhooks.ErrUnknownEventType {
+		WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logging.LogError("Cannot create webhook", err)
+		WriteError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, sub)
+}
+
+// GetWebhook returns a webhook subscription (without secret)
+//
+// @summary Get a webhook subscription
+// @tags Webhooks
+// @response 200 {webhooks.Subscription} OK
+// @response 400 The webhookID is invalid
+// @response 401 No credentials
+// @response 403 Not allowed to manage webhooks
+// @response 404 Webhook not found
+func (e *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(w, r, "webhookID")
+	if err != nil {
+		return
+	}
+	sub, err := e.registry.Get(id)
+	if err != nil {
+		WriteHTTPErrorCode(w, r, errors.New(WebhookNotFound), http.StatusNotFound)
+		return
+	}
+	render.JSON(w, r, sub.Redacted())
+}
+
+// DeleteWebhook removes a webhook subscription, pending retries to it are abandoned
+//
+// @summary Remove a webhook subscription
+// @tags Webhooks
+// @response 204 NO CONTENT
+// @response 400 The webhookID is invalid
+// @response 401 No credentials
+// @response 403 Not allowed to manage webhooks
+// @response 404 Webhook not found
+func (e *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(w, r, "webhookID")
+	if err != nil {
+		return
+	}
+	if err := e.registry.Remove(id); err != nil {
+		WriteHTTPErrorCode(w, r, errors.New(WebhookNotFound), http.StatusNotFound)
+		return
+	}
+	WriteHTTPCode(w, http.StatusNoContent)
+}
+
+// GetDeadLetters returns the deliveries that have failed after all attempts
+//
+// @summary Get deliveries that have failed after all attempts
+// @tags Webhooks
+// @response 200 {[]webhooks.Delivery} OK
+// @response 401 No credentials
+// @response 403 Not allowed to manage webhooks
+func (e *WebhookHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, e.dispatcher.DeadLetters())
+}
+
+// RetryDeadLetter queues a failed delivery again
+//
+// @summary Queue a failed delivery again
+// @tags Webhooks
+// @response 202 ACCEPTED
+// @response 400 The deliveryID is invalid
+// @response 401 No credentials
+// @response 403 Not allowed to manage webhooks
+// @response 404 There is no such dead letter
+func (e *WebhookHandler) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(w, r, "deliveryID")
+	if err != nil {
+		return
+	}
+	err = e.dispatcher.Redeliver(id)
+	if err == webhooks.ErrDeadLetterNotFound {
+		WriteHTTPErrorCode(w, r, errors.New(DeadLetterNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.LogError(RedeliveryFailure, err)
+		WriteHTTPErrorCode(w, r, errors.New(RedeliveryFailure), http.StatusServiceUnavailable)
+		return
+	}
+	WriteHTTPCode(w, http.StatusAccepted)
+}
+
+// parseUUIDParam parses the URLParam name and sends the HTTPError Response on failure
+func parseUUIDParam(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.FromString(chi.URLParam(r, name))
+	if err != nil {
+		logging.LogError("Error parsing URL parameter to UUID", err)
+		WriteHTTPErrorCode(w, r, errors.New("Malformed URL Parameter"), http.StatusBadRequest)
+		return uuid.UUID{}, err
+	}
+	return id, nil
+}
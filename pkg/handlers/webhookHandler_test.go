@@ -0,0 +1,93 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+func newWebhookServer() (storage.Storage, *webhooks.Dispatcher, *httptest.Server) {
+	db := storage.NewMapBiddingSystem()
+	registry := webhooks.NewRegistry()
+	dispatcher := webhooks.NewDispatcher(registry, webhooks.Config{MaxAttempts: 1})
+	dispatcher.Start()
+	db.Subscribe(dispatcher)
+	return db, dispatcher, httptest.NewServer(handlers.NewWebhookHandler(registry, dispatcher).Routes())
+}
+
+func TestWebhookHandler_CRUD(t *testing.T) {
+	_, dispatcher, server := newWebhookServer()
+	defer server.Close()
+	defer dispatcher.Stop()
+
+	e := httpexpect.New(t, server.URL)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Empty()
+
+	created := e.POST("/").WithJSON(map[string]interface{}{"url": "https://example.com/hook", "events": []string{"outbid"}}).
+		Expect().Status(http.StatusCreated).JSON().Object()
+	created.Value("secret").String().NotEmpty()
+	created.Value("events").Array().Elements("outbid")
+	id := created.Value("id").String().Raw()
+
+	e.GET("/{webhookID}", id).Expect().Status(http.StatusOK).JSON().Object().
+		ValueEqual("url", "https://example.com/hook").NotContainsKey("secret")
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+
+	e.DELETE("/{webhookID}", id).Expect().Status(http.StatusNoContent)
+	e.GET("/{webhookID}", id).Expect().Status(http.StatusNotFound)
+	e.DELETE("/{webhookID}", id).Expect().Status(http.StatusNotFound)
+	e.GET("/{webhookID}", "not-a-uuid").Expect().Status(http.StatusBadRequest)
+}
+
+func TestWebhookHandler_CreateWebhook_BadRequest(t *testing.T) {
+	_, dispatcher, server := newWebhookServer()
+	defer server.Close()
+	defer dispatcher.Stop()
+
+	e := httpexpect.New(t, server.URL)
+	e.POST("/").WithText("{").Expect().Status(http.StatusBadRequest)
+	e.POST("/").WithJSON(map[string]interface{}{"url": "not a url"}).Expect().Status(http.StatusBadRequest)
+	e.POST("/").WithJSON(map[string]interface{}{"url": "https://example.com", "events": []string{"bid"}}).
+		Expect().Status(http.StatusBadRequest)
+}
+
+func TestWebhookHandler_DeadLetters(t *testing.T) {
+	db, dispatcher, server := newWebhookServer()
+	defer server.Close()
+	defer dispatcher.Stop()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	e := httpexpect.New(t, server.URL)
+	e.POST("/").WithJSON(map[string]interface{}{"url": receiver.URL}).Expect().Status(http.StatusCreated)
+
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	for i := 0; len(dispatcher.DeadLetters()) == 0; i++ {
+		require.True(t, i < 500, "Delivery should be dead-lettered")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	letters := e.GET("/dead-letters").Expect().Status(http.StatusOK).JSON().Array()
+	letters.Length().Equal(1)
+	letter := letters.Element(0).Object()
+	letter.ValueEqual("attempts", 1)
+	letter.Value("payload").Object().ValueEqual("type", "new-bid")
+
+	e.POST("/dead-letters/{deliveryID}/retry", letter.Value("id").String().Raw()).Expect().Status(http.StatusAccepted)
+	e.POST("/dead-letters/{deliveryID}/retry", uuid.NewV4()).Expect().Status(http.StatusNotFound)
+}
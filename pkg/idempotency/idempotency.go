@@ -0,0 +1,154 @@
+// Package idempotency lets clients retry requests safely. A client sends a unique key with a request; the response
+// is stored under the key for a while and returned again for retries with the same key, instead of repeating the request.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// define errors
+var (
+	ErrKeyReused  = errors.New("Idempotency-Key has been used already for a different request")
+	ErrKeyTooLong = errors.New("Idempotency-Key must not be longer than 255 characters")
+	ErrNotStarted = errors.New("No request is in progress for this Idempotency-Key")
+)
+
+//MaxKeyLength is the maximum length of keys
+const MaxKeyLength = 255
+
+//pruneInterval is how often expired responses are removed
+const pruneInterval = time.Minute
+
+//Fingerprint identifies a request by its method, path and body - retries must send the same
+type Fingerprint [sha256.Size]byte
+
+//NewFingerprint computes the fingerprint of a request
+func NewFingerprint(method, path string, body []byte) Fingerprint {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	var f Fingerprint
+	copy(f[:], h.Sum(nil))
+	return f
+}
+
+//Response is a stored response
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type entry struct {
+	fingerprint Fingerprint
+	//done is closed when the request is completed or released
+	done      chan struct{}
+	response  *Response
+	expiresAt time.Time
+}
+
+//Store keeps responses by key for ttl
+type Store struct {
+	mutex     sync.Mutex
+	entries   map[string]*entry
+	ttl       time.Duration
+	now       func() time.Time
+	nextPrune time.Time
+}
+
+//NewStore creates a store keeping responses for ttl
+func NewStore(ttl time.Duration) *Store {
+	return &Store{entries: map[string]*entry{}, ttl: ttl, now: time.Now}
+}
+
+//WithClock replaces the clock - for tests
+func (s *Store) WithClock(now func() time.Time) *Store {
+	s.now = now
+	return s
+}
+
+//Begin starts the request with key. It returns the stored response if the request has been completed already -
+//otherwise the caller must handle the request and call Complete or Release. While a request is in progress,
+//Begin waits for it, so that duplicates are handled one after another. Requests reusing a key with another
+//fingerprint get ErrKeyReused.
+func (s *Store) Begin(ctx context.Context, key string, fingerprint Fingerprint) (*Response, error) {
+	if len(key) > MaxKeyLength {
+		return nil, ErrKeyTooLong
+	}
+	for {
+		s.mutex.Lock()
+		now := s.now()
+		s.prune(now)
+		e, ok := s.entries[key]
+		if ok && e.response != nil && !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+			ok = false
+		}
+		if !ok {
+			s.entries[key] = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.mutex.Unlock()
+			return nil, nil
+		}
+		s.mutex.Unlock()
+		if e.fingerprint != fingerprint {
+			return nil, ErrKeyReused
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if e.response != nil {
+			return e.response, nil
+		}
+		// the request has been released - try to handle it again
+	}
+}
+
+//Complete stores the response of the request with key and passes it to the duplicates waiting for it
+func (s *Store) Complete(key string, response *Response) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.entries[key]
+	if !ok || e.response != nil {
+		return ErrNotStarted
+	}
+	e.response = response
+	e.expiresAt = s.now().Add(s.ttl)
+	close(e.done)
+	return nil
+}
+
+//Release forgets the request with key without a response, e.g., after a server error, so that it can be retried
+func (s *Store) Release(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.entries[key]
+	if !ok || e.response != nil {
+		return ErrNotStarted
+	}
+	delete(s.entries, key)
+	close(e.done)
+	return nil
+}
+
+//prune removes expired responses - mutex must be held
+func (s *Store) prune(now time.Time) {
+	if now.Before(s.nextPrune) {
+		return
+	}
+	s.nextPrune = now.Add(pruneInterval)
+	for key, e := range s.entries {
+		if e.response != nil && !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
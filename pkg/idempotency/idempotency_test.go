@@ -0,0 +1,91 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/idempotency"
+)
+
+func Test_Store(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := idempotency.NewStore(time.Hour).WithClock(func() time.Time { return now })
+	ctx := context.Background()
+	fingerprint := idempotency.NewFingerprint("POST", "/item/1/bids", []byte(`{"amount": 10}`))
+	other := idempotency.NewFingerprint("POST", "/item/1/bids", []byte(`{"amount": 11}`))
+	assert.NotEqual(t, fingerprint, other)
+
+	stored, err := store.Begin(ctx, "key", fingerprint)
+	require.NoError(t, err)
+	assert.Nil(t, stored, "The first request is handled")
+	response := &idempotency.Response{Status: http.StatusCreated, Header: http.Header{}, Body: []byte("created")}
+	require.NoError(t, store.Complete("key", response))
+	assert.Equal(t, idempotency.ErrNotStarted, store.Complete("key", response))
+
+	stored, err = store.Begin(ctx, "key", fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, response, stored, "Retries get the stored response")
+	_, err = store.Begin(ctx, "key", other)
+	assert.Equal(t, idempotency.ErrKeyReused, err)
+	stored, err = store.Begin(ctx, "another key", other)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	require.NoError(t, store.Release("another key"))
+	stored, err = store.Begin(ctx, "another key", other)
+	require.NoError(t, err)
+	assert.Nil(t, stored, "Released requests are handled again")
+
+	now = now.Add(time.Hour)
+	stored, err = store.Begin(ctx, "key", other)
+	require.NoError(t, err)
+	assert.Nil(t, stored, "Keys can be reused after the TTL")
+
+	_, err = store.Begin(ctx, string(make([]byte, idempotency.MaxKeyLength+1)), fingerprint)
+	assert.Equal(t, idempotency.ErrKeyTooLong, err)
+}
+
+func Test_Store_Concurrent(t *testing.T) {
+	store := idempotency.NewStore(time.Hour)
+	fingerprint := idempotency.NewFingerprint("POST", "/item/1/bids", nil)
+	handled := 0
+	responses := make([]*idempotency.Response, 16)
+	wg := sync.WaitGroup{}
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stored, err := store.Begin(context.Background(), "key", fingerprint)
+			require.NoError(t, err)
+			if stored == nil {
+				// only one duplicate is handled at a time - the others wait for its response
+				handled++
+				time.Sleep(10 * time.Millisecond)
+				stored = &idempotency.Response{Status: http.StatusCreated}
+				require.NoError(t, store.Complete("key", stored))
+			}
+			responses[i] = stored
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, handled)
+	for _, response := range responses {
+		assert.Equal(t, http.StatusCreated, response.Status)
+	}
+}
+
+func Test_Store_Cancel(t *testing.T) {
+	store := idempotency.NewStore(time.Hour)
+	fingerprint := idempotency.NewFingerprint("POST", "/item/1/bids", nil)
+	_, err := store.Begin(context.Background(), "key", fingerprint)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = store.Begin(ctx, "key", fingerprint)
+	assert.Equal(t, context.DeadlineExceeded, err, "Duplicates stop waiting when their client is gone")
+}
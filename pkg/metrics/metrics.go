@@ -0,0 +1,64 @@
+// Package metrics counts auction activity. Counters subscribe to the events published by the storage,
+// so no handler has to report anything.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+)
+
+//Snapshot holds the values of the counters at a point in time
+type Snapshot struct {
+	ItemsListed     uint64    `json:"itemsListed"`
+	UsersRegistered uint64    `json:"usersRegistered"`
+	BidsPlaced      uint64    `json:"bidsPlaced"`
+	Outbids         uint64    `json:"outbids"`
+	AuctionsClosed  uint64    `json:"auctionsClosed"`
+	BidVolume       float64   `json:"bidVolume"`
+	LastEventSeq    uint64    `json:"lastEventSeq"`
+	LastEventAt     time.Time `json:"lastEventAt"`
+}
+
+//Counters count the events applied to them
+type Counters struct {
+	mutex    sync.RWMutex
+	snapshot Snapshot
+}
+
+//NewCounters creates counters starting at zero
+func NewCounters() *Counters {
+	return &Counters{}
+}
+
+//Apply implements events.Projection
+func (c *Counters) Apply(r events.Record) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := &c.snapshot
+	switch e := r.Event.(type) {
+	case events.ItemListed:
+		s.ItemsListed++
+	case events.UserRegistered:
+		s.UsersRegistered++
+	case events.BidPlaced:
+		s.BidsPlaced++
+		s.BidVolume += e.Bid.Amount
+		if e.Outbid != nil {
+			s.Outbids++
+		}
+	case events.AuctionClosed:
+		s.AuctionsClosed++
+	}
+	s.LastEventSeq = r.Seq
+	s.LastEventAt = r.At
+}
+
+//Snapshot returns the current values
+func (c *Counters) Snapshot() Snapshot {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.snapshot
+}
@@ -0,0 +1,50 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/metrics"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func Test_Counters(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	counters := metrics.NewCounters()
+	db.Subscribe(counters)
+
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 3)
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+	db.PlaceBid(models.NewBid(items[1].ID, users[1].ID, 5))
+	db.CloseAuction(items[0].ID)
+
+	snapshot := counters.Snapshot()
+	assert.Equal(t, uint64(3), snapshot.ItemsListed)
+	assert.Equal(t, uint64(2), snapshot.UsersRegistered)
+	assert.Equal(t, uint64(3), snapshot.BidsPlaced)
+	assert.Equal(t, uint64(1), snapshot.Outbids)
+	assert.Equal(t, uint64(1), snapshot.AuctionsClosed)
+	assert.Equal(t, 35.0, snapshot.BidVolume)
+	assert.Equal(t, uint64(9), snapshot.LastEventSeq)
+}
+
+func Test_Counters_Async(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	counters := metrics.NewCounters()
+	// the buffer takes all events - publishing drops events that do not fit
+	unsubscribe := db.Subscribe(counters, events.Async(100))
+
+	testutils.CreateTestItems(db, 100)
+	unsubscribe()
+
+	for counters.Snapshot().ItemsListed < 100 {
+		// the queued events are still handled after unsubscribing
+	}
+	assert.Equal(t, uint64(100), counters.Snapshot().ItemsListed)
+}
@@ -10,7 +10,23 @@ const (
 	BidListFailure     = "Failed listing bid"
 )
 
-// Bid model
+//BidStatus is the standing of a bid in the auction of its item
+type BidStatus string
+
+// define the standings of bids
+const (
+	//BidStatusWinning is the highest bid on an item whose auction is open
+	BidStatusWinning BidStatus = "winning"
+	//BidStatusOutbid is a bid that a higher one has overtaken while the auction is open
+	BidStatusOutbid BidStatus = "outbid"
+	//BidStatusWon is the highest bid on an item whose auction has closed with the reserve price met
+	BidStatusWon BidStatus = "won"
+	//BidStatusLost is any other bid on an item whose auction has closed
+	BidStatusLost BidStatus = "lost"
+)
+
+// Bid model. A bid is immutable once it has been placed: the storage keeps a copy of the bid passed to PlaceBid,
+// and the bids it returns are shared by all readers (and events), so they must not be modified.
 type Bid struct {
 	BaseModel
 	ItemID uuid.UUID `json:"itemID"`
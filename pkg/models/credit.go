@@ -0,0 +1,100 @@
+package models
+
+import (
+	uuid "github.com/satori/go.uuid"
+)
+
+// define errors of credit limits
+var (
+	ErrCreditExceeded = NewError(KindCreditExceeded, "credit_exceeded", "Bid exceeds the available credit of the user",
+		FieldError{Field: "amount", Message: "must not exceed the available credit"})
+	ErrNegativeCredit = NewError(KindInvalid, "negative_credit", "Credit limit and deposit must not be negative",
+		FieldError{Field: "creditLimit", Message: "must not be negative"}, FieldError{Field: "deposit", Message: "must not be negative"})
+)
+
+//Credit is how much a user may owe: the sum of the winning bids of the user (the exposure)
+//must not exceed CreditLimit and Deposit together. Users without a limit may bid any amount.
+type Credit struct {
+	Limited     bool    `json:"limited"`
+	CreditLimit float64 `json:"creditLimit"`
+	Deposit     float64 `json:"deposit"`
+	Exposure    float64 `json:"exposure"`
+	// Available is what is left of CreditLimit and Deposit - only meaningful if Limited
+	Available float64 `json:"available"`
+}
+
+//Validate checks that the limit and the deposit are not negative
+func (c Credit) Validate() error {
+	if c.CreditLimit < 0 || c.Deposit < 0 {
+		return ErrNegativeCredit
+	}
+	return nil
+}
+
+//SetCredit sets the credit limit and the deposit of the user - limited false removes the limit
+func (u *User) SetCredit(limited bool, creditLimit, deposit float64) {
+	u.mutexCredit.Lock()
+	defer u.mutexCredit.Unlock()
+	u.credit.Limited = limited
+	u.credit.CreditLimit = creditLimit
+	u.credit.Deposit = deposit
+}
+
+//GetCredit returns the credit of the user with the current exposure
+func (u *User) GetCredit() Credit {
+	u.mutexCredit.RLock()
+	defer u.mutexCredit.RUnlock()
+	credit := u.credit
+	credit.Exposure = u.exposure()
+	credit.Available = credit.CreditLimit + credit.Deposit - credit.Exposure
+	return credit
+}
+
+//Reserve exposes the user on the item with a bid of amount if the user can afford it, and returns false otherwise.
+//The winning bid of the user on the same item, if any, is replaced by the new one, so it does not count.
+//The check and the exposure are made under one lock, so that concurrent bids cannot both take the same credit.
+func (u *User) Reserve(itemID uuid.UUID, amount float64) bool {
+	u.mutexCredit.Lock()
+	defer u.mutexCredit.Unlock()
+	if u.credit.Limited && u.exposure()-u.exposed[itemID]+amount > u.credit.CreditLimit+u.credit.Deposit {
+		return false
+	}
+	u.exposed[itemID] = amount
+	return true
+}
+
+//Expose records that the bid of amount of the user is winning the item, replacing a previous winning bid of the user on it
+func (u *User) Expose(itemID uuid.UUID, amount float64) {
+	u.mutexCredit.Lock()
+	defer u.mutexCredit.Unlock()
+	u.exposed[itemID] = amount
+}
+
+//Release removes the exposure of the user on the item - e.g., when the user has been outbid.
+//It returns false if the user has not been exposed on the item.
+func (u *User) Release(itemID uuid.UUID) bool {
+	u.mutexCredit.Lock()
+	defer u.mutexCredit.Unlock()
+	if _, ok := u.exposed[itemID]; !ok {
+		return false
+	}
+	delete(u.exposed, itemID)
+	return true
+}
+
+//IsExposed returns true if the user has a winning bid on the item that counts against the credit
+func (u *User) IsExposed(itemID uuid.UUID) bool {
+	u.mutexCredit.RLock()
+	defer u.mutexCredit.RUnlock()
+	_, ok := u.exposed[itemID]
+	return ok
+}
+
+//exposure sums the winning bids - mutexCredit must be held
+func (u *User) exposure() float64 {
+	sum := 0.0
+	for _, amount := range u.exposed {
+		sum += amount
+	}
+	return sum
+}
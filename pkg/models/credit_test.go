@@ -0,0 +1,31 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+func Test_User_Credit(t *testing.T) {
+	user := models.NewUser("James Bond")
+	first, second := models.NewItem("A thing"), models.NewItem("Another thing")
+	assert.True(t, user.Reserve(first.ID, 1e9), "Users without limit can afford anything")
+
+	user.SetCredit(true, 100, 50)
+	user.Expose(first.ID, 120)
+	assert.False(t, user.Reserve(second.ID, 31))
+	assert.Equal(t, 120.0, user.GetCredit().Exposure, "A bid that cannot be afforded reserves nothing")
+	assert.True(t, user.Reserve(first.ID, 150), "Own winning bid on the item is replaced")
+	assert.True(t, user.Reserve(first.ID, 120))
+	assert.True(t, user.Reserve(second.ID, 30))
+
+	credit := user.GetCredit()
+	assert.Equal(t, 150.0, credit.Exposure)
+	assert.Equal(t, 0.0, credit.Available)
+
+	assert.True(t, user.Release(first.ID))
+	assert.False(t, user.Release(first.ID))
+	assert.Equal(t, 30.0, user.GetCredit().Exposure)
+	assert.Error(t, models.Credit{Limited: true, Deposit: -1}.Validate())
+}
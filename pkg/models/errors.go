@@ -0,0 +1,56 @@
+package models
+
+import "errors"
+
+//Kind classifies errors by what a client can do about them - handlers map kinds to status codes
+type Kind int
+
+// define kinds of errors
+const (
+	//KindInternal is a failure of the server
+	KindInternal Kind = iota
+	//KindInvalid is a malformed or invalid request
+	KindInvalid
+	//KindNotFound is a request for a resource that does not exist
+	KindNotFound
+	//KindConflict is a request that conflicts with the state of a resource, e.g., a bid on a closed auction
+	KindConflict
+	//KindRejected is a valid request breaking a business rule, e.g., a bid below the minimum increment
+	KindRejected
+	//KindCreditExceeded is a bid that the bidder cannot pay for
+	KindCreditExceeded
+)
+
+//FieldError tells which field of a request is invalid and why
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//Error is an error with a stable code for clients. Errors are compared by identity, so they are declared once
+//and returned as they are.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+//NewError creates an error of kind with code (snake_case, never changed once published) and message
+func NewError(kind Kind, code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Fields: fields}
+}
+
+//Error implements error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+//KindOf returns the kind of err - KindInternal if err is not an *Error
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
@@ -0,0 +1,17 @@
+package models_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+func Test_KindOf(t *testing.T) {
+	assert.Equal(t, models.KindConflict, models.KindOf(models.ErrAuctionClosed))
+	assert.Equal(t, models.KindRejected, models.KindOf(fmt.Errorf("Cannot place bid: %w", models.ErrBelowIncrement)))
+	assert.Equal(t, models.KindInternal, models.KindOf(errors.New("Disk full")))
+	assert.Equal(t, models.KindInternal, models.KindOf(nil))
+}
@@ -1,8 +1,11 @@
 package models
 
 import (
-	"errors"
+	"sort"
 	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
 )
 
 // define error messages
@@ -11,64 +14,231 @@ const (
 	ItemListFailure     = "Failed listing item"
 )
 
+//ErrAuctionClosed is returned when bidding on an item whose auction has ended
+var ErrAuctionClosed = NewError(KindConflict, "auction_closed", "Auction is closed")
+
+//ErrAuctionOpen is returned when an operation requires the auction to have ended
+var ErrAuctionOpen = NewError(KindConflict, "auction_open", "Auction is still open")
+
+//ErrNoBids is returned when an item has no winning bid (at the time asked for)
+var ErrNoBids = NewError(KindNotFound, "no_bids", "Cannot find valid bids on this item")
+
 // Item model
 type Item struct {
 	BaseModel
 	Name string `json:"name"`
+	// SellerID is the user selling the item - zero if the item has been listed without a seller
+	SellerID uuid.UUID `json:"sellerID"`
+	// ReservePrice is the lowest price the item is sold for - 0 if there is no reserve
+	ReservePrice float64 `json:"reservePrice,omitempty"`
+	// RelistedFrom is the item this one has been relisted from - zero for items listed for the first time
+	RelistedFrom uuid.UUID `json:"relistedFrom"`
+	// ClosesAt is the announced end of the auction - nil if the auction is open until closed explicitly
+	ClosesAt *time.Time `json:"closesAt,omitempty"`
 
+	// mutexWrite serializes the changes of the item - see Lock
+	mutexWrite sync.Mutex
+
+	// mutexBids guards bids, winningBid, maxBidAmount and closed, so that readers always see them consistent with each other
 	mutexBids sync.RWMutex
-	bids      []*Bid
+	closed    bool
+	// bids is append-only - elements within len(bids) are never modified
+	bids []*Bid
+	// bidsAcceptedAt holds the time each of bids has been accepted - it is sorted
+	bidsAcceptedAt []time.Time
+	// winners is the history of the winning bid - sorted by time
+	winners []winnerChange
+
+	winningBid   *Bid
+	maxBidAmount float64
+
+	mutexWatchers sync.RWMutex
+	// watchers are the IDs of the users watching the item, in the order they started watching
+	watchers []uuid.UUID
+}
 
-	mutexBestBid sync.RWMutex
-	WinningBid   *Bid    `json:"-"`
-	MaxBidAmount float64 `json:"-"`
+// winnerChange records that Bid has become the winning bid at time At
+type winnerChange struct {
+	At  time.Time
+	Bid *Bid
 }
 
 //NewItem creates an Item
 func NewItem(name string) *Item {
 	return &Item{
-		BaseModel:    NewBaseModel(),
-		Name:         name,
-		bids:         make([]*Bid, 0),
-		MaxBidAmount: float64(0.0),
-		WinningBid:   nil,
+		BaseModel: NewBaseModel(),
+		Name:      name,
+		bids:      make([]*Bid, 0),
 	}
 }
 
-//PlaceNewBid handles the bid placement
-func (i *Item) PlaceNewBid(bid *Bid) {
-	i.UpdateBestBid(bid)
+//Lock locks the item for a change: a change is decided and applied while the lock is held,
+//so that the changes of an item are made one at a time. Readers do not need it.
+func (i *Item) Lock() {
+	i.mutexWrite.Lock()
+}
+
+//Unlock unlocks the item - see Lock
+func (i *Item) Unlock() {
+	i.mutexWrite.Unlock()
+}
 
+//PlaceNewBid handles the bid placement. acceptedAt is the time the bid has been accepted by the system,
+//it must not be earlier than for the bids placed before.
+func (i *Item) PlaceNewBid(bid *Bid, acceptedAt time.Time) {
 	i.mutexBids.Lock()
 	defer i.mutexBids.Unlock()
+
 	i.bids = append(i.bids, bid)
+	i.bidsAcceptedAt = append(i.bidsAcceptedAt, acceptedAt)
+	i.updateBestBid(bid, acceptedAt)
 }
 
-//GetBids handles the bid placement
+//GetBids returns an immutable snapshot of bids placed on the item so far.
+//Bids placed later are not visible in the snapshot. The returned slice must not be modified, nor the bids (see Bid).
 func (i *Item) GetBids() []*Bid {
 	i.mutexBids.RLock()
 	defer i.mutexBids.RUnlock()
-	return i.bids
+	return snapshotBids(i.bids)
+}
+
+//GetBidsAsOf returns an immutable snapshot of bids that had been accepted until asOf (inclusive) - O(log n)
+func (i *Item) GetBidsAsOf(asOf time.Time) []*Bid {
+	i.mutexBids.RLock()
+	defer i.mutexBids.RUnlock()
+
+	n := sort.Search(len(i.bidsAcceptedAt), func(k int) bool {
+		return i.bidsAcceptedAt[k].After(asOf)
+	})
+	return snapshotBids(i.bids[:n])
 }
 
 //UpdateBestBid updates information about currently best bid
 func (i *Item) UpdateBestBid(bid *Bid) {
-	i.mutexBestBid.Lock()
-	defer i.mutexBestBid.Unlock()
+	i.mutexBids.Lock()
+	defer i.mutexBids.Unlock()
+	i.updateBestBid(bid, time.Now())
+}
 
-	if bid.Amount > i.MaxBidAmount {
-		i.MaxBidAmount = bid.Amount
-		i.WinningBid = bid
+func (i *Item) updateBestBid(bid *Bid, at time.Time) {
+	if bid.Amount > i.maxBidAmount {
+		i.maxBidAmount = bid.Amount
+		i.winningBid = bid
+		i.winners = append(i.winners, winnerChange{At: at, Bid: bid})
 	}
 }
 
 //GetWinningBid updates information about currently best bid
 func (i *Item) GetWinningBid() (*Bid, error) {
-	i.mutexBestBid.RLock()
-	defer i.mutexBestBid.RUnlock()
+	i.mutexBids.RLock()
+	defer i.mutexBids.RUnlock()
+
+	if i.winningBid == nil {
+		return nil, ErrNoBids
+	}
+	return i.winningBid, nil
+}
+
+//GetMaxBidAmount returns the amount of the winning bid - 0 if there is none
+func (i *Item) GetMaxBidAmount() float64 {
+	i.mutexBids.RLock()
+	defer i.mutexBids.RUnlock()
+	return i.maxBidAmount
+}
 
-	if i.WinningBid == nil {
-		return nil, errors.New("Cannot find valid bids on this item")
+//GetWinningBidAsOf returns the bid that was winning at asOf - O(log n)
+func (i *Item) GetWinningBidAsOf(asOf time.Time) (*Bid, error) {
+	i.mutexBids.RLock()
+	defer i.mutexBids.RUnlock()
+
+	n := sort.Search(len(i.winners), func(k int) bool {
+		return i.winners[k].At.After(asOf)
+	})
+	if n == 0 {
+		return nil, ErrNoBids
+	}
+	return i.winners[n-1].Bid, nil
+}
+
+//BidStatus returns the standing of bid, one of the bids on the item, in its auction
+func (i *Item) BidStatus(bid *Bid) BidStatus {
+	winning, err := i.GetWinningBid()
+	leading := err == nil && winning.ID == bid.ID
+	switch {
+	case !i.IsClosed() && leading:
+		return BidStatusWinning
+	case !i.IsClosed():
+		return BidStatusOutbid
+	case leading && i.IsReserveMet():
+		return BidStatusWon
 	}
-	return i.WinningBid, nil
+	return BidStatusLost
+}
+
+//Close ends the auction on the item
+func (i *Item) Close() {
+	i.mutexBids.Lock()
+	defer i.mutexBids.Unlock()
+	i.closed = true
+}
+
+//IsReserveMet returns true if the item has no reserve price or the winning bid reaches it
+func (i *Item) IsReserveMet() bool {
+	if i.ReservePrice <= 0 {
+		return true
+	}
+	winning, err := i.GetWinningBid()
+	return err == nil && winning.Amount >= i.ReservePrice
+}
+
+//IsClosed returns true if the auction on the item has ended
+func (i *Item) IsClosed() bool {
+	i.mutexBids.RLock()
+	defer i.mutexBids.RUnlock()
+	return i.closed
+}
+
+//AddWatcher adds the user to the watchers of the item - it returns false if the user is watching already
+func (i *Item) AddWatcher(userID uuid.UUID) bool {
+	i.mutexWatchers.Lock()
+	defer i.mutexWatchers.Unlock()
+	for _, id := range i.watchers {
+		if id == userID {
+			return false
+		}
+	}
+	i.watchers = append(i.watchers, userID)
+	return true
+}
+
+//RemoveWatcher removes the user from the watchers of the item - it returns false if the user has not been watching
+func (i *Item) RemoveWatcher(userID uuid.UUID) bool {
+	i.mutexWatchers.Lock()
+	defer i.mutexWatchers.Unlock()
+	for k, id := range i.watchers {
+		if id == userID {
+			i.watchers = append(i.watchers[:k:k], i.watchers[k+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+//GetWatchers returns a copy of the IDs of the users watching the item
+func (i *Item) GetWatchers() []uuid.UUID {
+	i.mutexWatchers.RLock()
+	defer i.mutexWatchers.RUnlock()
+	return append([]uuid.UUID{}, i.watchers...)
+}
+
+//ClosesWithin returns true if the auction is open and announced to close after from, but not later than until
+func (i *Item) ClosesWithin(from, until time.Time) bool {
+	return i.ClosesAt != nil && i.ClosesAt.After(from) && !i.ClosesAt.After(until) && !i.IsClosed()
+}
+
+//snapshotBids returns a view of the append-only slice s limited to its current length.
+//As the capacity equals the length, appending to the view always reallocates
+//and never overwrites elements appended to s later.
+func snapshotBids(s []*Bid) []*Bid {
+	return s[:len(s):len(s)]
 }
@@ -2,7 +2,10 @@ package models_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
 	"github.com/vikin91/bid-tracker-go/pkg/models"
 )
 
@@ -11,3 +14,88 @@ func Benchmark_Reference_NewItem(b *testing.B) {
 		models.NewItem("A name")
 	}
 }
+
+func Test_Item_GetBids_Snapshot(t *testing.T) {
+	item := models.NewItem("A thing")
+	first := models.NewBid(item.ID, config.ZeroUUID, 1.0)
+	item.PlaceNewBid(first, time.Now())
+
+	snapshot := item.GetBids()
+	item.PlaceNewBid(models.NewBid(item.ID, config.ZeroUUID, 2.0), time.Now())
+
+	assert.Equal(t, []*models.Bid{first}, snapshot, "Snapshot must not see bids placed later")
+
+	// appending to a snapshot must not overwrite bids placed later
+	snapshot = append(snapshot, models.NewBid(item.ID, config.ZeroUUID, 3.0))
+	bids := item.GetBids()
+	assert.Equal(t, 2, len(bids))
+	assert.Equal(t, 2.0, bids[1].Amount)
+}
+
+func Benchmark_Item_GetBids(b *testing.B) {
+	item := models.NewItem("A thing")
+	for n := 0; n < 100; n++ {
+		item.PlaceNewBid(models.NewBid(item.ID, config.ZeroUUID, float64(n)), time.Now())
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		item.GetBids()
+	}
+}
+
+func Test_Item_AsOf(t *testing.T) {
+	item := models.NewItem("A thing")
+	start := time.Now()
+	low := models.NewBid(item.ID, config.ZeroUUID, 1.0)
+	high := models.NewBid(item.ID, config.ZeroUUID, 3.0)
+	lower := models.NewBid(item.ID, config.ZeroUUID, 2.0)
+	item.PlaceNewBid(low, start.Add(1*time.Minute))
+	item.PlaceNewBid(high, start.Add(2*time.Minute))
+	item.PlaceNewBid(lower, start.Add(3*time.Minute))
+
+	tests := []struct {
+		name       string
+		asOf       time.Time
+		wantBids   []*models.Bid
+		wantWinner *models.Bid
+	}{
+		{"Before any bid", start, []*models.Bid{}, nil},
+		{"At the first bid", start.Add(1 * time.Minute), []*models.Bid{low}, low},
+		{"Between bids", start.Add(90 * time.Second), []*models.Bid{low}, low},
+		{"After higher bid", start.Add(2 * time.Minute), []*models.Bid{low, high}, high},
+		{"After lower bid", start.Add(time.Hour), []*models.Bid{low, high, lower}, high},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantBids, item.GetBidsAsOf(tt.asOf))
+			winner, err := item.GetWinningBidAsOf(tt.asOf)
+			if tt.wantWinner == nil {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWinner, winner)
+		})
+	}
+}
+
+func Test_Item_BidStatus(t *testing.T) {
+	item := models.NewItem("A thing")
+	item.ReservePrice = 2.0
+	low := models.NewBid(item.ID, config.ZeroUUID, 1.0)
+	item.PlaceNewBid(low, time.Now())
+	assert.Equal(t, models.BidStatusWinning, item.BidStatus(low))
+	high := models.NewBid(item.ID, config.ZeroUUID, 3.0)
+	item.PlaceNewBid(high, time.Now())
+	assert.Equal(t, models.BidStatusOutbid, item.BidStatus(low))
+	assert.Equal(t, models.BidStatusWinning, item.BidStatus(high))
+
+	item.Close()
+	assert.Equal(t, models.BidStatusLost, item.BidStatus(low))
+	assert.Equal(t, models.BidStatusWon, item.BidStatus(high))
+
+	// the highest bid loses if it misses the reserve price
+	item.ReservePrice = 5.0
+	assert.Equal(t, models.BidStatusLost, item.BidStatus(high))
+}
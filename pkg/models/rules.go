@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+)
+
+// define errors of bidding rules
+var (
+	ErrBelowOpeningBid = NewError(KindRejected, "below_opening_bid", "Bid is below the minimum opening bid",
+		FieldError{Field: "amount", Message: "must not be below the minimum opening bid"})
+	ErrBelowIncrement = NewError(KindRejected, "below_increment", "Bid does not exceed the winning bid by the minimum increment",
+		FieldError{Field: "amount", Message: "must exceed the winning bid by the minimum increment"})
+)
+
+//Rules constrain the bids of an auction house - the zero value accepts every bid
+type Rules struct {
+	//MinOpeningBid is the lowest amount of any bid
+	MinOpeningBid float64 `json:"minOpeningBid"`
+	//MinIncrement is by how much a bid must exceed the winning bid - if set, bids not outbidding it are rejected
+	MinIncrement float64 `json:"minIncrement"`
+}
+
+//Validate checks that the minimums are not negative
+func (r Rules) Validate() error {
+	if r.MinOpeningBid < 0 || r.MinIncrement < 0 {
+		return fmt.Errorf("Bidding rules must not be negative: %+v", r)
+	}
+	return nil
+}
+
+//Check checks a bid of amount against the winning bid (nil if there is none)
+func (r Rules) Check(winning *Bid, amount float64) error {
+	if amount < r.MinOpeningBid {
+		return ErrBelowOpeningBid
+	}
+	if r.MinIncrement > 0 && winning != nil && amount < winning.Amount+r.MinIncrement {
+		return ErrBelowIncrement
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// define errors of watchlists and saved searches
+var (
+	ErrNotWatching    = NewError(KindNotFound, "not_watching", "Item is not on the watchlist")
+	ErrSearchNotFound = NewError(KindNotFound, "search_not_found", "Saved search not found")
+	ErrEmptyQuery     = NewError(KindInvalid, "empty_query", "Search query must not be empty",
+		FieldError{Field: "query", Message: "must contain at least one term"})
+)
+
+//SavedSearch is a search query stored by a user - the user is told about new items matching it
+type SavedSearch struct {
+	BaseModel
+	UserID uuid.UUID `json:"userID"`
+	Name   string    `json:"name"`
+	Query  string    `json:"query"`
+}
+
+//NewSavedSearch creates a SavedSearch. The query is used as name if name is empty.
+func NewSavedSearch(userID uuid.UUID, name, query string) *SavedSearch {
+	if name == "" {
+		name = query
+	}
+	return &SavedSearch{
+		BaseModel: NewBaseModel(),
+		UserID:    userID,
+		Name:      name,
+		Query:     query,
+	}
+}
+
+//Validate checks that the query contains at least one term
+func (s *SavedSearch) Validate() error {
+	if len(strings.Fields(s.Query)) == 0 {
+		return ErrEmptyQuery
+	}
+	return nil
+}
+
+//Matches returns true if the item matches the query of the search
+func (s *SavedSearch) Matches(item *Item) bool {
+	return len(strings.Fields(s.Query)) > 0 && MatchesQuery(s.Query, item.Name)
+}
+
+//MatchesQuery returns true if every whitespace-separated term of query occurs in name, ignoring case.
+//An empty query matches every name.
+func MatchesQuery(query, name string) bool {
+	name = strings.ToLower(name)
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(name, term) {
+			return false
+		}
+	}
+	return true
+}
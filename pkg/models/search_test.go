@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+func Test_MatchesQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		want  bool
+	}{
+		{"pen", "A Pen", true},
+		{"red pen", "A red fountain pen", true},
+		{"pen red", "A red fountain pen", true},
+		{"blue pen", "A red fountain pen", false},
+		{"", "Anything", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, models.MatchesQuery(tt.query, tt.name), "%q on %q", tt.query, tt.name)
+	}
+}
+
+func Test_SavedSearch_Matches(t *testing.T) {
+	search := models.NewSavedSearch(models.NewUser("James Bond").ID, "", "watch")
+	assert.Equal(t, "watch", search.Name, "Query should be the default name")
+	assert.True(t, search.Matches(models.NewItem("Golden watch")))
+	assert.False(t, search.Matches(models.NewItem("Golden gun")))
+	assert.Error(t, models.NewSavedSearch(search.UserID, "", "  ").Validate())
+}
+
+func Test_Item_ClosesWithin(t *testing.T) {
+	now := time.Now()
+	item := models.NewItem("A thing")
+	assert.False(t, item.ClosesWithin(now, now.Add(time.Hour)), "Item without closing time never closes soon")
+
+	closesAt := now.Add(10 * time.Minute)
+	item.ClosesAt = &closesAt
+	assert.True(t, item.ClosesWithin(now, now.Add(time.Hour)))
+	assert.False(t, item.ClosesWithin(now, now.Add(time.Minute)))
+	item.Close()
+	assert.False(t, item.ClosesWithin(now, now.Add(time.Hour)), "Closed item does not close soon")
+}
+
+func Test_User_Watchlist(t *testing.T) {
+	user := models.NewUser("James Bond")
+	first, second := models.NewItem("A thing"), models.NewItem("Another thing")
+	assert.True(t, user.Watch(first))
+	assert.True(t, user.Watch(second))
+	assert.False(t, user.Watch(first), "Item should be watched only once")
+
+	watchlist := user.GetWatchlist()
+	assert.True(t, user.Unwatch(first.ID))
+	assert.False(t, user.Unwatch(first.ID))
+	assert.Equal(t, []*models.Item{first, second}, watchlist, "Copy must not change")
+	assert.Equal(t, []*models.Item{second}, user.GetWatchlist())
+	assert.False(t, user.IsWatching(first.ID))
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"sort"
 	"sync"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 )
@@ -15,13 +17,31 @@ const (
 // User model
 type User struct {
 	BaseModel
-	Name       string `json:"name"`
+	Name string `json:"name"`
+
+	// mutexWrite serializes the changes of the user - see Lock
+	mutexWrite sync.Mutex
 	mutexBids  sync.RWMutex
 	bids       map[uuid.UUID]*Bid //TODO: Could be a simple slice + mutex - not optimizing this, as not required in assignment
-	mutexItems sync.Mutex
-	//itemsBidFlag guards uniqueness of ItemsBid
+	mutexItems sync.RWMutex
+	//itemsBidFlag guards uniqueness of itemsBid
 	itemsBidFlag map[uuid.UUID]struct{}
-	ItemsBid     []*Item `json:"-"`
+	//itemsBid is append-only - elements within len(itemsBid) are never modified
+	itemsBid []*Item
+	//itemsFirstBidAt holds the time of the first bid of the user on each of itemsBid - it is sorted
+	itemsFirstBidAt []time.Time
+
+	mutexWatch sync.RWMutex
+	// watchlist holds the items the user follows, in the order they have been added
+	watchlist []*Item
+	// searches holds the saved searches of the user, in the order they have been saved
+	searches []*SavedSearch
+
+	// mutexCredit guards the credit of the user and the exposure against it
+	mutexCredit sync.RWMutex
+	credit      Credit
+	// exposed holds the amount of the winning bid of the user per item - their sum is the exposure
+	exposed map[uuid.UUID]float64
 }
 
 //NewUser creates an User
@@ -31,10 +51,22 @@ func NewUser(name string) *User {
 		Name:         name,
 		bids:         make(map[uuid.UUID]*Bid),
 		itemsBidFlag: make(map[uuid.UUID]struct{}),
-		ItemsBid:     make([]*Item, 0),
+		itemsBid:     make([]*Item, 0),
+		exposed:      make(map[uuid.UUID]float64),
 	}
 }
 
+//Lock locks the user for a change: a change is decided and applied while the lock is held,
+//so that the changes of a user are made one at a time. Readers do not need it.
+func (u *User) Lock() {
+	u.mutexWrite.Lock()
+}
+
+//Unlock unlocks the user - see Lock
+func (u *User) Unlock() {
+	u.mutexWrite.Unlock()
+}
+
 //GetBids returns all bids the user has placed
 func (u *User) GetBids() []*Bid {
 	values := make([]*Bid, 0)
@@ -48,21 +80,114 @@ func (u *User) GetBids() []*Bid {
 	return values
 }
 
-//PlaceNewBidOnItem handles the bid placement for user - adds items to a memo
-func (u *User) PlaceNewBidOnItem(bid *Bid, item *Item) {
+//PlaceNewBidOnItem handles the bid placement for user - adds items to a memo.
+//acceptedAt is the time the bid has been accepted by the system, it must not be earlier than for the bids placed before.
+func (u *User) PlaceNewBidOnItem(bid *Bid, item *Item, acceptedAt time.Time) {
 	u.registerBid(bid)
 
 	u.mutexItems.Lock()
 	defer u.mutexItems.Unlock()
 
 	if _, ok := u.itemsBidFlag[item.ID]; !ok {
-		u.ItemsBid = append(u.ItemsBid, item)
+		u.itemsBid = append(u.itemsBid, item)
+		u.itemsFirstBidAt = append(u.itemsFirstBidAt, acceptedAt)
 		u.itemsBidFlag[item.ID] = struct{}{}
 	}
 }
 
+//GetItemsBid returns an immutable snapshot of items on which the user has bid so far.
+//The returned slice must not be modified.
+func (u *User) GetItemsBid() []*Item {
+	u.mutexItems.RLock()
+	defer u.mutexItems.RUnlock()
+	return u.itemsBid[:len(u.itemsBid):len(u.itemsBid)]
+}
+
+//GetItemsBidAsOf returns an immutable snapshot of items on which the user had bid until asOf (inclusive) - O(log n)
+func (u *User) GetItemsBidAsOf(asOf time.Time) []*Item {
+	u.mutexItems.RLock()
+	defer u.mutexItems.RUnlock()
+
+	n := sort.Search(len(u.itemsFirstBidAt), func(k int) bool {
+		return u.itemsFirstBidAt[k].After(asOf)
+	})
+	return u.itemsBid[:n:n]
+}
+
 func (u *User) registerBid(bid *Bid) {
 	u.mutexBids.Lock()
 	defer u.mutexBids.Unlock()
 	u.bids[bid.ID] = bid
 }
+
+//Watch adds the item to the watchlist - it returns false if the item is on the watchlist already
+func (u *User) Watch(item *Item) bool {
+	u.mutexWatch.Lock()
+	defer u.mutexWatch.Unlock()
+	for _, watched := range u.watchlist {
+		if watched.ID == item.ID {
+			return false
+		}
+	}
+	u.watchlist = append(u.watchlist, item)
+	return true
+}
+
+//Unwatch removes the item from the watchlist - it returns false if the item has not been on the watchlist
+func (u *User) Unwatch(itemID uuid.UUID) bool {
+	u.mutexWatch.Lock()
+	defer u.mutexWatch.Unlock()
+	for k, watched := range u.watchlist {
+		if watched.ID == itemID {
+			u.watchlist = append(u.watchlist[:k:k], u.watchlist[k+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+//IsWatching returns true if the item is on the watchlist
+func (u *User) IsWatching(itemID uuid.UUID) bool {
+	u.mutexWatch.RLock()
+	defer u.mutexWatch.RUnlock()
+	for _, watched := range u.watchlist {
+		if watched.ID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+//GetWatchlist returns a copy of the watchlist
+func (u *User) GetWatchlist() []*Item {
+	u.mutexWatch.RLock()
+	defer u.mutexWatch.RUnlock()
+	return append([]*Item{}, u.watchlist...)
+}
+
+//AddSavedSearch stores the search for the user
+func (u *User) AddSavedSearch(search *SavedSearch) {
+	u.mutexWatch.Lock()
+	defer u.mutexWatch.Unlock()
+	u.searches = append(u.searches, search)
+}
+
+//RemoveSavedSearch removes the saved search - it returns false if the user has no search with this ID
+func (u *User) RemoveSavedSearch(searchID uuid.UUID) bool {
+	u.mutexWatch.Lock()
+	defer u.mutexWatch.Unlock()
+	for k, search := range u.searches {
+		if search.ID == searchID {
+			u.searches = append(u.searches[:k:k], u.searches[k+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+//GetSavedSearches returns a copy of the saved searches of the user
+func (u *User) GetSavedSearches() []*SavedSearch {
+	u.mutexWatch.RLock()
+	defer u.mutexWatch.RUnlock()
+	return append([]*SavedSearch{}, u.searches...)
+}
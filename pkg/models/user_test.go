@@ -2,7 +2,9 @@ package models_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/vikin91/bid-tracker-go/pkg/models"
 )
 
@@ -11,3 +13,43 @@ func Benchmark_Reference_NewUser(b *testing.B) {
 		models.NewUser("A name")
 	}
 }
+
+func Test_User_GetItemsBid_Snapshot(t *testing.T) {
+	user := models.NewUser("James Bond")
+	first := models.NewItem("A thing")
+	user.PlaceNewBidOnItem(models.NewBid(first.ID, user.ID, 1.0), first, time.Now())
+
+	snapshot := user.GetItemsBid()
+	second := models.NewItem("Another thing")
+	user.PlaceNewBidOnItem(models.NewBid(second.ID, user.ID, 1.0), second, time.Now())
+
+	assert.Equal(t, []*models.Item{first}, snapshot, "Snapshot must not see items bid later")
+	assert.Equal(t, []*models.Item{first, second}, user.GetItemsBid())
+}
+
+func Benchmark_User_GetItemsBid(b *testing.B) {
+	user := models.NewUser("James Bond")
+	for n := 0; n < 100; n++ {
+		item := models.NewItem("A thing")
+		user.PlaceNewBidOnItem(models.NewBid(item.ID, user.ID, 1.0), item, time.Now())
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		user.GetItemsBid()
+	}
+}
+
+func Test_User_GetItemsBidAsOf(t *testing.T) {
+	user := models.NewUser("James Bond")
+	start := time.Now()
+	first := models.NewItem("A thing")
+	second := models.NewItem("Another thing")
+	user.PlaceNewBidOnItem(models.NewBid(first.ID, user.ID, 1.0), first, start.Add(time.Minute))
+	user.PlaceNewBidOnItem(models.NewBid(second.ID, user.ID, 1.0), second, start.Add(2*time.Minute))
+	user.PlaceNewBidOnItem(models.NewBid(first.ID, user.ID, 2.0), first, start.Add(3*time.Minute))
+
+	assert.Empty(t, user.GetItemsBidAsOf(start))
+	assert.Equal(t, []*models.Item{first}, user.GetItemsBidAsOf(start.Add(time.Minute)))
+	assert.Equal(t, []*models.Item{first, second}, user.GetItemsBidAsOf(start.Add(time.Hour)))
+}
@@ -0,0 +1,76 @@
+package notifications
+
+import (
+	"sync"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+//Inbox keeps the in-app notifications of users, newest first
+type Inbox struct {
+	mutex  sync.RWMutex
+	byUser map[uuid.UUID][]*Notification
+}
+
+//NewInbox creates an empty inbox
+func NewInbox() *Inbox {
+	return &Inbox{byUser: make(map[uuid.UUID][]*Notification)}
+}
+
+//Add puts a notification into the inbox of its user
+func (i *Inbox) Add(n Notification) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.byUser[n.UserID] = append(i.byUser[n.UserID], &n)
+}
+
+//List returns copies of the notifications of a user, newest first - only the unread ones if unreadOnly is set
+func (i *Inbox) List(userID uuid.UUID, unreadOnly bool) []Notification {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	all := i.byUser[userID]
+	list := make([]Notification, 0, len(all))
+	for idx := len(all) - 1; idx >= 0; idx-- {
+		if unreadOnly && all[idx].Read {
+			continue
+		}
+		list = append(list, *all[idx])
+	}
+	return list
+}
+
+//Unread returns the number of unread notifications of a user
+func (i *Inbox) Unread(userID uuid.UUID) int {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	count := 0
+	for _, n := range i.byUser[userID] {
+		if !n.Read {
+			count++
+		}
+	}
+	return count
+}
+
+//SetRead marks a notification of a user as read or unread
+func (i *Inbox) SetRead(userID, notificationID uuid.UUID, read bool) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	for _, n := range i.byUser[userID] {
+		if n.ID == notificationID {
+			n.Read = read
+			return nil
+		}
+	}
+	return ErrNotificationAbsent
+}
+
+//MarkAllRead marks all notifications of a user as read
+func (i *Inbox) MarkAllRead(userID uuid.UUID) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	for _, n := range i.byUser[userID] {
+		n.Read = true
+	}
+}
@@ -0,0 +1,146 @@
+// Package notifications tells users when they have been outbid or have won an auction,
+// when an item they watch is about to close and when a new item matches one of their saved searches.
+// Messages are rendered from templates per kind of notification and sent over the channels each user has chosen:
+// the in-app inbox, email (SMTP) or a webhook of the user.
+package notifications
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+//Kind identifies what a notification is about
+type Kind string
+
+// define kinds of notifications
+const (
+	KindOutbid      Kind = "outbid"
+	KindAuctionWon  Kind = "auction-won"
+	KindClosingSoon Kind = "closing-soon"
+	KindSearchMatch Kind = "search-match"
+)
+
+//Channel is a way of reaching a user
+type Channel string
+
+// define channels
+const (
+	ChannelInbox   Channel = "inbox"
+	ChannelEmail   Channel = "email"
+	ChannelWebhook Channel = "webhook"
+)
+
+// define errors
+var (
+	ErrUnknownChannel     = errors.New("Unknown notification channel")
+	ErrMissingEmail       = errors.New("Email channel requires a valid email address")
+	ErrMissingWebhookURL  = errors.New("Webhook channel requires an absolute http(s) URL")
+	ErrNotificationAbsent = errors.New("Notification not found")
+)
+
+//Notification is a rendered message for a user
+type Notification struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"userID"`
+	Kind      Kind      `json:"kind"`
+	ItemID    uuid.UUID `json:"itemID"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	Read      bool      `json:"read"`
+}
+
+//Preferences are the channels a user wants to be notified on.
+//WebhookSecret signs the notifications sent to WebhookURL, like the webhooks of the webhooks package.
+type Preferences struct {
+	Channels      []Channel `json:"channels"`
+	Email         string    `json:"email,omitempty"`
+	WebhookURL    string    `json:"webhookURL,omitempty"`
+	WebhookSecret string    `json:"webhookSecret,omitempty"`
+}
+
+//DefaultPreferences are used for users that have not stored any: in-app inbox only
+func DefaultPreferences() Preferences {
+	return Preferences{Channels: []Channel{ChannelInbox}}
+}
+
+//Validate checks that every channel is known and has the address it needs
+func (p Preferences) Validate() error {
+	for _, c := range p.Channels {
+		switch c {
+		case ChannelInbox:
+		case ChannelEmail:
+			if _, err := mail.ParseAddress(p.Email); err != nil {
+				return ErrMissingEmail
+			}
+		case ChannelWebhook:
+			u, err := url.Parse(p.WebhookURL)
+			if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return ErrMissingWebhookURL
+			}
+		default:
+			return ErrUnknownChannel
+		}
+	}
+	return nil
+}
+
+//Wants reports whether c is one of the chosen channels
+func (p Preferences) Wants(c Channel) bool {
+	for _, chosen := range p.Channels {
+		if chosen == c {
+			return true
+		}
+	}
+	return false
+}
+
+//PreferenceStore keeps the preferences of users
+type PreferenceStore struct {
+	mutex sync.RWMutex
+	prefs map[uuid.UUID]Preferences
+}
+
+//NewPreferenceStore creates an empty store
+func NewPreferenceStore() *PreferenceStore {
+	return &PreferenceStore{prefs: make(map[uuid.UUID]Preferences)}
+}
+
+//Get returns the preferences of a user, or the defaults if none have been stored
+func (s *PreferenceStore) Get(userID uuid.UUID) Preferences {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	p, ok := s.prefs[userID]
+	if !ok {
+		return DefaultPreferences()
+	}
+	p.Channels = append([]Channel(nil), p.Channels...)
+	return p
+}
+
+//Set validates and stores the preferences of a user and returns them - with a generated webhook secret,
+//if a webhook URL is given without one
+func (s *PreferenceStore) Set(userID uuid.UUID, p Preferences) (Preferences, error) {
+	if err := p.Validate(); err != nil {
+		return Preferences{}, err
+	}
+	if p.WebhookURL != "" && p.WebhookSecret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			return Preferences{}, err
+		}
+		p.WebhookSecret = secret
+	}
+	p.Channels = append([]Channel(nil), p.Channels...)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prefs[userID] = p
+	return p, nil
+}
@@ -0,0 +1,232 @@
+package notifications_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/notifications"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+func setup(email notifications.EmailSender) (storage.Storage, *notifications.Notifier, []*models.User, []*models.Item) {
+	db := storage.NewMapBiddingSystem()
+	notifier := notifications.NewNotifier(db, email)
+	// synchronous, so that notifications have been sent when the writes return
+	db.Subscribe(notifier)
+	return db, notifier, testutils.CreateTestUsers(db, 2), testutils.CreateTestItems(db, 2)
+}
+
+func Test_Notifier_Inbox(t *testing.T) {
+	db, notifier, users, items := setup(nil)
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 15))
+	assert.Empty(t, notifier.Inbox().List(users[0].ID, false), "Raising own bid should not notify")
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+	list := notifier.Inbox().List(users[0].ID, false)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, notifications.KindOutbid, list[0].Kind)
+		assert.Equal(t, items[0].ID, list[0].ItemID)
+		assert.Equal(t, "You have been outbid on "+items[0].Name, list[0].Subject)
+		assert.Contains(t, list[0].Body, "your bid of 15.00")
+		assert.Contains(t, list[0].Body, "a bid of 20.00")
+		assert.False(t, list[0].Read)
+	}
+
+	db.CloseAuction(items[0].ID)
+	list = notifier.Inbox().List(users[1].ID, false)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, notifications.KindAuctionWon, list[0].Kind)
+		assert.Equal(t, "You have won "+items[0].Name, list[0].Subject)
+		assert.Contains(t, list[0].Body, "Hello "+users[1].Name)
+	}
+	assert.Len(t, notifier.Inbox().List(users[0].ID, false), 1, "Losers should not be told they have won")
+}
+
+func Test_Inbox_ReadState(t *testing.T) {
+	db, notifier, users, items := setup(nil)
+	inbox := notifier.Inbox()
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[1].ID, users[1].ID, 20))
+
+	list := inbox.List(users[0].ID, false)
+	require.Len(t, list, 2)
+	assert.Equal(t, items[1].ID, list[0].ItemID, "Newest notification should come first")
+	assert.Equal(t, 2, inbox.Unread(users[0].ID))
+
+	require.NoError(t, inbox.SetRead(users[0].ID, list[0].ID, true))
+	assert.Equal(t, 1, inbox.Unread(users[0].ID))
+	unread := inbox.List(users[0].ID, true)
+	if assert.Len(t, unread, 1) {
+		assert.Equal(t, list[1].ID, unread[0].ID)
+	}
+	assert.Equal(t, notifications.ErrNotificationAbsent, inbox.SetRead(users[1].ID, list[0].ID, true),
+		"Users should only change their own notifications")
+
+	inbox.MarkAllRead(users[0].ID)
+	assert.Empty(t, inbox.List(users[0].ID, true))
+}
+
+func Test_Notifier_Email(t *testing.T) {
+	smtpServer, err := testutils.NewFakeSMTPServer()
+	require.NoError(t, err)
+	defer smtpServer.Close()
+
+	db, notifier, users, items := setup(&notifications.SMTPSender{Addr: smtpServer.Addr, From: "auctions@example.com"})
+	_, err = notifier.Preferences().Set(users[0].ID, notifications.Preferences{
+		Channels: []notifications.Channel{notifications.ChannelEmail},
+		Email:    "bond@example.com",
+	})
+	require.NoError(t, err)
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+
+	select {
+	case msg := <-smtpServer.Received():
+		assert.Equal(t, "auctions@example.com", msg.From)
+		assert.Equal(t, []string{"bond@example.com"}, msg.To)
+		assert.Contains(t, msg.Data, "Subject: You have been outbid on "+items[0].Name)
+		assert.Contains(t, msg.Data, "To: bond@example.com")
+		assert.Contains(t, msg.Data, "your bid of 10.00")
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Email not received")
+	}
+	assert.Empty(t, notifier.Inbox().List(users[0].ID, false), "Inbox was not chosen")
+}
+
+func Test_Notifier_Webhook(t *testing.T) {
+	received := make(chan notifications.Notification, 1)
+	var secret string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !webhooks.Verify(secret, r.Header.Get(webhooks.HeaderTimestamp), r.Header.Get(webhooks.HeaderSignature), body, time.Minute) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := notifications.Notification{}
+		json.Unmarshal(body, &n)
+		received <- n
+	}))
+	defer receiver.Close()
+
+	db, notifier, users, items := setup(nil)
+	prefs, err := notifier.Preferences().Set(users[1].ID, notifications.Preferences{
+		Channels:   []notifications.Channel{notifications.ChannelWebhook, notifications.ChannelInbox},
+		WebhookURL: receiver.URL,
+	})
+	require.NoError(t, err)
+	secret = prefs.WebhookSecret
+	assert.NotEmpty(t, secret, "A webhook secret should be generated")
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 10))
+	db.CloseAuction(items[0].ID)
+
+	select {
+	case n := <-received:
+		assert.Equal(t, notifications.KindAuctionWon, n.Kind)
+		assert.Equal(t, users[1].ID, n.UserID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Webhook not received")
+	}
+	assert.Len(t, notifier.Inbox().List(users[1].ID, false), 1)
+}
+
+func Test_Notifier_CustomTemplates(t *testing.T) {
+	db, notifier, users, items := setup(nil)
+	won, err := notifications.NewTemplate("Won: {{.ItemName}}", "{{.UserName}} paid {{.Amount}}")
+	require.NoError(t, err)
+	notifier.WithTemplates(notifications.Templates{notifications.KindAuctionWon: won})
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 7))
+	db.CloseAuction(items[0].ID)
+
+	list := notifier.Inbox().List(users[0].ID, false)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Won: "+items[0].Name, list[0].Subject)
+	assert.Equal(t, users[0].Name+" paid 7", list[0].Body)
+}
+
+func Test_Notifier_SearchMatch(t *testing.T) {
+	db, notifier, users, _ := setup(nil)
+	require.NoError(t, db.SaveSearch(models.NewSavedSearch(users[0].ID, "Pens", "fountain pen")))
+	require.NoError(t, db.SaveSearch(models.NewSavedSearch(users[1].ID, "", "car")))
+
+	item := models.NewItem("Red Fountain Pen")
+	require.NoError(t, db.CreateItem(item))
+	require.NoError(t, db.CreateItem(models.NewItem("A pencil")))
+
+	list := notifier.Inbox().List(users[0].ID, false)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, notifications.KindSearchMatch, list[0].Kind)
+		assert.Equal(t, item.ID, list[0].ItemID)
+		assert.Equal(t, "New item matching Pens: Red Fountain Pen", list[0].Subject)
+	}
+	assert.Empty(t, notifier.Inbox().List(users[1].ID, false))
+}
+
+func Test_Reminder_Check(t *testing.T) {
+	db, notifier, users, _ := setup(nil)
+	now := time.Now()
+	soon, later := now.Add(10*time.Minute), now.Add(2*time.Hour)
+	closingSoon := models.NewItem("A thing closing soon")
+	closingSoon.ClosesAt = &soon
+	closingLater := models.NewItem("A thing closing later")
+	closingLater.ClosesAt = &later
+	require.NoError(t, db.CreateItem(closingSoon))
+	require.NoError(t, db.CreateItem(closingLater))
+	require.NoError(t, db.WatchItem(users[0].ID, closingSoon.ID))
+	require.NoError(t, db.WatchItem(users[0].ID, closingLater.ID))
+
+	reminder := notifications.NewReminder(notifier, 15*time.Minute, time.Minute)
+	assert.Equal(t, 1, reminder.Check(now))
+	assert.Equal(t, 0, reminder.Check(now.Add(time.Minute)), "Watchers should be reminded once")
+
+	require.NoError(t, db.WatchItem(users[1].ID, closingSoon.ID))
+	assert.Equal(t, 1, reminder.Check(now.Add(time.Minute)), "Late watchers should be reminded too")
+
+	list := notifier.Inbox().List(users[0].ID, false)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, notifications.KindClosingSoon, list[0].Kind)
+		assert.Equal(t, closingSoon.ID, list[0].ItemID)
+		assert.Contains(t, list[0].Body, soon.Format("2006-01-02 15:04"))
+	}
+
+	db.CloseAuction(closingLater.ID)
+	assert.Equal(t, 0, reminder.Check(later.Add(-time.Minute)), "Closed auctions need no reminder")
+
+	assert.Equal(t, 2, reminder.Len())
+	db.CloseAuction(closingSoon.ID)
+	reminder.Check(now.Add(time.Minute))
+	assert.Equal(t, 0, reminder.Len(), "Reminders are forgotten once the auction has closed")
+}
+
+func Test_Preferences(t *testing.T) {
+	store := notifications.NewPreferenceStore()
+	users := testutils.CreateTestUsers(storage.NewMapBiddingSystem(), 1)
+	assert.Equal(t, notifications.DefaultPreferences(), store.Get(users[0].ID))
+
+	_, err := store.Set(users[0].ID, notifications.Preferences{Channels: []notifications.Channel{"pigeon"}})
+	assert.Equal(t, notifications.ErrUnknownChannel, err)
+	_, err = store.Set(users[0].ID, notifications.Preferences{Channels: []notifications.Channel{notifications.ChannelEmail}, Email: "nope"})
+	assert.Equal(t, notifications.ErrMissingEmail, err)
+	_, err = store.Set(users[0].ID, notifications.Preferences{Channels: []notifications.Channel{notifications.ChannelWebhook}})
+	assert.Equal(t, notifications.ErrMissingWebhookURL, err)
+
+	_, err = store.Set(users[0].ID, notifications.Preferences{Channels: []notifications.Channel{}})
+	assert.NoError(t, err)
+	assert.Empty(t, store.Get(users[0].ID).Channels, "Users may opt out of all channels")
+}
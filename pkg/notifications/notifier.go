@@ -0,0 +1,139 @@
+package notifications
+
+import (
+	"fmt"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+//Notifier turns events into notifications and sends them over the channels chosen by the users.
+//Subscribe it to the storage asynchronously, as sending may be slow:
+//
+//	db.Subscribe(notifier, events.Async(1024), events.Only(notifications.EventTypes...))
+type Notifier struct {
+	db          storage.Storage
+	templates   Templates
+	preferences *PreferenceStore
+	inbox       *Inbox
+	email       EmailSender
+	webhook     *WebhookSender
+}
+
+//EventTypes are the events the Notifier reacts to
+var EventTypes = []events.Type{events.TypeBidPlaced, events.TypeAuctionClosed, events.TypeItemListed}
+
+//NewNotifier creates a notifier with the default templates. email may be nil if emails cannot be sent.
+func NewNotifier(db storage.Storage, email EmailSender) *Notifier {
+	return &Notifier{
+		db:          db,
+		templates:   DefaultTemplates(),
+		preferences: NewPreferenceStore(),
+		inbox:       NewInbox(),
+		email:       email,
+		webhook:     &WebhookSender{},
+	}
+}
+
+//WithTemplates replaces the templates for the kinds present in templates
+func (n *Notifier) WithTemplates(templates Templates) *Notifier {
+	for k, t := range templates {
+		n.templates[k] = t
+	}
+	return n
+}
+
+//Preferences returns the store of channel preferences
+func (n *Notifier) Preferences() *PreferenceStore {
+	return n.preferences
+}
+
+//Inbox returns the in-app inbox
+func (n *Notifier) Inbox() *Inbox {
+	return n.inbox
+}
+
+//Apply implements events.Projection
+func (n *Notifier) Apply(r events.Record) {
+	switch e := r.Event.(type) {
+	case events.BidPlaced:
+		if e.Outbid != nil && e.Outbid.UserID != e.Bid.UserID {
+			n.Notify(KindOutbid, e.Outbid.UserID, e.Bid.ItemID, Data{Amount: e.Outbid.Amount, NewAmount: e.Bid.Amount, At: r.At})
+		}
+	case events.AuctionClosed:
+		if e.WinningBid != nil {
+			n.Notify(KindAuctionWon, e.WinningBid.UserID, e.ItemID, Data{Amount: e.WinningBid.Amount, At: r.At})
+		}
+	case events.ItemListed:
+		n.notifySearchMatches(e, r.At)
+	}
+}
+
+//notifySearchMatches tells the owners of the saved searches matched by a new item - once per search
+func (n *Notifier) notifySearchMatches(e events.ItemListed, at time.Time) {
+	searches, err := n.db.AllSavedSearches()
+	if err != nil {
+		logging.LogError("Cannot list saved searches", err)
+		return
+	}
+	item := models.NewItem(e.Name)
+	for _, search := range searches {
+		if search.Matches(item) {
+			n.Notify(KindSearchMatch, search.UserID, e.ItemID, Data{SearchName: search.Name, At: at})
+		}
+	}
+}
+
+//Notify renders a notification of kind k about an item for a user and sends it over the channels the user has chosen.
+//The names of the user and the item are filled into data. Failures of single channels are logged - they do not stop the other channels.
+func (n *Notifier) Notify(k Kind, userID, itemID uuid.UUID, data Data) {
+	if data.At.IsZero() {
+		data.At = time.Now()
+	}
+	user, err := n.db.GetUser(userID)
+	if err != nil {
+		logging.LogError("Cannot notify unknown user", err)
+		return
+	}
+	data.UserName = user.Name
+	var item *models.Item
+	if item, err = n.db.GetItem(itemID); err == nil {
+		data.ItemName = item.Name
+	}
+
+	subject, body, err := n.templates.Render(k, data)
+	if err != nil {
+		logging.LogError("Cannot render notification", err)
+		return
+	}
+	notification := Notification{
+		ID:        uuid.NewV4(),
+		UserID:    userID,
+		Kind:      k,
+		ItemID:    itemID,
+		Subject:   subject,
+		Body:      body,
+		CreatedAt: data.At,
+	}
+
+	prefs := n.preferences.Get(userID)
+	if prefs.Wants(ChannelInbox) {
+		n.inbox.Add(notification)
+	}
+	if prefs.Wants(ChannelEmail) {
+		if n.email == nil {
+			logging.LogWarning("Cannot send notification email", fmt.Errorf("no email sender configured"))
+		} else if err := n.email.SendEmail(prefs.Email, subject, body); err != nil {
+			logging.LogError("Cannot send notification email", err)
+		}
+	}
+	if prefs.Wants(ChannelWebhook) {
+		if err := n.webhook.SendWebhook(prefs.WebhookURL, prefs.WebhookSecret, notification); err != nil {
+			logging.LogError("Cannot send notification webhook", err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package notifications
+
+import (
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+//Reminder periodically tells the watchers of items that the auction is about to close.
+//An item is about to close if its ClosesAt is within Window from now. Every watcher is reminded once per item,
+//also if the user starts watching after the others have been reminded. The reminders of an item are forgotten
+//once its auction has closed.
+type Reminder struct {
+	notifier *Notifier
+	window   time.Duration
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+
+	mutex sync.Mutex
+	// reminded are the watchers reminded, by item
+	reminded map[uuid.UUID]map[uuid.UUID]struct{}
+}
+
+//NewReminder creates a reminder checking every interval for items closing within window - call Start to begin checking
+func NewReminder(notifier *Notifier, window, interval time.Duration) *Reminder {
+	return &Reminder{
+		notifier: notifier,
+		window:   window,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		reminded: make(map[uuid.UUID]map[uuid.UUID]struct{}),
+	}
+}
+
+//Start launches the periodic check
+func (r *Reminder) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				r.Check(now)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+//Stop stops the periodic check - Start must have been called before
+func (r *Reminder) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+//Check reminds the watchers of the items closing within the window from now that have not been reminded yet.
+//It returns the number of reminders sent.
+func (r *Reminder) Check(now time.Time) int {
+	items, err := r.notifier.db.AllItems()
+	if err != nil {
+		return 0
+	}
+	sent := 0
+	for _, item := range items {
+		if item.IsClosed() {
+			r.forget(item.ID)
+			continue
+		}
+		if !item.ClosesWithin(now, now.Add(r.window)) {
+			continue
+		}
+		for _, userID := range item.GetWatchers() {
+			if !r.markReminded(item.ID, userID) {
+				continue
+			}
+			r.notifier.Notify(KindClosingSoon, userID, item.ID, Data{ClosesAt: *item.ClosesAt, At: now})
+			sent++
+		}
+	}
+	return sent
+}
+
+//markReminded returns false if the reminder has been sent already
+func (r *Reminder) markReminded(itemID, userID uuid.UUID) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	users, ok := r.reminded[itemID]
+	if !ok {
+		users = make(map[uuid.UUID]struct{})
+		r.reminded[itemID] = users
+	}
+	if _, ok := users[userID]; ok {
+		return false
+	}
+	users[userID] = struct{}{}
+	return true
+}
+
+//forget removes the reminders sent for the item
+func (r *Reminder) forget(itemID uuid.UUID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.reminded, itemID)
+}
+
+//Len returns the number of reminders remembered, so that they are not sent again
+func (r *Reminder) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	n := 0
+	for _, users := range r.reminded {
+		n += len(users)
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+//EmailSender sends plain text emails
+type EmailSender interface {
+	SendEmail(to, subject, body string) error
+}
+
+//SMTPSender sends emails through an SMTP server
+type SMTPSender struct {
+	Addr string    // host:port of the server
+	From string    // sender address
+	Auth smtp.Auth // nil if the server does not require authentication
+}
+
+//SendEmail implements EmailSender
+func (s *SMTPSender) SendEmail(to, subject, body string) error {
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(msg, "To: %s\r\n", to)
+	fmt.Fprintf(msg, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "Message-ID: <%s@bid-tracker>\r\n", uuid.NewV4())
+	fmt.Fprint(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprint(msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprint(msg, strings.Replace(body, "\n", "\r\n", -1))
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{to}, msg.Bytes())
+}
+
+//WebhookSender POSTs notifications as JSON, signed like the deliveries of the webhooks package
+type WebhookSender struct {
+	Client *http.Client
+}
+
+//SendWebhook posts n to url, signed with secret
+func (s *WebhookSender) SendWebhook(url, secret string, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.HeaderEvent, string(n.Kind))
+	req.Header.Set(webhooks.HeaderDelivery, n.ID.String())
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(secret, timestamp, body))
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Notification webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+//Data is passed to the templates
+type Data struct {
+	UserName  string
+	ItemName  string
+	Amount    float64 // the bid of the user
+	NewAmount float64 // the bid that has beaten it, for KindOutbid
+	// ClosesAt is the announced end of the auction, for KindClosingSoon
+	ClosesAt time.Time
+	// SearchName is the name of the saved search the item matches, for KindSearchMatch
+	SearchName string
+	At         time.Time
+}
+
+//Template renders the subject and body of a kind of notification
+type Template struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+//NewTemplate parses the subject and body templates (text/template syntax, fields of Data)
+func NewTemplate(subject, body string) (Template, error) {
+	s, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return Template{}, err
+	}
+	b, err := template.New("body").Parse(body)
+	if err != nil {
+		return Template{}, err
+	}
+	return Template{Subject: s, Body: b}, nil
+}
+
+//Templates holds a template per kind of notification
+type Templates map[Kind]Template
+
+//DefaultTemplates returns the built-in templates
+func DefaultTemplates() Templates {
+	outbid, _ := NewTemplate(
+		`You have been outbid on {{.ItemName}}`,
+		`Hello {{.UserName}},
+
+your bid of {{printf "%.2f" .Amount}} on {{.ItemName}} has been beaten by a bid of {{printf "%.2f" .NewAmount}}.
+Place a higher bid to stay in the auction.
+`)
+	won, _ := NewTemplate(
+		`You have won {{.ItemName}}`,
+		`Hello {{.UserName}},
+
+congratulations - the auction for {{.ItemName}} has closed and your bid of {{printf "%.2f" .Amount}} has won.
+`)
+	closing, _ := NewTemplate(
+		`{{.ItemName}} is about to close`,
+		`Hello {{.UserName}},
+
+the auction for {{.ItemName}} on your watchlist closes at {{.ClosesAt.Format "2006-01-02 15:04 MST"}}.
+Place your bid before it ends.
+`)
+	match, _ := NewTemplate(
+		`New item matching {{.SearchName}}: {{.ItemName}}`,
+		`Hello {{.UserName}},
+
+{{.ItemName}} has just been put on auction and matches your saved search {{.SearchName}}.
+`)
+	return Templates{KindOutbid: outbid, KindAuctionWon: won, KindClosingSoon: closing, KindSearchMatch: match}
+}
+
+//Render returns the subject and body of a notification of kind k
+func (t Templates) Render(k Kind, data Data) (string, string, error) {
+	tmpl, ok := t[k]
+	if !ok {
+		return "", "", fmt.Errorf("No template for notification %q", k)
+	}
+	subject, body := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := tmpl.Subject.Execute(subject, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.Body.Execute(body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
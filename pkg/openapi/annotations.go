@@ -0,0 +1,183 @@
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//Annotation is what the doc comment of a handler tells about its operation, in lines starting with "@":
+//
+//	@summary Get all bids for an item
+//	@tags Items, Bids
+//	@param query asOf {date-time} Return the state as it was at this time
+//	@param header Idempotency-Key {string} required Unique key of the request
+//	@body {models.Bid} A new bid
+//	@response 200 {[]models.Bid} OK
+//	@response 404 Item not found
+//
+//Types are given in braces: string, integer, number, boolean, object, uuid, date-time, email, uri, stream
+//(server-sent events), the name of a registered type (see Generator.WithTypes), or arrays of those ("[]T").
+//Types of the package of the handler may be named without the package.
+type Annotation struct {
+	//Package is the name of the package of the handler
+	Package   string
+	Summary   string
+	Tags      []string
+	Params    []AnnotatedParam
+	Body      *AnnotatedBody
+	Responses []AnnotatedResponse
+}
+
+//AnnotatedParam is a query or header parameter - path parameters are taken from the routes
+type AnnotatedParam struct {
+	In          string
+	Name        string
+	Type        string
+	Required    bool
+	Description string
+}
+
+//AnnotatedBody is the JSON body of a request
+type AnnotatedBody struct {
+	Type        string
+	Description string
+}
+
+//AnnotatedResponse is a response with its status and, if it has a body, its type
+type AnnotatedResponse struct {
+	Status      int
+	Type        string
+	Description string
+}
+
+//Annotations of handlers by "Receiver.Method" (e.g., "ItemHandler.GetBids") or by function name
+type Annotations map[string]*Annotation
+
+//ParseAnnotations reads the annotations of the functions and methods of the Go package in dir
+func ParseAnnotations(dir string) (Annotations, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	annotations := Annotations{}
+	for name, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Doc == nil {
+					continue
+				}
+				annotation, err := parseAnnotation(name, fn.Doc.Text())
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", fset.Position(fn.Pos()), err)
+				}
+				if annotation != nil {
+					annotations[funcKey(fn)] = annotation
+				}
+			}
+		}
+	}
+	return annotations, nil
+}
+
+//funcKey is the key of the annotations of fn, e.g., "ItemHandler.GetBids"
+func funcKey(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+//parseAnnotation parses the annotation lines of a doc comment - nil if there are none
+func parseAnnotation(pkg, doc string) (*Annotation, error) {
+	var annotation *Annotation
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "@") {
+			continue
+		}
+		if annotation == nil {
+			annotation = &Annotation{Package: pkg}
+		}
+		keyword, rest := cut(line[1:])
+		switch keyword {
+		case "summary":
+			annotation.Summary = rest
+		case "tags":
+			for _, tag := range strings.Split(rest, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					annotation.Tags = append(annotation.Tags, tag)
+				}
+			}
+		case "param":
+			in, rest := cut(rest)
+			name, rest := cut(rest)
+			typ, rest, err := typeOf(rest)
+			if err != nil || (in != "query" && in != "header") || name == "" {
+				return nil, fmt.Errorf("Malformed annotation %q - expected @param query|header name {type} [required] description", line)
+			}
+			param := AnnotatedParam{In: in, Name: name, Type: typ}
+			if word, description := cut(rest); word == "required" {
+				param.Required, rest = true, description
+			}
+			param.Description = rest
+			annotation.Params = append(annotation.Params, param)
+		case "body":
+			typ, rest, err := typeOf(rest)
+			if err != nil || typ == "" {
+				return nil, fmt.Errorf("Malformed annotation %q - expected @body {type} description", line)
+			}
+			annotation.Body = &AnnotatedBody{Type: typ, Description: rest}
+		case "response":
+			code, rest := cut(rest)
+			status, err := strconv.Atoi(code)
+			if err != nil || status < 100 || status > 599 {
+				return nil, fmt.Errorf("Malformed annotation %q - expected @response status [{type}] description", line)
+			}
+			typ, rest, err := typeOf(rest)
+			if err != nil {
+				return nil, fmt.Errorf("Malformed annotation %q: %v", line, err)
+			}
+			annotation.Responses = append(annotation.Responses, AnnotatedResponse{Status: status, Type: typ, Description: rest})
+		default:
+			return nil, fmt.Errorf("Unknown annotation %q", line)
+		}
+	}
+	return annotation, nil
+}
+
+//cut returns the first word of s and the rest of it
+func cut(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+//typeOf returns the type in braces at the start of s, if any, and the rest of s
+func typeOf(s string) (string, string, error) {
+	if !strings.HasPrefix(s, "{") {
+		return "", s, nil
+	}
+	end := strings.Index(s, "}")
+	if end < 0 {
+		return "", "", fmt.Errorf("Unterminated type in %q", s)
+	}
+	return strings.TrimSpace(s[1:end]), strings.TrimSpace(s[end+1:]), nil
+}
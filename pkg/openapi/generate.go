@@ -0,0 +1,342 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+var (
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	// formats of the types that are written as strings, by import path and name
+	formats = map[string]string{"time.Time": "date-time", "github.com/satori/go.uuid.UUID": "uuid"}
+)
+
+//Generator creates the OpenAPI document of a chi router: the paths come from the routes, the operations from the
+//annotations of their handlers (see Annotation) and the schemas from the Go types named by the annotations.
+//Properties are not marked as required, as the same types are read and written.
+type Generator struct {
+	template    Spec
+	annotations Annotations
+	types       map[string]reflect.Type
+	errorMedia  string
+	errorType   reflect.Type
+
+	schemas    map[string]*Schema
+	components map[reflect.Type]string
+}
+
+//NewGenerator creates a generator of documents like template - its info, servers, security and components are kept
+//and the paths and schemas of the routes are added
+func NewGenerator(template Spec) *Generator {
+	return &Generator{template: template, annotations: Annotations{}, types: map[string]reflect.Type{}}
+}
+
+//WithAnnotations sets the annotations of the handlers (see ParseAnnotations)
+func (g *Generator) WithAnnotations(annotations Annotations) *Generator {
+	g.annotations = annotations
+	return g
+}
+
+//WithTypes registers the types of values, so that annotations can name them, e.g., "models.Bid"
+func (g *Generator) WithTypes(values ...interface{}) *Generator {
+	for _, value := range values {
+		t := reflect.TypeOf(value)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		g.types[typeName(t)] = t
+	}
+	return g
+}
+
+//WithErrors documents the bodies of error responses (4xx and 5xx) without a type of their own as value in mediaType
+func (g *Generator) WithErrors(mediaType string, value interface{}) *Generator {
+	g.errorMedia = mediaType
+	g.errorType = reflect.TypeOf(value)
+	return g
+}
+
+//Generate walks router and returns the document of its routes under the base path of the template. Every handler
+//must be annotated, so that the document is complete.
+func (g *Generator) Generate(router chi.Routes) (*Spec, error) {
+	spec := g.template
+	spec.Paths = map[string]*PathItem{}
+	g.schemas = map[string]*Schema{}
+	for name, schema := range g.template.Components.Schemas {
+		g.schemas[name] = schema
+	}
+	spec.Components.Schemas = g.schemas
+	g.components = map[reflect.Type]string{}
+	basePath := spec.BasePath()
+
+	var missing []string
+	err := chi.Walk(router, func(method, route string, handler http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(strings.Replace(route, "/*", "", -1), "/")
+		if !strings.HasPrefix(route+"/", basePath+"/") || methodIndex(method) == len(Methods) {
+			return nil
+		}
+		route = strings.TrimPrefix(route, basePath)
+		if route == "" {
+			route = "/"
+		}
+		name := HandlerName(handler)
+		annotation, ok := g.annotations[name]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s %s (%s)", method, route, name))
+			return nil
+		}
+		op, err := g.operation(route, annotation)
+		if err != nil {
+			return fmt.Errorf("%s %s (%s): %v", method, route, name, err)
+		}
+		item, ok := spec.Paths[route]
+		if !ok {
+			item = &PathItem{}
+			spec.Paths[route] = item
+		}
+		item.setOperation(method, op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("Handlers without annotations: %s", strings.Join(missing, ", "))
+	}
+	return &spec, nil
+}
+
+//HandlerName names the function or method behind handler like the keys of Annotations, e.g., "ItemHandler.GetBids"
+func HandlerName(handler http.Handler) string {
+	v := reflect.ValueOf(handler)
+	if v.Kind() != reflect.Func {
+		return reflect.Indirect(v).Type().Name()
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	// e.g., github.com/vikin91/bid-tracker-go/pkg/handlers.(*ItemHandler).GetBids-fm
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = name[strings.Index(name, ".")+1:]
+	return strings.NewReplacer("(*", "", "(", "", ")", "").Replace(name)
+}
+
+func (p *PathItem) setOperation(method string, op *Operation) {
+	switch method {
+	case "GET":
+		p.Get = op
+	case "PUT":
+		p.Put = op
+	case "POST":
+		p.Post = op
+	case "DELETE":
+		p.Delete = op
+	case "PATCH":
+		p.Patch = op
+	}
+}
+
+func (g *Generator) operation(route string, annotation *Annotation) (*Operation, error) {
+	op := &Operation{Tags: annotation.Tags, Summary: annotation.Summary, Responses: map[string]*Response{}}
+	for _, segment := range splitPath(route) {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := segment[1 : len(segment)-1]
+			schema := &Schema{Type: "string"}
+			if strings.HasSuffix(name, "ID") {
+				schema.Format = "uuid"
+			}
+			op.Parameters = append(op.Parameters, &Parameter{In: "path", Name: name, Required: true, Schema: schema})
+		}
+	}
+	for _, p := range annotation.Params {
+		schema, err := g.schemaOf(annotation.Package, p.Type)
+		if err != nil {
+			return nil, err
+		}
+		op.Parameters = append(op.Parameters, &Parameter{In: p.In, Name: p.Name, Required: p.Required, Description: p.Description, Schema: schema})
+	}
+	if body := annotation.Body; body != nil {
+		schema, err := g.schemaOf(annotation.Package, body.Type)
+		if err != nil {
+			return nil, err
+		}
+		op.RequestBody = &RequestBody{Description: body.Description, Required: true,
+			Content: map[string]*MediaType{ContentTypeJSON: {Schema: schema}}}
+	}
+	for _, r := range annotation.Responses {
+		response := &Response{Description: r.Description}
+		if response.Description == "" {
+			response.Description = http.StatusText(r.Status)
+		}
+		switch {
+		case r.Type == "stream":
+			response.Content = map[string]*MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}}
+		case r.Type != "":
+			schema, err := g.schemaOf(annotation.Package, r.Type)
+			if err != nil {
+				return nil, err
+			}
+			response.Content = map[string]*MediaType{ContentTypeJSON: {Schema: schema}}
+		case r.Status >= 400 && g.errorType != nil:
+			response.Content = map[string]*MediaType{g.errorMedia: {Schema: g.schemaOfType(g.errorType)}}
+		}
+		op.Responses[fmt.Sprint(r.Status)] = response
+	}
+	if len(op.Responses) == 0 {
+		return nil, fmt.Errorf("No @response annotated")
+	}
+	return op, nil
+}
+
+//schemaOf returns the schema of a type of an annotation of a handler in pkg
+func (g *Generator) schemaOf(pkg, typ string) (*Schema, error) {
+	if strings.HasPrefix(typ, "[]") {
+		items, err := g.schemaOf(pkg, typ[2:])
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	}
+	switch typ {
+	case "string", "integer", "number", "boolean", "object":
+		return &Schema{Type: typ}, nil
+	case "uuid", "date-time", "email", "uri":
+		return &Schema{Type: "string", Format: typ}, nil
+	}
+	name := typ
+	if !strings.Contains(name, ".") {
+		name = pkg + "." + name
+	}
+	t, ok := g.types[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown type %q - register it with WithTypes", typ)
+	}
+	return g.schemaOfType(t), nil
+}
+
+//schemaOfType returns the schema of the JSON encoding of t - named structs are added to the components
+func (g *Generator) schemaOfType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler) {
+		return &Schema{Type: "string", Format: formats[t.PkgPath()+"."+t.Name()]}
+	}
+	if t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) {
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOfType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOfType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.component(t)
+	}
+	return &Schema{}
+}
+
+//component adds the schema of a named struct to the components and refers to it
+func (g *Generator) component(t reflect.Type) *Schema {
+	name, ok := g.components[t]
+	if !ok {
+		name = exported(t.Name())
+		if _, taken := g.schemas[name]; taken {
+			name = exported(path.Base(t.PkgPath())) + name
+		}
+		g.components[t] = name
+		// added before its properties, so that recursive types end
+		schema := &Schema{}
+		g.schemas[name] = schema
+		*schema = *g.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+//object returns the schema of the JSON encoding of a struct - fields of embedded structs are promoted
+func (g *Generator) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, options = tag[:i], tag[i:]
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, fieldType)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := g.schemaOfType(field.Type)
+		if strings.Contains(options, ",string") {
+			property = &Schema{Type: "string"}
+		}
+		if field.Type.Kind() == reflect.Ptr && !strings.Contains(options, ",omitempty") && property.Ref == "" {
+			property.Nullable = true
+		}
+		schema.Properties[name] = property
+	}
+	// fields of the struct itself take precedence
+	for _, e := range embedded {
+		for name, property := range g.object(e).Properties {
+			if _, ok := schema.Properties[name]; !ok {
+				schema.Properties[name] = property
+			}
+		}
+	}
+	return schema
+}
+
+//typeName is the name of t qualified by its package, e.g., "models.Bid"
+func typeName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,166 @@
+package openapi_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/openapi"
+)
+
+const handlers = `package shop
+
+// ListOrders returns the orders
+//
+// @summary Get the orders
+// @tags Orders
+// @param query status {string} required Return only orders in this status
+// @response 200 {[]Order} OK
+func ListOrders(w http.ResponseWriter, r *http.Request) {}
+
+// CreateOrder is not routed in the tests
+//
+// @summary Place an order
+// @body {Order} The order
+// @response 201 {Order} CREATED
+// @response 409 Out of stock
+func (e *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {}
+
+// Helper is not annotated
+func Helper() {}
+`
+
+type Order struct {
+	ID        uuid.UUID         `json:"id"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Amount    float64           `json:"amount"`
+	Count     int64             `json:"count,string"`
+	Note      *string           `json:"note"`
+	Tags      []string          `json:"tags,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	Parent    *Order            `json:"parent,omitempty"`
+	Secret    string            `json:"-"`
+	internal  string
+	Audit
+}
+
+type Audit struct {
+	Author string `json:"author"`
+	Amount string `json:"amount"`
+}
+
+type Error struct {
+	Title string `json:"title"`
+}
+
+func ListOrders(w http.ResponseWriter, r *http.Request) {}
+
+func GetOrder(w http.ResponseWriter, r *http.Request) {}
+
+func Test_ParseAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "annotations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shop.go"), []byte(handlers), 0644))
+
+	annotations, err := openapi.ParseAnnotations(dir)
+	require.NoError(t, err)
+	assert.Len(t, annotations, 2)
+	assert.Equal(t, &openapi.Annotation{
+		Package:   "shop",
+		Summary:   "Get the orders",
+		Tags:      []string{"Orders"},
+		Params:    []openapi.AnnotatedParam{{In: "query", Name: "status", Type: "string", Required: true, Description: "Return only orders in this status"}},
+		Responses: []openapi.AnnotatedResponse{{Status: 200, Type: "[]Order", Description: "OK"}},
+	}, annotations["ListOrders"])
+	create := annotations["OrderHandler.CreateOrder"]
+	require.NotNil(t, create)
+	assert.Equal(t, &openapi.AnnotatedBody{Type: "Order", Description: "The order"}, create.Body)
+	assert.Equal(t, []openapi.AnnotatedResponse{{Status: 201, Type: "Order", Description: "CREATED"}, {Status: 409, Description: "Out of stock"}}, create.Responses)
+
+	for _, broken := range []string{"// @sumary Typo", "// @param path id {uuid}", "// @response ok", "// @body {Order"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "shop.go"), []byte("package shop\n\n"+broken+"\nfunc F() {}\n"), 0644))
+		_, err := openapi.ParseAnnotations(dir)
+		assert.Error(t, err, broken)
+	}
+}
+
+func Test_Generate(t *testing.T) {
+	router := chi.NewRouter()
+	router.Route("/api/v1/orders", func(r chi.Router) {
+		r.Get("/", ListOrders)
+		r.Get("/{orderID}", GetOrder)
+	})
+	router.Get("/health", GetOrder)
+
+	annotations := openapi.Annotations{
+		"ListOrders": {Package: "openapi_test", Summary: "Get the orders", Tags: []string{"Orders"},
+			Params:    []openapi.AnnotatedParam{{In: "query", Name: "status", Type: "string"}},
+			Responses: []openapi.AnnotatedResponse{{Status: 200, Type: "[]Order"}}},
+		"GetOrder": {Package: "openapi_test", Summary: "Get an order",
+			Responses: []openapi.AnnotatedResponse{{Status: 200, Type: "openapi_test.Order"}, {Status: 404, Description: "Order not found"}}},
+	}
+	template := openapi.Spec{OpenAPI: "3.0.2", Servers: []openapi.Server{{URL: "https://localhost/api/v1"}}}
+	generator := openapi.NewGenerator(template).WithTypes(Order{}, &Error{}).WithErrors("application/problem+json", Error{}).WithAnnotations(annotations)
+	spec, err := generator.Generate(router)
+	require.NoError(t, err)
+
+	require.Len(t, spec.Paths, 2, "routes outside the base path are left out")
+	list := spec.Paths["/orders"].Get
+	require.NotNil(t, list)
+	assert.Equal(t, "Get the orders", list.Summary)
+	assert.Equal(t, &openapi.Schema{Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/Order"}},
+		list.Responses["200"].Content["application/json"].Schema)
+	assert.Equal(t, "OK", list.Responses["200"].Description, "descriptions default to the status text")
+
+	get := spec.Paths["/orders/{orderID}"].Get
+	require.NotNil(t, get)
+	assert.Equal(t, []*openapi.Parameter{{In: "path", Name: "orderID", Required: true, Schema: &openapi.Schema{Type: "string", Format: "uuid"}}}, get.Parameters)
+	assert.Equal(t, &openapi.Schema{Ref: "#/components/schemas/Error"}, get.Responses["404"].Content["application/problem+json"].Schema)
+
+	order := spec.Components.Schemas["Order"]
+	require.NotNil(t, order)
+	assert.Equal(t, &openapi.Schema{Type: "string", Format: "uuid"}, order.Properties["id"])
+	assert.Equal(t, &openapi.Schema{Type: "string", Format: "date-time"}, order.Properties["createdAt"])
+	assert.Equal(t, &openapi.Schema{Type: "number"}, order.Properties["amount"], "fields of the struct win over embedded ones")
+	assert.Equal(t, &openapi.Schema{Type: "string"}, order.Properties["count"])
+	assert.Equal(t, &openapi.Schema{Type: "string", Nullable: true}, order.Properties["note"])
+	assert.Equal(t, &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}}, order.Properties["labels"])
+	assert.Equal(t, &openapi.Schema{Ref: "#/components/schemas/Order"}, order.Properties["parent"], "recursive types refer to themselves")
+	assert.Equal(t, &openapi.Schema{Type: "string"}, order.Properties["author"], "fields of embedded structs are promoted")
+	assert.NotContains(t, order.Properties, "Secret")
+	assert.NotContains(t, order.Properties, "internal")
+
+	// the document can be parsed and used for validation
+	route, _ := spec.Find("GET", "/orders")
+	require.NotNil(t, route)
+	assert.NoError(t, spec.ValidateResponse(route, http.StatusOK, "application/json", []byte(`[{"amount": 1}]`)))
+
+	// every handler must be annotated
+	delete(annotations, "GetOrder")
+	_, err = generator.Generate(router)
+	assert.EqualError(t, err, "Handlers without annotations: GET /orders/{orderID} (GetOrder)")
+
+	// types must be registered
+	annotations["GetOrder"] = &openapi.Annotation{Package: "openapi_test", Responses: []openapi.AnnotatedResponse{{Status: 200, Type: "Invoice"}}}
+	_, err = generator.Generate(router)
+	assert.Error(t, err)
+}
+
+type OrderHandler struct{}
+
+func (e *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {}
+
+func Test_HandlerName(t *testing.T) {
+	assert.Equal(t, "ListOrders", openapi.HandlerName(http.HandlerFunc(ListOrders)))
+	assert.Equal(t, "OrderHandler.CreateOrder", openapi.HandlerName(http.HandlerFunc((&OrderHandler{}).CreateOrder)))
+	assert.Equal(t, "ServeMux", openapi.HandlerName(http.NewServeMux()))
+}
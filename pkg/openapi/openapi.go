@@ -0,0 +1,344 @@
+// Package openapi validates requests and responses against the OpenAPI 3 document of the API (swagger/openapi.json)
+// and generates OpenAPI documents from chi routers (see Generator).
+// It understands the subset of OpenAPI the document uses: paths with path, query and header parameters,
+// JSON request and response bodies, and schemas with types, formats, enums, required properties, lengths and bounds.
+package openapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//Methods are the HTTP methods of operations, in the order of the document
+var Methods = []string{"GET", "PUT", "POST", "DELETE", "PATCH"}
+
+//Spec is an OpenAPI document
+type Spec struct {
+	OpenAPI    string                `yaml:"openapi" json:"openapi"`
+	Info       Info                  `yaml:"info" json:"info"`
+	Servers    []Server              `yaml:"servers" json:"servers,omitempty"`
+	Security   []map[string][]string `yaml:"security" json:"security,omitempty"`
+	Paths      map[string]*PathItem  `yaml:"paths" json:"paths"`
+	Components Components            `yaml:"components" json:"components"`
+}
+
+//Info describes the API
+type Info struct {
+	Title   string `yaml:"title" json:"title"`
+	Version string `yaml:"version" json:"version"`
+}
+
+//Server is a base URL of the API
+type Server struct {
+	URL         string `yaml:"url" json:"url"`
+	Description string `yaml:"description" json:"description,omitempty"`
+}
+
+//Components are the schemas and responses referenced by the operations
+type Components struct {
+	SecuritySchemes map[string]*SecurityScheme `yaml:"securitySchemes" json:"securitySchemes,omitempty"`
+	Schemas         map[string]*Schema         `yaml:"schemas" json:"schemas,omitempty"`
+	Responses       map[string]*Response       `yaml:"responses" json:"responses,omitempty"`
+}
+
+//SecurityScheme is a way to authenticate requests
+type SecurityScheme struct {
+	Type         string `yaml:"type" json:"type"`
+	In           string `yaml:"in" json:"in,omitempty"`
+	Name         string `yaml:"name" json:"name,omitempty"`
+	Scheme       string `yaml:"scheme" json:"scheme,omitempty"`
+	BearerFormat string `yaml:"bearerFormat" json:"bearerFormat,omitempty"`
+	Description  string `yaml:"description" json:"description,omitempty"`
+}
+
+//PathItem holds the operations on a path
+type PathItem struct {
+	Parameters []*Parameter `yaml:"parameters" json:"parameters,omitempty"`
+	Get        *Operation   `yaml:"get" json:"get,omitempty"`
+	Put        *Operation   `yaml:"put" json:"put,omitempty"`
+	Post       *Operation   `yaml:"post" json:"post,omitempty"`
+	Delete     *Operation   `yaml:"delete" json:"delete,omitempty"`
+	Patch      *Operation   `yaml:"patch" json:"patch,omitempty"`
+}
+
+//Operation is a method on a path
+type Operation struct {
+	Tags        []string             `yaml:"tags" json:"tags,omitempty"`
+	Summary     string               `yaml:"summary" json:"summary,omitempty"`
+	Description string               `yaml:"description" json:"description,omitempty"`
+	Parameters  []*Parameter         `yaml:"parameters" json:"parameters,omitempty"`
+	RequestBody *RequestBody         `yaml:"requestBody" json:"requestBody,omitempty"`
+	Responses   map[string]*Response `yaml:"responses" json:"responses"`
+}
+
+//Parameter is a path, query or header parameter
+type Parameter struct {
+	In          string  `yaml:"in" json:"in"`
+	Name        string  `yaml:"name" json:"name"`
+	Required    bool    `yaml:"required" json:"required,omitempty"`
+	Description string  `yaml:"description" json:"description,omitempty"`
+	Schema      *Schema `yaml:"schema" json:"schema,omitempty"`
+}
+
+//RequestBody is the body of requests by media type
+type RequestBody struct {
+	Description string                `yaml:"description" json:"description,omitempty"`
+	Required    bool                  `yaml:"required" json:"required,omitempty"`
+	Content     map[string]*MediaType `yaml:"content" json:"content"`
+}
+
+//Response is a response by media type - Ref refers to one of the components
+type Response struct {
+	Ref         string                `yaml:"$ref" json:"$ref,omitempty"`
+	Description string                `yaml:"description" json:"description,omitempty"`
+	Content     map[string]*MediaType `yaml:"content" json:"content,omitempty"`
+}
+
+//MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `yaml:"schema" json:"schema,omitempty"`
+}
+
+//Schema describes a value - Ref refers to one of the components
+type Schema struct {
+	Ref                  string             `yaml:"$ref" json:"$ref,omitempty"`
+	Type                 string             `yaml:"type" json:"type,omitempty"`
+	Format               string             `yaml:"format" json:"format,omitempty"`
+	Description          string             `yaml:"description" json:"description,omitempty"`
+	Nullable             bool               `yaml:"nullable" json:"nullable,omitempty"`
+	Enum                 []interface{}      `yaml:"enum" json:"enum,omitempty"`
+	Required             []string           `yaml:"required" json:"required,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties" json:"properties,omitempty"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties" json:"additionalProperties,omitempty"`
+	Items                *Schema            `yaml:"items" json:"items,omitempty"`
+	Minimum              *float64           `yaml:"minimum" json:"minimum,omitempty"`
+	Maximum              *float64           `yaml:"maximum" json:"maximum,omitempty"`
+	MinLength            *int               `yaml:"minLength" json:"minLength,omitempty"`
+	MaxLength            *int               `yaml:"maxLength" json:"maxLength,omitempty"`
+}
+
+//Load reads the OpenAPI document at path
+func Load(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+//Parse parses an OpenAPI document and checks that its references can be resolved
+func Parse(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("Malformed OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("Unsupported OpenAPI version %q - expected 3.x", spec.OpenAPI)
+	}
+	for _, route := range spec.Routes() {
+		if err := spec.checkRefs(route); err != nil {
+			return nil, fmt.Errorf("%s %s: %v", route.Method, route.Path, err)
+		}
+	}
+	return spec, nil
+}
+
+//BasePath is the path of the URL of the first server, e.g., "/api/v1"
+func (s *Spec) BasePath() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(s.Servers[0].URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+//Route is an operation with its method and path (relative to BasePath)
+type Route struct {
+	Method     string
+	Path       string
+	Operation  *Operation
+	Parameters []*Parameter
+}
+
+//Routes returns the operations of the document sorted by path and method
+func (s *Spec) Routes() []*Route {
+	var routes []*Route
+	for path, item := range s.Paths {
+		for _, method := range Methods {
+			if op := item.operation(method); op != nil {
+				routes = append(routes, &Route{Method: method, Path: path, Operation: op, Parameters: mergeParameters(item.Parameters, op.Parameters)})
+			}
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return methodIndex(routes[i].Method) < methodIndex(routes[j].Method)
+	})
+	return routes
+}
+
+//Find returns the route of a request for path (relative to BasePath) and the values of its path parameters.
+//Paths without parameters take precedence over templated ones, e.g., /invoices/fees over /invoices/{invoiceID}.
+func (s *Spec) Find(method, path string) (*Route, map[string]string) {
+	segments := splitPath(path)
+	var found *Route
+	var foundParams map[string]string
+	foundTemplated := 0
+	for template, item := range s.Paths {
+		op := item.operation(method)
+		if op == nil {
+			continue
+		}
+		params, templated, ok := matchPath(splitPath(template), segments)
+		if !ok || (found != nil && templated >= foundTemplated) {
+			continue
+		}
+		found = &Route{Method: method, Path: template, Operation: op, Parameters: mergeParameters(item.Parameters, op.Parameters)}
+		foundParams, foundTemplated = params, templated
+	}
+	return found, foundParams
+}
+
+func (p *PathItem) operation(method string) *Operation {
+	switch method {
+	case "GET":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "DELETE":
+		return p.Delete
+	case "PATCH":
+		return p.Patch
+	}
+	return nil
+}
+
+//mergeParameters adds the parameters of an operation to those of its path, replacing those with the same name
+func mergeParameters(pathParams, opParams []*Parameter) []*Parameter {
+	merged := append([]*Parameter(nil), opParams...)
+	for _, p := range pathParams {
+		overridden := false
+		for _, o := range opParams {
+			overridden = overridden || (o.In == p.In && o.Name == p.Name)
+		}
+		if !overridden {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+func methodIndex(method string) int {
+	for i, m := range Methods {
+		if m == method {
+			return i
+		}
+	}
+	return len(Methods)
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+//matchPath matches path segments against a template, returning the values of the templated segments
+func matchPath(template, segments []string) (map[string]string, int, bool) {
+	if len(template) != len(segments) {
+		return nil, 0, false
+	}
+	params := map[string]string{}
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			params[t[1:len(t)-1]] = segments[i]
+			continue
+		}
+		if t != segments[i] {
+			return nil, 0, false
+		}
+	}
+	return params, len(params), true
+}
+
+//schema resolves a reference to a schema of the components
+func (s *Spec) schema(schema *Schema) (*Schema, error) {
+	for depth := 0; schema != nil && schema.Ref != ""; depth++ {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := s.Components.Schemas[name]
+		if name == schema.Ref || !ok || depth > 32 {
+			return nil, fmt.Errorf("Cannot resolve schema %s", schema.Ref)
+		}
+		schema = resolved
+	}
+	return schema, nil
+}
+
+//response resolves a reference to a response of the components
+func (s *Spec) response(response *Response) (*Response, error) {
+	if response == nil || response.Ref == "" {
+		return response, nil
+	}
+	name := strings.TrimPrefix(response.Ref, "#/components/responses/")
+	resolved, ok := s.Components.Responses[name]
+	if name == response.Ref || !ok {
+		return nil, fmt.Errorf("Cannot resolve response %s", response.Ref)
+	}
+	return resolved, nil
+}
+
+func (s *Spec) checkRefs(route *Route) error {
+	var schemas []*Schema
+	for _, p := range route.Parameters {
+		schemas = append(schemas, p.Schema)
+	}
+	if body := route.Operation.RequestBody; body != nil {
+		for _, media := range body.Content {
+			schemas = append(schemas, media.Schema)
+		}
+	}
+	for _, response := range route.Operation.Responses {
+		response, err := s.response(response)
+		if err != nil {
+			return err
+		}
+		for _, media := range response.Content {
+			schemas = append(schemas, media.Schema)
+		}
+	}
+	for len(schemas) > 0 {
+		schema, err := s.schema(schemas[0])
+		schemas = schemas[1:]
+		if err != nil {
+			return err
+		}
+		if schema == nil {
+			continue
+		}
+		for _, property := range schema.Properties {
+			if property.Ref == "" {
+				schemas = append(schemas, property)
+			} else if _, err := s.schema(property); err != nil {
+				return err
+			}
+		}
+		if schema.Items != nil {
+			schemas = append(schemas, schema.Items)
+		}
+	}
+	return nil
+}
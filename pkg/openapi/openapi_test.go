@@ -0,0 +1,174 @@
+package openapi_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/openapi"
+)
+
+const document = `
+openapi: '3.0.2'
+servers:
+  - url: https://localhost:9000/api/v1
+components:
+  schemas:
+    Bid:
+      type: object
+      required: [amount]
+      properties:
+        itemID:
+          type: string
+          format: uuid
+        amount:
+          type: number
+          minimum: 0
+        tags:
+          type: array
+          items:
+            type: string
+            enum: [new, used]
+paths:
+  /item/{itemID}/bids:
+    parameters:
+      - in: path
+        name: itemID
+        required: true
+        schema:
+          type: string
+          format: uuid
+    get:
+      parameters:
+        - in: query
+          name: limit
+          schema:
+            type: integer
+            maximum: 100
+      responses:
+        '200':
+          description: OK
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Bid'
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Bid'
+      responses:
+        '201':
+          description: CREATED
+  /item/fees/bids:
+    get:
+      responses:
+        '204':
+          description: NO CONTENT
+`
+
+func Test_Parse(t *testing.T) {
+	spec, err := openapi.Parse([]byte(document))
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1", spec.BasePath())
+	var routes []string
+	for _, route := range spec.Routes() {
+		routes = append(routes, route.Method+" "+route.Path)
+	}
+	assert.Equal(t, []string{"GET /item/fees/bids", "GET /item/{itemID}/bids", "POST /item/{itemID}/bids"}, routes)
+
+	_, err = openapi.Parse([]byte("swagger: '2.0'"))
+	assert.Error(t, err)
+	_, err = openapi.Parse([]byte(`
+openapi: '3.0.2'
+paths:
+  /item:
+    get:
+      responses:
+        '200':
+          $ref: '#/components/responses/Missing'
+`))
+	assert.Error(t, err)
+
+	// the document of the API
+	spec, err = openapi.Load("../../swagger/openapi.json")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1", spec.BasePath())
+}
+
+func Test_Find(t *testing.T) {
+	spec, err := openapi.Parse([]byte(document))
+	require.NoError(t, err)
+
+	route, params := spec.Find("GET", "/item/4a417d40-d1eb-4184-abbe-58700f0a062a/bids/")
+	require.NotNil(t, route)
+	assert.Equal(t, "/item/{itemID}/bids", route.Path)
+	assert.Equal(t, map[string]string{"itemID": "4a417d40-d1eb-4184-abbe-58700f0a062a"}, params)
+	assert.Len(t, route.Parameters, 2, "parameters of the path are merged into those of the operation")
+
+	// paths without parameters take precedence
+	route, _ = spec.Find("GET", "/item/fees/bids")
+	require.NotNil(t, route)
+	assert.Equal(t, "/item/fees/bids", route.Path)
+
+	route, _ = spec.Find("DELETE", "/item/fees/bids")
+	assert.Nil(t, route)
+	route, _ = spec.Find("GET", "/item")
+	assert.Nil(t, route)
+}
+
+func Test_ValidateRequest(t *testing.T) {
+	spec, err := openapi.Parse([]byte(document))
+	require.NoError(t, err)
+	itemID := "4a417d40-d1eb-4184-abbe-58700f0a062a"
+	get, params := spec.Find("GET", "/item/"+itemID+"/bids")
+	post, _ := spec.Find("POST", "/item/"+itemID+"/bids")
+
+	assert.Empty(t, spec.ValidateRequest(get, params, url.Values{"limit": {"10"}}, http.Header{}, "", nil))
+	assert.Equal(t, []models.FieldError{{Field: "query.limit", Message: "must be an integer"}},
+		spec.ValidateRequest(get, params, url.Values{"limit": {"ten"}}, http.Header{}, "", nil))
+	assert.Equal(t, []models.FieldError{{Field: "query.limit", Message: "must be at most 100"}},
+		spec.ValidateRequest(get, params, url.Values{"limit": {"101"}}, http.Header{}, "", nil))
+	assert.Equal(t, []models.FieldError{{Field: "path.itemID", Message: "must be a UUID"}},
+		spec.ValidateRequest(get, map[string]string{"itemID": "x"}, url.Values{}, http.Header{}, "", nil))
+
+	valid := []byte(`{"itemID": "` + itemID + `", "amount": 10.5, "tags": ["new"]}`)
+	assert.Empty(t, spec.ValidateRequest(post, params, url.Values{}, http.Header{}, "application/json; charset=utf-8", valid))
+	assert.Equal(t, []models.FieldError{{Field: "body", Message: "is required"}},
+		spec.ValidateRequest(post, params, url.Values{}, http.Header{}, "application/json", nil))
+	assert.Equal(t, []models.FieldError{{Field: "body", Message: "is not valid JSON"}},
+		spec.ValidateRequest(post, params, url.Values{}, http.Header{}, "application/json", []byte("{")))
+	assert.Empty(t, spec.ValidateRequest(post, params, url.Values{}, http.Header{}, "text/plain", []byte("{")),
+		"bodies of media types other than JSON are left to the handlers")
+
+	errs := spec.ValidateRequest(post, params, url.Values{}, http.Header{}, "application/json",
+		[]byte(`{"itemID": "x", "amount": -1, "tags": ["broken"]}`))
+	assert.ElementsMatch(t, []models.FieldError{
+		{Field: "body.itemID", Message: "must be a UUID"},
+		{Field: "body.amount", Message: "must be at least 0"},
+		{Field: "body.tags[0]", Message: "must be one of [new used]"},
+	}, errs)
+	assert.Equal(t, []models.FieldError{{Field: "body.amount", Message: "is required"}},
+		spec.ValidateRequest(post, params, url.Values{}, http.Header{}, "application/json", []byte(`{}`)))
+}
+
+func Test_ValidateResponse(t *testing.T) {
+	spec, err := openapi.Parse([]byte(document))
+	require.NoError(t, err)
+	get, _ := spec.Find("GET", "/item/4a417d40-d1eb-4184-abbe-58700f0a062a/bids")
+
+	assert.NoError(t, spec.ValidateResponse(get, http.StatusOK, "application/json", []byte(`[{"amount": 1}]`)))
+	assert.Error(t, spec.ValidateResponse(get, http.StatusOK, "application/json", []byte(`{"amount": 1}`)))
+	assert.Error(t, spec.ValidateResponse(get, http.StatusOK, "text/plain", []byte(`1`)), "media type is not documented")
+	assert.Error(t, spec.ValidateResponse(get, http.StatusCreated, "application/json", nil), "status is not documented")
+	// any route may answer with a problem
+	assert.NoError(t, spec.ValidateResponse(get, http.StatusTooManyRequests, "application/problem+json", []byte(`{}`)))
+}
@@ -0,0 +1,319 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//ContentTypeJSON is the media type of the bodies validated against schemas
+const ContentTypeJSON = "application/json"
+
+var (
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+)
+
+//ValidateRequest validates the parameters and the body of a request for route. Path parameters are the values
+//returned by Find. The body is only validated when it is JSON.
+func (s *Spec) ValidateRequest(route *Route, pathParams map[string]string, query url.Values, header http.Header, contentType string, body []byte) []models.FieldError {
+	var errs []models.FieldError
+	for _, p := range route.Parameters {
+		var values []string
+		switch p.In {
+		case "path":
+			if v, ok := pathParams[p.Name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			values = query[p.Name]
+		case "header":
+			values = header[http.CanonicalHeaderKey(p.Name)]
+		default:
+			continue
+		}
+		field := p.In + "." + p.Name
+		if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+			if p.Required {
+				errs = append(errs, models.FieldError{Field: field, Message: "is required"})
+			}
+			continue
+		}
+		errs = append(errs, s.validateParameter(p.Schema, field, values)...)
+	}
+
+	requestBody := route.Operation.RequestBody
+	if requestBody == nil {
+		return errs
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if requestBody.Required {
+			errs = append(errs, models.FieldError{Field: "body", Message: "is required"})
+		}
+		return errs
+	}
+	media, ok := requestBody.Content[mediaType(contentType)]
+	if !ok && contentType == "" {
+		media, ok = requestBody.Content[ContentTypeJSON]
+	}
+	if !ok || media.Schema == nil || !isJSON(contentType) {
+		return errs
+	}
+	return append(errs, s.validateJSON(media.Schema, "body", body)...)
+}
+
+//ValidateResponse validates a response of route. Error responses that the operation does not document are
+//accepted, as any route may answer with a problem (e.g., 401 or 429), but success responses must be documented.
+func (s *Spec) ValidateResponse(route *Route, status int, contentType string, body []byte) error {
+	response, err := s.response(findResponse(route.Operation.Responses, status))
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		if status < 400 {
+			return fmt.Errorf("%s %s: status %d is not documented", route.Method, route.Path, status)
+		}
+		return nil
+	}
+	if len(response.Content) == 0 || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	media, ok := response.Content[mediaType(contentType)]
+	if !ok {
+		if status < 400 {
+			return fmt.Errorf("%s %s: media type %q of status %d is not documented", route.Method, route.Path, contentType, status)
+		}
+		return nil
+	}
+	if media.Schema == nil || !isJSON(contentType) {
+		return nil
+	}
+	if errs := s.validateJSON(media.Schema, "body", body); len(errs) > 0 {
+		return fmt.Errorf("%s %s: response %d does not match the spec: %s", route.Method, route.Path, status, describe(errs))
+	}
+	return nil
+}
+
+//findResponse returns the response documented for status, for its range (e.g., "4XX") or the default one
+func findResponse(responses map[string]*Response, status int) *Response {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", "default"} {
+		if response, ok := responses[key]; ok {
+			return response
+		}
+	}
+	return nil
+}
+
+func describe(errs []models.FieldError) string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Field + " " + e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func mediaType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return media
+}
+
+func isJSON(contentType string) bool {
+	media := mediaType(contentType)
+	return media == "" || media == ContentTypeJSON || strings.HasSuffix(media, "+json")
+}
+
+func (s *Spec) validateJSON(schema *Schema, field string, body []byte) []models.FieldError {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return []models.FieldError{{Field: field, Message: "is not valid JSON"}}
+	}
+	var errs []models.FieldError
+	s.validate(schema, field, value, &errs)
+	return errs
+}
+
+//validateParameter converts the values of a parameter to the type of its schema before validating them
+func (s *Spec) validateParameter(schema *Schema, field string, values []string) []models.FieldError {
+	schema, err := s.schema(schema)
+	if err != nil || schema == nil {
+		return nil
+	}
+	var errs []models.FieldError
+	if schema.Type == "array" {
+		var items []interface{}
+		for _, v := range values {
+			for _, item := range strings.Split(v, ",") {
+				items = append(items, s.parameterValue(schema.Items, item))
+			}
+		}
+		s.validate(schema, field, items, &errs)
+		return errs
+	}
+	if len(values) > 1 {
+		return []models.FieldError{{Field: field, Message: "must be given once"}}
+	}
+	s.validate(schema, field, s.parameterValue(schema, values[0]), &errs)
+	return errs
+}
+
+//parameterValue parses value as the type of schema, leaving it a string if it cannot be parsed
+func (s *Spec) parameterValue(schema *Schema, value string) interface{} {
+	schema, err := s.schema(schema)
+	if err != nil || schema == nil {
+		return value
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func (s *Spec) validate(schema *Schema, field string, value interface{}, errs *[]models.FieldError) {
+	schema, err := s.schema(schema)
+	if err != nil {
+		*errs = append(*errs, models.FieldError{Field: field, Message: err.Error()})
+		return
+	}
+	if schema == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, models.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		fail("must be one of %v", schema.Enum)
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				*errs = append(*errs, models.FieldError{Field: field + "." + name, Message: "is required"})
+			}
+		}
+		for name, property := range schema.Properties {
+			if v, ok := object[name]; ok {
+				s.validate(property, field+"."+name, v, errs)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			s.validate(schema.Items, fmt.Sprintf("%s[%d]", field, i), item, errs)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if schema.MinLength != nil && len(str) < *schema.MinLength {
+			fail("must be at least %d characters long", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && len(str) > *schema.MaxLength {
+			fail("must be at most %d characters long", *schema.MaxLength)
+		}
+		if message := checkFormat(schema.Format, str); message != "" {
+			fail(message)
+		}
+	case "integer", "number":
+		mismatch := "must be a number"
+		if schema.Type == "integer" {
+			mismatch = "must be an integer"
+		}
+		number, ok := value.(json.Number)
+		if !ok {
+			fail(mismatch)
+			return
+		}
+		f, err := number.Float64()
+		if err != nil {
+			fail(mismatch)
+			return
+		}
+		if _, err := number.Int64(); schema.Type == "integer" && err != nil {
+			fail(mismatch)
+			return
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			fail("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			fail("must be at most %v", *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+//checkFormat returns why str does not have format - empty if it does or if the format is unknown
+func checkFormat(format, str string) string {
+	switch format {
+	case "uuid":
+		if !uuidPattern.MatchString(str) {
+			return "must be a UUID"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	case "email":
+		if !emailPattern.MatchString(str) {
+			return "must be an email address"
+		}
+	case "uri":
+		if u, err := url.Parse(str); err != nil || u.Scheme == "" {
+			return "must be an absolute URI"
+		}
+	}
+	return ""
+}
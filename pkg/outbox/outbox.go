@@ -0,0 +1,78 @@
+// Package outbox publishes the events of the storage to external systems without losing any.
+// The Outbox is a projection of the event journal, so an event enters it in the same commit that changes the state.
+// A Relay drains it to a Publisher and removes events only once they have been published (at-least-once delivery:
+// consumers should deduplicate by the sequence number of the event).
+package outbox
+
+import (
+	"sync"
+
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+)
+
+//Outbox keeps committed events until they are acknowledged as published
+type Outbox struct {
+	mutex   sync.Mutex
+	entries []events.Record
+	head    int
+	notify  chan struct{}
+}
+
+//New creates an empty outbox - register it with storage.MapBiddingSystem.Register
+func New() *Outbox {
+	return &Outbox{notify: make(chan struct{}, 1)}
+}
+
+//Apply implements events.Projection
+func (o *Outbox) Apply(r events.Record) {
+	o.mutex.Lock()
+	o.entries = append(o.entries, r)
+	o.mutex.Unlock()
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+//Pending returns up to limit unpublished events, oldest first
+func (o *Outbox) Pending(limit int) []events.Record {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	n := len(o.entries) - o.head
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	pending := make([]events.Record, n)
+	copy(pending, o.entries[o.head:o.head+n])
+	return pending
+}
+
+//Ack removes the events up to (and including) sequence number seq
+func (o *Outbox) Ack(seq uint64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for o.head < len(o.entries) && o.entries[o.head].Seq <= seq {
+		o.entries[o.head] = events.Record{}
+		o.head++
+	}
+	if o.head > len(o.entries)/2 {
+		// compact, so that published events can be collected
+		o.entries = append(o.entries[:0:0], o.entries[o.head:]...)
+		o.head = 0
+	}
+}
+
+//Len returns the number of unpublished events
+func (o *Outbox) Len() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.entries) - o.head
+}
+
+//Notify returns a channel that receives a value when events have been added
+func (o *Outbox) Notify() <-chan struct{} {
+	return o.notify
+}
@@ -0,0 +1,157 @@
+package outbox_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/outbox"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func seqs(records []events.Record) []uint64 {
+	var s []uint64
+	for _, r := range records {
+		s = append(s, r.Seq)
+	}
+	return s
+}
+
+func Test_Outbox_RecordedWithCommit(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	testutils.CreateTestUsers(db, 1)
+	box := outbox.New()
+	db.Register(box)
+	assert.Equal(t, 1, box.Len(), "Events committed before registering should be in the outbox")
+
+	items := testutils.CreateTestItems(db, 1)
+	assert.Equal(t, 2, box.Len(), "Event should be in the outbox when the write returns")
+
+	// a rejected write records nothing
+	err := db.PlaceBid(models.NewBid(items[0].ID, items[0].ID, 10))
+	assert.Error(t, err)
+	assert.Equal(t, 2, box.Len())
+}
+
+func Test_Outbox_PendingAndAck(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	box := outbox.New()
+	db.Register(box)
+	testutils.CreateTestItems(db, 5)
+
+	assert.Equal(t, []uint64{1, 2}, seqs(box.Pending(2)))
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, seqs(box.Pending(0)))
+
+	box.Ack(3)
+	assert.Equal(t, []uint64{4, 5}, seqs(box.Pending(10)))
+	box.Ack(3)
+	assert.Equal(t, 2, box.Len())
+	box.Ack(5)
+	assert.Empty(t, box.Pending(10))
+}
+
+func Test_Relay_Run(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	box := outbox.New()
+	db.Register(box)
+	publisher := outbox.NewMemoryPublisher()
+	relay := outbox.NewRelay(box, publisher, outbox.RelayConfig{BatchSize: 3, Interval: time.Hour, InitialBackoff: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	publisher.FailNext(2)
+	testutils.CreateTestItems(db, 7)
+
+	for i := 0; len(publisher.Records()) < 7; i++ {
+		require.True(t, i < 500, "Relay should publish all events")
+		select {
+		case <-publisher.Published():
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5, 6, 7}, seqs(publisher.Records()),
+		"Events should be published once and in order, despite the failures")
+	assert.Equal(t, 0, box.Len())
+}
+
+func Test_Relay_Drain_KeepsFailedEvents(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	box := outbox.New()
+	db.Register(box)
+	publisher := outbox.NewMemoryPublisher()
+	relay := outbox.NewRelay(box, publisher, outbox.RelayConfig{BatchSize: 2})
+	testutils.CreateTestItems(db, 3)
+
+	publisher.FailNext(1)
+	assert.Equal(t, outbox.ErrPublishFailed, relay.Drain(context.Background()))
+	assert.Equal(t, 3, box.Len())
+
+	assert.NoError(t, relay.Drain(context.Background()))
+	assert.Equal(t, 0, box.Len())
+	assert.Len(t, publisher.Records(), 3)
+}
+
+func Test_WriterPublisher(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	box := outbox.New()
+	db.Register(box)
+	testutils.CreateTestItems(db, 2)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, outbox.NewWriterPublisher(buf).Publish(context.Background(), box.Pending(0)))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	line := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(lines[1], &line))
+	assert.Equal(t, 2.0, line["seq"])
+	assert.Equal(t, string(events.TypeItemListed), line["type"])
+}
+
+func Test_FilePublisher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.jsonl")
+
+	db := storage.NewMapBiddingSystem()
+	box := outbox.New()
+	db.Register(box)
+	testutils.CreateTestItems(db, 3)
+
+	for _, records := range [][]events.Record{box.Pending(2), box.Pending(0)[2:]} {
+		publisher, err := outbox.NewFilePublisher(path)
+		require.NoError(t, err)
+		require.NoError(t, publisher.Publish(context.Background(), records))
+		require.NoError(t, publisher.Close())
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	count := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); count++ {
+	}
+	assert.Equal(t, 3, count, "Publishers should append to the file")
+}
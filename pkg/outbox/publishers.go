@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+)
+
+//WriterPublisher writes events as JSON lines, e.g., to os.Stdout
+type WriterPublisher struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+//NewWriterPublisher creates a publisher writing to w
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+//Publish implements Publisher
+func (p *WriterPublisher) Publish(ctx context.Context, records []events.Record) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	enc := json.NewEncoder(p.w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//FilePublisher appends events as JSON lines to a file and syncs it after every batch
+type FilePublisher struct {
+	*WriterPublisher
+	file *os.File
+}
+
+//NewFilePublisher opens (or creates) the file at path for appending
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{WriterPublisher: NewWriterPublisher(file), file: file}, nil
+}
+
+//Publish implements Publisher - the events are on disk when it returns
+func (p *FilePublisher) Publish(ctx context.Context, records []events.Record) error {
+	if err := p.WriterPublisher.Publish(ctx, records); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+//Close closes the file
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
+
+//ErrPublishFailed is returned by MemoryPublisher when it has been told to fail
+var ErrPublishFailed = errors.New("Publish failed")
+
+//MemoryPublisher keeps published events in memory - a test double for real publishers
+type MemoryPublisher struct {
+	mutex     sync.Mutex
+	records   []events.Record
+	failures  int
+	published chan struct{}
+}
+
+//NewMemoryPublisher creates an empty publisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{published: make(chan struct{}, 1)}
+}
+
+//FailNext makes the next n calls of Publish fail with ErrPublishFailed
+func (p *MemoryPublisher) FailNext(n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failures = n
+}
+
+//Publish implements Publisher
+func (p *MemoryPublisher) Publish(ctx context.Context, records []events.Record) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return ErrPublishFailed
+	}
+	p.records = append(p.records, records...)
+	select {
+	case p.published <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+//Records returns a copy of the published events
+func (p *MemoryPublisher) Records() []events.Record {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]events.Record(nil), p.records...)
+}
+
+//Published returns a channel that receives a value when events have been published
+func (p *MemoryPublisher) Published() <-chan struct{} {
+	return p.published
+}
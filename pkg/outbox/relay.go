@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+)
+
+//Publisher sends events to an external system, e.g., a message broker.
+//Publish must either publish all records, in order, or return an error - they are then published again.
+type Publisher interface {
+	Publish(ctx context.Context, records []events.Record) error
+}
+
+//ErrBacklog is logged while more events than RelayConfig.WarnBacklog wait in the outbox
+var ErrBacklog = errors.New("Events are not published as fast as they are committed")
+
+//RelayConfig tunes a Relay
+type RelayConfig struct {
+	BatchSize      int           // events passed to a single Publish
+	Interval       time.Duration // how often the outbox is checked, in addition to being notified
+	InitialBackoff time.Duration // wait after a failed Publish, doubled for each further failure
+	MaxBackoff     time.Duration // upper bound of the wait after failures
+	WarnBacklog    int           // unpublished events above which every failed Publish logs a warning
+}
+
+func (c RelayConfig) withDefaults() RelayConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = time.Minute
+	}
+	if c.WarnBacklog <= 0 {
+		c.WarnBacklog = 10000
+	}
+	return c
+}
+
+//Relay moves events from an Outbox to a Publisher
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	config    RelayConfig
+}
+
+//NewRelay creates a relay - call Run to start it
+func NewRelay(outbox *Outbox, publisher Publisher, config RelayConfig) *Relay {
+	return &Relay{outbox: outbox, publisher: publisher, config: config.withDefaults()}
+}
+
+//Run publishes events as they arrive until ctx is done, retrying failed batches with exponential backoff
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	backoff := time.Duration(0)
+	for {
+		if err := r.Drain(ctx); err != nil {
+			if backoff == 0 {
+				backoff = r.config.InitialBackoff
+			} else if backoff *= 2; backoff > r.config.MaxBackoff {
+				backoff = r.config.MaxBackoff
+			}
+			logging.LogError("Cannot publish events from the outbox", err)
+			// the outbox is not capped, as no event may be lost - it grows until the publisher recovers
+			if n := r.outbox.Len(); n > r.config.WarnBacklog {
+				logging.LogWarning(fmt.Sprintf("%d events waiting in the outbox", n), ErrBacklog)
+			}
+			select {
+			case <-time.After(backoff):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		backoff = 0
+
+		select {
+		case <-r.outbox.Notify():
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//Drain publishes all pending events in batches and returns on the first error
+func (r *Relay) Drain(ctx context.Context) error {
+	for {
+		pending := r.outbox.Pending(r.config.BatchSize)
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := r.publisher.Publish(ctx, pending); err != nil {
+			return err
+		}
+		r.outbox.Ack(pending[len(pending)-1].Seq)
+	}
+}
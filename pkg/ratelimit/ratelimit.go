@@ -0,0 +1,289 @@
+// Package ratelimit limits the rate of requests with token buckets. Every client (a key such as a user ID or an IP address)
+// has a bucket of Burst tokens, refilled by Requests tokens every Per; a request takes a token or is rejected.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrLimited is the error of rejected requests
+var ErrLimited = errors.New("Too many requests - retry later")
+
+//numShards is the number of independently locked partitions of the buckets of a limiter - must be a power of two
+const numShards = 32
+
+//sweepInterval is how often a shard forgets the buckets of clients that have not been limited for a while
+const sweepInterval = time.Minute
+
+//Limit is a number of requests per period with bursts up to Burst requests
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+//ParseLimit parses "requests/unit[:burst]" with the units s, m and h, e.g., "5/s:10" - burst defaults to requests
+func ParseLimit(s string) (Limit, error) {
+	limit := Limit{}
+	rate := strings.TrimSpace(s)
+	if i := strings.Index(rate, ":"); i >= 0 {
+		burst, err := strconv.Atoi(rate[i+1:])
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("Malformed burst of rate limit %q", s)
+		}
+		limit.Burst = burst
+		rate = rate[:i]
+	}
+	parts := strings.SplitN(rate, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("Malformed rate limit %q - expected requests/unit[:burst]", s)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("Malformed number of requests of rate limit %q", s)
+	}
+	limit.Requests = requests
+	switch parts[1] {
+	case "s":
+		limit.Per = time.Second
+	case "m":
+		limit.Per = time.Minute
+	case "h":
+		limit.Per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("Unknown unit of rate limit %q - expected s, m or h", s)
+	}
+	if limit.Burst == 0 {
+		limit.Burst = requests
+	}
+	return limit, nil
+}
+
+//String formats the limit as parsed by ParseLimit
+func (l Limit) String() string {
+	unit := map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h"}[l.Per]
+	return fmt.Sprintf("%d/%s:%d", l.Requests, unit, l.Burst)
+}
+
+//interval is the time to refill one token
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+//Result is the decision on a request
+type Result struct {
+	Allowed bool
+	//Limit is the size of the bucket, Remaining the number of tokens left in it
+	Limit     int
+	Remaining int
+	//RetryAfter is the time until the next request is allowed - zero if Allowed
+	RetryAfter time.Duration
+	//Reset is the time until the bucket is full again
+	Reset time.Duration
+}
+
+//bucket is the state of a client: the time its bucket is full again. Tokens are derived from it, so that
+//a bucket is updated by a single subtraction - the theoretical arrival time of the generic cell rate algorithm.
+type bucket struct {
+	full time.Time
+}
+
+type shard struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	nextSweep time.Time
+}
+
+//Limiter keeps a bucket per key. The buckets are spread over shards locked independently,
+//so that clients only contend with the clients in the same shard.
+type Limiter struct {
+	limit  Limit
+	now    func() time.Time
+	shards [numShards]shard
+}
+
+//NewLimiter creates a limiter applying limit to every key
+func NewLimiter(limit Limit) *Limiter {
+	l := &Limiter{limit: limit, now: time.Now}
+	for i := range l.shards {
+		l.shards[i].buckets = map[string]*bucket{}
+	}
+	return l
+}
+
+//WithClock replaces the clock - for tests
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	l.now = now
+	return l
+}
+
+//Limit returns the limit applied by the limiter
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+//Allow takes a token from the bucket of key, if there is one
+func (l *Limiter) Allow(key string) Result {
+	now := l.now()
+	interval := l.limit.interval()
+	capacity := time.Duration(l.limit.Burst) * interval
+
+	s := l.shardOf(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{full: now}
+		s.buckets[key] = b
+	}
+	full := b.full
+	if full.Before(now) {
+		full = now
+	}
+	// the bucket holds the tokens not yet taken - it is empty if it is full only after capacity
+	next := full.Add(interval)
+	result := Result{Limit: l.limit.Burst}
+	if next.Sub(now) > capacity {
+		result.RetryAfter = next.Sub(now) - capacity
+		result.Reset = full.Sub(now)
+		return result
+	}
+	b.full = next
+	result.Allowed = true
+	result.Remaining = int((capacity - next.Sub(now)) / interval)
+	result.Reset = next.Sub(now)
+	return result
+}
+
+func (l *Limiter) shardOf(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &l.shards[h.Sum32()&(numShards-1)]
+}
+
+//sweep forgets the buckets that are full - mutex must be held
+func (s *shard) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(sweepInterval)
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+//Rule limits the requests matching Method and Pattern or one of the Aliases. Patterns are paths whose segments in braces
+//match any segment, e.g., "/item/{itemID}/bids". Aliases share the limit, e.g., the route in another version of the API.
+type Rule struct {
+	Method  string
+	Pattern string
+	Aliases []string
+	Limit   Limit
+}
+
+//matches checks whether the rule applies to a request
+func (r *Rule) matches(method, path string) bool {
+	if r.Method != method {
+		return false
+	}
+	if matchPattern(r.Pattern, path) {
+		return true
+	}
+	for _, alias := range r.Aliases {
+		if matchPattern(alias, path) {
+			return true
+		}
+	}
+	return false
+}
+
+//ParseRules parses rules given as "METHOD pattern[|alias...]=limit,...", e.g., "POST /item/{itemID}/bids|/items/{itemID}/bids=5/s:10"
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		route := strings.Fields(parts[0])
+		if len(parts) != 2 || len(route) != 2 {
+			return nil, fmt.Errorf("Malformed rate limit entry %q - expected METHOD /pattern=limit", entry)
+		}
+		patterns := strings.Split(route[1], "|")
+		for _, pattern := range patterns {
+			if !strings.HasPrefix(pattern, "/") {
+				return nil, fmt.Errorf("Malformed rate limit entry %q - expected METHOD /pattern=limit", entry)
+			}
+		}
+		limit, err := ParseLimit(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, Rule{Method: strings.ToUpper(route[0]), Pattern: patterns[0], Aliases: patterns[1:], Limit: limit})
+	}
+	return rules, nil
+}
+
+//Limits applies the limiter of the first rule matching a request
+type Limits struct {
+	rules    []Rule
+	limiters []*Limiter
+}
+
+//NewLimits creates limiters for rules - more specific patterns should be given first
+func NewLimits(rules []Rule) *Limits {
+	l := &Limits{rules: rules}
+	for _, rule := range rules {
+		l.limiters = append(l.limiters, NewLimiter(rule.Limit))
+	}
+	return l
+}
+
+//WithClock replaces the clock of all limiters - for tests
+func (l *Limits) WithClock(now func() time.Time) *Limits {
+	for _, limiter := range l.limiters {
+		limiter.WithClock(now)
+	}
+	return l
+}
+
+//Match returns the limiter and the rule of a request, nil if no rule matches
+func (l *Limits) Match(method, path string) (*Limiter, *Rule) {
+	for i := range l.rules {
+		if l.rules[i].matches(method, path) {
+			return l.limiters[i], &l.rules[i]
+		}
+	}
+	return nil, nil
+}
+
+func matchPattern(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		wildcard := strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+		if !wildcard && segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//Seconds rounds d up to whole seconds, as sent in headers
+func Seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,115 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/ratelimit"
+)
+
+func Test_ParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("5/s:10")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 5, Per: time.Second, Burst: 10}, limit)
+	assert.Equal(t, "5/s:10", limit.String())
+	limit, err = ratelimit.ParseLimit(" 30/m ")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 30, Per: time.Minute, Burst: 30}, limit)
+
+	for _, s := range []string{"", "5", "5/d", "0/s", "x/s", "5/s:0", "5/s:x"} {
+		_, err := ratelimit.ParseLimit(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_ParseRules(t *testing.T) {
+	rules, err := ratelimit.ParseRules("post /item/{itemID}/bids=5/s:10, GET /item=100/m,")
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "POST", rules[0].Method)
+	assert.Equal(t, "/item/{itemID}/bids", rules[0].Pattern)
+
+	limits := ratelimit.NewLimits(rules)
+	limiter, rule := limits.Match("POST", "/item/4f3c/bids/")
+	require.NotNil(t, limiter)
+	assert.Equal(t, 10, limiter.Limit().Burst)
+	assert.Equal(t, "/item/{itemID}/bids", rule.Pattern)
+	limiter, _ = limits.Match("GET", "/item/4f3c/bids")
+	assert.Nil(t, limiter)
+	limiter, _ = limits.Match("POST", "/item/4f3c/bids/x")
+	assert.Nil(t, limiter)
+
+	rules, err = ratelimit.ParseRules("POST /item/{itemID}/bids|/items/{itemID}/bids=5/s")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/items/{itemID}/bids"}, rules[0].Aliases)
+	limits = ratelimit.NewLimits(rules)
+	v1, _ := limits.Match("POST", "/item/4f3c/bids")
+	v2, rule := limits.Match("POST", "/items/4f3c/bids")
+	require.NotNil(t, v2)
+	assert.True(t, v1 == v2, "Aliases share the limiter of the rule")
+	assert.Equal(t, "/item/{itemID}/bids", rule.Pattern)
+
+	for _, s := range []string{"POST=5/s", "/item=5/s", "POST item=5/s", "POST /item", "POST /item|items=5/s", "POST /item|=5/s"} {
+		_, err := ratelimit.ParseRules(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_Limiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Requests: 2, Per: time.Second, Burst: 3}).
+		WithClock(func() time.Time { return now })
+
+	// a burst, then one request every 500ms
+	for remaining := 2; remaining >= 0; remaining-- {
+		result := limiter.Allow("alice")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+	result := limiter.Allow("alice")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+	assert.True(t, limiter.Allow("bob").Allowed, "Clients have buckets of their own")
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.Allow("alice").Allowed)
+	assert.False(t, limiter.Allow("alice").Allowed)
+
+	now = now.Add(time.Hour)
+	result = limiter.Allow("alice")
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining, "Buckets do not fill over their size")
+}
+
+func Test_Limiter_Concurrent(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Requests: 1, Per: time.Hour, Burst: 10})
+	allowed := make([]int, 8)
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for c := range allowed {
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				for i := 0; i < 10; i++ {
+					if limiter.Allow(fmt.Sprintf("client-%d", c)).Allowed {
+						mutex.Lock()
+						allowed[c]++
+						mutex.Unlock()
+					}
+				}
+			}(c)
+		}
+	}
+	wg.Wait()
+	for c, n := range allowed {
+		assert.Equal(t, 10, n, "client-%d", c)
+	}
+}
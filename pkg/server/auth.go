@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/rsa"
+	"log"
+	"net/http"
+
+	"github.com/spf13/viper"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/tenancy"
+)
+
+//Authenticate is a middleware putting the principal of a request on its context (see auth.FromContext).
+//Requests with invalid credentials are rejected with 401. Requests without credentials are rejected as well,
+//unless they only read (GET, HEAD and OPTIONS).
+func Authenticate(authenticator *auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if err == nil && principal == nil && !isRead(r.Method) {
+				err = auth.ErrNoCredentials
+			}
+			if err != nil {
+				logging.LogError("Authentication failed", err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="bid-tracker"`)
+				handlers.WriteHTTPErrorCode(w, r, err, http.StatusUnauthorized)
+				return
+			}
+			if principal != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), principal))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+//newAuthenticator reads the API keys and JWT keys from the configuration - the server does not start with invalid ones.
+//The authenticator accepts only credentials of tenant; those naming no tenant belong to the default tenant.
+func newAuthenticator(tenant *tenancy.Tenant) *auth.Authenticator {
+	keys, err := auth.ParseAPIKeys(viper.GetString("API_KEYS"))
+	if err != nil {
+		log.Fatalf("Invalid API_KEYS: %v", err)
+	}
+	authenticator := auth.NewAuthenticator().WithAPIKeys(keys).WithTenant(tenant.ID, tenant.ID == tenancy.DefaultTenantID)
+
+	var hmacKey []byte
+	if path := viper.GetString("JWT_HMAC_KEY_FILE"); path != "" {
+		if hmacKey, err = auth.LoadHMACKey(path); err != nil {
+			log.Fatalf("Invalid JWT_HMAC_KEY_FILE: %v", err)
+		}
+	}
+	var rsaKey *rsa.PublicKey
+	if path := viper.GetString("JWT_RSA_KEY_FILE"); path != "" {
+		if rsaKey, err = auth.LoadRSAPublicKey(path); err != nil {
+			log.Fatalf("Invalid JWT_RSA_KEY_FILE: %v", err)
+		}
+	}
+	if hmacKey != nil || rsaKey != nil {
+		authenticator.WithJWT(auth.NewJWTVerifier(hmacKey, rsaKey).
+			WithIssuer(viper.GetString("JWT_ISSUER")).
+			WithAudience(viper.GetString("JWT_AUDIENCE")).
+			WithLeeway(viper.GetDuration("JWT_LEEWAY")))
+	}
+	return authenticator
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/spf13/viper"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/openapi"
+)
+
+// define error messages
+const (
+	InvalidRequest  = "Request does not match the API specification"
+	InvalidResponse = "Response does not match the API specification"
+)
+
+//errInvalidResponse answers requests whose responses break the spec in test mode
+var errInvalidResponse = errors.New(InvalidResponse)
+
+//ValidateOpenAPI is a middleware rejecting requests whose parameters or body break spec with 400 - the fields
+//at fault are listed in the errors of the problem. Requests for routes that are not in spec are passed on.
+//With responses, it also buffers the responses of JSON routes and answers those breaking spec with 500 instead,
+//so that tests notice the drift; streams (server-sent events and WebSockets) are passed on unchecked.
+func ValidateOpenAPI(spec *openapi.Spec, responses bool) func(http.Handler) http.Handler {
+	basePath := spec.BasePath()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams := spec.Find(r.Method, strings.TrimPrefix(r.URL.Path, basePath))
+			if route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var body []byte
+			if route.Operation.RequestBody != nil && r.Body != nil {
+				var err error
+				body, err = ioutil.ReadAll(r.Body)
+				if err != nil {
+					logging.LogError(handlers.RequestReadFailure, err)
+					handlers.WriteHTTPErrorCode(w, r, errors.New(handlers.RequestReadFailure), http.StatusBadRequest)
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+			fields := spec.ValidateRequest(route, pathParams, r.URL.Query(), r.Header, r.Header.Get("Content-Type"), body)
+			if len(fields) > 0 {
+				handlers.WriteError(w, r, models.NewError(models.KindInvalid, "invalid_request", InvalidRequest, fields...))
+				return
+			}
+			if !responses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			buffer := &responseBuffer{ResponseWriter: w}
+			next.ServeHTTP(buffer, r)
+			if buffer.streaming {
+				return
+			}
+			status := buffer.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if err := spec.ValidateResponse(route, status, w.Header().Get("Content-Type"), buffer.body.Bytes()); err != nil {
+				logging.LogError(InvalidResponse, err)
+				handlers.WriteHTTPErrorCode(w, r, errInvalidResponse, http.StatusInternalServerError)
+				return
+			}
+			if buffer.status != 0 {
+				w.WriteHeader(buffer.status)
+			}
+			w.Write(buffer.body.Bytes())
+		})
+	}
+}
+
+//responseBuffer holds a response back until it has been validated. Streams are passed on as soon as they start.
+type responseBuffer struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (buf *responseBuffer) WriteHeader(status int) {
+	if buf.status != 0 || buf.streaming {
+		return
+	}
+	if strings.HasPrefix(buf.Header().Get("Content-Type"), "text/event-stream") {
+		buf.streaming = true
+		buf.ResponseWriter.WriteHeader(status)
+		return
+	}
+	buf.status = status
+}
+
+func (buf *responseBuffer) Write(data []byte) (int, error) {
+	if buf.status == 0 && !buf.streaming {
+		buf.WriteHeader(http.StatusOK)
+	}
+	if buf.streaming {
+		return buf.ResponseWriter.Write(data)
+	}
+	return buf.body.Write(data)
+}
+
+//Flush implements http.Flusher for streams
+func (buf *responseBuffer) Flush() {
+	if flusher, ok := buf.ResponseWriter.(http.Flusher); ok && buf.streaming {
+		flusher.Flush()
+	}
+}
+
+//Hijack implements http.Hijacker for WebSockets
+func (buf *responseBuffer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := buf.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Hijacking is not supported")
+	}
+	buf.streaming = true
+	return hijacker.Hijack()
+}
+
+//openAPIValidation reads the OpenAPI document at the path configured by key and the validation mode from the configuration -
+//requests are passed on if validation is off. The server does not start with an invalid document.
+func openAPIValidation(key string) func(http.Handler) http.Handler {
+	mode := viper.GetString("OPENAPI_VALIDATION")
+	switch mode {
+	case config.OpenAPIValidationOff:
+		return func(next http.Handler) http.Handler { return next }
+	case config.OpenAPIValidationRequests, config.OpenAPIValidationAll:
+	default:
+		log.Fatalf("Invalid OPENAPI_VALIDATION %q - expected %s, %s or %s", mode,
+			config.OpenAPIValidationOff, config.OpenAPIValidationRequests, config.OpenAPIValidationAll)
+	}
+	spec, err := openapi.Load(viper.GetString(key))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return ValidateOpenAPI(spec, mode == config.OpenAPIValidationAll)
+}
+
+//openAPIDocument reads the generated document at the path configured by key - it is empty until it has been generated
+func openAPIDocument(key string) []byte {
+	path := viper.GetString(key)
+	document, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		logging.LogError("OpenAPI document not found - generate it with make openapi", err)
+		return nil
+	}
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	if _, err := openapi.Parse(document); err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return document
+}
+
+//GenerateOpenAPI generates the OpenAPI document of the API version under prefix (e.g., config.APIPrefixV1) of router,
+//the routes set up by SetupRoutes, from annotations of the handlers (see openapi.ParseAnnotations) and the types they
+//read and write
+func GenerateOpenAPI(router chi.Routes, annotations openapi.Annotations, prefix string) (*openapi.Spec, error) {
+	version := "1.0"
+	if prefix == config.APIPrefixV2 {
+		version = "2.0"
+	}
+	template := openapi.Spec{
+		OpenAPI: "3.0.2",
+		Info:    openapi.Info{Title: "Bid-Tracker RESTfulApi", Version: version},
+		Servers: []openapi.Server{{URL: "https://localhost:9000" + prefix}},
+		// requests may be anonymous, the handlers decide
+		Security: []map[string][]string{{}, {"apiKey": {}}, {"bearer": {}}},
+		Components: openapi.Components{SecuritySchemes: map[string]*openapi.SecurityScheme{
+			"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key",
+				Description: "Static API key, configured with BID_API_KEYS"},
+			"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+				Description: "JWT signed with HS256/384/512 or RS256/384/512 (the subject is the user ID), or a session token from /accounts/login"},
+		}},
+	}
+	return openapi.NewGenerator(template).
+		WithTypes(handlers.Payloads()...).
+		WithErrors(handlers.ContentTypeProblem, handlers.Problem{}).
+		WithAnnotations(annotations).
+		Generate(router)
+}
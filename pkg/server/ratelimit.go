@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/spf13/viper"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/ratelimit"
+)
+
+// define rate limit headers
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+//RateLimit is a middleware limiting the requests matching the rules of limits per client: the authenticated user,
+//the API key or, for anonymous requests, the IP address. Limited requests are rejected with 429.
+//It must run after Authenticate, so that users are not limited by the IP addresses they share.
+func RateLimit(limits *ratelimit.Limits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+				path = rctx.RoutePath
+			}
+			limiter, _ := limits.Match(r.Method, path)
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result := limiter.Allow(clientKey(r))
+			w.Header().Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			w.Header().Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			w.Header().Set(HeaderRateLimitReset, strconv.Itoa(ratelimit.Seconds(result.Reset)))
+			if !result.Allowed {
+				w.Header().Set(HeaderRetryAfter, strconv.Itoa(ratelimit.Seconds(result.RetryAfter)))
+				handlers.WriteHTTPErrorCode(w, r, ratelimit.ErrLimited, http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//clientKey identifies the client of a request - API keys by their digest, so that they are not kept in memory
+func clientKey(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		if principal.Method == auth.MethodAPIKey {
+			digest := sha256.Sum256([]byte(r.Header.Get(auth.HeaderAPIKey)))
+			return "api-key:" + hex.EncodeToString(digest[:16])
+		}
+		return "user:" + principal.UserID.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+//newLimits reads the rate limits of routes from the configuration - the server does not start with invalid ones
+func newLimits() *ratelimit.Limits {
+	rules, err := ratelimit.ParseRules(viper.GetString("RATE_LIMITS"))
+	if err != nil {
+		log.Fatalf("Invalid RATE_LIMITS: %v", err)
+	}
+	return ratelimit.NewLimits(rules)
+}
@@ -5,19 +5,36 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/smtp"
+	"sync"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
+	"github.com/spf13/viper"
+	"github.com/vikin91/bid-tracker-go/pkg/accounts"
+	"github.com/vikin91/bid-tracker-go/pkg/auth"
 	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
 	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/idempotency"
 	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/metrics"
+	"github.com/vikin91/bid-tracker-go/pkg/notifications"
+	"github.com/vikin91/bid-tracker-go/pkg/ratelimit"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
 	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+	"github.com/vikin91/bid-tracker-go/pkg/tenancy"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
 )
 
 //Server wraps a chi router (chi.Mux)
 type Server struct {
 	mux *chi.Mux
+	// shutdowns stop the services started for the tenants - see Shutdown
+	shutdowns    []func()
+	shutdownOnce sync.Once
 }
 
 func newMux() *chi.Mux {
@@ -30,6 +47,8 @@ func newMux() *chi.Mux {
 		middleware.Recoverer,       // Recover from panics without crashing server
 		middleware.StripSlashes,
 	)
+	mux.NotFound(handlers.NotFound)
+	mux.MethodNotAllowed(handlers.MethodNotAllowed)
 	return mux
 }
 
@@ -44,18 +63,186 @@ func (s *Server) Mux() *chi.Mux {
 	return s.mux
 }
 
-//SetupRoutes adds all routes that the server should listen to
+//SetupRoutes adds all routes that the server should listen to - for a single auction house keeping its data in db
 func (s *Server) SetupRoutes(db storage.Storage) {
-	userHandler := handlers.NewUserHandler(db)
-	itemHandler := handlers.NewItemHandler(db)
+	registry, err := tenancy.NewRegistry(tenancy.Tenant{ID: tenancy.DefaultTenantID})
+	if err != nil {
+		log.Fatalf("Invalid tenant: %v", err)
+	}
+	namespaces := storage.NewNamespaces(nil)
+	namespaces.Add(tenancy.DefaultTenantID, db)
+	s.SetupTenantRoutes(registry, namespaces)
+}
+
+//SetupTenantRoutes adds the routes of several auction houses (tenants), each keeping its data in its own namespace.
+//Every tenant is served by its own handlers, so that no request can reach the data of another tenant.
+func (s *Server) SetupTenantRoutes(registry *tenancy.Registry, namespaces *storage.Namespaces) {
+	// clients are limited across tenants
+	limits := newLimits()
+	validateV1, validateV2 := openAPIValidation("OPENAPI_DOCUMENT"), openAPIValidation("OPENAPI_DOCUMENT_V2")
+	routersV1, routersV2 := map[string]chi.Router{}, map[string]chi.Router{}
+	for _, tenant := range registry.Tenants() {
+		db, err := namespaces.Get(tenant.ID)
+		if err != nil {
+			log.Fatalf("No storage for tenant %s: %v", tenant.ID, err)
+		}
+		var shutdown func()
+		routersV1[tenant.ID], routersV2[tenant.ID], shutdown = tenantRoutes(tenant, db, limits, validateV1, validateV2)
+		s.shutdowns = append(s.shutdowns, shutdown)
+	}
+	s.Mux().With(ResolveTenant(registry)).Mount(config.APIPrefixV1, newTenantRouter(registry, routersV1))
+	s.Mux().With(ResolveTenant(registry)).Mount(config.APIPrefixV2, newTenantRouter(registry, routersV2))
+	// the documents are the same for all tenants
+	s.Mux().Mount(config.APIPrefixV1+"/openapi.json", handlers.NewDocumentHandler(openAPIDocument("OPENAPI_DOCUMENT")).Routes())
+	s.Mux().Mount(config.APIPrefixV2+"/openapi.json", handlers.NewDocumentHandler(openAPIDocument("OPENAPI_DOCUMENT_V2")).Routes())
+}
+
+//Shutdown stops the background services of the tenants (reminders, webhook deliveries and event subscribers)
+//and waits for them. The routes must not be served anymore.
+func (s *Server) Shutdown() {
+	s.shutdownOnce.Do(func() {
+		for _, shutdown := range s.shutdowns {
+			shutdown()
+		}
+	})
+}
+
+//tenantRoutes creates the routes of API v1 and v2 of a tenant and the services behind them, which both versions share.
+//Requests are validated by validateV1 and validateV2 once they have been authenticated and admitted by the rate limits.
+//shutdown stops the services.
+func tenantRoutes(tenant *tenancy.Tenant, db storage.Storage, limits *ratelimit.Limits, validateV1, validateV2 func(http.Handler) http.Handler) (
+	v1, v2 chi.Router, shutdown func()) {
+	// independent subscribers to the events published by the storage - they see every committed change
+	var unsubscribes []func()
+	subscribe := func(p events.Projection, opts ...events.SubscribeOption) {
+		unsubscribes = append(unsubscribes, db.Subscribe(p, opts...))
+	}
+	hub := stream.NewHub(viper.GetInt("STREAM_BUFFER"), viper.GetInt("STREAM_HISTORY"))
+	subscribe(hub, events.Only(events.TypeBidPlaced, events.TypeAuctionClosed))
+
+	var email notifications.EmailSender
+	if addr := viper.GetString("SMTP_ADDR"); addr != "" {
+		sender := &notifications.SMTPSender{Addr: addr, From: viper.GetString("SMTP_FROM")}
+		if username := viper.GetString("SMTP_USERNAME"); username != "" {
+			host, _, _ := net.SplitHostPort(addr)
+			sender.Auth = smtp.PlainAuth("", username, viper.GetString("SMTP_PASSWORD"), host)
+		}
+		email = sender
+	}
+	notifier := notifications.NewNotifier(db, email)
+	subscribe(notifier, events.Async(viper.GetInt("NOTIFICATIONS_BUFFER")), events.Only(notifications.EventTypes...))
+	reminder := notifications.NewReminder(notifier, viper.GetDuration("REMINDER_WINDOW"), viper.GetDuration("REMINDER_INTERVAL"))
+	reminder.Start()
+
+	currency := tenant.Currency
+	if currency == "" {
+		currency = viper.GetString("CURRENCY")
+	}
+	ledger := settlement.NewLedger(db, settlementFees(tenant), viper.GetDuration("PAYMENT_TERMS")).
+		WithOfferValidity(viper.GetDuration("OFFER_VALIDITY")).
+		WithCurrency(currency)
+	subscribe(ledger, events.Only(events.TypeAuctionClosed))
+	policy := auth.DefaultPolicy()
+	invoiceHandler := handlers.NewInvoiceHandler(ledger).WithPolicy(policy)
+	offerHandler := handlers.NewOfferHandler(ledger).WithPolicy(policy)
+	tenantHandler := handlers.NewTenantHandler(tenant, ledger)
 
-	s.Mux().Route(config.APIPrefixV1, func(r chi.Router) {
+	var resets accounts.ResetSender
+	if email != nil {
+		resets = &accounts.EmailResetSender{Email: email, URL: viper.GetString("PASSWORD_RESET_URL")}
+	}
+	accountManager := accounts.NewManager(db, resets).
+		WithHasher(accounts.Hasher{Iterations: viper.GetInt("PASSWORD_ITERATIONS")}).
+		WithSessionTTL(viper.GetDuration("SESSION_TTL")).
+		WithResetTokenTTL(viper.GetDuration("RESET_TOKEN_TTL"))
+	accountHandler := handlers.NewAccountHandler(accountManager)
+
+	userHandler := handlers.NewUserHandler(db).WithStream(hub).WithNotifications(notifier).WithInvoices(ledger).WithPolicy(policy).
+		WithAccounts(accountManager)
+	itemHandler := handlers.NewItemHandler(db).WithStream(hub).WithSettlement(ledger).WithPolicy(policy).
+		WithIdempotency(idempotency.NewStore(viper.GetDuration("IDEMPOTENCY_TTL")))
+	streamHandler := handlers.NewStreamHandler(db, hub)
+
+	registry := webhooks.NewRegistry()
+	dispatcher := webhooks.NewDispatcher(registry, webhooks.Config{
+		Workers:        viper.GetInt("WEBHOOK_WORKERS"),
+		MaxAttempts:    viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+		InitialBackoff: viper.GetDuration("WEBHOOK_BACKOFF"),
+	})
+	dispatcher.Start()
+	subscribe(dispatcher, events.Only(events.TypeBidPlaced, events.TypeAuctionClosed))
+	webhookHandler := handlers.NewWebhookHandler(registry, dispatcher).WithPolicy(policy)
+
+	counters := metrics.NewCounters()
+	subscribe(counters, events.Async(viper.GetInt("METRICS_BUFFER")))
+	metricsHandler := handlers.NewMetricsHandler(counters).WithPolicy(policy)
+
+	authenticate := Authenticate(newAuthenticator(tenant).WithSessions(accountManager))
+	limit := RateLimit(limits)
+	shutdown = func() {
+		for _, unsubscribe := range unsubscribes {
+			unsubscribe()
+		}
+		reminder.Stop()
+		dispatcher.Stop()
+		accountManager.WaitForResets()
+	}
+
+	r := chi.NewRouter()
+	// signing up, logging in, resetting passwords and describing the tenant need no credentials
+	r.With(limit, validateV1).Mount("/accounts", accountHandler.Routes())
+	r.With(validateV1).Mount("/tenant", tenantHandler.Routes())
+	r.Group(func(r chi.Router) {
+		r.Use(authenticate, limit, validateV1)
 		r.Mount("/user", userHandler.Routes())
 		r.Mount("/item", itemHandler.Routes())
+		r.Mount("/stream", streamHandler.Routes())
+		r.Mount("/webhooks", webhookHandler.Routes())
+		r.Mount("/metrics", metricsHandler.Routes())
+		r.Mount("/invoices", invoiceHandler.Routes())
+		r.Mount("/offers", offerHandler.Routes())
 	})
+	// the routers of the handlers answer unknown routes with problems as well
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
+
+	v2 = chi.NewRouter()
+	v2.Use(authenticate, limit, validateV2)
+	v2.Mount("/items", itemHandler.RoutesV2())
+	v2.Mount("/users", userHandler.RoutesV2())
+	v2.NotFound(handlers.NotFound)
+	v2.MethodNotAllowed(handlers.MethodNotAllowed)
+	return r, v2, shutdown
+}
+
+//settlementFees reads the fee schedules of tenant, falling back to the configuration - the server does not start with invalid ones
+func settlementFees(tenant *tenancy.Tenant) settlement.Fees {
+	premium, err := settlement.ParseSchedule(orConfig(tenant.BuyersPremium, "BUYERS_PREMIUM"))
+	if err != nil {
+		log.Fatalf("Invalid BUYERS_PREMIUM of tenant %s: %v", tenant.ID, err)
+	}
+	commission, err := settlement.ParseSchedule(orConfig(tenant.SellerCommission, "SELLER_COMMISSION"))
+	if err != nil {
+		log.Fatalf("Invalid SELLER_COMMISSION of tenant %s: %v", tenant.ID, err)
+	}
+	fees := settlement.Fees{BuyersPremium: premium, SellerCommission: commission, TaxRate: viper.GetFloat64("TAX_RATE")}
+	if tenant.TaxRate != nil {
+		fees.TaxRate = *tenant.TaxRate
+	}
+	if err := fees.Validate(); err != nil {
+		log.Fatalf("Invalid fees of tenant %s: %v", tenant.ID, err)
+	}
+	return fees
+}
+
+func orConfig(value, key string) string {
+	if value == "" {
+		return viper.GetString(key)
+	}
+	return value
 }
 
-//ListenAndServe starts the server
+//ListenAndServe starts the server and shuts it down (see Shutdown) once quit receives a value
 func (s *Server) ListenAndServe(quit chan struct{}, errors chan config.ErrorMessage, port string) {
 	go func() {
 		listenAddress := net.JoinHostPort("", port)
@@ -72,5 +259,6 @@ func (s *Server) ListenAndServe(quit chan struct{}, errors chan config.ErrorMess
 	}()
 
 	<-quit
+	s.Shutdown()
 	log.Printf("Server has been shutdown")
 }
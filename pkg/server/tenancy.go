@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/vikin91/bid-tracker-go/pkg/handlers"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/tenancy"
+)
+
+//ResolveTenant is a middleware putting the tenant of a request on its context (see tenancy.FromContext).
+//Requests naming an unknown tenant are rejected with 404, requests naming none or contradicting tenants with 400.
+func ResolveTenant(registry *tenancy.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, err := registry.Resolve(r)
+			if err == tenancy.ErrUnknownTenant {
+				handlers.WriteHTTPErrorCode(w, r, err, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				logging.LogError("Cannot resolve tenant", err)
+				handlers.WriteHTTPErrorCode(w, r, err, http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(tenancy.NewContext(r.Context(), tenant)))
+		})
+	}
+}
+
+//tenantRouter passes requests to the router of their tenant. All tenants have the same routes,
+//so it describes them by the routes of the first tenant - e.g., for chi.Walk.
+type tenantRouter struct {
+	routers  map[string]chi.Router
+	template chi.Router
+}
+
+func newTenantRouter(registry *tenancy.Registry, routers map[string]chi.Router) *tenantRouter {
+	return &tenantRouter{routers: routers, template: routers[registry.Tenants()[0].ID]}
+}
+
+//ServeHTTP implements http.Handler - the tenant must have been resolved by ResolveTenant
+func (t *tenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant := tenancy.FromContext(r.Context())
+	if tenant == nil {
+		handlers.WriteHTTPErrorCode(w, r, tenancy.ErrNoTenant, http.StatusBadRequest)
+		return
+	}
+	router, ok := t.routers[tenant.ID]
+	if !ok {
+		handlers.WriteHTTPErrorCode(w, r, tenancy.ErrUnknownTenant, http.StatusNotFound)
+		return
+	}
+	router.ServeHTTP(w, r)
+}
+
+//Routes implements chi.Routes
+func (t *tenantRouter) Routes() []chi.Route {
+	return t.template.Routes()
+}
+
+//Middlewares implements chi.Routes
+func (t *tenantRouter) Middlewares() chi.Middlewares {
+	return t.template.Middlewares()
+}
+
+//Match implements chi.Routes
+func (t *tenantRouter) Match(rctx *chi.Context, method, path string) bool {
+	return t.template.Match(rctx, method, path)
+}
@@ -0,0 +1,115 @@
+package settlement
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//Tier charges Rate on the part of an amount up to UpTo that is above the previous tier. UpTo is 0 for the last tier.
+type Tier struct {
+	UpTo float64 `json:"upTo,omitempty"`
+	Rate float64 `json:"rate"`
+}
+
+//Schedule is a list of tiers sorted by UpTo - e.g., 25% up to 1000 and 20% above.
+//An empty schedule charges nothing.
+type Schedule []Tier
+
+//FlatRate is a schedule charging rate on the whole amount
+func FlatRate(rate float64) Schedule {
+	return Schedule{{Rate: rate}}
+}
+
+//ParseSchedule parses a comma-separated list of tiers "rate@upTo", the last one without "@upTo",
+//e.g., "0.25@1000,0.20". An empty string is an empty schedule.
+func ParseSchedule(s string) (Schedule, error) {
+	schedule := Schedule{}
+	if strings.TrimSpace(s) == "" {
+		return schedule, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.SplitN(strings.TrimSpace(part), "@", 2)
+		rate, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed fee rate %q: %v", fields[0], err)
+		}
+		tier := Tier{Rate: rate}
+		if len(fields) == 2 {
+			if tier.UpTo, err = strconv.ParseFloat(fields[1], 64); err != nil {
+				return nil, fmt.Errorf("Malformed fee tier limit %q: %v", fields[1], err)
+			}
+		}
+		schedule = append(schedule, tier)
+	}
+	return schedule, schedule.Validate()
+}
+
+//Validate checks that rates are not negative, limits are increasing and only the last tier is unlimited
+func (s Schedule) Validate() error {
+	previous := 0.0
+	for k, tier := range s {
+		if tier.Rate < 0 {
+			return fmt.Errorf("Fee rate must not be negative: %v", tier.Rate)
+		}
+		if tier.UpTo == 0 {
+			if k != len(s)-1 {
+				return fmt.Errorf("Only the last fee tier may be unlimited")
+			}
+			continue
+		}
+		if tier.UpTo <= previous {
+			return fmt.Errorf("Fee tier limits must be increasing: %v after %v", tier.UpTo, previous)
+		}
+		previous = tier.UpTo
+	}
+	return nil
+}
+
+//Fee returns the fee for amount, rounded to cents. The part of amount above the limit of the last tier is not charged.
+func (s Schedule) Fee(amount float64) float64 {
+	fee, lower := 0.0, 0.0
+	for _, tier := range s {
+		upper := tier.UpTo
+		if upper == 0 || upper > amount {
+			upper = amount
+		}
+		if upper > lower {
+			fee += (upper - lower) * tier.Rate
+		}
+		if tier.UpTo == 0 || tier.UpTo >= amount {
+			break
+		}
+		lower = tier.UpTo
+	}
+	return roundCents(fee)
+}
+
+//Fees are the charges applied when an auction is settled
+type Fees struct {
+	//BuyersPremium is charged to the buyer on top of the hammer price
+	BuyersPremium Schedule `json:"buyersPremium"`
+	//SellerCommission is deducted from the hammer price paid out to the seller
+	SellerCommission Schedule `json:"sellerCommission"`
+	//TaxRate is charged to the buyer on the hammer price and the buyer's premium
+	TaxRate float64 `json:"taxRate"`
+}
+
+//Validate checks the schedules and the tax rate
+func (f Fees) Validate() error {
+	if err := f.BuyersPremium.Validate(); err != nil {
+		return err
+	}
+	if err := f.SellerCommission.Validate(); err != nil {
+		return err
+	}
+	if f.TaxRate < 0 {
+		return fmt.Errorf("Tax rate must not be negative: %v", f.TaxRate)
+	}
+	return nil
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,116 @@
+// Package settlement issues invoices for won auctions. The buyer pays the hammer price (the winning bid),
+// the buyer's premium and taxes; the seller receives the hammer price less the seller commission.
+// Fees come from configurable schedules (see Fees).
+// If the reserve price has not been met or the buyer fails to pay, the item may be offered to the next-highest bidder.
+package settlement
+
+import (
+	"bytes"
+	"text/template"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//Status is a state of an invoice
+type Status string
+
+// define invoice states. Issued and overdue invoices can be paid or cancelled, paid and cancelled ones are final.
+const (
+	StatusIssued    Status = "issued"
+	StatusOverdue   Status = "overdue"
+	StatusPaid      Status = "paid"
+	StatusCancelled Status = "cancelled"
+)
+
+// define errors
+var (
+	ErrInvoiceNotFound = models.NewError(models.KindNotFound, "invoice_not_found", "Invoice not found")
+	ErrInvoiceSettled  = models.NewError(models.KindConflict, "invoice_settled", "Invoice has already been paid or cancelled")
+	ErrUnknownStatus   = models.NewError(models.KindInvalid, "unknown_invoice_status", "Unknown invoice status",
+		models.FieldError{Field: "status", Message: "must be issued, overdue, paid or cancelled"})
+	ErrItemReoffered = models.NewError(models.KindConflict, "item_reoffered", "Item has been offered to another bidder - the invoice cannot be paid")
+)
+
+//Invoice is the bill for a won auction
+type Invoice struct {
+	ID       uuid.UUID `json:"id"`
+	Number   string    `json:"number"`
+	ItemID   uuid.UUID `json:"itemID"`
+	ItemName string    `json:"itemName"`
+	BidID    uuid.UUID `json:"bidID"`
+	BuyerID  uuid.UUID `json:"buyerID"`
+	// SellerID is zero if the item has been listed without a seller
+	SellerID uuid.UUID `json:"sellerID"`
+	// Currency of all amounts, an ISO 4217 code - empty if none has been configured
+	Currency string `json:"currency,omitempty"`
+
+	HammerPrice   float64 `json:"hammerPrice"`
+	BuyersPremium float64 `json:"buyersPremium"`
+	Tax           float64 `json:"tax"`
+	// Total is what the buyer pays: hammer price, buyer's premium and tax
+	Total            float64 `json:"total"`
+	SellerCommission float64 `json:"sellerCommission"`
+	// SellerPayout is what the seller receives: hammer price less seller commission
+	SellerPayout float64 `json:"sellerPayout"`
+
+	Status      Status     `json:"status"`
+	IssuedAt    time.Time  `json:"issuedAt"`
+	DueAt       time.Time  `json:"dueAt"`
+	PaidAt      *time.Time `json:"paidAt,omitempty"`
+	CancelledAt *time.Time `json:"cancelledAt,omitempty"`
+}
+
+//ParseStatus returns the status named s
+func ParseStatus(s string) (Status, error) {
+	switch status := Status(s); status {
+	case StatusIssued, StatusOverdue, StatusPaid, StatusCancelled:
+		return status, nil
+	}
+	return "", ErrUnknownStatus
+}
+
+//IsSettled returns true if the invoice has been paid or cancelled
+func (i *Invoice) IsSettled() bool {
+	return i.Status == StatusPaid || i.Status == StatusCancelled
+}
+
+var textTemplate = template.Must(template.New("invoice").Parse(`INVOICE {{.Number}}
+Status:            {{.Status}}
+Issued:            {{.IssuedAt.Format "2006-01-02 15:04 MST"}}
+Due:               {{.DueAt.Format "2006-01-02 15:04 MST"}}
+{{- if .PaidAt}}
+Paid:              {{.PaidAt.Format "2006-01-02 15:04 MST"}}
+{{- end}}
+{{- if .CancelledAt}}
+Cancelled:         {{.CancelledAt.Format "2006-01-02 15:04 MST"}}
+{{- end}}
+Item:              {{.ItemName}} ({{.ItemID}})
+Buyer:             {{.BuyerID}}
+{{- if .HasSeller}}
+Seller:            {{.SellerID}}
+{{- end}}
+{{- if .Currency}}
+Currency:          {{.Currency}}
+{{- end}}
+
+Hammer price:      {{printf "%12.2f" .HammerPrice}}
+Buyer's premium:   {{printf "%12.2f" .BuyersPremium}}
+Tax:               {{printf "%12.2f" .Tax}}
+Total due:         {{printf "%12.2f" .Total}}
+
+Seller commission: {{printf "%12.2f" .SellerCommission}}
+Seller payout:     {{printf "%12.2f" .SellerPayout}}
+`))
+
+//Text renders the invoice as plain text
+func (i Invoice) Text() string {
+	buf := &bytes.Buffer{}
+	textTemplate.Execute(buf, struct {
+		Invoice
+		HasSeller bool
+	}{i, i.SellerID != config.ZeroUUID})
+	return buf.String()
+}
@@ -0,0 +1,251 @@
+package settlement
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+//Ledger issues an invoice for every auction closed with a winning bid and keeps track of the payments.
+//Subscribe it to the storage to settle closing auctions:
+//
+//	db.Subscribe(ledger, events.Only(events.TypeAuctionClosed))
+//
+//Issued invoices become overdue once their due date has passed - the state is updated whenever the ledger is read.
+type Ledger struct {
+	db            storage.Storage
+	fees          Fees
+	paymentTerms  time.Duration
+	offerValidity time.Duration
+	currency      string
+	now           func() time.Time
+
+	mutex    sync.Mutex
+	count    int
+	invoices map[uuid.UUID]*Invoice
+	// order holds the IDs of the invoices in the order they have been issued
+	order  []uuid.UUID
+	offers map[uuid.UUID]*Offer
+	// offerOrder holds the IDs of the second-chance offers in the order they have been made
+	offerOrder []uuid.UUID
+}
+
+//NewLedger creates a ledger charging fees. Invoices are due paymentTerms after they have been issued.
+func NewLedger(db storage.Storage, fees Fees, paymentTerms time.Duration) *Ledger {
+	return &Ledger{
+		db:            db,
+		fees:          fees,
+		paymentTerms:  paymentTerms,
+		offerValidity: DefaultOfferValidity,
+		now:           time.Now,
+		invoices:      make(map[uuid.UUID]*Invoice),
+		offers:        make(map[uuid.UUID]*Offer),
+	}
+}
+
+//WithOfferValidity sets how long second-chance offers may be accepted
+func (l *Ledger) WithOfferValidity(validity time.Duration) *Ledger {
+	l.offerValidity = validity
+	return l
+}
+
+//WithCurrency sets the currency of the amounts of invoices
+func (l *Ledger) WithCurrency(currency string) *Ledger {
+	l.currency = currency
+	return l
+}
+
+//Currency returns the currency of the amounts of invoices
+func (l *Ledger) Currency() string {
+	return l.currency
+}
+
+//WithClock replaces the source of the current time - useful for tests
+func (l *Ledger) WithClock(now func() time.Time) *Ledger {
+	l.now = now
+	return l
+}
+
+//Fees returns the fees charged on settlement
+func (l *Ledger) Fees() Fees {
+	return l.fees
+}
+
+//Apply implements events.Projection
+func (l *Ledger) Apply(r events.Record) {
+	e, ok := r.Event.(events.AuctionClosed)
+	if !ok || e.WinningBid == nil {
+		return
+	}
+	item, err := l.db.GetItem(e.ItemID)
+	if err != nil {
+		logging.LogError("Cannot settle auction of unknown item", err)
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.issue(item, e.WinningBid, e.WinningBid.Amount, r.At)
+}
+
+//issue creates an invoice for the item sold to the bidder of bid for amount - mutex must be held
+func (l *Ledger) issue(item *models.Item, bid *models.Bid, amount float64, at time.Time) *Invoice {
+	premium := l.fees.BuyersPremium.Fee(amount)
+	tax := roundCents((amount + premium) * l.fees.TaxRate)
+	commission := l.fees.SellerCommission.Fee(amount)
+
+	l.count++
+	invoice := &Invoice{
+		ID:               uuid.NewV4(),
+		Number:           fmt.Sprintf("INV-%06d", l.count),
+		ItemID:           item.ID,
+		ItemName:         item.Name,
+		BidID:            bid.ID,
+		BuyerID:          bid.UserID,
+		SellerID:         item.SellerID,
+		Currency:         l.currency,
+		HammerPrice:      amount,
+		BuyersPremium:    premium,
+		Tax:              tax,
+		Total:            roundCents(amount + premium + tax),
+		SellerCommission: commission,
+		SellerPayout:     roundCents(amount - commission),
+		Status:           StatusIssued,
+		IssuedAt:         at,
+		DueAt:            at.Add(l.paymentTerms),
+	}
+	l.invoices[invoice.ID] = invoice
+	l.order = append(l.order, invoice.ID)
+	return invoice
+}
+
+//Get returns a copy of the invoice
+func (l *Ledger) Get(id uuid.UUID) (Invoice, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	invoice, ok := l.invoices[id]
+	if !ok {
+		return Invoice{}, ErrInvoiceNotFound
+	}
+	l.updateOverdue(invoice, l.now())
+	return *invoice, nil
+}
+
+//ForItem returns a copy of the latest invoice issued for the item
+func (l *Ledger) ForItem(itemID uuid.UUID) (Invoice, error) {
+	list := l.list(func(i *Invoice) bool { return i.ItemID == itemID }, "")
+	if len(list) == 0 {
+		return Invoice{}, ErrInvoiceNotFound
+	}
+	return list[len(list)-1], nil
+}
+
+//ForBuyer returns copies of the invoices of the buyer in the order they have been issued - only the ones in status, if not empty
+func (l *Ledger) ForBuyer(userID uuid.UUID, status Status) []Invoice {
+	return l.list(func(i *Invoice) bool { return i.BuyerID == userID }, status)
+}
+
+//ForSeller returns copies of the invoices for the items of the seller in the order they have been issued - only the ones in status, if not empty
+func (l *Ledger) ForSeller(sellerID uuid.UUID, status Status) []Invoice {
+	return l.list(func(i *Invoice) bool { return i.SellerID == sellerID }, status)
+}
+
+//All returns copies of all invoices in the order they have been issued - only the ones in status, if not empty
+func (l *Ledger) All(status Status) []Invoice {
+	return l.list(func(*Invoice) bool { return true }, status)
+}
+
+func (l *Ledger) list(filter func(*Invoice) bool, status Status) []Invoice {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	list := make([]Invoice, 0)
+	for _, id := range l.order {
+		invoice := l.invoices[id]
+		l.updateOverdue(invoice, now)
+		if filter(invoice) && (status == "" || invoice.Status == status) {
+			list = append(list, *invoice)
+		}
+	}
+	return list
+}
+
+//Pay marks an issued or overdue invoice as paid. The winning bid no longer counts against the credit of the buyer.
+//Once the item has been offered to another bidder (the offer is pending or has been accepted), the invoice cannot be paid
+//any more (ErrItemReoffered), so that the item is not sold twice.
+func (l *Ledger) Pay(id uuid.UUID) (Invoice, error) {
+	return l.settle(id, func(invoice *Invoice, now time.Time) error {
+		for _, offerID := range l.offerOrder {
+			offer := l.offers[offerID]
+			if offer.ItemID != invoice.ItemID {
+				continue
+			}
+			l.updateExpired(offer, now)
+			if offer.Status == OfferPending || (offer.Status == OfferAccepted && offer.InvoiceID != invoice.ID) {
+				return ErrItemReoffered
+			}
+		}
+		invoice.Status = StatusPaid
+		invoice.PaidAt = &now
+		return nil
+	})
+}
+
+//Cancel marks an issued or overdue invoice as cancelled. The winning bid no longer counts against the credit of the buyer.
+func (l *Ledger) Cancel(id uuid.UUID) (Invoice, error) {
+	return l.settle(id, func(invoice *Invoice, now time.Time) error {
+		invoice.Status = StatusCancelled
+		invoice.CancelledAt = &now
+		return nil
+	})
+}
+
+func (l *Ledger) settle(id uuid.UUID, change func(*Invoice, time.Time) error) (Invoice, error) {
+	invoice, err := l.change(id, change)
+	if err != nil {
+		return invoice, err
+	}
+	if err := l.db.ReleaseExposure(invoice.BuyerID, invoice.ItemID); err != nil {
+		logging.LogError("Cannot release the exposure of the buyer", err)
+	}
+	return invoice, nil
+}
+
+//change applies change to an unsettled invoice - change is called with the mutex held and may refuse with an error
+func (l *Ledger) change(id uuid.UUID, change func(*Invoice, time.Time) error) (Invoice, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	invoice, ok := l.invoices[id]
+	if !ok {
+		return Invoice{}, ErrInvoiceNotFound
+	}
+	now := l.now()
+	l.updateOverdue(invoice, now)
+	if invoice.IsSettled() {
+		return *invoice, ErrInvoiceSettled
+	}
+	if err := change(invoice, now); err != nil {
+		return *invoice, err
+	}
+	return *invoice, nil
+}
+
+//Overdue returns copies of the overdue invoices, the longest overdue first
+func (l *Ledger) Overdue() []Invoice {
+	list := l.All(StatusOverdue)
+	sort.SliceStable(list, func(a, b int) bool { return list[a].DueAt.Before(list[b].DueAt) })
+	return list
+}
+
+//updateOverdue moves an issued invoice past its due date to overdue - mutex must be held
+func (l *Ledger) updateOverdue(invoice *Invoice, now time.Time) {
+	if invoice.Status == StatusIssued && now.After(invoice.DueAt) {
+		invoice.Status = StatusOverdue
+	}
+}
@@ -0,0 +1,253 @@
+package settlement
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//DefaultOfferValidity is how long second-chance offers may be accepted, unless set with WithOfferValidity
+const DefaultOfferValidity = 48 * time.Hour
+
+//OfferStatus is a state of a second-chance offer
+type OfferStatus string
+
+// define offer states. Pending offers become expired once their deadline has passed.
+const (
+	OfferPending  OfferStatus = "pending"
+	OfferAccepted OfferStatus = "accepted"
+	OfferDeclined OfferStatus = "declined"
+	OfferExpired  OfferStatus = "expired"
+)
+
+//Reason tells why an item is offered to another bidder
+type Reason string
+
+// define reasons of second-chance offers
+const (
+	ReasonReserveNotMet Reason = "reserve-not-met"
+	ReasonPaymentFailed Reason = "payment-failed"
+)
+
+// define errors of second-chance offers
+var (
+	ErrOfferNotFound  = models.NewError(models.KindNotFound, "offer_not_found", "Offer not found")
+	ErrOfferClosed    = models.NewError(models.KindConflict, "offer_closed", "Offer is no longer pending")
+	ErrOfferPending   = models.NewError(models.KindConflict, "offer_pending", "A second-chance offer for the item is pending")
+	ErrNoSecondChance = models.NewError(models.KindConflict, "no_second_chance", "Item has been sold or awaits payment - no second-chance offer possible")
+	ErrNoBidders      = models.NewError(models.KindConflict, "no_bidders", "No bidder left for a second-chance offer")
+)
+
+//Offer is a second chance for a losing bidder to buy an item for the highest bid of the bidder
+type Offer struct {
+	ID        uuid.UUID   `json:"id"`
+	ItemID    uuid.UUID   `json:"itemID"`
+	UserID    uuid.UUID   `json:"userID"`
+	BidID     uuid.UUID   `json:"bidID"`
+	Amount    float64     `json:"amount"`
+	Reason    Reason      `json:"reason"`
+	Status    OfferStatus `json:"status"`
+	CreatedAt time.Time   `json:"createdAt"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+	// InvoiceID is the invoice issued when the offer has been accepted
+	InvoiceID uuid.UUID `json:"invoiceID"`
+}
+
+//OfferSecondChance offers a closed item to the next-highest bidder: the bidder with the highest bid
+//who has neither bought the item before nor received an offer for it. This is possible if
+//the reserve price has not been met or the buyer has failed to pay (the invoice is overdue or cancelled).
+func (l *Ledger) OfferSecondChance(itemID uuid.UUID) (Offer, error) {
+	item, err := l.db.GetItem(itemID)
+	if err != nil {
+		return Offer{}, err
+	}
+	if !item.IsClosed() {
+		return Offer{}, models.ErrAuctionOpen
+	}
+	bids, err := l.db.GetBidsOnItem(itemID)
+	if err != nil {
+		return Offer{}, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	excluded := make(map[uuid.UUID]struct{})
+	var latest *Invoice
+	for _, id := range l.order {
+		if invoice := l.invoices[id]; invoice.ItemID == itemID {
+			l.updateOverdue(invoice, now)
+			latest = invoice
+			excluded[invoice.BuyerID] = struct{}{}
+		}
+	}
+	for _, id := range l.offerOrder {
+		if offer := l.offers[id]; offer.ItemID == itemID {
+			l.updateExpired(offer, now)
+			if offer.Status == OfferPending {
+				return *offer, ErrOfferPending
+			}
+			excluded[offer.UserID] = struct{}{}
+		}
+	}
+
+	var reason Reason
+	switch {
+	case latest != nil && (latest.Status == StatusOverdue || latest.Status == StatusCancelled):
+		reason = ReasonPaymentFailed
+	case latest == nil && !item.IsReserveMet():
+		reason = ReasonReserveNotMet
+	default:
+		return Offer{}, ErrNoSecondChance
+	}
+
+	var best *models.Bid
+	for _, bid := range bids {
+		if _, ok := excluded[bid.UserID]; ok {
+			continue
+		}
+		if best == nil || bid.Amount > best.Amount {
+			best = bid
+		}
+	}
+	if best == nil {
+		return Offer{}, ErrNoBidders
+	}
+	offer := &Offer{
+		ID:        uuid.NewV4(),
+		ItemID:    itemID,
+		UserID:    best.UserID,
+		BidID:     best.ID,
+		Amount:    best.Amount,
+		Reason:    reason,
+		Status:    OfferPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(l.offerValidity),
+	}
+	l.offers[offer.ID] = offer
+	l.offerOrder = append(l.offerOrder, offer.ID)
+	return *offer, nil
+}
+
+//AcceptOffer accepts a pending offer: an invoice for the amount of the offer is issued to its bidder, and the amount
+//counts against the credit of the bidder until the invoice is settled. An overdue invoice of the previous buyer is cancelled.
+//Offers for items whose invoice has been paid in the meantime cannot be accepted (ErrNoSecondChance).
+func (l *Ledger) AcceptOffer(id uuid.UUID) (Offer, Invoice, error) {
+	offer, invoice, cancelled, err := l.acceptOffer(id)
+	if err != nil {
+		return offer, Invoice{}, err
+	}
+	for _, c := range cancelled {
+		if err := l.db.ReleaseExposure(c.BuyerID, c.ItemID); err != nil {
+			logging.LogError("Cannot release the exposure of the buyer", err)
+		}
+	}
+	if err := l.db.AddExposure(invoice.BuyerID, invoice.ItemID, invoice.HammerPrice); err != nil {
+		logging.LogError("Cannot add the exposure of the buyer", err)
+	}
+	return offer, invoice, nil
+}
+
+func (l *Ledger) acceptOffer(id uuid.UUID) (Offer, Invoice, []Invoice, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	offer, ok := l.offers[id]
+	if !ok {
+		return Offer{}, Invoice{}, nil, ErrOfferNotFound
+	}
+	now := l.now()
+	l.updateExpired(offer, now)
+	if offer.Status != OfferPending {
+		return *offer, Invoice{}, nil, ErrOfferClosed
+	}
+	item, err := l.db.GetItem(offer.ItemID)
+	if err != nil {
+		return *offer, Invoice{}, nil, err
+	}
+
+	for _, invoiceID := range l.order {
+		if invoice := l.invoices[invoiceID]; invoice.ItemID == offer.ItemID && invoice.Status == StatusPaid {
+			return *offer, Invoice{}, nil, ErrNoSecondChance
+		}
+	}
+
+	cancelled := make([]Invoice, 0)
+	for _, invoiceID := range l.order {
+		invoice := l.invoices[invoiceID]
+		if invoice.ItemID != offer.ItemID {
+			continue
+		}
+		l.updateOverdue(invoice, now)
+		if invoice.Status == StatusOverdue {
+			invoice.Status = StatusCancelled
+			invoice.CancelledAt = &now
+			cancelled = append(cancelled, *invoice)
+		}
+	}
+	invoice := l.issue(item, &models.Bid{BaseModel: models.BaseModel{ID: offer.BidID}, UserID: offer.UserID}, offer.Amount, now)
+	offer.Status = OfferAccepted
+	offer.InvoiceID = invoice.ID
+	return *offer, *invoice, cancelled, nil
+}
+
+//DeclineOffer declines a pending offer - the item may be offered to the next bidder
+func (l *Ledger) DeclineOffer(id uuid.UUID) (Offer, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	offer, ok := l.offers[id]
+	if !ok {
+		return Offer{}, ErrOfferNotFound
+	}
+	l.updateExpired(offer, l.now())
+	if offer.Status != OfferPending {
+		return *offer, ErrOfferClosed
+	}
+	offer.Status = OfferDeclined
+	return *offer, nil
+}
+
+//GetOffer returns a copy of the offer
+func (l *Ledger) GetOffer(id uuid.UUID) (Offer, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	offer, ok := l.offers[id]
+	if !ok {
+		return Offer{}, ErrOfferNotFound
+	}
+	l.updateExpired(offer, l.now())
+	return *offer, nil
+}
+
+//OffersForItem returns copies of the offers made for the item in the order they have been made
+func (l *Ledger) OffersForItem(itemID uuid.UUID) []Offer {
+	return l.listOffers(func(o *Offer) bool { return o.ItemID == itemID })
+}
+
+//OffersForUser returns copies of the offers made to the user in the order they have been made
+func (l *Ledger) OffersForUser(userID uuid.UUID) []Offer {
+	return l.listOffers(func(o *Offer) bool { return o.UserID == userID })
+}
+
+func (l *Ledger) listOffers(filter func(*Offer) bool) []Offer {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	list := make([]Offer, 0)
+	for _, id := range l.offerOrder {
+		offer := l.offers[id]
+		l.updateExpired(offer, now)
+		if filter(offer) {
+			list = append(list, *offer)
+		}
+	}
+	return list
+}
+
+//updateExpired moves a pending offer past its deadline to expired - mutex must be held
+func (l *Ledger) updateExpired(offer *Offer, now time.Time) {
+	if offer.Status == OfferPending && now.After(offer.ExpiresAt) {
+		offer.Status = OfferExpired
+	}
+}
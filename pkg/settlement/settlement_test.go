@@ -0,0 +1,306 @@
+package settlement_test
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/settlement"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+func Test_Schedule_Fee(t *testing.T) {
+	tiered, err := settlement.ParseSchedule("0.25@1000, 0.20@5000, 0.10")
+	require.NoError(t, err)
+	tests := []struct {
+		schedule settlement.Schedule
+		amount   float64
+		want     float64
+	}{
+		{settlement.Schedule{}, 100, 0},
+		{settlement.FlatRate(0.1), 123.45, 12.35},
+		{tiered, 800, 200},
+		{tiered, 1000, 250},
+		{tiered, 2000, 450},
+		{tiered, 10000, 250 + 800 + 500},
+		{settlement.Schedule{{UpTo: 100, Rate: 0.5}}, 300, 50},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.schedule.Fee(tt.amount), "%v on %v", tt.schedule, tt.amount)
+	}
+}
+
+func Test_ParseSchedule_Invalid(t *testing.T) {
+	for _, s := range []string{"x", "0.1@y", "-0.1", "0.1,0.2@100", "0.2@100,0.1@50"} {
+		_, err := settlement.ParseSchedule(s)
+		assert.Error(t, err, s)
+	}
+	schedule, err := settlement.ParseSchedule("")
+	assert.NoError(t, err)
+	assert.Empty(t, schedule)
+}
+
+func setup(t *testing.T, now func() time.Time) (storage.Storage, *settlement.Ledger, []*models.User) {
+	db := storage.NewMapBiddingSystem()
+	fees := settlement.Fees{
+		BuyersPremium:    settlement.FlatRate(0.2),
+		SellerCommission: settlement.FlatRate(0.1),
+		TaxRate:          0.1,
+	}
+	ledger := settlement.NewLedger(db, fees, 24*time.Hour).WithClock(now).WithCurrency("EUR")
+	db.Subscribe(ledger, events.Only(events.TypeAuctionClosed))
+	return db, ledger, testutils.CreateTestUsers(db, 2)
+}
+
+func Test_Ledger_Settlement(t *testing.T) {
+	db, ledger, users := setup(t, time.Now)
+	seller, buyer := users[0], users[1]
+	item := models.NewItem("A painting")
+	item.SellerID = seller.ID
+	require.NoError(t, db.CreateItem(item))
+	unsold := testutils.CreateTestItems(db, 1)[0]
+
+	bid := models.NewBid(item.ID, buyer.ID, 100)
+	require.NoError(t, db.PlaceBid(bid))
+	_, err := db.CloseAuction(item.ID)
+	require.NoError(t, err)
+	_, err = db.CloseAuction(unsold.ID)
+	require.NoError(t, err)
+
+	invoices := ledger.All("")
+	require.Len(t, invoices, 1, "Only auctions with a winner are settled")
+	invoice := invoices[0]
+	assert.Equal(t, "INV-000001", invoice.Number)
+	assert.Equal(t, bid.ID, invoice.BidID)
+	assert.Equal(t, buyer.ID, invoice.BuyerID)
+	assert.Equal(t, seller.ID, invoice.SellerID)
+	assert.Equal(t, 100.0, invoice.HammerPrice)
+	assert.Equal(t, 20.0, invoice.BuyersPremium)
+	assert.Equal(t, 12.0, invoice.Tax)
+	assert.Equal(t, 132.0, invoice.Total)
+	assert.Equal(t, "EUR", invoice.Currency)
+	assert.Equal(t, 10.0, invoice.SellerCommission)
+	assert.Equal(t, 90.0, invoice.SellerPayout)
+	assert.Equal(t, settlement.StatusIssued, invoice.Status)
+	assert.Equal(t, invoice.IssuedAt.Add(24*time.Hour), invoice.DueAt)
+
+	assert.Len(t, ledger.ForBuyer(buyer.ID, ""), 1)
+	assert.Empty(t, ledger.ForBuyer(seller.ID, ""))
+	assert.Len(t, ledger.ForSeller(seller.ID, settlement.StatusIssued), 1)
+	assert.Empty(t, ledger.ForSeller(seller.ID, settlement.StatusPaid))
+	byItem, err := ledger.ForItem(item.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, invoice.ID, byItem.ID)
+
+	text := invoice.Text()
+	assert.Contains(t, text, "INVOICE INV-000001")
+	assert.Contains(t, text, "A painting")
+	assert.Contains(t, text, "132.00")
+	assert.Contains(t, text, "Seller:            "+seller.ID.String())
+	assert.Contains(t, text, "Currency:          EUR")
+}
+
+func Test_Ledger_States(t *testing.T) {
+	now := time.Now()
+	db, ledger, users := setup(t, func() time.Time { return now })
+	items := testutils.CreateTestItems(db, 3)
+	for _, item := range items {
+		require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[0].ID, 10)))
+		_, err := db.CloseAuction(item.ID)
+		require.NoError(t, err)
+	}
+	invoices := ledger.All("")
+	require.Len(t, invoices, 3)
+
+	require.NoError(t, db.SetCredit(users[0].ID, models.Credit{Limited: true, CreditLimit: 30}))
+	credit, err := db.GetCredit(users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, credit.Exposure, "Won items count against the credit")
+
+	paid, err := ledger.Pay(invoices[0].ID)
+	assert.NoError(t, err)
+	credit, err = db.GetCredit(users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, credit.Exposure, "Paying should release the exposure")
+	assert.Equal(t, settlement.StatusPaid, paid.Status)
+	assert.NotNil(t, paid.PaidAt)
+	_, err = ledger.Cancel(invoices[0].ID)
+	assert.Equal(t, settlement.ErrInvoiceSettled, err, "Paid invoices cannot be cancelled")
+
+	now = now.Add(25 * time.Hour)
+	overdue := ledger.Overdue()
+	require.Len(t, overdue, 2, "Unpaid invoices should become overdue")
+	assert.Equal(t, settlement.StatusOverdue, overdue[0].Status)
+
+	cancelled, err := ledger.Cancel(invoices[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, settlement.StatusCancelled, cancelled.Status)
+	_, err = ledger.Pay(invoices[1].ID)
+	assert.Equal(t, settlement.ErrInvoiceSettled, err)
+
+	paid, err = ledger.Pay(invoices[2].ID)
+	assert.NoError(t, err, "Overdue invoices can be paid")
+	assert.Equal(t, settlement.StatusPaid, paid.Status)
+	assert.Empty(t, ledger.Overdue())
+
+	_, err = ledger.Pay(uuid.NewV4())
+	assert.Equal(t, settlement.ErrInvoiceNotFound, err)
+	_, err = ledger.Get(uuid.NewV4())
+	assert.Equal(t, settlement.ErrInvoiceNotFound, err)
+}
+
+func Test_Ledger_SecondChance_ReserveNotMet(t *testing.T) {
+	now := time.Now()
+	db, ledger, users := setup(t, func() time.Time { return now })
+	ledger.WithOfferValidity(time.Hour)
+	others := testutils.CreateTestUsers(db, 1)
+	item := models.NewItem("A painting")
+	item.ReservePrice = 100
+	require.NoError(t, db.CreateItem(item))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[0].ID, 50)))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[1].ID, 80)))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, others[0].ID, 60)))
+
+	_, err := ledger.OfferSecondChance(item.ID)
+	assert.Equal(t, models.ErrAuctionOpen, err)
+	winner, err := db.CloseAuction(item.ID)
+	require.NoError(t, err)
+	assert.Nil(t, winner, "Nothing is sold below the reserve price")
+	assert.Empty(t, ledger.All(""))
+
+	offer, err := ledger.OfferSecondChance(item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, users[1].ID, offer.UserID, "Highest bidder gets the first offer")
+	assert.Equal(t, 80.0, offer.Amount)
+	assert.Equal(t, settlement.ReasonReserveNotMet, offer.Reason)
+	_, err = ledger.OfferSecondChance(item.ID)
+	assert.Equal(t, settlement.ErrOfferPending, err)
+
+	now = now.Add(2 * time.Hour)
+	expired, err := ledger.GetOffer(offer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, settlement.OfferExpired, expired.Status)
+	_, _, err = ledger.AcceptOffer(offer.ID)
+	assert.Equal(t, settlement.ErrOfferClosed, err)
+
+	offer, err = ledger.OfferSecondChance(item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, others[0].ID, offer.UserID, "Next-highest bidder gets the next offer")
+	_, err = ledger.DeclineOffer(offer.ID)
+	require.NoError(t, err)
+
+	offer, err = ledger.OfferSecondChance(item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, users[0].ID, offer.UserID)
+	offer, invoice, err := ledger.AcceptOffer(offer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, settlement.OfferAccepted, offer.Status)
+	assert.Equal(t, invoice.ID, offer.InvoiceID)
+	assert.Equal(t, users[0].ID, invoice.BuyerID)
+	assert.Equal(t, 50.0, invoice.HammerPrice)
+
+	_, err = ledger.OfferSecondChance(item.ID)
+	assert.Equal(t, settlement.ErrNoSecondChance, err, "Item awaiting payment cannot be offered")
+	assert.Len(t, ledger.OffersForItem(item.ID), 3)
+	assert.Len(t, ledger.OffersForUser(users[0].ID), 1)
+}
+
+func Test_Ledger_SecondChance_PaymentFailed(t *testing.T) {
+	now := time.Now()
+	db, ledger, users := setup(t, func() time.Time { return now })
+	item := testutils.CreateTestItems(db, 1)[0]
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[0].ID, 50)))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[1].ID, 80)))
+	_, err := db.CloseAuction(item.ID)
+	require.NoError(t, err)
+
+	_, err = ledger.OfferSecondChance(item.ID)
+	assert.Equal(t, settlement.ErrNoSecondChance, err, "Buyer still has time to pay")
+
+	now = now.Add(25 * time.Hour)
+	offer, err := ledger.OfferSecondChance(item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, users[0].ID, offer.UserID)
+	assert.Equal(t, settlement.ReasonPaymentFailed, offer.Reason)
+
+	_, invoice, err := ledger.AcceptOffer(offer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, invoice.HammerPrice)
+	invoices := ledger.All("")
+	require.Len(t, invoices, 2)
+	assert.Equal(t, settlement.StatusCancelled, invoices[0].Status, "Overdue invoice of the failed buyer is cancelled")
+	credit, err := db.GetCredit(users[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, credit.Exposure, "Failed buyer is no longer exposed")
+
+	now = now.Add(25 * time.Hour)
+	_, err = ledger.OfferSecondChance(item.ID)
+	assert.Equal(t, settlement.ErrNoBidders, err)
+	_, err = ledger.OfferSecondChance(uuid.NewV4())
+	assert.Error(t, err)
+}
+
+func Test_Ledger_SecondChance_NoDoubleSale(t *testing.T) {
+	now := time.Now()
+	db, ledger, users := setup(t, func() time.Time { return now })
+	item := testutils.CreateTestItems(db, 1)[0]
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[0].ID, 50)))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[1].ID, 80)))
+	_, err := db.CloseAuction(item.ID)
+	require.NoError(t, err)
+	overdue, err := ledger.ForItem(item.ID)
+	require.NoError(t, err)
+
+	now = now.Add(25 * time.Hour)
+	offer, err := ledger.OfferSecondChance(item.ID)
+	require.NoError(t, err)
+	_, err = ledger.Pay(overdue.ID)
+	assert.Equal(t, settlement.ErrItemReoffered, err, "Invoice cannot be paid while the item is offered to another bidder")
+
+	_, invoice, err := ledger.AcceptOffer(offer.ID)
+	require.NoError(t, err)
+	credit, err := db.GetCredit(users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, credit.Exposure, "Accepted offer counts against the credit of the new buyer")
+	_, err = ledger.Pay(overdue.ID)
+	assert.Equal(t, settlement.ErrInvoiceSettled, err)
+	_, err = ledger.Pay(invoice.ID)
+	require.NoError(t, err, "Invoice of the accepted offer can be paid")
+	credit, err = db.GetCredit(users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, credit.Exposure)
+}
+
+func Test_Ledger_SecondChance_PaidInvoice(t *testing.T) {
+	now := time.Now()
+	db, ledger, users := setup(t, func() time.Time { return now })
+	ledger.WithOfferValidity(time.Hour)
+	item := testutils.CreateTestItems(db, 1)[0]
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[0].ID, 50)))
+	require.NoError(t, db.PlaceBid(models.NewBid(item.ID, users[1].ID, 80)))
+	_, err := db.CloseAuction(item.ID)
+	require.NoError(t, err)
+	overdue, err := ledger.ForItem(item.ID)
+	require.NoError(t, err)
+
+	now = now.Add(25 * time.Hour)
+	offer, err := ledger.OfferSecondChance(item.ID)
+	require.NoError(t, err)
+	now = now.Add(2 * time.Hour)
+	_, err = ledger.Pay(overdue.ID)
+	require.NoError(t, err, "Late payment is accepted once the offer has expired")
+
+	_, _, err = ledger.AcceptOffer(offer.ID)
+	assert.Equal(t, settlement.ErrOfferClosed, err)
+	_, err = ledger.OfferSecondChance(item.ID)
+	assert.Equal(t, settlement.ErrNoSecondChance, err, "Paid item cannot be offered again")
+	credit, err := db.GetCredit(users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, credit.Exposure, "Losing bidder is not exposed")
+}
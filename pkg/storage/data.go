@@ -1,11 +1,11 @@
 package storage
 
 import (
-	"errors"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
 	"github.com/vikin91/bid-tracker-go/pkg/models"
 )
 
@@ -13,86 +13,293 @@ import (
  * Current state:
  * Place Bid = O(1)
  * item.AllBids = O(1)
- * item.WinningBid = O(1)
+ * item.GetWinningBid = O(1)
  * user.ItemsBided = O(1)
  */
 
-//MapBiddingSystem is a data structure ...
+//MapBiddingSystem is an event-sourced data structure.
+//Every change is recorded in a journal as a domain event and only then applied to the read models:
+//items and users kept in copy-on-write concurrent indices.
+//A change locks the items and users it is decided on (see models.Item.Lock) - items before users - so that changes
+//of other items and users proceed in parallel. Items and users are never removed, and closed items never reopen:
+//decisions may rely on those without locking.
 type MapBiddingSystem struct {
-	Items map[uuid.UUID]*models.Item
-	Users map[uuid.UUID]*models.User
+	journal *events.Journal
+	items   *index
+	users   *index
+	rules   models.Rules
 }
 
-//NewMapBiddingSystem creates empty BiddingSystem
+//NewMapBiddingSystem creates empty BiddingSystem - its journal keeps all events
 func NewMapBiddingSystem() *MapBiddingSystem {
-	return &MapBiddingSystem{
-		Items: make(map[uuid.UUID]*models.Item),
-		Users: make(map[uuid.UUID]*models.User),
+	h := &MapBiddingSystem{
+		journal: events.NewJournal(),
+		items:   newIndex(),
+		users:   newIndex(),
+	}
+	h.journal.WithState(events.ProjectionFunc(h.apply))
+	return h
+}
+
+//WithRules rejects bids breaking rules - they are checked when bids are placed, not when events are replayed
+func (h *MapBiddingSystem) WithRules(rules models.Rules) *MapBiddingSystem {
+	h.rules = rules
+	return h
+}
+
+//WithRetention keeps the latest records events in the journal, all of them if 0 - see events.Journal.WithRetention.
+//The state is not affected, but the events dropped are lost for History, Replay and the projections registered later:
+//use it only if the events are kept elsewhere, e.g., published through the outbox.
+func (h *MapBiddingSystem) WithRetention(records int) *MapBiddingSystem {
+	h.journal.WithRetention(records)
+	return h
+}
+
+//Replay rebuilds a BiddingSystem from recorded events, e.g., obtained with History. Records referring to items or users
+//that the records before them have not created are rejected with an error, instead of being applied.
+func Replay(records []events.Record) (*MapBiddingSystem, error) {
+	h := NewMapBiddingSystem()
+	if err := h.journal.Import(records, h.check); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+//History returns the events recorded so far that the journal keeps - the audit trail
+func (h *MapBiddingSystem) History() []events.Record {
+	return h.journal.Records()
+}
+
+//Register adds a projection (read model) - all past events are replayed into it first
+func (h *MapBiddingSystem) Register(p events.Projection) {
+	h.journal.Register(p)
+}
+
+//Subscribe passes every event committed from now on to p, after it has been applied - see events.Bus
+func (h *MapBiddingSystem) Subscribe(p events.Projection, opts ...events.SubscribeOption) (unsubscribe func()) {
+	return h.journal.Bus().Subscribe(p, opts...)
+}
+
+//check makes sure that apply can apply a record taken from elsewhere: the items and users it refers to exist
+func (h *MapBiddingSystem) check(r events.Record) error {
+	var items, users []uuid.UUID
+	switch e := r.Event.(type) {
+	case events.ItemListed:
+		if _, ok := h.items.Load(e.ItemID); ok {
+			return ErrItemExists
+		}
+	case events.UserRegistered:
+		if _, ok := h.users.Load(e.UserID); ok {
+			return ErrUserExists
+		}
+	case events.BidPlaced:
+		if e.Bid == nil {
+			return ErrIncomplete
+		}
+		items, users = []uuid.UUID{e.Bid.ItemID}, []uuid.UUID{e.Bid.UserID}
+		if e.Outbid != nil {
+			users = append(users, e.Outbid.UserID)
+		}
+	case events.AuctionClosed:
+		items = []uuid.UUID{e.ItemID}
+		if e.ReserveNotMet {
+			if e.HighestBid == nil {
+				return ErrIncomplete
+			}
+			users = []uuid.UUID{e.HighestBid.UserID}
+		}
+	case events.ItemWatched:
+		items, users = []uuid.UUID{e.ItemID}, []uuid.UUID{e.UserID}
+	case events.ItemUnwatched:
+		items, users = []uuid.UUID{e.ItemID}, []uuid.UUID{e.UserID}
+	case events.SearchSaved:
+		users = []uuid.UUID{e.UserID}
+	case events.SearchDeleted:
+		users = []uuid.UUID{e.UserID}
+	case events.CreditChanged:
+		users = []uuid.UUID{e.UserID}
+	case events.ExposureReleased:
+		items, users = []uuid.UUID{e.ItemID}, []uuid.UUID{e.UserID}
+	case events.ExposureAdded:
+		items, users = []uuid.UUID{e.ItemID}, []uuid.UUID{e.UserID}
+	}
+	for _, id := range items {
+		if _, err := h.GetItem(id); err != nil {
+			return err
+		}
+	}
+	for _, id := range users {
+		if _, err := h.GetUser(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//apply is the state: the projection maintaining items and users. It is applied under the locks of the change.
+func (h *MapBiddingSystem) apply(r events.Record) {
+	switch e := r.Event.(type) {
+	case events.ItemListed:
+		item := models.NewItem(e.Name)
+		item.ID = e.ItemID
+		item.CreatedAt = e.ListedAt
+		item.ClosesAt = e.ClosesAt
+		item.SellerID = e.SellerID
+		item.ReservePrice = e.ReservePrice
+		item.RelistedFrom = e.RelistedFrom
+		h.items.Store(item.ID, item)
+	case events.UserRegistered:
+		user := models.NewUser(e.Name)
+		user.ID = e.UserID
+		user.CreatedAt = e.RegisteredAt
+		h.users.Store(user.ID, user)
+	case events.BidPlaced:
+		item, _ := h.GetItem(e.Bid.ItemID)
+		user, _ := h.GetUser(e.Bid.UserID)
+		item.PlaceNewBid(e.Bid, r.At)
+		user.PlaceNewBidOnItem(e.Bid, item, r.At)
+		if e.Outbid != nil {
+			outbid, _ := h.GetUser(e.Outbid.UserID)
+			outbid.Release(item.ID)
+		}
+		if winning, err := item.GetWinningBid(); err == nil && winning.ID == e.Bid.ID {
+			user.Expose(item.ID, e.Bid.Amount)
+		}
+	case events.AuctionClosed:
+		item, _ := h.GetItem(e.ItemID)
+		item.Close()
+		if e.ReserveNotMet {
+			// nothing is sold, so the highest bid does not count against the credit of the bidder
+			bidder, _ := h.GetUser(e.HighestBid.UserID)
+			bidder.Release(item.ID)
+		}
+	case events.ItemWatched:
+		item, _ := h.GetItem(e.ItemID)
+		user, _ := h.GetUser(e.UserID)
+		user.Watch(item)
+		item.AddWatcher(user.ID)
+	case events.ItemUnwatched:
+		item, _ := h.GetItem(e.ItemID)
+		user, _ := h.GetUser(e.UserID)
+		user.Unwatch(item.ID)
+		item.RemoveWatcher(user.ID)
+	case events.SearchSaved:
+		user, _ := h.GetUser(e.UserID)
+		search := models.NewSavedSearch(e.UserID, e.Name, e.Query)
+		search.ID = e.SearchID
+		search.CreatedAt = e.SavedAt
+		user.AddSavedSearch(search)
+	case events.SearchDeleted:
+		user, _ := h.GetUser(e.UserID)
+		user.RemoveSavedSearch(e.SearchID)
+	case events.CreditChanged:
+		user, _ := h.GetUser(e.UserID)
+		user.SetCredit(e.Limited, e.CreditLimit, e.Deposit)
+	case events.ExposureReleased:
+		user, _ := h.GetUser(e.UserID)
+		user.Release(e.ItemID)
+	case events.ExposureAdded:
+		user, _ := h.GetUser(e.UserID)
+		user.Expose(e.ItemID, e.Amount)
 	}
 }
 
 //AllItems ...
 func (h *MapBiddingSystem) AllItems() ([]*models.Item, error) {
-	var values []*models.Item = make([]*models.Item, 0)
-	for _, v := range h.Items {
-		values = append(values, v)
-	}
+	var values []*models.Item = make([]*models.Item, 0, h.items.Len())
+	h.items.Range(func(v interface{}) bool {
+		values = append(values, v.(*models.Item))
+		return true
+	})
 	return values, nil
 }
 
-//CreateItem ...
+//CreateItem records listing of the item. ID and CreatedAt are set, if empty - ErrItemExists if an item has the ID already.
+//The seller must be a known user, if set, and the item it is relisted from must be closed, if set.
 func (h *MapBiddingSystem) CreateItem(item *models.Item) error {
 	if item.ID == config.ZeroUUID {
 		item.ID = uuid.NewV4()
 		item.CreatedAt = time.Now()
 	}
-	h.Items[item.ID] = item
-	return nil
+	_, err := h.journal.Commit(func() (events.Event, error) {
+		if _, ok := h.items.Load(item.ID); ok {
+			return nil, ErrItemExists
+		}
+		if item.SellerID != config.ZeroUUID {
+			if _, err := h.GetUser(item.SellerID); err != nil {
+				return nil, err
+			}
+		}
+		if item.RelistedFrom != config.ZeroUUID {
+			original, err := h.GetItem(item.RelistedFrom)
+			if err != nil {
+				return nil, err
+			}
+			if !original.IsClosed() {
+				return nil, models.ErrAuctionOpen
+			}
+		}
+		return events.ItemListed{
+			ItemID:       item.ID,
+			Name:         item.Name,
+			ListedAt:     item.CreatedAt,
+			ClosesAt:     item.ClosesAt,
+			SellerID:     item.SellerID,
+			ReservePrice: item.ReservePrice,
+			RelistedFrom: item.RelistedFrom,
+		}, nil
+	}, h.items.Locker(item.ID))
+	return err
 }
 
 //GetItem ...
 func (h *MapBiddingSystem) GetItem(id uuid.UUID) (*models.Item, error) {
-	if itm, ok := h.Items[id]; ok {
-		return itm, nil
+	if itm, ok := h.items.Load(id); ok {
+		return itm.(*models.Item), nil
 	}
-	return &models.Item{}, errors.New("Cannot find item")
+	return &models.Item{}, ErrItemNotFound
 }
 
 //AllUsers ...
 func (h *MapBiddingSystem) AllUsers() ([]*models.User, error) {
-	var values []*models.User = make([]*models.User, 0)
-	for _, v := range h.Users {
-		values = append(values, v)
-	}
+	var values []*models.User = make([]*models.User, 0, h.users.Len())
+	h.users.Range(func(v interface{}) bool {
+		values = append(values, v.(*models.User))
+		return true
+	})
 	return values, nil
 }
 
-//CreateUser ...
+//CreateUser records registration of the user. ID and CreatedAt are set, if empty - ErrUserExists if a user has the ID already.
 func (h *MapBiddingSystem) CreateUser(user *models.User) error {
 	if user.ID == config.ZeroUUID {
 		user.ID = uuid.NewV4()
 		user.CreatedAt = time.Now()
 	}
-	h.Users[user.ID] = user
-	return nil
+	_, err := h.journal.Commit(func() (events.Event, error) {
+		if _, ok := h.users.Load(user.ID); ok {
+			return nil, ErrUserExists
+		}
+		return events.UserRegistered{UserID: user.ID, Name: user.Name, RegisteredAt: user.CreatedAt}, nil
+	}, h.users.Locker(user.ID))
+	return err
 }
 
 //GetUser ...
 func (h *MapBiddingSystem) GetUser(id uuid.UUID) (*models.User, error) {
-	if usr, ok := h.Users[id]; ok {
-		return usr, nil
+	if usr, ok := h.users.Load(id); ok {
+		return usr.(*models.User), nil
 	}
-	return &models.User{}, errors.New("Cannot find user")
+	return &models.User{}, ErrUserNotFound
 }
 
 //AllBids ...
 func (h *MapBiddingSystem) AllBids() ([]*models.Bid, error) {
 	var values []*models.Bid = make([]*models.Bid, 0)
-	for _, item := range h.Items {
-		for _, bidsOnItem := range item.GetBids() {
-			values = append(values, bidsOnItem)
-		}
-	}
+	h.items.Range(func(v interface{}) bool {
+		values = append(values, v.(*models.Item).GetBids()...)
+		return true
+	})
 	return values, nil
 }
 
@@ -100,12 +307,14 @@ func (h *MapBiddingSystem) AllBids() ([]*models.Bid, error) {
 func (h *MapBiddingSystem) GetUserBids(userID uuid.UUID) ([]*models.Bid, error) {
 	user, err := h.GetUser(userID)
 	if err != nil {
-		return make([]*models.Bid, 0), errors.New("User not found")
+		return make([]*models.Bid, 0), ErrUserNotFound
 	}
 	return user.GetBids(), nil
 }
 
-//PlaceBid (ASSIGNMENT FUNCTION)
+//PlaceBid (ASSIGNMENT FUNCTION). A bid that would become the winning one is rejected with models.ErrCreditExceeded
+//if the user cannot afford it. The check and the update of the exposure are atomic: the item and the bidder are locked
+//until the bid has been applied. The exposure of the outbid user is only lowered, so it needs no lock.
 func (h *MapBiddingSystem) PlaceBid(bid *models.Bid) error {
 	if bid.ID == config.ZeroUUID {
 		bid.ID = uuid.NewV4()
@@ -113,6 +322,9 @@ func (h *MapBiddingSystem) PlaceBid(bid *models.Bid) error {
 	if bid.CreatedAt.IsZero() {
 		bid.CreatedAt = time.Now()
 	}
+	// the caller keeps its bid, so that it cannot change the placed one (see models.Bid)
+	placed := *bid
+	bid = &placed
 	item, err := h.GetItem(bid.ItemID)
 	if err != nil {
 		return err
@@ -121,26 +333,65 @@ func (h *MapBiddingSystem) PlaceBid(bid *models.Bid) error {
 	if err != nil {
 		return err
 	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		if item.IsClosed() {
+			return nil, models.ErrAuctionClosed
+		}
+		event := events.BidPlaced{Bid: bid}
+		winning, _ := item.GetWinningBid()
+		noWinner := winning == nil
+		if rejected := h.rules.Check(winning, bid.Amount); rejected != nil {
+			return nil, rejected
+		}
+		if !noWinner && bid.Amount > winning.Amount {
+			event.Outbid = winning
+		}
+		becomesWinning := event.Outbid != nil || (noWinner && bid.Amount > 0)
+		// the exposure is reserved while the item and the user are locked, and made final when the event is applied
+		if becomesWinning && !user.Reserve(item.ID, bid.Amount) {
+			return nil, models.ErrCreditExceeded
+		}
+		return event, nil
+	}, item, user)
+	return err
+}
 
-	item.PlaceNewBid(bid)
-	user.PlaceNewBidOnItem(bid, item)
-	return nil
+//CloseAuction ends the auction on the item and returns the winning bid - nil if nobody has bid or the reserve price has not been met
+func (h *MapBiddingSystem) CloseAuction(itemID uuid.UUID) (*models.Bid, error) {
+	item, err := h.GetItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	record, err := h.journal.Commit(func() (events.Event, error) {
+		if item.IsClosed() {
+			return nil, models.ErrAuctionClosed
+		}
+		winning, _ := item.GetWinningBid()
+		if winning != nil && !item.IsReserveMet() {
+			return events.AuctionClosed{ItemID: itemID, ReserveNotMet: true, HighestBid: winning}, nil
+		}
+		return events.AuctionClosed{ItemID: itemID, WinningBid: winning}, nil
+	}, item)
+	if err != nil {
+		return nil, err
+	}
+	return record.Event.(events.AuctionClosed).WinningBid, nil
 }
 
 //GetItemsUserHasBid (ASSIGNMENT FUNCTION) returns a slice of items no which user has placed at least one bid
 func (h *MapBiddingSystem) GetItemsUserHasBid(userID uuid.UUID) ([]*models.Item, error) {
 	user, err := h.GetUser(userID)
 	if err != nil {
-		return []*models.Item{}, errors.New("User not found")
+		return []*models.Item{}, ErrUserNotFound
 	}
-	return user.ItemsBid, nil
+	return user.GetItemsBid(), nil
 }
 
 //GetBidsOnItem (ASSIGNMENT FUNCTION) return slice of Bids for an Item
 func (h *MapBiddingSystem) GetBidsOnItem(itemID uuid.UUID) ([]*models.Bid, error) {
 	item, err := h.GetItem(itemID)
 	if err != nil {
-		return make([]*models.Bid, 0), errors.New("Item not found")
+		return make([]*models.Bid, 0), ErrItemNotFound
 	}
 	return item.GetBids(), nil
 }
@@ -154,7 +405,246 @@ func (h *MapBiddingSystem) GetWinningBid(itemID uuid.UUID) (*models.Bid, error)
 	return item.GetWinningBid()
 }
 
+//GetBidsOnItemAsOf returns bids on an item that had been accepted until asOf
+func (h *MapBiddingSystem) GetBidsOnItemAsOf(itemID uuid.UUID, asOf time.Time) ([]*models.Bid, error) {
+	item, err := h.GetItem(itemID)
+	if err != nil {
+		return make([]*models.Bid, 0), ErrItemNotFound
+	}
+	return item.GetBidsAsOf(asOf), nil
+}
+
+//GetWinningBidAsOf returns the bid that was winning for an item at asOf
+func (h *MapBiddingSystem) GetWinningBidAsOf(itemID uuid.UUID, asOf time.Time) (*models.Bid, error) {
+	item, err := h.GetItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	return item.GetWinningBidAsOf(asOf)
+}
+
+//GetItemsUserHasBidAsOf returns items on which user had placed at least one bid until asOf
+func (h *MapBiddingSystem) GetItemsUserHasBidAsOf(userID uuid.UUID, asOf time.Time) ([]*models.Item, error) {
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return []*models.Item{}, ErrUserNotFound
+	}
+	return user.GetItemsBidAsOf(asOf), nil
+}
+
+//WatchItem adds the item to the watchlist of the user - watching an item twice has no effect
+func (h *MapBiddingSystem) WatchItem(userID, itemID uuid.UUID) error {
+	if _, err := h.GetItem(itemID); err != nil {
+		return err
+	}
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		if user.IsWatching(itemID) {
+			return nil, nil
+		}
+		return events.ItemWatched{UserID: userID, ItemID: itemID}, nil
+	}, user)
+	return err
+}
+
+//UnwatchItem removes the item from the watchlist of the user - models.ErrNotWatching if it is not there
+func (h *MapBiddingSystem) UnwatchItem(userID, itemID uuid.UUID) error {
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		if !user.IsWatching(itemID) {
+			return nil, models.ErrNotWatching
+		}
+		return events.ItemUnwatched{UserID: userID, ItemID: itemID}, nil
+	}, user)
+	return err
+}
+
+//GetWatchlist returns the items the user watches, in the order they have been added
+func (h *MapBiddingSystem) GetWatchlist(userID uuid.UUID) ([]*models.Item, error) {
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return []*models.Item{}, ErrUserNotFound
+	}
+	return user.GetWatchlist(), nil
+}
+
+//GetWatchers returns the users watching the item
+func (h *MapBiddingSystem) GetWatchers(itemID uuid.UUID) ([]*models.User, error) {
+	item, err := h.GetItem(itemID)
+	if err != nil {
+		return []*models.User{}, ErrItemNotFound
+	}
+	ids := item.GetWatchers()
+	users := make([]*models.User, 0, len(ids))
+	for _, id := range ids {
+		if user, err := h.GetUser(id); err == nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+//SaveSearch records the search for its user. ID and CreatedAt are set, if empty.
+func (h *MapBiddingSystem) SaveSearch(search *models.SavedSearch) error {
+	if err := search.Validate(); err != nil {
+		return err
+	}
+	if search.ID == config.ZeroUUID {
+		search.ID = uuid.NewV4()
+		search.CreatedAt = time.Now()
+	}
+	user, err := h.GetUser(search.UserID)
+	if err != nil {
+		return err
+	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		return events.SearchSaved{
+			SearchID: search.ID,
+			UserID:   search.UserID,
+			Name:     search.Name,
+			Query:    search.Query,
+			SavedAt:  search.CreatedAt,
+		}, nil
+	}, user)
+	return err
+}
+
+//DeleteSearch removes a saved search of the user - models.ErrSearchNotFound if the user has no such search
+func (h *MapBiddingSystem) DeleteSearch(userID, searchID uuid.UUID) error {
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		for _, search := range user.GetSavedSearches() {
+			if search.ID == searchID {
+				return events.SearchDeleted{UserID: userID, SearchID: searchID}, nil
+			}
+		}
+		return nil, models.ErrSearchNotFound
+	}, user)
+	return err
+}
+
+//GetSavedSearches returns the searches saved by the user
+func (h *MapBiddingSystem) GetSavedSearches(userID uuid.UUID) ([]*models.SavedSearch, error) {
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return []*models.SavedSearch{}, ErrUserNotFound
+	}
+	return user.GetSavedSearches(), nil
+}
+
+//AllSavedSearches returns the searches saved by all users
+func (h *MapBiddingSystem) AllSavedSearches() ([]*models.SavedSearch, error) {
+	values := make([]*models.SavedSearch, 0)
+	h.users.Range(func(v interface{}) bool {
+		values = append(values, v.(*models.User).GetSavedSearches()...)
+		return true
+	})
+	return values, nil
+}
+
+//RelistItem lists a copy of a closed item in a new auction linked to the original by RelistedFrom.
+//closesAt is the announced end of the new auction, nil for none.
+func (h *MapBiddingSystem) RelistItem(itemID uuid.UUID, closesAt *time.Time) (*models.Item, error) {
+	original, err := h.GetItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	item := models.NewItem(original.Name)
+	item.SellerID = original.SellerID
+	item.ReservePrice = original.ReservePrice
+	item.RelistedFrom = original.ID
+	item.ClosesAt = closesAt
+	if err := h.CreateItem(item); err != nil {
+		return nil, err
+	}
+	return h.GetItem(item.ID)
+}
+
+//SetCredit sets the credit limit and the deposit of the user - credit.Limited false removes the limit.
+//Exposure and Available of credit are ignored.
+func (h *MapBiddingSystem) SetCredit(userID uuid.UUID, credit models.Credit) error {
+	if err := credit.Validate(); err != nil {
+		return err
+	}
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		return events.CreditChanged{
+			UserID:      userID,
+			Limited:     credit.Limited,
+			CreditLimit: credit.CreditLimit,
+			Deposit:     credit.Deposit,
+		}, nil
+	}, user)
+	return err
+}
+
+//GetCredit returns the credit of the user with the current exposure
+func (h *MapBiddingSystem) GetCredit(userID uuid.UUID) (models.Credit, error) {
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return models.Credit{}, ErrUserNotFound
+	}
+	return user.GetCredit(), nil
+}
+
+//ReleaseExposure stops counting the winning bid of the user on the item against the credit of the user - e.g., once it has been paid.
+//Bids on open auctions are released only by being outbid. Releasing twice has no effect.
+func (h *MapBiddingSystem) ReleaseExposure(userID, itemID uuid.UUID) error {
+	item, err := h.GetItem(itemID)
+	if err != nil {
+		return err
+	}
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		if !item.IsClosed() {
+			return nil, ErrExposureOpen
+		}
+		if !user.IsExposed(itemID) {
+			return nil, nil
+		}
+		return events.ExposureReleased{UserID: userID, ItemID: itemID}, nil
+	}, item, user)
+	return err
+}
+
+//AddExposure counts amount against the credit of the user for a closed item bought after the auction -
+//e.g., with a second-chance offer. It replaces a previous exposure of the user on the item.
+func (h *MapBiddingSystem) AddExposure(userID, itemID uuid.UUID, amount float64) error {
+	item, err := h.GetItem(itemID)
+	if err != nil {
+		return err
+	}
+	user, err := h.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	_, err = h.journal.Commit(func() (events.Event, error) {
+		if !item.IsClosed() {
+			return nil, models.ErrAuctionOpen
+		}
+		return events.ExposureAdded{UserID: userID, ItemID: itemID, Amount: amount}, nil
+	}, item, user)
+	return err
+}
+
 //Reset empties the data structure
 func (h *MapBiddingSystem) Reset() {
-	h = NewMapBiddingSystem()
+	h.journal.Reset()
+	h.items.Reset()
+	h.users.Reset()
 }
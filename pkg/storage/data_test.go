@@ -1,533 +1,237 @@
 package storage_test
 
 import (
-	"fmt"
-	"math"
-	"math/rand"
-	"reflect"
-	"sort"
+	"errors"
 	"testing"
-	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/vikin91/bid-tracker-go/internal/testutils"
-	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
 	"github.com/vikin91/bid-tracker-go/pkg/models"
 	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/storage/storagetest"
 )
 
-var h = storage.NewMapBiddingSystem()
-
-//scale is related to the maximum number of elements used in benchmarks - 2^scale
-const scale = 8
-
-func Test_AllItems(t *testing.T) {
-	tests := []struct {
-		name           string
-		numItemsCreate int
-		wantNumItems   int
-		wantErr        bool
-	}{
-		{"Should get empty set of items", 0, 0, false},
-		{"Should get 2 items", 2, 2, false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			h.Reset()
-			testutils.CreateTestItems(h, tt.numItemsCreate)
-
-			got, err := h.AllItems()
-			assert.Equal(t, tt.wantNumItems, len(got), "Got Wrong number of items")
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".AllItems() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-		})
-	}
-}
-
-func Test_AllUsers(t *testing.T) {
-
-	tests := []struct {
-		name           string
-		numUsersCreate int
-		wantNumUsers   int
-		wantErr        bool
-	}{
-		{"Should get empty set of items", 0, 0, false},
-		{"Should get 2 items", 2, 2, false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			h.Reset()
-			testutils.CreateTestUsers(h, tt.numUsersCreate)
-
-			got, err := h.AllUsers()
-			assert.Equal(t, tt.wantNumUsers, len(got), "Got Wrong number of items")
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".AllUsers() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-		})
-	}
-}
-
-func Test_AllBids(t *testing.T) {
-	tests := []struct {
-		name          string
-		numBidsCreate int
-		wantNumBids   int
-		wantErr       bool
-	}{
-		{"Should get empty set of items", 0, 0, false},
-		{"Should get 2 items", 2, 2, false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			h.Reset()
-			testutils.CreateTestBids(h, tt.numBidsCreate, testutils.GenerateSliceOfRandomFloat64(tt.numBidsCreate))
-
-			got, err := h.AllBids()
-			assert.Equal(t, tt.wantNumBids, len(got), "Got Wrong number of items")
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".AllBids() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-		})
-	}
-}
-
-func Test_CreateItem(t *testing.T) {
-
-	tests := []struct {
-		name     string
-		itemName string
-		wantErr  bool
-	}{
-		{"Should create an item", "A thing", false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			h.Reset()
-			item := &models.Item{Name: tt.itemName}
-			if err := h.CreateItem(item); (err != nil) != tt.wantErr {
-				t.Errorf(".CreateItem() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			//CreateItem should set required fields
-			assert.NotNil(t, item.ID)
-			assert.NotNil(t, item.CreatedAt)
-			assert.NotEqualf(t, config.ZeroUUID, item.ID, "UUID must mot be empty. Is: %s", item.ID.String())
-			assert.NotEqualf(t, time.Time{}, item.CreatedAt, "CreatedAt must mot be empty. Is: %s", item.CreatedAt.String())
-		})
-	}
-}
-
-func Test_CreateUser(t *testing.T) {
-
-	tests := []struct {
-		name     string
-		userName string
-		wantErr  bool
-	}{
-		{"test1", "James Bond", false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			h.Reset()
-			user := &models.User{Name: tt.userName}
-			if err := h.CreateUser(user); (err != nil) != tt.wantErr {
-				t.Errorf(".CreateUser() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			//CreateUser should set required fields
-			assert.NotNil(t, user.ID)
-			assert.NotNil(t, user.CreatedAt)
-			assert.NotEqualf(t, config.ZeroUUID, user.ID, "UUID must mot be empty. Is: %s", user.ID.String())
-			assert.NotEqualf(t, time.Time{}, user.CreatedAt, "CreatedAt must mot be empty. Is: %s", user.CreatedAt.String())
-		})
-	}
-}
-
-func Test_PlaceBid(t *testing.T) {
-
-	h.Reset()
-	users := testutils.CreateTestUsers(h, 10)
-	items := testutils.CreateTestItems(h, 10)
-
-	tests := []struct {
-		name    string
-		userIdx int
-		itemIdx int
-		amount  float64
-		wantErr bool
-	}{
-		{"Bid should be added", 0, 0, 9.99, false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			user := users[tt.userIdx]
-			item := items[tt.itemIdx]
-			bid := models.NewBid(item.ID, user.ID, tt.amount)
-
-			if err := h.PlaceBid(bid); (err != nil) != tt.wantErr {
-				t.Errorf(".PlaceBid() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			//PlaceBid should set required fields
-			assert.NotNil(t, bid.ID)
-			assert.NotNil(t, bid.UserID)
-			assert.NotNil(t, bid.ItemID)
-			assert.NotNil(t, bid.CreatedAt)
-			assert.NotEqualf(t, config.ZeroUUID, bid.ID, "Bid ID must mot be empty. Is: %s", bid.ID.String())
-			assert.NotEqualf(t, config.ZeroUUID, bid.UserID, "Bid User ID must mot be empty. Is: %s", bid.UserID.String())
-			assert.NotEqualf(t, config.ZeroUUID, bid.ItemID, "Bid Item ID must mot be empty. Is: %s", bid.ItemID.String())
-			assert.Falsef(t, bid.CreatedAt.IsZero(), "CreatedAt must mot be empty. Is: %s", bid.CreatedAt.String())
-		})
-	}
-}
-
-func Test_GetUser(t *testing.T) {
-
-	h.Reset()
-	users := testutils.CreateTestUsers(h, 10)
-
-	tests := []struct {
-		name     string
-		ID       uuid.UUID
-		expected *models.User
-		wantErr  bool
-	}{
-		{"User 0 should exist", users[0].ID, users[0], false},
-		{"User 1 should exist", users[1].ID, users[1], false},
-		{"User 9 should exist", users[9].ID, users[9], false},
-		{"User should not exist", uuid.NewV4(), &models.User{}, true},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			user, err := h.GetUser(tt.ID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".GetUser() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			if !reflect.DeepEqual(user, tt.expected) {
-				t.Errorf(".GetUser() = \n%+v\n, want \n%+v", user, tt.expected)
-			}
-		})
-	}
-}
-
-func Test_GetItem(t *testing.T) {
-
-	h.Reset()
-	items := testutils.CreateTestItems(h, 10)
-
-	tests := []struct {
-		name     string
-		ID       uuid.UUID
-		expected *models.Item
-		wantErr  bool
-	}{
-		{"Item 0 should exist", items[0].ID, items[0], false},
-		{"Item 1 should exist", items[1].ID, items[1], false},
-		{"Item 9 should exist", items[9].ID, items[9], false},
-		{"Item should not exist", uuid.NewV4(), &models.Item{}, true},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			user, err := h.GetItem(tt.ID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".GetItem() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			if !reflect.DeepEqual(user, tt.expected) {
-				t.Errorf(".GetItem() = \n%+v\n, want \n%+v", user, tt.expected)
-			}
-		})
-	}
-}
-
-func Test_GetUserBids(t *testing.T) {
-
-	h.Reset()
-	num := 10
-	amounts := testutils.GenerateSliceOfRandomFloat64(num)
-	bids, _, users := testutils.CreateTestBids(h, num, amounts)
-
-	tests := []struct {
-		name    string
-		userID  uuid.UUID
-		wantBid []*models.Bid
-		wantErr bool
-	}{
-		{"Should find the bid", users[0].ID, []*models.Bid{bids[0]}, false},
-		{"Should not find the bid", uuid.NewV4(), []*models.Bid{bids[6]}, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			got, err := h.GetUserBids(tt.userID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".GetUserBids() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !tt.wantErr {
-				// got is slice, so must be compared against slice
-				if !reflect.DeepEqual(got, tt.wantBid) {
-					t.Errorf(".GetUserBids() got = \n%+v\n, want \n%+v\n", got, tt.wantBid)
-				}
-			}
-		})
-	}
-}
-
-func Test_GetBidsOnItem(t *testing.T) {
-
-	h.Reset()
-	num := 10
-	amounts := testutils.GenerateSliceOfRandomFloat64(num)
-	bids, items, _ := testutils.CreateTestBids(h, num, amounts)
-
-	tests := []struct {
-		name    string
-		itemID  uuid.UUID
-		wantBid []*models.Bid
-		wantErr bool
-	}{
-		{"Should find the bid", items[0].ID, []*models.Bid{bids[0]}, false},
-		{"Should not find the bid", uuid.NewV4(), []*models.Bid{bids[6]}, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			got, err := h.GetBidsOnItem(tt.itemID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".GetBidsOnItem() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !tt.wantErr {
-				if !reflect.DeepEqual(got, tt.wantBid) {
-					t.Errorf(".GetBidsOnItem() got = \n%+v\n, want \n%+v\n", got, tt.wantBid)
-				}
-			}
-		})
-	}
+func newMapBiddingSystem() storage.Storage {
+	return storage.NewMapBiddingSystem()
 }
 
-func Test_GetWinningBid(t *testing.T) {
-
-	h.Reset()
-	numItems := 10
-	amountsMatrix, maxAmounts := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
-	_, items, _ := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
-
-	tests := []struct {
-		name          string
-		itemID        uuid.UUID
-		wantMaxAmount float64
-		wantErr       bool
-	}{
-		{"Should find winning bid for 0", items[0].ID, maxAmounts[0], false},
-		{"Should find winning bid for 4", items[4].ID, maxAmounts[4], false},
-		{"Should not find the bid", uuid.NewV4(), 0.0, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			item, _ := h.GetItem(tt.itemID)
-			if !tt.wantErr {
-				assert.NotNil(t, item.WinningBid)
-				assert.Equal(t, tt.wantMaxAmount, item.MaxBidAmount)
-			} else {
-				assert.Nil(t, item.WinningBid)
-				assert.Equal(t, tt.wantMaxAmount, item.MaxBidAmount)
-			}
-
-			gotBid, err := h.GetWinningBid(tt.itemID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf(".GetWinningBid() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !tt.wantErr {
-				assert.Equal(t, tt.wantMaxAmount, gotBid.Amount)
-			}
-
-		})
-	}
-}
-
-func Test_GetItemsUserHasBid(t *testing.T) {
-
-	h.Reset()
-	numItems := 10
-	amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
-	_, items, users := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix) // each users bids multiple times but on exactly one item
-
-	tests := []struct {
-		name         string
-		userID       uuid.UUID
-		wantNumItems int
-		wantItems    []*models.Item
-		wantErr      bool
-	}{
-		{"User 0 should bid on 0-th item", users[0].ID, 1, []*models.Item{items[0]}, false},
-		{"User 1 should bid on 1-st item", users[1].ID, 1, []*models.Item{items[1]}, false},
-		{"Unknown user should not be found", uuid.NewV4(), 0, []*models.Item{}, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			gotItems, err := h.GetItemsUserHasBid(tt.userID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetItemsUserHasBid() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			assert.Equal(t, tt.wantNumItems, len(gotItems))
-			if !tt.wantErr && !reflect.DeepEqual(gotItems, tt.wantItems) {
-				t.Errorf("GetItemsUserHasBid() gotItems = \n%+v\n, wantItems \n%+v\n", gotItems, tt.wantItems)
-			}
-		})
-	}
-}
-
-func Test_GetItemsUserHasBid_TwoUsers(t *testing.T) {
-
-	h.Reset()
-	numItems := 10
-	amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
-	_, items, users := testutils.CreateTestTwoUsersBidOnManyItems(h, numItems, amountsMatrix) // each users bids multiple times but on exactly one item
-
-	evenItems := []*models.Item{items[0], items[2], items[4], items[6], items[8]}
-	oddItems := []*models.Item{items[1], items[3], items[5], items[7], items[9]}
-
-	tests := []struct {
-		name         string
-		userID       uuid.UUID
-		wantNumItems int
-		wantItems    []*models.Item
-		wantErr      bool
-	}{
-		{"User 0 should bid on even items", users[0].ID, 5, evenItems, false},
-		{"User 1 should bid on odd items", users[1].ID, 5, oddItems, false},
-		{"Unknown user should not be found", uuid.NewV4(), 0, []*models.Item{}, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			gotItems, err := h.GetItemsUserHasBid(tt.userID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetItemsUserHasBid() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			assert.Equal(t, tt.wantNumItems, len(gotItems))
-
-			//sorting the results by Name, so that compare is possible
-			sort.Slice(gotItems, func(i, j int) bool {
-				return gotItems[i].Name > gotItems[j].Name
-			})
-			sort.Slice(tt.wantItems, func(i, j int) bool {
-				return tt.wantItems[i].Name > tt.wantItems[j].Name
-			})
-			if !tt.wantErr && !reflect.DeepEqual(gotItems, tt.wantItems) {
-				t.Errorf("GetItemsUserHasBid() gotItems = \n%+v\n, wantItems \n%+v\n", gotItems, tt.wantItems)
-			}
-		})
-	}
+func Test_MapBiddingSystem(t *testing.T) {
+	storagetest.RunTests(t, newMapBiddingSystem)
 }
 
 //// BENCHMARKS
 
 func Benchmark_PlaceBid_OneUser_OneItem(b *testing.B) {
-
-	user := models.NewUser("James Bond")
-	item := models.NewItem("A thing")
-
-	for n := 0; n < b.N; n++ {
-		bid := models.NewBid(item.ID, user.ID, 3.1415)
-		h.PlaceBid(bid)
-	}
+	storagetest.BenchmarkPlaceBidOneUserOneItem(b, newMapBiddingSystem)
 }
 
 func Benchmark_PlaceBid_ManyUsers_ManyItems(b *testing.B) {
-	for k := 0.; k <= scale; k++ {
-		n := int(math.Pow(2, k))
-		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
-			b.StopTimer()
-			h.Reset()
-			items := testutils.CreateTestItems(h, n)
-			users := testutils.CreateTestUsers(h, n)
-
-			randomItemIdx := rand.Int31n(int32(n))
-			randomUserIdx := rand.Int31n(int32(n))
-			b.StartTimer()
-			for i := 0; i < b.N; i++ {
-				bid := models.NewBid(items[randomItemIdx].ID, users[randomUserIdx].ID, 3.1415)
-				h.PlaceBid(bid)
-			}
-		})
-	}
+	storagetest.BenchmarkPlaceBidManyUsersManyItems(b, newMapBiddingSystem)
 }
 
 func Benchmark_GetWinningBid(b *testing.B) {
-	for k := 0.; k <= scale; k++ {
-		n := int(math.Pow(2, k))
-		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
-			b.StopTimer()
-
-			h.Reset()
-			numItems := n
-			amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
-			_, items, _ := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
-
-			b.StartTimer()
-			for i := 0; i < b.N; i++ {
-				randomItemIdx := rand.Int31n(int32(numItems))
-				h.GetWinningBid(items[randomItemIdx].ID)
-			}
-		})
-	}
+	storagetest.BenchmarkGetWinningBid(b, newMapBiddingSystem)
 }
 
 func Benchmark_GetBidsOnItem(b *testing.B) {
-	for k := 0.; k <= scale; k++ {
-		n := int(math.Pow(2, k))
-		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
-			b.StopTimer()
-
-			h.Reset()
-			numItems := n
-			amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
-			_, items, _ := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
-
-			b.StartTimer()
-			for i := 0; i < b.N; i++ {
-				randomItemIdx := rand.Int31n(int32(numItems))
-				h.GetBidsOnItem(items[randomItemIdx].ID)
-			}
-		})
-	}
+	storagetest.BenchmarkGetBidsOnItem(b, newMapBiddingSystem)
 }
 
 func Benchmark_GetItemsUserHasBid(b *testing.B) {
-	for k := 0.; k <= scale; k++ {
-		n := int(math.Pow(2, k))
-		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
-			b.StopTimer()
-
-			h.Reset()
-			numItems := n //also numUsers
-			amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
-			_, _, users := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
-
-			b.StartTimer()
-			for i := 0; i < b.N; i++ {
-				randomUserIdx := rand.Int31n(int32(numItems))
-				h.GetItemsUserHasBid(users[randomUserIdx].ID)
-			}
-		})
-	}
+	storagetest.BenchmarkGetItemsUserHasBid(b, newMapBiddingSystem)
+}
+
+func Benchmark_MixedWorkload_Parallel(b *testing.B) {
+	storagetest.BenchmarkMixedWorkloadParallel(b, newMapBiddingSystem)
+}
+
+func Test_MapBiddingSystem_History(t *testing.T) {
+	h := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(h, 1)
+	users := testutils.CreateTestUsers(h, 2)
+	first := models.NewBid(items[0].ID, users[0].ID, 10.0)
+	second := models.NewBid(items[0].ID, users[1].ID, 20.0)
+	h.PlaceBid(first)
+	h.PlaceBid(second)
+	h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 5.0))
+	h.CloseAuction(items[0].ID)
+
+	history := h.History()
+	types := make([]events.Type, 0, len(history))
+	for idx, r := range history {
+		assert.Equal(t, uint64(idx+1), r.Seq)
+		types = append(types, r.Event.Type())
+	}
+	assert.Equal(t, []events.Type{
+		events.TypeItemListed,
+		events.TypeUserRegistered, events.TypeUserRegistered,
+		events.TypeBidPlaced, events.TypeBidPlaced, events.TypeBidPlaced,
+		events.TypeAuctionClosed,
+	}, types)
+
+	assert.Nil(t, history[3].Event.(events.BidPlaced).Outbid, "First bid outbids nobody")
+	assert.Equal(t, first, history[4].Event.(events.BidPlaced).Outbid)
+	assert.Nil(t, history[5].Event.(events.BidPlaced).Outbid, "Lower bid outbids nobody")
+	assert.Equal(t, second, history[6].Event.(events.AuctionClosed).WinningBid)
+}
+
+func Test_MapBiddingSystem_Replay(t *testing.T) {
+	h := storage.NewMapBiddingSystem()
+	numItems := 5
+	amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+	_, items, users := testutils.CreateTestTwoUsersBidOnManyItems(h, numItems, amountsMatrix)
+	h.CloseAuction(items[0].ID)
+
+	replayed, err := storage.Replay(h.History())
+	require.NoError(t, err)
+
+	assert.Equal(t, h.History(), replayed.History())
+	for _, item := range items {
+		want, _ := h.GetWinningBid(item.ID)
+		got, err := replayed.GetWinningBid(item.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		wantBids, _ := h.GetBidsOnItem(item.ID)
+		gotBids, _ := replayed.GetBidsOnItem(item.ID)
+		assert.Equal(t, wantBids, gotBids)
+	}
+	for _, user := range users {
+		want, _ := h.GetItemsUserHasBid(user.ID)
+		got, _ := replayed.GetItemsUserHasBid(user.ID)
+		assert.Equal(t, len(want), len(got))
+	}
+	assert.Error(t, replayed.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 1000.0)), "Closed auction must stay closed")
+}
+
+func Test_MapBiddingSystem_Replay_Invalid(t *testing.T) {
+	h := storage.NewMapBiddingSystem()
+	_, items, _ := testutils.CreateTestBids(h, 2, []float64{10, 20})
+	h.CloseAuction(items[0].ID)
+	history := h.History()
+
+	// the bids and the close refer to the items and users listed and registered before
+	for _, skip := range []events.Type{events.TypeItemListed, events.TypeUserRegistered} {
+		var truncated []events.Record
+		for _, r := range history {
+			if r.Event.Type() != skip {
+				truncated = append(truncated, r)
+			}
+		}
+		replayed, err := storage.Replay(truncated)
+		assert.Error(t, err, "Records without %s must be rejected", skip)
+		assert.Nil(t, replayed)
+	}
+
+	last := history[len(history)-1]
+	for _, event := range []events.Event{
+		events.BidPlaced{},
+		events.BidPlaced{Bid: models.NewBid(uuid.NewV4(), uuid.NewV4(), 1)},
+		events.AuctionClosed{ItemID: items[1].ID, ReserveNotMet: true},
+		events.ItemWatched{ItemID: items[1].ID, UserID: uuid.NewV4()},
+		events.CreditChanged{UserID: uuid.NewV4()},
+	} {
+		_, err := storage.Replay(append(history, events.Record{Seq: last.Seq + 1, At: last.At, Event: event}))
+		assert.Error(t, err, "%#v must be rejected", event)
+	}
+	_, err := storage.Replay(append(history, events.Record{Seq: last.Seq + 1, At: last.At,
+		Event: events.BidPlaced{Bid: models.NewBid(uuid.NewV4(), uuid.NewV4(), 1)}}))
+	assert.True(t, errors.Is(err, storage.ErrItemNotFound))
+}
+
+func Test_MapBiddingSystem_Register(t *testing.T) {
+	h := storage.NewMapBiddingSystem()
+	items := testutils.CreateTestItems(h, 2)
+	users := testutils.CreateTestUsers(h, 1)
+	h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10.0))
+
+	//a new read model: number of bids per item, built from past and future events
+	bidsPerItem := make(map[uuid.UUID]int)
+	h.Register(events.ProjectionFunc(func(r events.Record) {
+		if e, ok := r.Event.(events.BidPlaced); ok {
+			bidsPerItem[e.Bid.ItemID]++
+		}
+	}))
+	h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 20.0))
+	h.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 20.0))
+
+	assert.Equal(t, 2, bidsPerItem[items[0].ID])
+	assert.Equal(t, 1, bidsPerItem[items[1].ID])
+}
+
+func Test_MapBiddingSystem_Rules(t *testing.T) {
+	h := storage.NewMapBiddingSystem().WithRules(models.Rules{MinOpeningBid: 5, MinIncrement: 1})
+	items := testutils.CreateTestItems(h, 1)
+	users := testutils.CreateTestUsers(h, 2)
+	assert.Equal(t, models.ErrBelowOpeningBid, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 4.0)))
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 5.0)))
+	assert.Equal(t, models.ErrBelowIncrement, h.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 5.5)))
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 6.0)))
+
+	bids, _ := h.GetBidsOnItem(items[0].ID)
+	assert.Len(t, bids, 2, "Rejected bids are not recorded")
+}
+
+func Test_Namespaces(t *testing.T) {
+	created := 0
+	namespaces := storage.NewNamespaces(func(name string) storage.Storage {
+		created++
+		return storage.NewMapBiddingSystem()
+	})
+	get := func(namespaces *storage.Namespaces, name string) storage.Storage {
+		db, err := namespaces.Get(name)
+		require.NoError(t, err)
+		return db
+	}
+	a, b := get(namespaces, "a"), get(namespaces, "b")
+	assert.True(t, a == get(namespaces, "a"), "Namespaces are created once")
+	assert.Equal(t, 2, created)
+	assert.Equal(t, []string{"a", "b"}, namespaces.Names())
+
+	itemsA := testutils.CreateTestItems(a, 2)
+	usersA := testutils.CreateTestUsers(a, 1)
+	assert.NoError(t, a.PlaceBid(models.NewBid(itemsA[0].ID, usersA[0].ID, 10.0)))
+	itemsB := testutils.CreateTestItems(b, 1)
+	usersB := testutils.CreateTestUsers(b, 1)
+
+	// no query of one namespace sees the data of the other
+	items, _ := b.AllItems()
+	assert.Equal(t, []*models.Item{itemsB[0]}, items)
+	users, _ := b.AllUsers()
+	assert.Equal(t, []*models.User{usersB[0]}, users)
+	bids, _ := b.AllBids()
+	assert.Empty(t, bids)
+	_, err := b.GetItem(itemsA[0].ID)
+	assert.Error(t, err)
+	_, err = b.GetUser(usersA[0].ID)
+	assert.Error(t, err)
+	_, err = b.GetBidsOnItem(itemsA[0].ID)
+	assert.Error(t, err)
+	_, err = b.GetWinningBid(itemsA[0].ID)
+	assert.Error(t, err)
+	_, err = b.CloseAuction(itemsA[0].ID)
+	assert.Error(t, err)
+	assert.Error(t, b.PlaceBid(models.NewBid(itemsA[0].ID, usersB[0].ID, 20.0)), "Items of other namespaces cannot be bid on")
+	assert.Error(t, b.PlaceBid(models.NewBid(itemsB[0].ID, usersA[0].ID, 20.0)), "Users of other namespaces cannot bid")
+	assert.Error(t, b.WatchItem(usersB[0].ID, itemsA[0].ID))
+
+	winning, err := a.GetWinningBid(itemsA[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, winning.Amount, "Bids in other namespaces do not change the namespace")
+
+	existing := storage.NewMapBiddingSystem()
+	namespaces.Add("c", existing)
+	assert.True(t, existing == get(namespaces, "c"))
+	assert.Equal(t, 2, created)
+
+	// without a function creating them, only added namespaces exist
+	namespaces = storage.NewNamespaces(nil)
+	namespaces.Add("c", existing)
+	assert.True(t, existing == get(namespaces, "c"))
+	_, err = namespaces.Get("d")
+	assert.Equal(t, storage.ErrUnknownNamespace, err)
+	assert.Equal(t, []string{"c"}, namespaces.Names())
 }
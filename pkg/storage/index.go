@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+//values is the map type held by an index. Maps published in index.read are never modified.
+type values map[uuid.UUID]interface{}
+
+//index is a concurrent map from UUID to a value, optimized for values that are written once and read often.
+//It keeps the values in a copy-on-write map: lookups are a load of the immutable map and take no locks,
+//a Store copies the map and publishes the copy. Creating a value therefore costs O(n) - items and users
+//are created far less often than they are read.
+type index struct {
+	//read points to the current values - it is loaded with atomic.LoadPointer, which is cheaper than atomic.Value
+	read unsafe.Pointer // *values
+
+	//creating serializes the creation of values - see Locker
+	creating sync.Mutex
+
+	//mu serializes the writers of read
+	mu sync.Mutex
+}
+
+func newIndex() *index {
+	x := &index{}
+	x.storeRead(make(values))
+	return x
+}
+
+func (x *index) loadRead() values {
+	return *(*values)(atomic.LoadPointer(&x.read))
+}
+
+func (x *index) storeRead(read values) {
+	atomic.StorePointer(&x.read, unsafe.Pointer(&read))
+}
+
+//Locker returns a lock for creating the value under id, so that checking that there is none and storing it are atomic.
+//It does not block readers.
+func (x *index) Locker(id uuid.UUID) sync.Locker {
+	return &x.creating
+}
+
+//Load returns the value stored under id
+func (x *index) Load(id uuid.UUID) (interface{}, bool) {
+	v, ok := x.loadRead()[id]
+	return v, ok
+}
+
+//Store sets the value under id
+func (x *index) Store(id uuid.UUID, value interface{}) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	read := x.loadRead()
+	copied := make(values, len(read)+1)
+	for k, v := range read {
+		copied[k] = v
+	}
+	copied[id] = value
+	x.storeRead(copied)
+}
+
+//Range calls fn for every value until fn returns false. It visits the values stored when it is called.
+func (x *index) Range(fn func(value interface{}) bool) {
+	for _, v := range x.loadRead() {
+		if !fn(v) {
+			return
+		}
+	}
+}
+
+//Len returns the number of stored values
+func (x *index) Len() int {
+	return len(x.loadRead())
+}
+
+//Reset removes all values
+func (x *index) Reset() {
+	x.mu.Lock()
+	x.storeRead(make(values))
+	x.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//ErrUnknownNamespace is returned for namespaces that have not been added if there is no function creating them
+var ErrUnknownNamespace = models.NewError(models.KindNotFound, "namespace_not_found", "Namespace not found")
+
+//Namespaces isolates the data of tenants: every namespace is a Storage of its own, with its own journal,
+//items, users and bids. A query on one namespace cannot see the data of another, as they share no state.
+type Namespaces struct {
+	create func(name string) Storage
+
+	mutex      sync.RWMutex
+	namespaces map[string]Storage
+}
+
+//NewNamespaces creates namespaces whose storages are created by create on first use.
+//With a nil create, only the namespaces added with Add exist.
+func NewNamespaces(create func(name string) Storage) *Namespaces {
+	return &Namespaces{create: create, namespaces: map[string]Storage{}}
+}
+
+//Add uses db as the storage of namespace name - e.g., to serve existing data as a namespace
+func (n *Namespaces) Add(name string, db Storage) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.namespaces[name] = db
+}
+
+//Get returns the storage of namespace name, creating it on first use - ErrUnknownNamespace if it cannot be created
+func (n *Namespaces) Get(name string) (Storage, error) {
+	n.mutex.RLock()
+	db, ok := n.namespaces[name]
+	n.mutex.RUnlock()
+	if ok {
+		return db, nil
+	}
+	if n.create == nil {
+		return nil, ErrUnknownNamespace
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if db, ok = n.namespaces[name]; !ok {
+		db = n.create(name)
+		n.namespaces[name] = db
+	}
+	return db, nil
+}
+
+//Names returns the sorted names of the namespaces in use
+func (n *Namespaces) Names() []string {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	names := make([]string, 0, len(n.namespaces))
+	for name := range n.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
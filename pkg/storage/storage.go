@@ -1,10 +1,23 @@
 package storage
 
 import (
+	"time"
+
 	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
 	"github.com/vikin91/bid-tracker-go/pkg/models"
 )
 
+// define errors of storages
+var (
+	ErrItemNotFound = models.NewError(models.KindNotFound, "item_not_found", "Item not found")
+	ErrUserNotFound = models.NewError(models.KindNotFound, "user_not_found", "User not found")
+	ErrItemExists   = models.NewError(models.KindConflict, "item_exists", "An item with this ID exists already")
+	ErrUserExists   = models.NewError(models.KindConflict, "user_exists", "A user with this ID exists already")
+	ErrExposureOpen = models.NewError(models.KindConflict, "auction_open", "Exposure on open auctions is released only by outbidding")
+	ErrIncomplete   = models.NewError(models.KindInvalid, "incomplete_event", "Event lacks the bid it refers to")
+)
+
 //Storage is an interface for underlying data structure storing a state - useful when implementing multiple storage backends
 type Storage interface {
 	//CR methods for item
@@ -27,5 +40,35 @@ type Storage interface {
 	GetItemsUserHasBid(userID uuid.UUID) ([]*models.Item, error)
 	GetWinningBid(itemID uuid.UUID) (*models.Bid, error)
 
+	//Historical versions of the functions required in the assignment - the state as it was at asOf
+	GetBidsOnItemAsOf(itemID uuid.UUID, asOf time.Time) ([]*models.Bid, error)
+	GetItemsUserHasBidAsOf(userID uuid.UUID, asOf time.Time) ([]*models.Item, error)
+	GetWinningBidAsOf(itemID uuid.UUID, asOf time.Time) (*models.Bid, error)
+
+	//CloseAuction ends bidding on an item
+	CloseAuction(itemID uuid.UUID) (*models.Bid, error)
+	//RelistItem puts a copy of a closed item on auction again
+	RelistItem(itemID uuid.UUID, closesAt *time.Time) (*models.Item, error)
+
+	//Watchlists and saved searches of users
+	WatchItem(userID, itemID uuid.UUID) error
+	UnwatchItem(userID, itemID uuid.UUID) error
+	GetWatchlist(userID uuid.UUID) ([]*models.Item, error)
+	GetWatchers(itemID uuid.UUID) ([]*models.User, error)
+	SaveSearch(*models.SavedSearch) error
+	DeleteSearch(userID, searchID uuid.UUID) error
+	GetSavedSearches(userID uuid.UUID) ([]*models.SavedSearch, error)
+	AllSavedSearches() ([]*models.SavedSearch, error)
+
+	//Credit of users - the winning bids of a user must not exceed the credit limit and deposit
+	SetCredit(userID uuid.UUID, credit models.Credit) error
+	GetCredit(userID uuid.UUID) (models.Credit, error)
+	ReleaseExposure(userID, itemID uuid.UUID) error
+	AddExposure(userID, itemID uuid.UUID, amount float64) error
+
+	//Subscribe feeds events committed from now on to p - e.g., to push them to clients.
+	//p is called synchronously, in the order of commits, unless the events.Async option is given.
+	Subscribe(p events.Projection, opts ...events.SubscribeOption) (unsubscribe func())
+
 	Reset()
 }
@@ -0,0 +1,142 @@
+package storagetest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//Scale is related to the maximum number of elements used in benchmarks - 2^Scale
+const Scale = 8
+
+//BenchmarkPlaceBidOneUserOneItem measures placing bids by one user on one item
+func BenchmarkPlaceBidOneUserOneItem(b *testing.B, newStorage Factory) {
+	h := newStorage()
+	user := models.NewUser("James Bond")
+	item := models.NewItem("A thing")
+	h.CreateUser(user)
+	h.CreateItem(item)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		bid := models.NewBid(item.ID, user.ID, 3.1415)
+		h.PlaceBid(bid)
+	}
+}
+
+//BenchmarkPlaceBidManyUsersManyItems measures placing bids for growing number of users and items
+func BenchmarkPlaceBidManyUsersManyItems(b *testing.B, newStorage Factory) {
+	for k := 0.; k <= Scale; k++ {
+		n := int(math.Pow(2, k))
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.StopTimer()
+			h := newStorage()
+			items := testutils.CreateTestItems(h, n)
+			users := testutils.CreateTestUsers(h, n)
+
+			randomItemIdx := rand.Int31n(int32(n))
+			randomUserIdx := rand.Int31n(int32(n))
+			b.StartTimer()
+			for i := 0; i < b.N; i++ {
+				bid := models.NewBid(items[randomItemIdx].ID, users[randomUserIdx].ID, 3.1415)
+				h.PlaceBid(bid)
+			}
+		})
+	}
+}
+
+//BenchmarkGetWinningBid measures GetWinningBid for growing number of items
+func BenchmarkGetWinningBid(b *testing.B, newStorage Factory) {
+	for k := 0.; k <= Scale; k++ {
+		n := int(math.Pow(2, k))
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.StopTimer()
+			h := newStorage()
+			numItems := n
+			amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+			_, items, _ := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
+
+			b.StartTimer()
+			for i := 0; i < b.N; i++ {
+				randomItemIdx := rand.Int31n(int32(numItems))
+				h.GetWinningBid(items[randomItemIdx].ID)
+			}
+		})
+	}
+}
+
+//BenchmarkGetBidsOnItem measures GetBidsOnItem for growing number of items
+func BenchmarkGetBidsOnItem(b *testing.B, newStorage Factory) {
+	for k := 0.; k <= Scale; k++ {
+		n := int(math.Pow(2, k))
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.StopTimer()
+			h := newStorage()
+			numItems := n
+			amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+			_, items, _ := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
+
+			b.StartTimer()
+			for i := 0; i < b.N; i++ {
+				randomItemIdx := rand.Int31n(int32(numItems))
+				h.GetBidsOnItem(items[randomItemIdx].ID)
+			}
+		})
+	}
+}
+
+//BenchmarkGetItemsUserHasBid measures GetItemsUserHasBid for growing number of users
+func BenchmarkGetItemsUserHasBid(b *testing.B, newStorage Factory) {
+	for k := 0.; k <= Scale; k++ {
+		n := int(math.Pow(2, k))
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.StopTimer()
+			h := newStorage()
+			numItems := n //also numUsers
+			amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+			_, _, users := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
+
+			b.StartTimer()
+			for i := 0; i < b.N; i++ {
+				randomUserIdx := rand.Int31n(int32(numItems))
+				h.GetItemsUserHasBid(users[randomUserIdx].ID)
+			}
+		})
+	}
+}
+
+//BenchmarkMixedWorkloadParallel measures reads interleaved with creation of users and items and bidding on them
+func BenchmarkMixedWorkloadParallel(b *testing.B, newStorage Factory) {
+	h := newStorage()
+	numItems := int(math.Pow(2, Scale))
+	amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3)
+	_, items, users := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			switch i % 8 {
+			case 0:
+				user := models.NewUser("Bidder")
+				item := models.NewItem("Lot")
+				h.CreateUser(user)
+				h.CreateItem(item)
+				h.PlaceBid(models.NewBid(item.ID, user.ID, 1.0))
+			case 1:
+				h.PlaceBid(models.NewBid(items[i%numItems].ID, users[i%numItems].ID, 1.0))
+			case 2, 3:
+				h.GetWinningBid(items[i%numItems].ID)
+			case 4, 5:
+				h.GetBidsOnItem(items[i%numItems].ID)
+			default:
+				h.GetItemsUserHasBid(users[i%numItems].ID)
+			}
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package storagetest
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+const (
+	concurrentBidders     = 8
+	concurrentBidsPerUser = 200
+	concurrentReaders     = 4
+)
+
+// testConcurrentPlaceBid checks that PlaceBid is linearizable: concurrent readers never observe
+// the winning amount going down, an observed winner is always among the bids on the item,
+// and the final state equals the result of applying all bids one after another.
+func testConcurrentPlaceBid(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 1)
+	users := testutils.CreateTestUsers(h, concurrentBidders)
+	itemID := items[0].ID
+
+	//every amount is unique, so the winner is well defined regardless of the interleaving
+	total := concurrentBidders * concurrentBidsPerUser
+	amounts := rand.Perm(total)
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < concurrentReaders; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			lastAmount := -1.0
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				winner, err := h.GetWinningBid(itemID)
+				if err != nil {
+					continue
+				}
+				if winner.Amount < lastAmount {
+					t.Errorf("Winning amount decreased from %f to %f", lastAmount, winner.Amount)
+					return
+				}
+				lastAmount = winner.Amount
+				bids, err := h.GetBidsOnItem(itemID)
+				if err != nil {
+					t.Errorf("Unexpected error on GetBidsOnItem: %v", err)
+					return
+				}
+				if !containsBid(bids, winner) {
+					t.Errorf("Winning bid %s is not among the bids on the item", winner.ID)
+					return
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for u := 0; u < concurrentBidders; u++ {
+		writers.Add(1)
+		go func(u int) {
+			defer writers.Done()
+			for i := 0; i < concurrentBidsPerUser; i++ {
+				amount := float64(amounts[u*concurrentBidsPerUser+i])
+				if err := h.PlaceBid(models.NewBid(itemID, users[u].ID, amount)); err != nil {
+					t.Errorf("Unexpected error on PlaceBid: %v", err)
+					return
+				}
+			}
+		}(u)
+	}
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	bids, err := h.GetBidsOnItem(itemID)
+	assert.NoError(t, err)
+	assert.Equal(t, total, len(bids), "Every bid must be recorded exactly once")
+
+	winner, err := h.GetWinningBid(itemID)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(total-1), winner.Amount, "Highest bid must win")
+
+	for _, user := range users {
+		userBids, err := h.GetUserBids(user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, concurrentBidsPerUser, len(userBids))
+
+		userItems, err := h.GetItemsUserHasBid(user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(userItems), "Item must be recorded once per user")
+	}
+}
+
+// testConcurrentPlaceBidManyItems checks that bids placed concurrently on different items do not interfere
+func testConcurrentPlaceBidManyItems(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	numItems := 16
+	items := testutils.CreateTestItems(h, numItems)
+	users := testutils.CreateTestUsers(h, concurrentBidders)
+
+	var writers sync.WaitGroup
+	for u := 0; u < concurrentBidders; u++ {
+		writers.Add(1)
+		go func(u int) {
+			defer writers.Done()
+			for i := 0; i < concurrentBidsPerUser; i++ {
+				item := items[(u+i)%numItems]
+				amount := float64(i*concurrentBidders + u)
+				if err := h.PlaceBid(models.NewBid(item.ID, users[u].ID, amount)); err != nil {
+					t.Errorf("Unexpected error on PlaceBid: %v", err)
+					return
+				}
+			}
+		}(u)
+	}
+	writers.Wait()
+
+	allBids, err := h.AllBids()
+	assert.NoError(t, err)
+	assert.Equal(t, concurrentBidders*concurrentBidsPerUser, len(allBids))
+
+	for _, item := range items {
+		bids, err := h.GetBidsOnItem(item.ID)
+		assert.NoError(t, err)
+		winner, err := h.GetWinningBid(item.ID)
+		assert.NoError(t, err)
+		for _, bid := range bids {
+			assert.True(t, bid.Amount <= winner.Amount, "No bid may be higher than the winning bid")
+		}
+	}
+	for _, user := range users {
+		userItems, err := h.GetItemsUserHasBid(user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, numItems, len(userItems))
+	}
+}
+
+// testConcurrentMixedWorkload creates users and items while others bid on them and list everything.
+// It is meant to be run with the race detector.
+func testConcurrentMixedWorkload(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	const workers = 8
+	const rounds = 100
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				user := models.NewUser("Bidder")
+				item := models.NewItem("Lot")
+				if err := h.CreateUser(user); err != nil {
+					t.Errorf("Unexpected error on CreateUser: %v", err)
+					return
+				}
+				if err := h.CreateItem(item); err != nil {
+					t.Errorf("Unexpected error on CreateItem: %v", err)
+					return
+				}
+				if err := h.PlaceBid(models.NewBid(item.ID, user.ID, float64(i))); err != nil {
+					t.Errorf("Unexpected error on PlaceBid: %v", err)
+					return
+				}
+				if _, err := h.GetItemsUserHasBid(user.ID); err != nil {
+					t.Errorf("Unexpected error on GetItemsUserHasBid: %v", err)
+					return
+				}
+			}
+		}(w)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				items, _ := h.AllItems()
+				for _, item := range items {
+					if _, err := h.GetItem(item.ID); err != nil {
+						t.Errorf("Listed item cannot be found: %v", err)
+						return
+					}
+				}
+				h.AllUsers()
+				h.AllBids()
+			}
+		}()
+	}
+	wg.Wait()
+
+	items, err := h.AllItems()
+	assert.NoError(t, err)
+	assert.Equal(t, workers*rounds, len(items))
+	users, err := h.AllUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, workers*rounds, len(users))
+	bids, err := h.AllBids()
+	assert.NoError(t, err)
+	assert.Equal(t, workers*rounds, len(bids))
+}
+
+func containsBid(bids []*models.Bid, bid *models.Bid) bool {
+	for _, b := range bids {
+		if b.ID == bid.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// testConcurrentCreditLimit checks that bids placed concurrently by a user on many items never take the exposure
+// of the user above the credit limit, while others outbid the user
+func testConcurrentCreditLimit(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	numItems := 16
+	limit := 100.0
+	items := testutils.CreateTestItems(h, numItems)
+	users := testutils.CreateTestUsers(h, concurrentBidders)
+	assert.NoError(t, h.SetCredit(users[0].ID, models.Credit{Limited: true, CreditLimit: limit}))
+
+	var writers sync.WaitGroup
+	for u := 0; u < concurrentBidders; u++ {
+		writers.Add(1)
+		go func(u int) {
+			defer writers.Done()
+			for i := 0; i < concurrentBidsPerUser; i++ {
+				item := items[(u+i)%numItems]
+				amount := float64(i + u + 1)
+				err := h.PlaceBid(models.NewBid(item.ID, users[u].ID, amount))
+				if err != nil && err != models.ErrCreditExceeded {
+					t.Errorf("Unexpected error on PlaceBid: %v", err)
+					return
+				}
+				if u == 0 {
+					credit, _ := h.GetCredit(users[0].ID)
+					if credit.Exposure > limit {
+						t.Errorf("Exposure %v exceeds the limit %v", credit.Exposure, limit)
+						return
+					}
+				}
+			}
+		}(u)
+	}
+	writers.Wait()
+
+	exposure := 0.0
+	for _, item := range items {
+		if winner, err := h.GetWinningBid(item.ID); err == nil && winner.UserID == users[0].ID {
+			exposure += winner.Amount
+		}
+	}
+	credit, err := h.GetCredit(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, exposure, credit.Exposure, "Exposure should be the sum of the winning bids")
+	assert.True(t, credit.Exposure <= limit)
+}
@@ -0,0 +1,848 @@
+// Package storagetest provides a conformance suite for implementations of storage.Storage.
+//
+// Every storage backend should be run against RunTests, e.g.:
+//
+//	func Test_MyStorage(t *testing.T) {
+//		storagetest.RunTests(t, func() storage.Storage { return NewMyStorage() })
+//	}
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/config"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+)
+
+//Factory creates a new, empty storage backend
+type Factory func() storage.Storage
+
+//RunTests runs the whole conformance suite against storages created by newStorage
+func RunTests(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, newStorage Factory)
+	}{
+		{"AllItems", testAllItems},
+		{"AllUsers", testAllUsers},
+		{"AllBids", testAllBids},
+		{"CreateItem", testCreateItem},
+		{"CreateUser", testCreateUser},
+		{"PlaceBid", testPlaceBid},
+		{"GetUser", testGetUser},
+		{"GetItem", testGetItem},
+		{"GetUserBids", testGetUserBids},
+		{"GetBidsOnItem", testGetBidsOnItem},
+		{"GetWinningBid", testGetWinningBid},
+		{"GetItemsUserHasBid", testGetItemsUserHasBid},
+		{"GetItemsUserHasBid_TwoUsers", testGetItemsUserHasBidTwoUsers},
+		{"AsOf", testAsOf},
+		{"CloseAuction", testCloseAuction},
+		{"ReservePrice", testReservePrice},
+		{"RelistItem", testRelistItem},
+		{"Watchlist", testWatchlist},
+		{"SavedSearches", testSavedSearches},
+		{"CreditLimit", testCreditLimit},
+		{"Reset", testReset},
+		{"Snapshots", testSnapshots},
+		{"UnknownIDs", testUnknownIDs},
+		{"ConcurrentPlaceBid", testConcurrentPlaceBid},
+		{"ConcurrentPlaceBid_ManyItems", testConcurrentPlaceBidManyItems},
+		{"ConcurrentMixedWorkload", testConcurrentMixedWorkload},
+		{"ConcurrentCreditLimit", testConcurrentCreditLimit},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage)
+		})
+	}
+}
+
+func testAllItems(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name           string
+		numItemsCreate int
+		wantNumItems   int
+		wantErr        bool
+	}{
+		{"Should get empty set of items", 0, 0, false},
+		{"Should get 2 items", 2, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newStorage()
+			testutils.CreateTestItems(h, tt.numItemsCreate)
+
+			got, err := h.AllItems()
+			assert.Equal(t, tt.wantNumItems, len(got), "Got Wrong number of items")
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".AllItems() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func testAllUsers(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name           string
+		numUsersCreate int
+		wantNumUsers   int
+		wantErr        bool
+	}{
+		{"Should get empty set of users", 0, 0, false},
+		{"Should get 2 users", 2, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newStorage()
+			testutils.CreateTestUsers(h, tt.numUsersCreate)
+
+			got, err := h.AllUsers()
+			assert.Equal(t, tt.wantNumUsers, len(got), "Got Wrong number of users")
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".AllUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func testAllBids(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name          string
+		numBidsCreate int
+		wantNumBids   int
+		wantErr       bool
+	}{
+		{"Should get empty set of bids", 0, 0, false},
+		{"Should get 2 bids", 2, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newStorage()
+			testutils.CreateTestBids(h, tt.numBidsCreate, testutils.GenerateSliceOfRandomFloat64(tt.numBidsCreate))
+
+			got, err := h.AllBids()
+			assert.Equal(t, tt.wantNumBids, len(got), "Got Wrong number of bids")
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".AllBids() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func testCreateItem(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name     string
+		itemName string
+		wantErr  bool
+	}{
+		{"Should create an item", "A thing", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newStorage()
+			item := &models.Item{Name: tt.itemName}
+			if err := h.CreateItem(item); (err != nil) != tt.wantErr {
+				t.Errorf(".CreateItem() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			//CreateItem should set required fields
+			assert.NotEqualf(t, config.ZeroUUID, item.ID, "UUID must mot be empty. Is: %s", item.ID.String())
+			assert.NotEqualf(t, time.Time{}, item.CreatedAt, "CreatedAt must mot be empty. Is: %s", item.CreatedAt.String())
+
+			got, err := h.GetItem(item.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, item.ID, got.ID)
+
+			err = h.CreateItem(&models.Item{BaseModel: models.BaseModel{ID: item.ID}, Name: "Another thing"})
+			assert.True(t, errors.Is(err, storage.ErrItemExists), "An item must not be replaced")
+			got, _ = h.GetItem(item.ID)
+			assert.Equal(t, tt.itemName, got.Name)
+		})
+	}
+}
+
+func testCreateUser(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name     string
+		userName string
+		wantErr  bool
+	}{
+		{"Should create a user", "James Bond", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newStorage()
+			user := &models.User{Name: tt.userName}
+			if err := h.CreateUser(user); (err != nil) != tt.wantErr {
+				t.Errorf(".CreateUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			//CreateUser should set required fields
+			assert.NotEqualf(t, config.ZeroUUID, user.ID, "UUID must mot be empty. Is: %s", user.ID.String())
+			assert.NotEqualf(t, time.Time{}, user.CreatedAt, "CreatedAt must mot be empty. Is: %s", user.CreatedAt.String())
+
+			got, err := h.GetUser(user.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, user.ID, got.ID)
+
+			err = h.CreateUser(&models.User{BaseModel: models.BaseModel{ID: user.ID}, Name: "Another one"})
+			assert.True(t, errors.Is(err, storage.ErrUserExists), "A user must not be replaced")
+			got, _ = h.GetUser(user.ID)
+			assert.Equal(t, tt.userName, got.Name)
+		})
+	}
+}
+
+func testPlaceBid(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	users := testutils.CreateTestUsers(h, 10)
+	items := testutils.CreateTestItems(h, 10)
+
+	tests := []struct {
+		name    string
+		userIdx int
+		itemIdx int
+		amount  float64
+		wantErr bool
+	}{
+		{"Bid should be added", 0, 0, 9.99, false},
+		{"Second bid on the same item should be added", 1, 0, 10.99, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := users[tt.userIdx]
+			item := items[tt.itemIdx]
+			bid := &models.Bid{ItemID: item.ID, UserID: user.ID, Amount: tt.amount}
+
+			if err := h.PlaceBid(bid); (err != nil) != tt.wantErr {
+				t.Errorf(".PlaceBid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			//PlaceBid should set required fields
+			assert.NotEqualf(t, config.ZeroUUID, bid.ID, "Bid ID must mot be empty. Is: %s", bid.ID.String())
+			assert.NotEqualf(t, config.ZeroUUID, bid.UserID, "Bid User ID must mot be empty. Is: %s", bid.UserID.String())
+			assert.NotEqualf(t, config.ZeroUUID, bid.ItemID, "Bid Item ID must mot be empty. Is: %s", bid.ItemID.String())
+			assert.Falsef(t, bid.CreatedAt.IsZero(), "CreatedAt must mot be empty. Is: %s", bid.CreatedAt.String())
+
+			bids, err := h.GetBidsOnItem(item.ID)
+			assert.NoError(t, err)
+			assert.Contains(t, bids, bid)
+		})
+	}
+}
+
+func testGetUser(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	users := testutils.CreateTestUsers(h, 10)
+
+	tests := []struct {
+		name     string
+		ID       uuid.UUID
+		expected *models.User
+		wantErr  bool
+	}{
+		{"User 0 should exist", users[0].ID, users[0], false},
+		{"User 1 should exist", users[1].ID, users[1], false},
+		{"User 9 should exist", users[9].ID, users[9], false},
+		{"User should not exist", uuid.NewV4(), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := h.GetUser(tt.ID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".GetUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (user.ID != tt.expected.ID || user.Name != tt.expected.Name) {
+				t.Errorf(".GetUser() = \n%+v\n, want \n%+v", user, tt.expected)
+			}
+		})
+	}
+}
+
+func testGetItem(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 10)
+
+	tests := []struct {
+		name     string
+		ID       uuid.UUID
+		expected *models.Item
+		wantErr  bool
+	}{
+		{"Item 0 should exist", items[0].ID, items[0], false},
+		{"Item 1 should exist", items[1].ID, items[1], false},
+		{"Item 9 should exist", items[9].ID, items[9], false},
+		{"Item should not exist", uuid.NewV4(), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := h.GetItem(tt.ID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".GetItem() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (item.ID != tt.expected.ID || item.Name != tt.expected.Name) {
+				t.Errorf(".GetItem() = \n%+v\n, want \n%+v", item, tt.expected)
+			}
+		})
+	}
+}
+
+func testGetUserBids(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	num := 10
+	amounts := testutils.GenerateSliceOfRandomFloat64(num)
+	bids, _, users := testutils.CreateTestBids(h, num, amounts)
+
+	tests := []struct {
+		name    string
+		userID  uuid.UUID
+		wantBid []*models.Bid
+		wantErr bool
+	}{
+		{"Should find the bid", users[0].ID, []*models.Bid{bids[0]}, false},
+		{"Should not find the bid", uuid.NewV4(), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.GetUserBids(tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".GetUserBids() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.wantBid) {
+				t.Errorf(".GetUserBids() got = \n%+v\n, want \n%+v\n", got, tt.wantBid)
+			}
+		})
+	}
+}
+
+func testGetBidsOnItem(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	num := 10
+	amounts := testutils.GenerateSliceOfRandomFloat64(num)
+	bids, items, _ := testutils.CreateTestBids(h, num, amounts)
+
+	tests := []struct {
+		name    string
+		itemID  uuid.UUID
+		wantBid []*models.Bid
+		wantErr bool
+	}{
+		{"Should find the bid", items[0].ID, []*models.Bid{bids[0]}, false},
+		{"Should not find the bid", uuid.NewV4(), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.GetBidsOnItem(tt.itemID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".GetBidsOnItem() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.wantBid) {
+				t.Errorf(".GetBidsOnItem() got = \n%+v\n, want \n%+v\n", got, tt.wantBid)
+			}
+		})
+	}
+}
+
+func testGetWinningBid(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	numItems := 10
+	amountsMatrix, maxAmounts := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+	_, items, _ := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix)
+
+	tests := []struct {
+		name          string
+		itemID        uuid.UUID
+		wantMaxAmount float64
+		wantErr       bool
+	}{
+		{"Should find winning bid for 0", items[0].ID, maxAmounts[0], false},
+		{"Should find winning bid for 4", items[4].ID, maxAmounts[4], false},
+		{"Should not find the bid", uuid.NewV4(), 0.0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBid, err := h.GetWinningBid(tt.itemID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf(".GetWinningBid() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantMaxAmount, gotBid.Amount)
+			}
+		})
+	}
+
+	t.Run("Earlier bid should win a tie", func(t *testing.T) {
+		h := newStorage()
+		items := testutils.CreateTestItems(h, 1)
+		users := testutils.CreateTestUsers(h, 2)
+
+		first := models.NewBid(items[0].ID, users[0].ID, 15.0)
+		second := models.NewBid(items[0].ID, users[1].ID, 15.0)
+		assert.NoError(t, h.PlaceBid(first))
+		assert.NoError(t, h.PlaceBid(second))
+
+		gotBid, err := h.GetWinningBid(items[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, first.ID, gotBid.ID)
+	})
+}
+
+func testGetItemsUserHasBid(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	numItems := 10
+	amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+	_, items, users := testutils.CreateTestBidsManyOnItem(h, numItems, amountsMatrix) // each users bids multiple times but on exactly one item
+
+	tests := []struct {
+		name         string
+		userID       uuid.UUID
+		wantNumItems int
+		wantItems    []*models.Item
+		wantErr      bool
+	}{
+		{"User 0 should bid on 0-th item", users[0].ID, 1, []*models.Item{items[0]}, false},
+		{"User 1 should bid on 1-st item", users[1].ID, 1, []*models.Item{items[1]}, false},
+		{"Unknown user should not be found", uuid.NewV4(), 0, []*models.Item{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotItems, err := h.GetItemsUserHasBid(tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetItemsUserHasBid() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.wantNumItems, len(gotItems))
+			if !tt.wantErr && !reflect.DeepEqual(itemIDs(gotItems), itemIDs(tt.wantItems)) {
+				t.Errorf("GetItemsUserHasBid() gotItems = \n%+v\n, wantItems \n%+v\n", gotItems, tt.wantItems)
+			}
+		})
+	}
+}
+
+func testGetItemsUserHasBidTwoUsers(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	numItems := 10
+	amountsMatrix, _ := testutils.GenerateAmountsMatrix(numItems, 3*numItems)
+	_, items, users := testutils.CreateTestTwoUsersBidOnManyItems(h, numItems, amountsMatrix)
+
+	evenItems := []*models.Item{items[0], items[2], items[4], items[6], items[8]}
+	oddItems := []*models.Item{items[1], items[3], items[5], items[7], items[9]}
+
+	tests := []struct {
+		name         string
+		userID       uuid.UUID
+		wantNumItems int
+		wantItems    []*models.Item
+		wantErr      bool
+	}{
+		{"User 0 should bid on even items", users[0].ID, 5, evenItems, false},
+		{"User 1 should bid on odd items", users[1].ID, 5, oddItems, false},
+		{"Unknown user should not be found", uuid.NewV4(), 0, []*models.Item{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotItems, err := h.GetItemsUserHasBid(tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetItemsUserHasBid() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.wantNumItems, len(gotItems))
+
+			//sorting the results by Name, so that compare is possible
+			sorted := append([]*models.Item{}, gotItems...)
+			sort.Slice(sorted, func(i, j int) bool {
+				return sorted[i].Name > sorted[j].Name
+			})
+			sort.Slice(tt.wantItems, func(i, j int) bool {
+				return tt.wantItems[i].Name > tt.wantItems[j].Name
+			})
+			if !tt.wantErr && !reflect.DeepEqual(itemIDs(sorted), itemIDs(tt.wantItems)) {
+				t.Errorf("GetItemsUserHasBid() gotItems = \n%+v\n, wantItems \n%+v\n", sorted, tt.wantItems)
+			}
+		})
+	}
+}
+
+func testAsOf(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 2)
+	users := testutils.CreateTestUsers(h, 2)
+
+	//the clock must advance between the steps, so that every step is distinguishable
+	tick := func() time.Time {
+		time.Sleep(time.Millisecond)
+		now := time.Now()
+		time.Sleep(time.Millisecond)
+		return now
+	}
+	beforeBids := tick()
+	first := models.NewBid(items[0].ID, users[0].ID, 10.0)
+	assert.NoError(t, h.PlaceBid(first))
+	afterFirst := tick()
+	second := models.NewBid(items[0].ID, users[1].ID, 20.0)
+	assert.NoError(t, h.PlaceBid(second))
+	afterSecond := tick()
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 5.0)))
+	afterThird := tick()
+
+	tests := []struct {
+		name          string
+		asOf          time.Time
+		wantWinner    *models.Bid
+		wantNumBids   int
+		wantUserItems []*models.Item
+	}{
+		{"Before any bid", beforeBids, nil, 0, []*models.Item{}},
+		{"After first bid", afterFirst, first, 1, []*models.Item{items[0]}},
+		{"After second bid", afterSecond, second, 2, []*models.Item{items[0]}},
+		{"After bid on other item", afterThird, second, 2, []*models.Item{items[0], items[1]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			winner, err := h.GetWinningBidAsOf(items[0].ID, tt.asOf)
+			if tt.wantWinner == nil {
+				assert.Error(t, err, "There was no winner yet")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantWinner.ID, winner.ID)
+			}
+
+			bids, err := h.GetBidsOnItemAsOf(items[0].ID, tt.asOf)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantNumBids, len(bids))
+
+			userItems, err := h.GetItemsUserHasBidAsOf(users[0].ID, tt.asOf)
+			assert.NoError(t, err)
+			assert.Equal(t, itemIDs(tt.wantUserItems), itemIDs(userItems))
+		})
+	}
+
+	unknown := uuid.NewV4()
+	_, err := h.GetWinningBidAsOf(unknown, afterThird)
+	assert.Error(t, err)
+	_, err = h.GetBidsOnItemAsOf(unknown, afterThird)
+	assert.Error(t, err)
+	_, err = h.GetItemsUserHasBidAsOf(unknown, afterThird)
+	assert.Error(t, err)
+}
+
+func testCloseAuction(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 2)
+	users := testutils.CreateTestUsers(h, 2)
+	low := models.NewBid(items[0].ID, users[0].ID, 10.0)
+	high := models.NewBid(items[0].ID, users[1].ID, 20.0)
+	assert.NoError(t, h.PlaceBid(low))
+	assert.NoError(t, h.PlaceBid(high))
+
+	winner, err := h.CloseAuction(items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, high.ID, winner.ID, "Closing should return the winning bid")
+
+	assert.Error(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 30.0)), "Bidding on closed auction should fail")
+	bids, err := h.GetBidsOnItem(items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bids))
+	winner, err = h.GetWinningBid(items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, high.ID, winner.ID, "Winner must not change after closing")
+
+	_, err = h.CloseAuction(items[0].ID)
+	assert.Error(t, err, "Closing twice should fail")
+
+	winner, err = h.CloseAuction(items[1].ID)
+	assert.NoError(t, err, "Auction without bids can be closed")
+	assert.Nil(t, winner)
+
+	_, err = h.CloseAuction(uuid.NewV4())
+	assert.Error(t, err, "Closing unknown item should fail")
+}
+
+func testReservePrice(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	users := testutils.CreateTestUsers(h, 1)
+	items := []*models.Item{models.NewItem("Reserve met"), models.NewItem("Reserve not met")}
+	for _, item := range items {
+		item.ReservePrice = 50
+		assert.NoError(t, h.CreateItem(item))
+	}
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 50)))
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 49)))
+
+	winner, err := h.CloseAuction(items[0].ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, winner)
+	winner, err = h.CloseAuction(items[1].ID)
+	assert.NoError(t, err)
+	assert.Nil(t, winner, "Nothing is sold below the reserve price")
+	credit, err := h.GetCredit(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, credit.Exposure, "Bid below the reserve price should be released")
+}
+
+func testRelistItem(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	users := testutils.CreateTestUsers(h, 1)
+	original := models.NewItem("A painting")
+	original.SellerID = users[0].ID
+	original.ReservePrice = 100
+	assert.NoError(t, h.CreateItem(original))
+
+	_, err := h.RelistItem(original.ID, nil)
+	assert.Equal(t, models.ErrAuctionOpen, err, "Open auctions cannot be relisted")
+	_, err = h.CloseAuction(original.ID)
+	assert.NoError(t, err)
+
+	closesAt := time.Now().Add(time.Hour)
+	relisted, err := h.RelistItem(original.ID, &closesAt)
+	assert.NoError(t, err)
+	assert.NotEqual(t, original.ID, relisted.ID)
+	assert.Equal(t, original.ID, relisted.RelistedFrom)
+	assert.Equal(t, original.Name, relisted.Name)
+	assert.Equal(t, original.SellerID, relisted.SellerID)
+	assert.Equal(t, original.ReservePrice, relisted.ReservePrice)
+	assert.Equal(t, &closesAt, relisted.ClosesAt)
+	assert.False(t, relisted.IsClosed())
+	assert.NoError(t, h.PlaceBid(models.NewBid(relisted.ID, users[0].ID, 1)))
+
+	_, err = h.RelistItem(uuid.NewV4(), nil)
+	assert.Error(t, err)
+}
+
+func testWatchlist(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 2)
+	users := testutils.CreateTestUsers(h, 2)
+
+	assert.NoError(t, h.WatchItem(users[0].ID, items[1].ID))
+	assert.NoError(t, h.WatchItem(users[0].ID, items[0].ID))
+	assert.NoError(t, h.WatchItem(users[0].ID, items[0].ID), "Watching twice should have no effect")
+	assert.NoError(t, h.WatchItem(users[1].ID, items[0].ID))
+
+	watchlist, err := h.GetWatchlist(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{items[1].ID, items[0].ID}, itemIDs(watchlist), "Watchlist should keep the order of adding")
+	watchers, err := h.GetWatchers(items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(watchers))
+
+	assert.NoError(t, h.UnwatchItem(users[0].ID, items[0].ID))
+	assert.Error(t, h.UnwatchItem(users[0].ID, items[0].ID), "Unwatching an item not on the watchlist should fail")
+	watchlist, err = h.GetWatchlist(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{items[1].ID}, itemIDs(watchlist))
+	watchers, err = h.GetWatchers(items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(watchers))
+	assert.Equal(t, users[1].ID, watchers[0].ID)
+
+	assert.Error(t, h.WatchItem(users[0].ID, uuid.NewV4()), "Watching unknown item should fail")
+	assert.Error(t, h.WatchItem(uuid.NewV4(), items[0].ID), "Watching by unknown user should fail")
+	_, err = h.GetWatchlist(uuid.NewV4())
+	assert.Error(t, err)
+	_, err = h.GetWatchers(uuid.NewV4())
+	assert.Error(t, err)
+}
+
+func testSavedSearches(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	users := testutils.CreateTestUsers(h, 2)
+
+	pens := models.NewSavedSearch(users[0].ID, "Pens", "pen")
+	assert.NoError(t, h.SaveSearch(pens))
+	assert.NoError(t, h.SaveSearch(models.NewSavedSearch(users[1].ID, "", "red car")))
+	assert.Error(t, h.SaveSearch(models.NewSavedSearch(users[0].ID, "Empty", " ")), "Empty query should be rejected")
+	assert.Error(t, h.SaveSearch(models.NewSavedSearch(uuid.NewV4(), "", "pen")), "Search of unknown user should be rejected")
+
+	searches, err := h.GetSavedSearches(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(searches))
+	assert.Equal(t, pens.ID, searches[0].ID)
+	assert.Equal(t, "pen", searches[0].Query)
+	all, err := h.AllSavedSearches()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(all))
+
+	assert.Error(t, h.DeleteSearch(users[1].ID, pens.ID), "Users can delete only their own searches")
+	assert.NoError(t, h.DeleteSearch(users[0].ID, pens.ID))
+	assert.Error(t, h.DeleteSearch(users[0].ID, pens.ID), "Deleting twice should fail")
+	searches, err = h.GetSavedSearches(users[0].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, searches)
+	_, err = h.GetSavedSearches(uuid.NewV4())
+	assert.Error(t, err)
+}
+
+func testCreditLimit(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 3)
+	users := testutils.CreateTestUsers(h, 2)
+	assert.NoError(t, h.SetCredit(users[0].ID, models.Credit{Limited: true, CreditLimit: 80, Deposit: 20}))
+	assert.Error(t, h.SetCredit(users[0].ID, models.Credit{Limited: true, CreditLimit: -1}), "Negative credit should be rejected")
+	assert.Error(t, h.SetCredit(uuid.NewV4(), models.Credit{Limited: true}), "Credit of unknown user should be rejected")
+
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 60)))
+	assert.Equal(t, models.ErrCreditExceeded, h.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 50)))
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 90)), "Raising own winning bid replaces it")
+	credit, err := h.GetCredit(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 90.0, credit.Exposure)
+	assert.Equal(t, 10.0, credit.Available)
+
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 95)))
+	credit, err = h.GetCredit(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, credit.Exposure, "Exposure should be released when outbid")
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 40)), "Losing bids do not count")
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 100)))
+
+	_, err = h.CloseAuction(items[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ErrCreditExceeded, h.PlaceBid(models.NewBid(items[2].ID, users[0].ID, 1)), "Won items stay exposed")
+	assert.Error(t, h.ReleaseExposure(users[1].ID, items[0].ID), "Open auctions are released only by outbidding")
+	assert.NoError(t, h.ReleaseExposure(users[0].ID, items[1].ID))
+	assert.NoError(t, h.ReleaseExposure(users[0].ID, items[1].ID), "Releasing twice should have no effect")
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[2].ID, users[0].ID, 1)))
+
+	assert.Equal(t, models.ErrAuctionOpen, h.AddExposure(users[0].ID, items[2].ID, 10), "Open auctions are exposed only by bidding")
+	assert.NoError(t, h.AddExposure(users[0].ID, items[1].ID, 50))
+	credit, err = h.GetCredit(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 51.0, credit.Exposure, "Items bought after the auction count against the credit")
+	assert.Error(t, h.AddExposure(uuid.NewV4(), items[1].ID, 50))
+
+	assert.NoError(t, h.SetCredit(users[0].ID, models.Credit{}))
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[2].ID, users[0].ID, 1000)), "Users without limit may bid any amount")
+	_, err = h.GetCredit(uuid.NewV4())
+	assert.Error(t, err)
+}
+
+func testReset(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	testutils.CreateTestBids(h, 3, testutils.GenerateSliceOfRandomFloat64(3))
+
+	h.Reset()
+
+	items, err := h.AllItems()
+	assert.NoError(t, err)
+	assert.Empty(t, items, "Reset should remove all items")
+	users, err := h.AllUsers()
+	assert.NoError(t, err)
+	assert.Empty(t, users, "Reset should remove all users")
+	bids, err := h.AllBids()
+	assert.NoError(t, err)
+	assert.Empty(t, bids, "Reset should remove all bids")
+}
+
+func testSnapshots(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 2)
+	users := testutils.CreateTestUsers(h, 1)
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 1.0)))
+
+	bids, err := h.GetBidsOnItem(items[0].ID)
+	assert.NoError(t, err)
+	userItems, err := h.GetItemsUserHasBid(users[0].ID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 2.0)))
+	assert.NoError(t, h.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 2.0)))
+
+	assert.Equal(t, 1, len(bids), "Bids read earlier must not change")
+	assert.Equal(t, 1, len(userItems), "Items read earlier must not change")
+
+	//modifying results of a read must not affect the storage
+	_ = append(bids, models.NewBid(items[0].ID, users[0].ID, 3.0))
+	_ = append(userItems, items[0])
+	bids, err = h.GetBidsOnItem(items[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, bids[1].Amount)
+	userItems, err = h.GetItemsUserHasBid(users[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, itemIDs(items), itemIDs(userItems))
+
+	//the storage keeps its own copy of a placed bid
+	bid := models.NewBid(items[1].ID, users[0].ID, 5.0)
+	assert.NoError(t, h.PlaceBid(bid))
+	bid.Amount = 50.0
+	winning, err := h.GetWinningBid(items[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, bid.ID, winning.ID)
+	assert.Equal(t, 5.0, winning.Amount)
+	item, err := h.GetItem(items[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, item.GetMaxBidAmount())
+}
+
+func testUnknownIDs(t *testing.T, newStorage Factory) {
+	h := newStorage()
+	items := testutils.CreateTestItems(h, 1)
+	users := testutils.CreateTestUsers(h, 1)
+	unknown := uuid.NewV4()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GetItem", func() error { _, err := h.GetItem(unknown); return err }},
+		{"GetUser", func() error { _, err := h.GetUser(unknown); return err }},
+		{"GetUserBids", func() error { _, err := h.GetUserBids(unknown); return err }},
+		{"GetBidsOnItem", func() error { _, err := h.GetBidsOnItem(unknown); return err }},
+		{"GetItemsUserHasBid", func() error { _, err := h.GetItemsUserHasBid(unknown); return err }},
+		{"GetWinningBid", func() error { _, err := h.GetWinningBid(unknown); return err }},
+		{"GetWinningBid without bids", func() error { _, err := h.GetWinningBid(items[0].ID); return err }},
+		{"PlaceBid on unknown item", func() error { return h.PlaceBid(models.NewBid(unknown, users[0].ID, 1.0)) }},
+		{"PlaceBid by unknown user", func() error { return h.PlaceBid(models.NewBid(items[0].ID, unknown, 1.0)) }},
+		{"CreateItem of unknown seller", func() error {
+			item := models.NewItem("A thing")
+			item.SellerID = unknown
+			return h.CreateItem(item)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.call(), "Expected an error for unknown ID")
+		})
+	}
+
+	//Failed bids must not leave any traces
+	bids, err := h.GetBidsOnItem(items[0].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, bids)
+	userBids, err := h.GetUserBids(users[0].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, userBids)
+	userItems, err := h.GetItemsUserHasBid(users[0].ID)
+	assert.NoError(t, err)
+	assert.Empty(t, userItems)
+}
+
+//itemIDs maps items to their IDs - storages may return other instances than the ones passed on creation
+func itemIDs(items []*models.Item) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
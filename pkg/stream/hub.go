@@ -0,0 +1,327 @@
+// Package stream pushes live bidding activity to clients that are subscribed to items.
+// The Hub is a projection of the event journal: it is fed by the storage when an event is committed,
+// so every bid reaches the subscribers no matter which handler (or tool) placed it.
+package stream
+
+import (
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+//MessageType identifies the kind of a message sent to subscribers
+type MessageType string
+
+// define message types
+const (
+	MessageNewBid        MessageType = "new-bid"
+	MessageOutbid        MessageType = "outbid"
+	MessageAuctionClosed MessageType = "auction-closed"
+)
+
+//DefaultBufferSize is the number of messages buffered for a subscriber before it is considered too slow
+const DefaultBufferSize = 64
+
+//DefaultHistorySize is the number of recent messages kept for clients resuming a stream
+const DefaultHistorySize = 1024
+
+//Message describes a change on an item. ID increases by one with every message sent by the hub,
+//Seq is the position of the originating event in the journal - one event may result in several messages.
+//For MessageOutbid, Bid is the new winning bid and Outbid the bid it has beaten.
+//For MessageAuctionClosed, Bid is the winning bid - nil if nobody has bid.
+type Message struct {
+	ID     uint64      `json:"id"`
+	Seq    uint64      `json:"seq"`
+	Type   MessageType `json:"type"`
+	ItemID uuid.UUID   `json:"itemID"`
+	At     time.Time   `json:"at"`
+	Bid    *models.Bid `json:"bid,omitempty"`
+	Outbid *models.Bid `json:"outbid,omitempty"`
+}
+
+//topic is an item or a user messages can be subscribed to
+type topic struct {
+	user bool
+	id   uuid.UUID
+}
+
+//topics returns the item of the message and the users involved in it:
+//the bidder (or the winner of a closed auction) and the user that has been outbid
+func (m Message) topics() []topic {
+	topics := []topic{{id: m.ItemID}}
+	if m.Bid != nil {
+		topics = append(topics, topic{user: true, id: m.Bid.UserID})
+	}
+	if m.Outbid != nil && (m.Bid == nil || m.Outbid.UserID != m.Bid.UserID) {
+		topics = append(topics, topic{user: true, id: m.Outbid.UserID})
+	}
+	return topics
+}
+
+//MessagesFromRecord returns the messages subscribers should receive for a journal record (without IDs)
+func MessagesFromRecord(r events.Record) []Message {
+	switch e := r.Event.(type) {
+	case events.BidPlaced:
+		msgs := []Message{{Seq: r.Seq, Type: MessageNewBid, ItemID: e.Bid.ItemID, At: r.At, Bid: e.Bid}}
+		if e.Outbid != nil && e.Outbid.UserID != e.Bid.UserID {
+			msgs = append(msgs, Message{Seq: r.Seq, Type: MessageOutbid, ItemID: e.Bid.ItemID, At: r.At, Bid: e.Bid, Outbid: e.Outbid})
+		}
+		return msgs
+	case events.AuctionClosed:
+		return []Message{{Seq: r.Seq, Type: MessageAuctionClosed, ItemID: e.ItemID, At: r.At, Bid: e.WinningBid}}
+	}
+	return nil
+}
+
+//Hub fans out messages to subscriptions and keeps the most recent ones, so clients can resume where they left off.
+//Delivery never blocks the journal: a subscriber whose buffer is full is dropped and its channel is closed.
+type Hub struct {
+	bufferSize int
+
+	mutex   sync.RWMutex
+	lastID  uint64
+	history *ring
+	byTopic map[topic]map[*Subscription]struct{}
+	subs    map[*Subscription]struct{}
+}
+
+//NewHub creates a hub buffering up to bufferSize messages per subscriber and keeping the last historySize messages
+func NewHub(bufferSize, historySize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Hub{
+		bufferSize: bufferSize,
+		history:    newRing(historySize),
+		byTopic:    make(map[topic]map[*Subscription]struct{}),
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+//Apply implements events.Projection
+func (h *Hub) Apply(r events.Record) {
+	msgs := MessagesFromRecord(r)
+	if len(msgs) == 0 {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, msg := range msgs {
+		h.lastID++
+		msg.ID = h.lastID
+		h.history.push(msg)
+		h.deliver(msg)
+	}
+}
+
+//deliver sends msg to every subscription of its topics - mutex must be held
+func (h *Hub) deliver(msg Message) {
+	topics := msg.topics()
+	for i, t := range topics {
+		for s := range h.byTopic[t] {
+			if s.subscribedToAny(topics[:i]) {
+				// already delivered for a previous topic
+				continue
+			}
+			select {
+			case s.ch <- msg:
+			default:
+				h.remove(s)
+				s.dropped = true
+				close(s.ch)
+			}
+		}
+	}
+}
+
+//Subscribe creates a subscription to the given items
+func (h *Hub) Subscribe(itemIDs ...uuid.UUID) *Subscription {
+	s, _, _ := h.subscribe(itemTopics(itemIDs), 0, false)
+	return s
+}
+
+//SubscribeUsers creates a subscription to the activity of the given users
+func (h *Hub) SubscribeUsers(userIDs ...uuid.UUID) *Subscription {
+	s, _, _ := h.subscribe(userTopics(userIDs), 0, false)
+	return s
+}
+
+//ResumeItem subscribes to an item and returns the recent messages on it after lastID.
+//The bool is false if some of these messages are no longer kept.
+func (h *Hub) ResumeItem(itemID uuid.UUID, lastID uint64) (*Subscription, []Message, bool) {
+	return h.subscribe(itemTopics([]uuid.UUID{itemID}), lastID, true)
+}
+
+//ResumeUser subscribes to the activity of a user and returns the recent messages on it after lastID.
+//The bool is false if some of these messages are no longer kept.
+func (h *Hub) ResumeUser(userID uuid.UUID, lastID uint64) (*Subscription, []Message, bool) {
+	return h.subscribe(userTopics([]uuid.UUID{userID}), lastID, true)
+}
+
+//subscribe creates a subscription to topics. If resume is set, it also returns the messages on topics after lastID
+//and whether all of them are still in the history. No message is both returned and delivered on the subscription.
+func (h *Hub) subscribe(topics []topic, lastID uint64, resume bool) (*Subscription, []Message, bool) {
+	s := &Subscription{
+		hub:    h,
+		ch:     make(chan Message, h.bufferSize),
+		topics: make(map[topic]struct{}),
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.subs[s] = struct{}{}
+	h.add(s, topics)
+	if !resume {
+		return s, nil, true
+	}
+
+	var missed []Message
+	complete := h.history.since(lastID, func(msg Message) {
+		if s.subscribedToAny(msg.topics()) {
+			missed = append(missed, msg)
+		}
+	})
+	return s, missed, complete
+}
+
+//LastID returns the ID of the most recent message
+func (h *Hub) LastID() uint64 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.lastID
+}
+
+//Len returns the number of active subscriptions
+func (h *Hub) Len() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.subs)
+}
+
+func itemTopics(itemIDs []uuid.UUID) []topic {
+	topics := make([]topic, 0, len(itemIDs))
+	for _, id := range itemIDs {
+		topics = append(topics, topic{id: id})
+	}
+	return topics
+}
+
+func userTopics(userIDs []uuid.UUID) []topic {
+	topics := make([]topic, 0, len(userIDs))
+	for _, id := range userIDs {
+		topics = append(topics, topic{user: true, id: id})
+	}
+	return topics
+}
+
+//add registers s for topics - mutex must be held
+func (h *Hub) add(s *Subscription, topics []topic) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+		if h.byTopic[t] == nil {
+			h.byTopic[t] = make(map[*Subscription]struct{})
+		}
+		h.byTopic[t][s] = struct{}{}
+	}
+}
+
+//drop unregisters s from topics - mutex must be held
+func (h *Hub) drop(s *Subscription, topics []topic) {
+	for _, t := range topics {
+		delete(s.topics, t)
+		delete(h.byTopic[t], s)
+		if len(h.byTopic[t]) == 0 {
+			delete(h.byTopic, t)
+		}
+	}
+}
+
+//remove unregisters s completely and reports whether it was registered - mutex must be held
+func (h *Hub) remove(s *Subscription) bool {
+	if _, ok := h.subs[s]; !ok {
+		return false
+	}
+	delete(h.subs, s)
+	for t := range s.topics {
+		h.drop(s, []topic{t})
+	}
+	return true
+}
+
+//Subscription receives messages for a set of items and users on the channel returned by C
+type Subscription struct {
+	hub     *Hub
+	ch      chan Message
+	topics  map[topic]struct{} // guarded by hub.mutex
+	dropped bool               // guarded by hub.mutex
+}
+
+//C returns the channel messages are delivered on. It is closed when the subscription ends.
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+//Add subscribes to more items
+func (s *Subscription) Add(itemIDs ...uuid.UUID) {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	s.hub.add(s, itemTopics(itemIDs))
+}
+
+//Remove unsubscribes from the given items
+func (s *Subscription) Remove(itemIDs ...uuid.UUID) {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		s.hub.drop(s, itemTopics(itemIDs))
+	}
+}
+
+//Items returns the items the subscription is for
+func (s *Subscription) Items() []uuid.UUID {
+	s.hub.mutex.RLock()
+	defer s.hub.mutex.RUnlock()
+	items := make([]uuid.UUID, 0, len(s.topics))
+	for t := range s.topics {
+		if !t.user {
+			items = append(items, t.id)
+		}
+	}
+	return items
+}
+
+//subscribedToAny reports whether s is subscribed to one of topics - hub.mutex must be held
+func (s *Subscription) subscribedToAny(topics []topic) bool {
+	for _, t := range topics {
+		if _, ok := s.topics[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+//Dropped reports whether the hub has ended the subscription because messages were not consumed fast enough
+func (s *Subscription) Dropped() bool {
+	s.hub.mutex.RLock()
+	defer s.hub.mutex.RUnlock()
+	return s.dropped
+}
+
+//Close ends the subscription. It is safe to call Close more than once.
+func (s *Subscription) Close() {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	if s.hub.remove(s) {
+		close(s.ch)
+	}
+}
@@ -0,0 +1,225 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+)
+
+func setup(bufferSize int) (storage.Storage, *stream.Hub, []*models.User, []*models.Item) {
+	db := storage.NewMapBiddingSystem()
+	hub := stream.NewHub(bufferSize, 0)
+	db.Subscribe(hub)
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 2)
+	return db, hub, users, items
+}
+
+// receive returns the messages delivered so far
+func receive(sub *stream.Subscription) []stream.Message {
+	var msgs []stream.Message
+	for {
+		select {
+		case msg, ok := <-sub.C():
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func Test_Hub_NewBidAndOutbid(t *testing.T) {
+	db, hub, users, items := setup(0)
+	sub := hub.Subscribe(items[0].ID)
+	defer sub.Close()
+
+	first := models.NewBid(items[0].ID, users[0].ID, 10)
+	second := models.NewBid(items[0].ID, users[1].ID, 20)
+	db.PlaceBid(first)
+	db.PlaceBid(second)
+
+	msgs := receive(sub)
+	if assert.Len(t, msgs, 3) {
+		assert.Equal(t, stream.MessageNewBid, msgs[0].Type)
+		assert.Equal(t, first.ID, msgs[0].Bid.ID)
+		assert.Equal(t, stream.MessageNewBid, msgs[1].Type)
+		assert.Equal(t, stream.MessageOutbid, msgs[2].Type)
+		assert.Equal(t, second.ID, msgs[2].Bid.ID)
+		assert.Equal(t, first.ID, msgs[2].Outbid.ID)
+		assert.Equal(t, msgs[1].Seq, msgs[2].Seq, "Messages of one event should share its sequence number")
+	}
+}
+
+func Test_Hub_NoOutbidOnOwnBid(t *testing.T) {
+	db, hub, users, items := setup(0)
+	sub := hub.Subscribe(items[0].ID)
+	defer sub.Close()
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 20))
+
+	for _, msg := range receive(sub) {
+		assert.Equal(t, stream.MessageNewBid, msg.Type, "Raising own bid should not be reported as outbid")
+	}
+}
+
+func Test_Hub_AuctionClosed(t *testing.T) {
+	db, hub, users, items := setup(0)
+	bid := models.NewBid(items[0].ID, users[0].ID, 10)
+	db.PlaceBid(bid)
+	sub := hub.Subscribe(items[0].ID)
+	defer sub.Close()
+
+	db.CloseAuction(items[0].ID)
+
+	msgs := receive(sub)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, stream.MessageAuctionClosed, msgs[0].Type)
+		assert.Equal(t, bid.ID, msgs[0].Bid.ID)
+	}
+}
+
+func Test_Hub_Filtering(t *testing.T) {
+	db, hub, users, items := setup(0)
+	sub := hub.Subscribe(items[0].ID)
+	defer sub.Close()
+
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 10))
+	assert.Empty(t, receive(sub), "Bids on other items should not be delivered")
+
+	sub.Add(items[1].ID)
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 20))
+	assert.Len(t, receive(sub), 1)
+
+	sub.Remove(items[0].ID, items[1].ID)
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 30))
+	db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, 30))
+	assert.Empty(t, receive(sub))
+	assert.Empty(t, sub.Items())
+}
+
+func Test_Hub_DropsSlowConsumer(t *testing.T) {
+	db, hub, users, items := setup(2)
+	slow := hub.Subscribe(items[0].ID)
+	fast := hub.Subscribe(items[0].ID)
+	defer fast.Close()
+
+	for i := 1; i <= 3; i++ {
+		db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, float64(i)))
+		assert.Len(t, receive(fast), 1)
+	}
+
+	assert.Len(t, receive(slow), 2, "Buffered messages should still be delivered to a dropped subscriber")
+	_, open := <-slow.C()
+	assert.False(t, open)
+	assert.True(t, slow.Dropped())
+	assert.False(t, fast.Dropped())
+	assert.Equal(t, 1, hub.Len())
+
+	slow.Close()
+}
+
+func Test_Hub_Close(t *testing.T) {
+	db, hub, users, items := setup(0)
+	sub := hub.Subscribe(items[0].ID)
+	sub.Close()
+	sub.Close()
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	_, open := <-sub.C()
+	assert.False(t, open)
+	assert.False(t, sub.Dropped())
+	assert.Equal(t, 0, hub.Len())
+}
+
+func Test_Hub_SubscribeUsers(t *testing.T) {
+	db, hub, users, items := setup(0)
+	sub := hub.SubscribeUsers(users[0].ID)
+	defer sub.Close()
+
+	first := models.NewBid(items[0].ID, users[0].ID, 10)
+	db.PlaceBid(first)
+	db.PlaceBid(models.NewBid(items[1].ID, users[1].ID, 10))
+	db.PlaceBid(models.NewBid(items[0].ID, users[1].ID, 20))
+
+	msgs := receive(sub)
+	if assert.Len(t, msgs, 2, "User should see own bids and being outbid, but not the bids of others") {
+		assert.Equal(t, stream.MessageNewBid, msgs[0].Type)
+		assert.Equal(t, first.ID, msgs[0].Bid.ID)
+		assert.Equal(t, stream.MessageOutbid, msgs[1].Type)
+		assert.Equal(t, first.ID, msgs[1].Outbid.ID)
+	}
+}
+
+func Test_Hub_DeliversOnce(t *testing.T) {
+	db, hub, users, items := setup(0)
+	sub := hub.Subscribe(items[0].ID)
+	defer sub.Close()
+	sub.Add(items[0].ID)
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+	msgs := receive(sub)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, hub.LastID(), msgs[0].ID)
+	}
+}
+
+func Test_Hub_Resume(t *testing.T) {
+	db, hub, users, items := setup(0)
+	for i := 1; i <= 3; i++ {
+		db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, float64(i)))
+		db.PlaceBid(models.NewBid(items[1].ID, users[0].ID, float64(i)))
+	}
+	// IDs 1-6, odd ones on items[0]
+
+	sub, missed, complete := hub.ResumeItem(items[0].ID, 1)
+	defer sub.Close()
+	assert.True(t, complete)
+	if assert.Len(t, missed, 2) {
+		assert.Equal(t, uint64(3), missed[0].ID)
+		assert.Equal(t, uint64(5), missed[1].ID)
+	}
+
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 4))
+	msgs := receive(sub)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, uint64(7), msgs[0].ID, "Live messages should follow the missed ones")
+	}
+
+	userSub, missed, complete := hub.ResumeUser(users[0].ID, 5)
+	defer userSub.Close()
+	assert.True(t, complete)
+	assert.Len(t, missed, 2)
+}
+
+func Test_Hub_ResumeTooOld(t *testing.T) {
+	db := storage.NewMapBiddingSystem()
+	hub := stream.NewHub(0, 2)
+	db.Subscribe(hub)
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+	for i := 1; i <= 5; i++ {
+		db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, float64(i)))
+	}
+
+	sub, missed, complete := hub.ResumeItem(items[0].ID, 1)
+	defer sub.Close()
+	assert.False(t, complete, "Messages 2 and 3 are no longer kept")
+	if assert.Len(t, missed, 2) {
+		assert.Equal(t, uint64(4), missed[0].ID)
+		assert.Equal(t, uint64(5), missed[1].ID)
+	}
+
+	sub, missed, complete = hub.ResumeItem(items[0].ID, 5)
+	defer sub.Close()
+	assert.True(t, complete)
+	assert.Empty(t, missed)
+}
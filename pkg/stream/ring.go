@@ -0,0 +1,41 @@
+package stream
+
+//ring keeps the most recent messages. IDs of the messages pushed must be consecutive.
+type ring struct {
+	messages []Message
+	start    int
+	count    int
+}
+
+func newRing(size int) *ring {
+	return &ring{messages: make([]Message, size)}
+}
+
+//push adds msg, overwriting the oldest message when the ring is full
+func (r *ring) push(msg Message) {
+	if r.count < len(r.messages) {
+		r.messages[(r.start+r.count)%len(r.messages)] = msg
+		r.count++
+		return
+	}
+	r.messages[r.start] = msg
+	r.start = (r.start + 1) % len(r.messages)
+}
+
+//since calls fn for every message with an ID greater than lastID, oldest first.
+//It reports false if messages after lastID have already been overwritten.
+func (r *ring) since(lastID uint64, fn func(Message)) bool {
+	if r.count == 0 {
+		return true
+	}
+	oldest := r.messages[r.start].ID
+	complete := lastID+1 >= oldest
+	skip := 0
+	if complete {
+		skip = int(lastID + 1 - oldest)
+	}
+	for i := skip; i < r.count; i++ {
+		fn(r.messages[(r.start+i)%len(r.messages)])
+	}
+	return complete
+}
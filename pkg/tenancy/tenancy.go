@@ -0,0 +1,160 @@
+// Package tenancy lets one server run several independent auction houses (tenants). Every tenant has
+// its own storage namespace (see storage.Namespaces) and its own configuration: currency, fees and bidding rules.
+// The tenant of a request is resolved from the X-Tenant-ID header or from the hostname.
+package tenancy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+)
+
+// define tenant defaults
+const (
+	//HeaderTenant names the tenant of a request
+	HeaderTenant = "X-Tenant-ID"
+	//DefaultTenantID is the tenant of requests naming none - if it is configured
+	DefaultTenantID = "default"
+)
+
+// define errors
+var (
+	ErrUnknownTenant  = errors.New("Unknown tenant")
+	ErrNoTenant       = errors.New("No tenant - set the X-Tenant-ID header or use the hostname of a tenant")
+	ErrTenantMismatch = errors.New("X-Tenant-ID header does not match the tenant of the hostname")
+)
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+//Tenant is an auction house. Empty fees fall back to the configuration of the server.
+type Tenant struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name,omitempty"`
+	Hosts []string `json:"hosts,omitempty"`
+	//Currency of all amounts, an ISO 4217 code
+	Currency string `json:"currency,omitempty"`
+	//BuyersPremium and SellerCommission are fee schedules, see settlement.ParseSchedule
+	BuyersPremium    string       `json:"buyersPremium,omitempty"`
+	SellerCommission string       `json:"sellerCommission,omitempty"`
+	TaxRate          *float64     `json:"taxRate,omitempty"`
+	Rules            models.Rules `json:"rules"`
+}
+
+//Registry resolves the tenants of requests
+type Registry struct {
+	tenants []*Tenant
+	byID    map[string]*Tenant
+	byHost  map[string]*Tenant
+}
+
+//NewRegistry creates a registry of tenants with unique IDs and hosts
+func NewRegistry(tenants ...Tenant) (*Registry, error) {
+	if len(tenants) == 0 {
+		return nil, errors.New("No tenants configured")
+	}
+	r := &Registry{byID: map[string]*Tenant{}, byHost: map[string]*Tenant{}}
+	for i := range tenants {
+		t := tenants[i]
+		if !validID.MatchString(t.ID) {
+			return nil, fmt.Errorf("Invalid tenant ID %q - expected lower case letters, digits and dashes", t.ID)
+		}
+		if _, ok := r.byID[t.ID]; ok {
+			return nil, fmt.Errorf("Duplicate tenant ID %q", t.ID)
+		}
+		if err := t.Rules.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid rules of tenant %q: %v", t.ID, err)
+		}
+		t.Hosts = append([]string(nil), t.Hosts...)
+		for j, host := range t.Hosts {
+			host = strings.ToLower(host)
+			if other, ok := r.byHost[host]; ok {
+				return nil, fmt.Errorf("Host %q of tenant %q is used by tenant %q already", host, t.ID, other.ID)
+			}
+			t.Hosts[j] = host
+			r.byHost[host] = &t
+		}
+		r.byID[t.ID] = &t
+		r.tenants = append(r.tenants, &t)
+	}
+	return r, nil
+}
+
+//Load reads a JSON array of tenants from path
+func Load(path string) ([]Tenant, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("Malformed tenants file %s: %v", path, err)
+	}
+	return tenants, nil
+}
+
+//Tenants returns the tenants in the order they have been configured
+func (r *Registry) Tenants() []*Tenant {
+	return append([]*Tenant(nil), r.tenants...)
+}
+
+//Get returns the tenant with id
+func (r *Registry) Get(id string) (*Tenant, error) {
+	t, ok := r.byID[id]
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return t, nil
+}
+
+//Resolve returns the tenant named by the X-Tenant-ID header or by the hostname of the request.
+//If both name a tenant, it must be the same one - a tenant's hostname cannot be used to reach another tenant.
+//Requests naming no tenant belong to the tenant DefaultTenantID, if it is configured.
+func (r *Registry) Resolve(req *http.Request) (*Tenant, error) {
+	byHost := r.byHost[hostname(req.Host)]
+	id := strings.TrimSpace(req.Header.Get(HeaderTenant))
+	if id == "" {
+		if byHost != nil {
+			return byHost, nil
+		}
+		if t, ok := r.byID[DefaultTenantID]; ok {
+			return t, nil
+		}
+		return nil, ErrNoTenant
+	}
+	t, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if byHost != nil && byHost != t {
+		return nil, ErrTenantMismatch
+	}
+	return t, nil
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+type contextKey struct{}
+
+//NewContext returns a copy of ctx carrying the tenant
+func NewContext(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+//FromContext returns the tenant carried by ctx, nil if there is none
+func FromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(contextKey{}).(*Tenant)
+	return tenant
+}
@@ -0,0 +1,105 @@
+package tenancy_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/tenancy"
+)
+
+func Test_NewRegistry(t *testing.T) {
+	_, err := tenancy.NewRegistry()
+	assert.Error(t, err)
+	_, err = tenancy.NewRegistry(tenancy.Tenant{ID: "Acme"})
+	assert.Error(t, err, "IDs are lower case")
+	_, err = tenancy.NewRegistry(tenancy.Tenant{ID: "acme"}, tenancy.Tenant{ID: "acme"})
+	assert.Error(t, err, "IDs are unique")
+	_, err = tenancy.NewRegistry(tenancy.Tenant{ID: "acme", Hosts: []string{"a.example"}}, tenancy.Tenant{ID: "other", Hosts: []string{"A.example"}})
+	assert.Error(t, err, "Hosts are unique")
+	_, err = tenancy.NewRegistry(tenancy.Tenant{ID: "acme", Rules: models.Rules{MinIncrement: -1}})
+	assert.Error(t, err)
+
+	registry, err := tenancy.NewRegistry(tenancy.Tenant{ID: "acme"}, tenancy.Tenant{ID: "other"})
+	require.NoError(t, err)
+	tenants := registry.Tenants()
+	require.Len(t, tenants, 2)
+	assert.Equal(t, "acme", tenants[0].ID)
+	_, err = registry.Get("nope")
+	assert.Equal(t, tenancy.ErrUnknownTenant, err)
+}
+
+func Test_Resolve(t *testing.T) {
+	registry, err := tenancy.NewRegistry(
+		tenancy.Tenant{ID: "acme", Hosts: []string{"acme.example.com"}},
+		tenancy.Tenant{ID: "other", Hosts: []string{"other.example.com"}},
+	)
+	require.NoError(t, err)
+	resolve := func(host, header string) (string, error) {
+		r := httptest.NewRequest("GET", "/api/v1/item", nil)
+		r.Host = host
+		if header != "" {
+			r.Header.Set(tenancy.HeaderTenant, header)
+		}
+		tenant, err := registry.Resolve(r)
+		if err != nil {
+			return "", err
+		}
+		return tenant.ID, nil
+	}
+
+	id, err := resolve("ACME.example.com:9000", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", id)
+	id, err = resolve("localhost:9000", "other")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", id)
+	id, err = resolve("other.example.com", "other")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", id)
+
+	_, err = resolve("acme.example.com", "other")
+	assert.Equal(t, tenancy.ErrTenantMismatch, err, "The hostname of a tenant does not reach another tenant")
+	_, err = resolve("localhost", "nope")
+	assert.Equal(t, tenancy.ErrUnknownTenant, err)
+	_, err = resolve("localhost", "")
+	assert.Equal(t, tenancy.ErrNoTenant, err)
+
+	withDefault, err := tenancy.NewRegistry(tenancy.Tenant{ID: "acme", Hosts: []string{"acme.example.com"}}, tenancy.Tenant{ID: tenancy.DefaultTenantID})
+	require.NoError(t, err)
+	r := httptest.NewRequest("GET", "/api/v1/item", nil)
+	tenant, err := withDefault.Resolve(r)
+	require.NoError(t, err)
+	assert.Equal(t, tenancy.DefaultTenantID, tenant.ID)
+}
+
+func Test_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tenants.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[
+		{"id": "acme", "hosts": ["acme.example.com"], "currency": "EUR", "taxRate": 0.19, "rules": {"minIncrement": 1}},
+		{"id": "other", "buyersPremium": "0.25@1000,0.2"}
+	]`), 0600))
+
+	tenants, err := tenancy.Load(path)
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	assert.Equal(t, "EUR", tenants[0].Currency)
+	require.NotNil(t, tenants[0].TaxRate)
+	assert.Equal(t, 0.19, *tenants[0].TaxRate)
+	assert.Equal(t, 1.0, tenants[0].Rules.MinIncrement)
+	assert.Nil(t, tenants[1].TaxRate)
+	assert.Equal(t, "0.25@1000,0.2", tenants[1].BuyersPremium)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"id": "acme"}`), 0600))
+	_, err = tenancy.Load(path)
+	assert.Error(t, err)
+}
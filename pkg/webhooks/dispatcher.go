@@ -0,0 +1,318 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/events"
+	"github.com/vikin91/bid-tracker-go/pkg/logging"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+)
+
+// define errors
+var (
+	ErrQueueFull          = errors.New("Webhook delivery queue is full")
+	ErrDispatcherStopped  = errors.New("Webhook dispatcher has been stopped")
+	ErrDeadLetterNotFound = errors.New("Dead letter not found")
+)
+
+//Payload is the JSON body POSTed to subscribers. ID identifies the event and stays the same across retries.
+type Payload struct {
+	ID     uuid.UUID          `json:"id"`
+	Type   stream.MessageType `json:"type"`
+	Seq    uint64             `json:"seq"`
+	At     time.Time          `json:"at"`
+	ItemID uuid.UUID          `json:"itemID"`
+	Bid    *models.Bid        `json:"bid,omitempty"`
+	Outbid *models.Bid        `json:"outbid,omitempty"`
+}
+
+//Delivery is a payload on its way to one subscription
+type Delivery struct {
+	ID             uuid.UUID `json:"id"`
+	SubscriptionID uuid.UUID `json:"subscriptionID"`
+	URL            string    `json:"url"`
+	Payload        Payload   `json:"payload"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"lastError,omitempty"`
+	FailedAt       time.Time `json:"failedAt"`
+
+	secret string
+}
+
+//Config tunes the delivery of webhooks
+type Config struct {
+	Workers        int           // number of concurrent deliveries
+	QueueSize      int           // deliveries waiting for a worker before new ones are dead-lettered
+	MaxAttempts    int           // attempts before a delivery is dead-lettered
+	InitialBackoff time.Duration // wait before the first retry, doubled for each further retry
+	MaxBackoff     time.Duration // upper bound of the wait between retries
+	Timeout        time.Duration // timeout of one attempt
+	DeadLetterSize int           // dead letters kept, the oldest are discarded first
+	Client         *http.Client  // defaults to a client with Timeout
+}
+
+//DefaultConfig returns the configuration used for zero fields of Config
+func DefaultConfig() Config {
+	return Config{
+		Workers:        4,
+		QueueSize:      1024,
+		MaxAttempts:    8,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Minute,
+		Timeout:        10 * time.Second,
+		DeadLetterSize: 1000,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.Workers <= 0 {
+		c.Workers = d.Workers
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = d.QueueSize
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = d.MaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = d.InitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = d.MaxBackoff
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.DeadLetterSize <= 0 {
+		c.DeadLetterSize = d.DeadLetterSize
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: c.Timeout}
+	}
+	return c
+}
+
+//Backoff returns the wait before attempt number attempt+1, after attempt has failed
+func (c Config) Backoff(attempt int) time.Duration {
+	backoff := c.InitialBackoff
+	for i := 1; i < attempt && backoff < c.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.MaxBackoff {
+		backoff = c.MaxBackoff
+	}
+	return backoff
+}
+
+//Dispatcher turns journal events into deliveries and sends them with a pool of workers.
+//It is a projection of the journal: Apply only enqueues, so it never blocks writers.
+type Dispatcher struct {
+	registry *Registry
+	config   Config
+	queue    chan *Delivery
+	stop     chan struct{}
+	workers  sync.WaitGroup
+	retries  sync.WaitGroup
+
+	mutex       sync.Mutex
+	stopped     bool
+	deadLetters []*Delivery
+}
+
+//NewDispatcher creates a dispatcher for the subscriptions in registry - call Start to begin delivering
+func NewDispatcher(registry *Registry, config Config) *Dispatcher {
+	config = config.withDefaults()
+	return &Dispatcher{
+		registry: registry,
+		config:   config,
+		queue:    make(chan *Delivery, config.QueueSize),
+		stop:     make(chan struct{}),
+	}
+}
+
+//Start launches the workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.config.Workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+}
+
+//Stop stops the workers after their current attempt. Deliveries still waiting are dropped.
+func (d *Dispatcher) Stop() {
+	d.mutex.Lock()
+	if d.stopped {
+		d.mutex.Unlock()
+		return
+	}
+	d.stopped = true
+	close(d.stop)
+	d.mutex.Unlock()
+
+	d.workers.Wait()
+	d.retries.Wait()
+}
+
+//Apply implements events.Projection
+func (d *Dispatcher) Apply(r events.Record) {
+	msgs := stream.MessagesFromRecord(r)
+	if len(msgs) == 0 {
+		return
+	}
+	subs := d.registry.All()
+	for _, msg := range msgs {
+		payload := Payload{
+			ID:     uuid.NewV4(),
+			Type:   msg.Type,
+			Seq:    msg.Seq,
+			At:     msg.At,
+			ItemID: msg.ItemID,
+			Bid:    msg.Bid,
+			Outbid: msg.Outbid,
+		}
+		for _, sub := range subs {
+			if sub.Wants(msg.Type) {
+				d.enqueue(&Delivery{ID: uuid.NewV4(), SubscriptionID: sub.ID, URL: sub.URL, Payload: payload, secret: sub.Secret})
+			}
+		}
+	}
+}
+
+//enqueue hands a delivery to the workers without blocking - it is dead-lettered if the queue is full
+func (d *Dispatcher) enqueue(delivery *Delivery) {
+	select {
+	case d.queue <- delivery:
+	default:
+		delivery.LastError = ErrQueueFull.Error()
+		d.deadLetter(delivery)
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.attempt(delivery)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+//attempt sends a delivery once and schedules a retry or dead-letters it on failure
+func (d *Dispatcher) attempt(delivery *Delivery) {
+	if _, err := d.registry.Get(delivery.SubscriptionID); err != nil {
+		// the subscription has been removed in the meantime
+		return
+	}
+	delivery.Attempts++
+	err := d.send(delivery)
+	if err == nil {
+		return
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.config.MaxAttempts {
+		logging.LogError(fmt.Sprintf("Webhook delivery %s failed %d times", delivery.ID, delivery.Attempts), err)
+		d.deadLetter(delivery)
+		return
+	}
+
+	d.retries.Add(1)
+	time.AfterFunc(d.config.Backoff(delivery.Attempts), func() {
+		defer d.retries.Done()
+		select {
+		case d.queue <- delivery:
+		case <-d.stop:
+		}
+	})
+}
+
+func (d *Dispatcher) send(delivery *Delivery) error {
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(delivery.Payload.Type))
+	req.Header.Set(HeaderDelivery, delivery.Payload.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.secret, timestamp, body))
+
+	resp, err := d.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Webhook receiver responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (d *Dispatcher) deadLetter(delivery *Delivery) {
+	delivery.FailedAt = time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.deadLetters = append(d.deadLetters, delivery)
+	if over := len(d.deadLetters) - d.config.DeadLetterSize; over > 0 {
+		d.deadLetters = append(d.deadLetters[:0:0], d.deadLetters[over:]...)
+	}
+}
+
+//DeadLetters returns copies of the deliveries that have failed, oldest first
+func (d *Dispatcher) DeadLetters() []Delivery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	letters := make([]Delivery, 0, len(d.deadLetters))
+	for _, delivery := range d.deadLetters {
+		letters = append(letters, *delivery)
+	}
+	return letters
+}
+
+//Redeliver removes a dead letter from the list and queues it again with a fresh count of attempts
+func (d *Dispatcher) Redeliver(id uuid.UUID) error {
+	d.mutex.Lock()
+	if d.stopped {
+		d.mutex.Unlock()
+		return ErrDispatcherStopped
+	}
+	var delivery *Delivery
+	for idx, letter := range d.deadLetters {
+		if letter.ID == id {
+			delivery = letter
+			d.deadLetters = append(d.deadLetters[:idx:idx], d.deadLetters[idx+1:]...)
+			break
+		}
+	}
+	d.mutex.Unlock()
+
+	if delivery == nil {
+		return ErrDeadLetterNotFound
+	}
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	delivery.FailedAt = time.Time{}
+	d.enqueue(delivery)
+	return nil
+}
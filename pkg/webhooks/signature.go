@@ -0,0 +1,46 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// define headers sent with every delivery
+const (
+	HeaderEvent     = "X-Bid-Tracker-Event"
+	HeaderDelivery  = "X-Bid-Tracker-Delivery"
+	HeaderTimestamp = "X-Bid-Tracker-Timestamp"
+	HeaderSignature = "X-Bid-Tracker-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+//Sign returns the value of the signature header for a body sent at timestamp (unix seconds):
+//the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+//Verify checks the signature and timestamp headers of a delivery - for receivers.
+//Deliveries older than tolerance are rejected to prevent replays; a zero tolerance disables the check.
+func Verify(secret string, timestampHeader string, signatureHeader string, body []byte, tolerance time.Duration) bool {
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil || !strings.HasPrefix(signatureHeader, signaturePrefix) {
+		return false
+	}
+	if tolerance > 0 {
+		age := time.Since(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return false
+		}
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signatureHeader))
+}
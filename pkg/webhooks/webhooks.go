@@ -0,0 +1,151 @@
+// Package webhooks pushes auction events to downstream systems over HTTP.
+// Subscriptions choose the event types they receive; deliveries are signed with the secret of the subscription,
+// retried with exponential backoff and put on a dead-letter list when all attempts fail.
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+)
+
+// define errors
+var (
+	ErrInvalidURL       = errors.New("Webhook URL must be an absolute http(s) URL")
+	ErrUnknownEventType = errors.New("Unknown webhook event type")
+	ErrNotFound         = errors.New("Webhook not found")
+)
+
+//EventTypes are the types of events webhooks can subscribe to
+var EventTypes = []stream.MessageType{stream.MessageNewBid, stream.MessageOutbid, stream.MessageAuctionClosed}
+
+//Subscription asks for events of the given types (all types if empty) to be POSTed to URL.
+//Secret is used to sign the deliveries and is only shown when the subscription is created.
+type Subscription struct {
+	ID        uuid.UUID            `json:"id"`
+	URL       string               `json:"url"`
+	Events    []stream.MessageType `json:"events,omitempty"`
+	Secret    string               `json:"secret,omitempty"`
+	CreatedAt time.Time            `json:"createdAt"`
+}
+
+//Wants reports whether the subscription receives events of type t
+func (s *Subscription) Wants(t stream.MessageType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+//Redacted returns a copy of the subscription without the secret
+func (s Subscription) Redacted() Subscription {
+	s.Secret = ""
+	return s
+}
+
+//Validate checks the URL and the event types of the subscription
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	for _, e := range s.Events {
+		known := false
+		for _, t := range EventTypes {
+			known = known || e == t
+		}
+		if !known {
+			return ErrUnknownEventType
+		}
+	}
+	return nil
+}
+
+//Registry keeps the webhook subscriptions
+type Registry struct {
+	mutex sync.RWMutex
+	subs  map[uuid.UUID]Subscription
+}
+
+//NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{subs: make(map[uuid.UUID]Subscription)}
+}
+
+//Add validates and stores a new subscription, assigning its ID, creation time and - if not given - a random secret
+func (r *Registry) Add(sub Subscription) (Subscription, error) {
+	if err := sub.Validate(); err != nil {
+		return Subscription{}, err
+	}
+	if sub.Secret == "" {
+		secret, err := NewSecret()
+		if err != nil {
+			return Subscription{}, err
+		}
+		sub.Secret = secret
+	}
+	sub.ID = uuid.NewV4()
+	sub.CreatedAt = time.Now()
+	sub.Events = append([]stream.MessageType(nil), sub.Events...)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.subs[sub.ID] = sub
+	return sub, nil
+}
+
+//Get returns the subscription with the given ID
+func (r *Registry) Get(id uuid.UUID) (Subscription, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	sub, ok := r.subs[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return sub, nil
+}
+
+//Remove deletes the subscription with the given ID
+func (r *Registry) Remove(id uuid.UUID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.subs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.subs, id)
+	return nil
+}
+
+//All returns all subscriptions, oldest first
+func (r *Registry) All() []Subscription {
+	r.mutex.RLock()
+	subs := make([]Subscription, 0, len(r.subs))
+	for _, sub := range r.subs {
+		subs = append(subs, sub)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+	return subs
+}
+
+//NewSecret generates a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
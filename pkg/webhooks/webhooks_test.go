@@ -0,0 +1,212 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikin91/bid-tracker-go/internal/testutils"
+	"github.com/vikin91/bid-tracker-go/pkg/models"
+	"github.com/vikin91/bid-tracker-go/pkg/storage"
+	"github.com/vikin91/bid-tracker-go/pkg/stream"
+	"github.com/vikin91/bid-tracker-go/pkg/webhooks"
+)
+
+// receiver is a webhook endpoint failing the first failures requests
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mutex    sync.Mutex
+	failures int
+	requests int
+	payloads []webhooks.Payload
+	verified []bool
+	received chan struct{}
+}
+
+func newReceiver(secret string, failures int) *receiver {
+	rcv := &receiver{secret: secret, failures: failures, received: make(chan struct{}, 100)}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		rcv.mutex.Lock()
+		defer rcv.mutex.Unlock()
+		rcv.requests++
+		if rcv.requests <= rcv.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		payload := webhooks.Payload{}
+		json.Unmarshal(body, &payload)
+		rcv.payloads = append(rcv.payloads, payload)
+		rcv.verified = append(rcv.verified, webhooks.Verify(rcv.secret,
+			r.Header.Get(webhooks.HeaderTimestamp), r.Header.Get(webhooks.HeaderSignature), body, time.Minute))
+		rcv.received <- struct{}{}
+	}))
+	return rcv
+}
+
+func (rcv *receiver) wait(t *testing.T, n int) []webhooks.Payload {
+	for i := 0; i < n; i++ {
+		select {
+		case <-rcv.received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Webhook not received")
+		}
+	}
+	rcv.mutex.Lock()
+	defer rcv.mutex.Unlock()
+	for _, verified := range rcv.verified {
+		assert.True(t, verified, "Signature should be valid")
+	}
+	return append([]webhooks.Payload(nil), rcv.payloads...)
+}
+
+func testConfig() webhooks.Config {
+	return webhooks.Config{
+		Workers:        2,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Timeout:        time.Second,
+	}
+}
+
+func setup(t *testing.T, config webhooks.Config) (storage.Storage, *webhooks.Registry, *webhooks.Dispatcher) {
+	db := storage.NewMapBiddingSystem()
+	registry := webhooks.NewRegistry()
+	dispatcher := webhooks.NewDispatcher(registry, config)
+	dispatcher.Start()
+	db.Subscribe(dispatcher)
+	return db, registry, dispatcher
+}
+
+func Test_Dispatcher_Deliver(t *testing.T) {
+	db, registry, dispatcher := setup(t, testConfig())
+	defer dispatcher.Stop()
+	rcv := newReceiver("s3cret", 0)
+	defer rcv.Close()
+	_, err := registry.Add(webhooks.Subscription{URL: rcv.URL, Secret: "s3cret",
+		Events: []stream.MessageType{stream.MessageOutbid, stream.MessageAuctionClosed}})
+	require.NoError(t, err)
+
+	users := testutils.CreateTestUsers(db, 2)
+	items := testutils.CreateTestItems(db, 1)
+	first := models.NewBid(items[0].ID, users[0].ID, 10)
+	second := models.NewBid(items[0].ID, users[1].ID, 20)
+	db.PlaceBid(first)
+	db.PlaceBid(second)
+
+	payloads := rcv.wait(t, 1)
+	assert.Equal(t, stream.MessageOutbid, payloads[0].Type)
+	assert.Equal(t, second.ID, payloads[0].Bid.ID)
+	assert.Equal(t, first.ID, payloads[0].Outbid.ID)
+
+	db.CloseAuction(items[0].ID)
+	payloads = rcv.wait(t, 1)
+	assert.Equal(t, stream.MessageAuctionClosed, payloads[1].Type)
+	assert.Empty(t, dispatcher.DeadLetters())
+}
+
+func Test_Dispatcher_Retry(t *testing.T) {
+	db, registry, dispatcher := setup(t, testConfig())
+	defer dispatcher.Stop()
+	rcv := newReceiver("s3cret", 2)
+	defer rcv.Close()
+	registry.Add(webhooks.Subscription{URL: rcv.URL, Secret: "s3cret"})
+
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+
+	payloads := rcv.wait(t, 1)
+	assert.Equal(t, stream.MessageNewBid, payloads[0].Type)
+	rcv.mutex.Lock()
+	assert.Equal(t, 3, rcv.requests, "Delivery should succeed on the third attempt")
+	rcv.mutex.Unlock()
+	assert.Empty(t, dispatcher.DeadLetters())
+}
+
+func Test_Dispatcher_DeadLetter(t *testing.T) {
+	db, registry, dispatcher := setup(t, testConfig())
+	defer dispatcher.Stop()
+	rcv := newReceiver("s3cret", 3)
+	defer rcv.Close()
+	sub, _ := registry.Add(webhooks.Subscription{URL: rcv.URL, Secret: "s3cret"})
+
+	users := testutils.CreateTestUsers(db, 1)
+	items := testutils.CreateTestItems(db, 1)
+	db.PlaceBid(models.NewBid(items[0].ID, users[0].ID, 10))
+
+	var letters []webhooks.Delivery
+	for i := 0; len(letters) == 0; i++ {
+		require.True(t, i < 500, "Delivery should be dead-lettered")
+		time.Sleep(10 * time.Millisecond)
+		letters = dispatcher.DeadLetters()
+	}
+	assert.Equal(t, sub.ID, letters[0].SubscriptionID)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.NotEmpty(t, letters[0].LastError)
+
+	require.NoError(t, dispatcher.Redeliver(letters[0].ID))
+	payloads := rcv.wait(t, 1)
+	assert.Equal(t, letters[0].Payload.ID, payloads[0].ID, "Redelivery should send the same event")
+	assert.Empty(t, dispatcher.DeadLetters())
+	assert.Equal(t, webhooks.ErrDeadLetterNotFound, dispatcher.Redeliver(letters[0].ID))
+}
+
+func Test_Config_Backoff(t *testing.T) {
+	config := webhooks.Config{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	assert.Equal(t, time.Second, config.Backoff(1))
+	assert.Equal(t, 2*time.Second, config.Backoff(2))
+	assert.Equal(t, 8*time.Second, config.Backoff(4))
+	assert.Equal(t, 10*time.Second, config.Backoff(5))
+	assert.Equal(t, 10*time.Second, config.Backoff(50))
+}
+
+func Test_Registry(t *testing.T) {
+	registry := webhooks.NewRegistry()
+
+	_, err := registry.Add(webhooks.Subscription{URL: "ftp://example.com"})
+	assert.Equal(t, webhooks.ErrInvalidURL, err)
+	_, err = registry.Add(webhooks.Subscription{URL: "/relative"})
+	assert.Equal(t, webhooks.ErrInvalidURL, err)
+	_, err = registry.Add(webhooks.Subscription{URL: "http://example.com", Events: []stream.MessageType{"bid"}})
+	assert.Equal(t, webhooks.ErrUnknownEventType, err)
+
+	sub, err := registry.Add(webhooks.Subscription{URL: "https://example.com/hook"})
+	require.NoError(t, err)
+	assert.Len(t, sub.Secret, 64, "A random secret should be generated")
+	assert.Empty(t, sub.Redacted().Secret)
+
+	got, err := registry.Get(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sub, got)
+	assert.Len(t, registry.All(), 1)
+
+	assert.NoError(t, registry.Remove(sub.ID))
+	assert.Equal(t, webhooks.ErrNotFound, registry.Remove(sub.ID))
+	_, err = registry.Get(sub.ID)
+	assert.Equal(t, webhooks.ErrNotFound, err)
+}
+
+func Test_Verify(t *testing.T) {
+	body := []byte(`{"type":"new-bid"}`)
+	now := time.Now().Unix()
+	signature := webhooks.Sign("secret", now, body)
+
+	assert.False(t, webhooks.Verify("secret", "", "", body, 0))
+	assert.True(t, webhooks.Verify("secret", strconv.FormatInt(now, 10), signature, body, time.Minute))
+	assert.False(t, webhooks.Verify("other", strconv.FormatInt(now, 10), signature, body, time.Minute))
+	assert.False(t, webhooks.Verify("secret", strconv.FormatInt(now, 10), signature, []byte(`{}`), time.Minute))
+	assert.False(t, webhooks.Verify("secret", strconv.FormatInt(now-3600, 10), webhooks.Sign("secret", now-3600, body), body, time.Minute),
+		"Old deliveries should be rejected")
+}